@@ -20,16 +20,27 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
+	"io/ioutil"
 	"log"
 	nethttp "net/http"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
 	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
 	"github.com/bitmaelum/key-resolver-go/internal"
+	"github.com/bitmaelum/key-resolver-go/internal/adaptive"
+	"github.com/bitmaelum/key-resolver-go/internal/difficulty"
 	"github.com/bitmaelum/key-resolver-go/internal/handler"
 	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/keypolicy"
+	"github.com/bitmaelum/key-resolver-go/internal/lockout"
+	"github.com/bitmaelum/key-resolver-go/internal/proof"
+	"github.com/bitmaelum/key-resolver-go/internal/ratelimit"
 	"github.com/gorilla/mux"
 )
 
@@ -41,33 +52,42 @@ func requestWrapper(f func(hash.Hash, http.Request) *http.Response) func(nethttp
 		var resp *http.Response
 
 		defer func() {
-			if resp == nil {
-				return
-			}
-
-			// Write response to output
-			w.WriteHeader(resp.StatusCode)
-			for k, v := range resp.Headers.Headers {
-				w.Header().Set(k, v)
-			}
-			_, _ = w.Write([]byte(resp.Body))
+			writeResponse(w, resp)
 		}()
 
 		// Convert standard net/http request to our internal request structure
 		httpReq := http.NetReqToReq(*req)
 
-		// Fetch hash from mux variables
-		h, err := hash.NewFromHash(mux.Vars(req)["hash"])
-		if err != nil {
-			resp = http.CreateError("invalid hash", 400)
-			return
+		// Fetch hash from mux variables, when the route has one
+		var h hash.Hash
+		if v, ok := mux.Vars(req)["hash"]; ok {
+			parsed, err := hash.NewFromHash(v)
+			if err != nil {
+				resp = http.CreateError("invalid hash", 400)
+				return
+			}
+			h = *parsed
 		}
 
 		// Call our wrapped function
-		resp = f(*h, httpReq)
+		resp = handler.RateLimited(f)(h, httpReq)
 	}
 }
 
+// writeResponse writes our internal response to the net/http response writer
+func writeResponse(w nethttp.ResponseWriter, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+
+	// Headers must be set before writing the status code, or they are ignored
+	for k, v := range resp.Headers.Headers {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write([]byte(resp.Body))
+}
+
 func getLogo(_ hash.Hash, _ http.Request) *http.Response {
 	resp := http.NewResponse(200, internal.Logo)
 	resp.Headers.Set("content-type", "text/plain")
@@ -77,10 +97,24 @@ func getLogo(_ hash.Hash, _ http.Request) *http.Response {
 
 func getConfig(_ hash.Hash, _ http.Request) *http.Response {
 	data := http.RawJSONOut{
-		"proof_of_work": http.RawJSONOut{
-			"address":      handler.MinimumProofBitsAddress,
-			"organisation": handler.MinimumProofBitsOrganisation,
-		},
+		"proof_of_work": handler.ProofOfWorkConfig(),
+		"key_policy":    handler.KeyPolicy,
+	}
+
+	// Publish the key that signs our responses, so clients can verify them
+	pubKey, err := handler.SigningPublicKey()
+	if err != nil {
+		log.Print(err)
+	}
+	if pubKey != nil {
+		data["signing_key"] = pubKey.String()
+	}
+
+	// Proofs of work must be computed over a challenge from /challenge/{hash} when challenges are enabled
+	if len(handler.ChallengeSecret) > 0 {
+		data["challenge"] = http.RawJSONOut{
+			"expiry": int64(handler.ChallengeExpiry.Seconds()),
+		}
 	}
 
 	strJson, _ := json.MarshalIndent(data, "", "  ")
@@ -91,6 +125,13 @@ func getConfig(_ hash.Hash, _ http.Request) *http.Response {
 	return &resp
 }
 
+func getMetrics(_ hash.Hash, _ http.Request) *http.Response {
+	resp := http.NewResponse(200, handler.AdaptiveDifficultyMetrics())
+	resp.Headers.Set("content-type", "text/plain")
+
+	return &resp
+}
+
 func main() {
 	boltDbPath := flag.String("db", "./bolt.db", "Bolt DB path")
 	TcpPort := flag.String("port", "443", "HTTP(s) port to run")
@@ -99,34 +140,231 @@ func main() {
 	KeyPemFile := flag.String("key", "./resolver.key.pem", "Key file in PEM format")
 
 	workBits := flag.Int("bits", 20, "Bits for accounts and organisations")
+	organisationWorkBits := flag.Int("organisation-bits", 0, "Bits for organisations (defaults to -bits)")
+	routingWorkBits := flag.Int("routing-bits", 20, "Bits for routing")
+	signingKeyFile := flag.String("signing-key", "", "File with the private key used for signing")
+	adminKeyFile := flag.String("admin-key", "", "File with the public key that is allowed to query the fingerprint index")
+	versionRetention := flag.Duration("version-retention", 0, "Time to keep superseded record versions (0 keeps them forever)")
+	keyTypes := flag.String("key-types", "rsa,ecdsa,ed25519", "Comma separated list of allowed key types")
+	minRSABits := flag.Int("min-rsa-bits", handler.KeyPolicy.MinRSABits, "Minimum size of RSA keys in bits")
+	maxRecordsPerKey := flag.Int("max-records-per-key", 0, "Maximum number of records that can use the same key (0 for no limit)")
+	keyDenylistFile := flag.String("key-denylist", "", "File with fingerprints of keys that are not allowed")
+	difficultyScheduleFile := flag.String("difficulty-schedule", "", "JSON file with the proof-of-work difficulty schedule")
+	adaptiveWindow := flag.Duration("adaptive-window", 0, "Window over which creations are counted for adaptive proof-of-work difficulty (0 disables)")
+	adaptiveThreshold := flag.Int("adaptive-threshold", 100, "Creations within the window above which the difficulty is raised")
+	adaptiveMaxBits := flag.Int("adaptive-max-bits", 4, "Maximum number of bits added by the adaptive difficulty")
+	adaptiveInterval := flag.Duration("adaptive-interval", 5*time.Minute, "Minimum time between adaptive difficulty changes")
+	argon2Memory := flag.Uint("argon2-memory", 0, "Memory in KiB for argon2id proofs of work (0 disables argon2id)")
+	argon2Time := flag.Uint("argon2-time", 1, "Number of passes for argon2id proofs of work")
+	argon2Threads := flag.Uint("argon2-threads", 4, "Number of threads for argon2id proofs of work")
+	argon2BitReduction := flag.Int("argon2-bit-reduction", handler.Argon2BitReduction, "Bits an argon2id proof of work needs less than a sha256 proof of work")
+	challengeSecretFile := flag.String("challenge-secret", "", "File with the secret used for proof-of-work challenges")
+	challengeExpiry := flag.Duration("challenge-expiry", handler.ChallengeExpiry, "Time a proof-of-work challenge stays valid")
+	rateLimitClientRead := flag.String("rate-limit-client-read", "", "Reads per client, like 600/1m (empty disables)")
+	rateLimitClientWrite := flag.String("rate-limit-client-write", "", "Writes per client, like 60/1m (empty disables)")
+	rateLimitClientAuth := flag.String("rate-limit-client-auth", "", "Failed authentications per client, like 10/1h (empty disables)")
+	rateLimitHashRead := flag.String("rate-limit-hash-read", "", "Reads per hash, like 600/1m (empty disables)")
+	rateLimitHashWrite := flag.String("rate-limit-hash-write", "", "Writes per hash, like 10/1m (empty disables)")
+	rateLimitHashAuth := flag.String("rate-limit-hash-auth", "", "Failed authentications per hash, like 10/1h (empty disables)")
+	lockoutThreshold := flag.Int("lockout-threshold", 0, "Failed authentications after which a client or hash is locked out (0 disables)")
+	lockoutWindow := flag.Duration("lockout-window", 15*time.Minute, "Quiet period after which failed authentications are forgotten")
+	lockoutDuration := flag.Duration("lockout-duration", time.Minute, "Duration of the first lockout, doubled for every next lockout")
+	lockoutMaxDuration := flag.Duration("lockout-max-duration", 24*time.Hour, "Maximum duration of a lockout")
+	redirectDeletePolicy := flag.String("redirect-delete-policy", handler.RedirectDeleteReport, "Deleting a redirect target: report or refuse")
 	flag.Parse()
 
+	if *signingKeyFile != "" {
+		data, err := ioutil.ReadFile(*signingKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		handler.SigningKey, err = bmcrypto.NewPrivKey(strings.TrimSpace(string(data)))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *adminKeyFile != "" {
+		data, err := ioutil.ReadFile(*adminKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		handler.AdminKey, err = bmcrypto.NewPubKey(strings.TrimSpace(string(data)))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *keyTypes != "" {
+		types, err := keypolicy.ParseTypes(*keyTypes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.KeyPolicy.AllowedTypes = types
+	}
+	handler.KeyPolicy.MinRSABits = *minRSABits
+	handler.KeyPolicy.MaxRecordsPerKey = *maxRecordsPerKey
+
+	if *keyDenylistFile != "" {
+		data, err := ioutil.ReadFile(*keyDenylistFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		handler.KeyPolicy.Denylist = keypolicy.ParseDenylist(string(data))
+	}
+
+	if *difficultyScheduleFile != "" {
+		schedule, err := difficulty.LoadFile(*difficultyScheduleFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.DifficultySchedule = schedule
+	}
+
+	if *adaptiveWindow > 0 {
+		cfg := adaptive.Config{
+			Window:       *adaptiveWindow,
+			Threshold:    *adaptiveThreshold,
+			MaxExtraBits: *adaptiveMaxBits,
+			Interval:     *adaptiveInterval,
+		}
+		if err := cfg.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		handler.AdaptiveDifficulty = adaptive.New(cfg, time.Now)
+	}
+
+	if *argon2Memory > 0 {
+		params := proof.Argon2Params{
+			Time:    uint32(*argon2Time),
+			Memory:  uint32(*argon2Memory),
+			Threads: uint8(*argon2Threads),
+		}
+		if err := params.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		handler.Argon2Params = &params
+	}
+	handler.Argon2BitReduction = *argon2BitReduction
+
+	if *challengeSecretFile != "" {
+		data, err := ioutil.ReadFile(*challengeSecretFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		handler.ChallengeSecret = []byte(strings.TrimSpace(string(data)))
+	}
+	handler.ChallengeExpiry = *challengeExpiry
+
+	clientBudgets, err := ratelimit.ParseBudgets(*rateLimitClientRead, *rateLimitClientWrite, *rateLimitClientAuth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hashBudgets, err := ratelimit.ParseBudgets(*rateLimitHashRead, *rateLimitHashWrite, *rateLimitHashAuth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rateLimits := ratelimit.Config{Client: clientBudgets, Hash: hashBudgets}
+	if rateLimits.Enabled() {
+		// A single server keeps its buckets in memory
+		ratelimit.SetDefaultRepository(ratelimit.NewMemoryRepository())
+		handler.RateLimits = &rateLimits
+	}
+
+	if *lockoutThreshold > 0 {
+		policy := lockout.Policy{
+			Threshold:   *lockoutThreshold,
+			Window:      *lockoutWindow,
+			Duration:    *lockoutDuration,
+			MaxDuration: *lockoutMaxDuration,
+		}
+		if err := policy.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		handler.LockoutPolicy = &policy
+	}
+
+	if !handler.IsValidRedirectDeletePolicy(*redirectDeletePolicy) {
+		log.Fatal("invalid redirect delete policy: " + *redirectDeletePolicy)
+	}
+	handler.RedirectDeletePolicy = *redirectDeletePolicy
+	handler.VersionRetention = *versionRetention
+
 	// Set the current bits
 	handler.MinimumProofBitsOrganisation = *workBits
+	if *organisationWorkBits > 0 {
+		handler.MinimumProofBitsOrganisation = *organisationWorkBits
+	}
 	handler.MinimumProofBitsAddress = *workBits
+	handler.MinimumProofBitsRouting = *routingWorkBits
 
 	// Make sure we use BOLTDB
 	_ = os.Setenv("USE_BOLT", "1")
 	_ = os.Setenv("BOLT_DB_FILE", *boltDbPath)
 
+	handler.StartWebhooks(context.Background())
+
 	router := mux.NewRouter().StrictSlash(true)
 	router.HandleFunc("/", requestWrapper(getLogo)).Methods("GET")
 	router.HandleFunc("/config.json", requestWrapper(getConfig)).Methods("GET")
+	router.HandleFunc("/prometheus-export", requestWrapper(getMetrics)).Methods("GET")
 
-	router.HandleFunc("/address/{hash}", requestWrapper(handler.GetAddressHash)).Methods("GET")
+	router.HandleFunc("/challenge/{hash}", requestWrapper(handler.GetChallenge)).Methods("GET")
+
+	router.HandleFunc("/address/resolve", requestWrapper(handler.PostAddressResolve)).Methods("POST")
+	router.HandleFunc("/address/{hash}", requestWrapper(handler.SignedResponse(handler.GetAddressHash))).Methods("GET")
 	router.HandleFunc("/address/{hash}", requestWrapper(handler.DeleteAddressHash)).Methods("DELETE")
 	router.HandleFunc("/address/{hash}", requestWrapper(handler.PostAddressHash)).Methods("POST")
 
-	router.HandleFunc("/address/{hash}/status/{fingerprint}", requestWrapper(handler.GetKeyStatus)).Methods("GET")
+	router.HandleFunc("/address/{hash}/versions", requestWrapper(handler.SignedResponse(handler.GetAddressVersions))).Methods("GET")
+	router.HandleFunc("/address/{hash}/lockout", requestWrapper(handler.GetAddressLockout)).Methods("GET")
+	router.HandleFunc("/address/{hash}/redirected-by", requestWrapper(handler.GetAddressRedirectedBy)).Methods("GET")
+	router.HandleFunc("/address/{hash}/revoke", requestWrapper(handler.RevokeAddressHash)).Methods("POST")
+	router.HandleFunc("/address/{hash}/reset", requestWrapper(handler.RequestAddressKeyReset)).Methods("POST")
+	router.HandleFunc("/address/{hash}/reset/cancel", requestWrapper(handler.CancelAddressKeyReset)).Methods("POST")
+	router.HandleFunc("/address/{hash}/reset/complete", requestWrapper(handler.CompleteAddressKeyReset)).Methods("POST")
+	router.HandleFunc("/address/{hash}/pending/cancel", requestWrapper(handler.CancelAddressPendingKey)).Methods("POST")
+
+	router.HandleFunc("/address/{hash}/status/{fingerprint}", requestWrapper(handler.SignedResponse(handler.GetKeyStatus))).Methods("GET")
 	router.HandleFunc("/address/{hash}/status/{fingerprint}", requestWrapper(handler.SetKeyStatus)).Methods("POST")
 
-	router.HandleFunc("/routing/{hash}", requestWrapper(handler.GetRoutingHash)).Methods("GET")
+	router.HandleFunc("/address/{hash}/webhooks", requestWrapper(handler.PostAddressWebhook)).Methods("POST")
+	router.HandleFunc("/address/{hash}/webhooks", requestWrapper(handler.GetAddressWebhooks)).Methods("GET")
+	router.HandleFunc("/address/{hash}/webhooks/{id}", requestWrapper(handler.DeleteAddressWebhook)).Methods("DELETE")
+
+	router.HandleFunc("/routing/{hash}", requestWrapper(handler.SignedResponse(handler.GetRoutingHash))).Methods("GET")
 	router.HandleFunc("/routing/{hash}", requestWrapper(handler.DeleteRoutingHash)).Methods("DELETE")
 	router.HandleFunc("/routing/{hash}", requestWrapper(handler.PostRoutingHash)).Methods("POST")
+	router.HandleFunc("/routing/{hash}/versions", requestWrapper(handler.SignedResponse(handler.GetRoutingVersions))).Methods("GET")
+	router.HandleFunc("/routing/{hash}/lockout", requestWrapper(handler.GetRoutingLockout)).Methods("GET")
+	router.HandleFunc("/routing/{hash}/webhooks", requestWrapper(handler.PostRoutingWebhook)).Methods("POST")
+	router.HandleFunc("/routing/{hash}/webhooks", requestWrapper(handler.GetRoutingWebhooks)).Methods("GET")
+	router.HandleFunc("/routing/{hash}/webhooks/{id}", requestWrapper(handler.DeleteRoutingWebhook)).Methods("DELETE")
 
-	router.HandleFunc("/organisation/{hash}", requestWrapper(handler.GetOrganisationHash)).Methods("GET")
+	router.HandleFunc("/organisation/{hash}", requestWrapper(handler.SignedResponse(handler.GetOrganisationHash))).Methods("GET")
 	router.HandleFunc("/organisation/{hash}", requestWrapper(handler.DeleteOrganisationHash)).Methods("DELETE")
 	router.HandleFunc("/organisation/{hash}", requestWrapper(handler.PostOrganisationHash)).Methods("POST")
+	router.HandleFunc("/organisation/{hash}/versions", requestWrapper(handler.SignedResponse(handler.GetOrganisationVersions))).Methods("GET")
+	router.HandleFunc("/organisation/{hash}/lockout", requestWrapper(handler.GetOrganisationLockout)).Methods("GET")
+	router.HandleFunc("/organisation/{hash}/reset", requestWrapper(handler.RequestOrganisationKeyReset)).Methods("POST")
+	router.HandleFunc("/organisation/{hash}/reset/cancel", requestWrapper(handler.CancelOrganisationKeyReset)).Methods("POST")
+	router.HandleFunc("/organisation/{hash}/reset/complete", requestWrapper(handler.CompleteOrganisationKeyReset)).Methods("POST")
+	router.HandleFunc("/organisation/{hash}/webhooks", requestWrapper(handler.PostOrganisationWebhook)).Methods("POST")
+	router.HandleFunc("/organisation/{hash}/webhooks", requestWrapper(handler.GetOrganisationWebhooks)).Methods("GET")
+	router.HandleFunc("/organisation/{hash}/webhooks/{id}", requestWrapper(handler.DeleteOrganisationWebhook)).Methods("DELETE")
+
+	router.HandleFunc("/log/sth", requestWrapper(handler.GetLogTreeHead)).Methods("GET")
+	router.HandleFunc("/log/entries", requestWrapper(handler.GetLogEntries)).Methods("GET")
+	router.HandleFunc("/log/proof/inclusion", requestWrapper(handler.GetLogInclusionProof)).Methods("GET")
+	router.HandleFunc("/log/proof/consistency", requestWrapper(handler.GetLogConsistencyProof)).Methods("GET")
+
+	router.HandleFunc("/changes", requestWrapper(handler.GetChanges)).Methods("GET")
+	router.HandleFunc("/fingerprint/{fingerprint}", requestWrapper(handler.GetFingerprint)).Methods("GET")
+	router.HandleFunc("/changes/stream", rateLimitedStream(streamChanges)).Methods("GET")
 
 	// Serve HTTP if we like
 	if *ServeHttp {
@@ -134,6 +372,6 @@ func main() {
 		log.Fatal(err)
 	}
 
-	err := nethttp.ListenAndServeTLS(":"+*TcpPort, *CertPemFile, *KeyPemFile, router)
+	err = nethttp.ListenAndServeTLS(":"+*TcpPort, *CertPemFile, *KeyPemFile, router)
 	log.Fatal(err)
 }
@@ -0,0 +1,254 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	nethttp "net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bitmaelum/key-resolver-go/internal/changefeed"
+	"github.com/bitmaelum/key-resolver-go/internal/handler"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+)
+
+// streamPollInterval is the interval in which the change feed is checked for new changes
+var streamPollInterval = time.Second
+
+// maxStreams is the maximum number of streams that can be open at the same time
+var maxStreams = 100
+
+// streamBatchSize is the maximum number of changes that is fetched from the feed at once
+const streamBatchSize = 100
+
+// streamBufferSize is the number of batches that can wait for a stream. Streams that fall further behind are closed,
+// and will catch up from the feed itself when the client reconnects.
+const streamBufferSize = 16
+
+var errTooManyStreams = errors.New("too many streams")
+
+// changePoller polls the change feed once for all open streams, and passes the new changes on to each of them
+type changePoller struct {
+	mu      sync.Mutex
+	cursor  uint64
+	started bool
+	streams map[chan []changefeed.Change]struct{}
+}
+
+var poller = &changePoller{}
+
+// subscribe returns a channel that receives all changes that are found from now on
+func (p *changePoller) subscribe() (chan []changefeed.Change, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.streams) >= maxStreams {
+		return nil, errTooManyStreams
+	}
+
+	// Skip the existing changes, every stream reads those from the feed itself
+	if !p.started {
+		_, err := p.fetch()
+		if err != nil {
+			return nil, err
+		}
+
+		p.started = true
+		p.streams = make(map[chan []changefeed.Change]struct{})
+		go p.run()
+	}
+
+	ch := make(chan []changefeed.Change, streamBufferSize)
+	p.streams[ch] = struct{}{}
+
+	return ch, nil
+}
+
+// unsubscribe removes the channel, when it has not been removed already
+func (p *changePoller) unsubscribe(ch chan []changefeed.Change) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.streams[ch]; ok {
+		delete(p.streams, ch)
+		close(ch)
+	}
+}
+
+func (p *changePoller) run() {
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.mu.Lock()
+		changes, err := p.fetch()
+		if err != nil {
+			log.Print(err)
+		}
+
+		if len(changes) > 0 {
+			for ch := range p.streams {
+				select {
+				case ch <- changes:
+				default:
+					// The stream cannot keep up
+					delete(p.streams, ch)
+					close(ch)
+				}
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// fetch returns all changes after the cursor of the poller, and moves the cursor past them
+func (p *changePoller) fetch() ([]changefeed.Change, error) {
+	var ret []changefeed.Change
+
+	for {
+		changes, err := changefeed.GetRepository().Since(p.cursor, streamBatchSize)
+		if err != nil {
+			return ret, err
+		}
+
+		ret = append(ret, changes...)
+		if len(changes) > 0 {
+			p.cursor = changes[len(changes)-1].Cursor
+		}
+
+		if len(changes) < streamBatchSize {
+			return ret, nil
+		}
+	}
+}
+
+// rateLimitedStream wraps a stream handler, so opening a stream counts against the read budget of the client
+func rateLimitedStream(f nethttp.HandlerFunc) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, req *nethttp.Request) {
+		if resp := handler.TakeRateLimit("", http.NetReqToReq(*req)); resp != nil {
+			writeResponse(w, resp)
+			return
+		}
+
+		f(w, req)
+	}
+}
+
+// streamChanges streams the change feed as server-sent events. Each event carries the cursor of the change as id, so
+// clients that reconnect continue where they left off through the Last-Event-ID header.
+func streamChanges(w nethttp.ResponseWriter, req *nethttp.Request) {
+	flusher, ok := w.(nethttp.Flusher)
+	if !ok {
+		nethttp.Error(w, "streaming not supported", nethttp.StatusInternalServerError)
+		return
+	}
+
+	cursorStr := req.URL.Query().Get("since")
+	if req.Header.Get("Last-Event-ID") != "" {
+		cursorStr = req.Header.Get("Last-Event-ID")
+	}
+
+	var cursor uint64
+	if cursorStr != "" {
+		var err error
+		cursor, err = strconv.ParseUint(cursorStr, 10, 64)
+		if err != nil {
+			nethttp.Error(w, "invalid cursor", nethttp.StatusBadRequest)
+			return
+		}
+	}
+
+	// Subscribe before catching up, so no change is missed in between
+	ch, err := poller.subscribe()
+	if err == errTooManyStreams {
+		nethttp.Error(w, err.Error(), nethttp.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Print(err)
+		nethttp.Error(w, "error while fetching changes", nethttp.StatusInternalServerError)
+		return
+	}
+	defer poller.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(nethttp.StatusOK)
+	flusher.Flush()
+
+	// Catch up with the changes that already exist
+	for {
+		changes, err := changefeed.GetRepository().Since(cursor, streamBatchSize)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+
+		if !writeChanges(w, changes, &cursor) {
+			return
+		}
+		flusher.Flush()
+
+		if len(changes) < streamBatchSize {
+			break
+		}
+	}
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case changes, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			if !writeChanges(w, changes, &cursor) {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeChanges writes the changes after the cursor as events, and moves the cursor along. It returns false when the
+// client cannot be written to anymore.
+func writeChanges(w nethttp.ResponseWriter, changes []changefeed.Change, cursor *uint64) bool {
+	for _, change := range changes {
+		// Changes can be received from both the feed and the poller
+		if change.Cursor <= *cursor {
+			continue
+		}
+
+		data, _ := json.Marshal(change)
+		_, err := fmt.Fprintf(w, "id: %d\nevent: change\ndata: %s\n\n", change.Cursor, data)
+		if err != nil {
+			return false
+		}
+		*cursor = change.Cursor
+	}
+
+	return true
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitmaelum/key-resolver-go/internal/changefeed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamChanges(t *testing.T) {
+	repo := changefeed.NewSqliteRepository(":memory:")
+	changefeed.SetDefaultRepository(repo)
+	streamPollInterval = 10 * time.Millisecond
+
+	for i := 0; i < 3; i++ {
+		_, _ = repo.Append(changefeed.Change{Type: "address", Action: "update", Hash: "hash", Serial: 1234, Timestamp: 1000})
+	}
+
+	srv := httptest.NewServer(nethttp.HandlerFunc(streamChanges))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := nethttp.NewRequestWithContext(ctx, "GET", srv.URL+"?since=1", nil)
+	resp, err := nethttp.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	r := bufio.NewReader(resp.Body)
+	readEvent := func() []string {
+		var lines []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil || line == "\n" {
+				return lines
+			}
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+	}
+
+	// Changes that already exist
+	assert.Equal(t, []string{"id: 2", "event: change", `data: {"cursor":2,"type":"address","action":"update","hash":"hash","serial_number":1234,"timestamp":1000}`}, readEvent())
+	assert.Equal(t, "id: 3", readEvent()[0])
+
+	// Changes that are added while streaming
+	_, _ = repo.Append(changefeed.Change{Type: "routing", Action: "delete", Hash: "hash2", Timestamp: 1001})
+	assert.Equal(t, []string{"id: 4", "event: change", `data: {"cursor":4,"type":"routing","action":"delete","hash":"hash2","serial_number":0,"timestamp":1001}`}, readEvent())
+}
+
+func TestStreamChangesInvalidCursor(t *testing.T) {
+	req := httptest.NewRequest("GET", "/changes/stream?since=foo", nil)
+	w := httptest.NewRecorder()
+	streamChanges(w, req)
+
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestStreamChangesLimit(t *testing.T) {
+	changefeed.SetDefaultRepository(changefeed.NewSqliteRepository(":memory:"))
+
+	prev := maxStreams
+	maxStreams = 0
+	defer func() {
+		maxStreams = prev
+	}()
+
+	req := httptest.NewRequest("GET", "/changes/stream", nil)
+	w := httptest.NewRecorder()
+	streamChanges(w, req)
+
+	assert.Equal(t, 503, w.Code)
+}
@@ -21,37 +21,73 @@ package main
 
 import (
 	"encoding/json"
+	"log"
 	"math/rand"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
 	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
 	"github.com/bitmaelum/key-resolver-go/internal"
 	"github.com/bitmaelum/key-resolver-go/internal/apigateway"
+	"github.com/bitmaelum/key-resolver-go/internal/difficulty"
 	"github.com/bitmaelum/key-resolver-go/internal/handler"
 	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/keypolicy"
+	"github.com/bitmaelum/key-resolver-go/internal/lockout"
+	"github.com/bitmaelum/key-resolver-go/internal/proof"
+	"github.com/bitmaelum/key-resolver-go/internal/ratelimit"
 )
 
 type HandlerFunc func(hash.Hash, http.Request) *http.Response
 
 var handlerMapping = map[string]HandlerFunc{
-	"GET /address/{hash}":                       handler.GetAddressHash,
+	"GET /challenge/{hash}":                     handler.GetChallenge,
+	"GET /address/{hash}":                       handler.SignedResponse(handler.GetAddressHash),
 	"POST /address/{hash}/delete":               handler.SoftDeleteAddressHash,
 	"POST /address/{hash}/undelete":             handler.SoftUndeleteAddressHash,
-	"GET /address/{hash}/status/{fingerprint}":  handler.GetKeyStatus,
+	"POST /address/{hash}/revoke":               handler.RevokeAddressHash,
+	"POST /address/{hash}/reset":                handler.RequestAddressKeyReset,
+	"POST /address/{hash}/reset/cancel":         handler.CancelAddressKeyReset,
+	"POST /address/{hash}/reset/complete":       handler.CompleteAddressKeyReset,
+	"POST /address/{hash}/pending/cancel":       handler.CancelAddressPendingKey,
+	"GET /address/{hash}/status/{fingerprint}":  handler.SignedResponse(handler.GetKeyStatus),
 	"POST /address/{hash}/status/{fingerprint}": handler.SetKeyStatus,
 	"DELETE /address/{hash}":                    handler.DeleteAddressHash,
+	"GET /address/{hash}/redirected-by":         handler.GetAddressRedirectedBy,
+	"GET /address/{hash}/versions":              handler.SignedResponse(handler.GetAddressVersions),
+	"GET /address/{hash}/lockout":               handler.GetAddressLockout,
 	"POST /address/{hash}":                      handler.PostAddressHash,
-	"GET /routing/{hash}":                       handler.GetRoutingHash,
+	"GET /routing/{hash}":                       handler.SignedResponse(handler.GetRoutingHash),
 	"DELETE /routing/{hash}":                    handler.DeleteRoutingHash,
 	"POST /routing/{hash}":                      handler.PostRoutingHash,
-	"GET /organisation/{hash}":                  handler.GetOrganisationHash,
+	"GET /routing/{hash}/versions":              handler.SignedResponse(handler.GetRoutingVersions),
+	"GET /routing/{hash}/lockout":               handler.GetRoutingLockout,
+	"GET /organisation/{hash}":                  handler.SignedResponse(handler.GetOrganisationHash),
 	"POST /organisation/{hash}/delete":          handler.SoftDeleteOrganisationHash,
 	"POST /organisation/{hash}/undelete":        handler.SoftUndeleteOrganisationHash,
+	"POST /organisation/{hash}/reset":           handler.RequestOrganisationKeyReset,
+	"POST /organisation/{hash}/reset/cancel":    handler.CancelOrganisationKeyReset,
+	"POST /organisation/{hash}/reset/complete":  handler.CompleteOrganisationKeyReset,
 	"DELETE /organisation/{hash}":               handler.DeleteOrganisationHash,
 	"POST /organisation/{hash}":                 handler.PostOrganisationHash,
+	"GET /organisation/{hash}/versions":         handler.SignedResponse(handler.GetOrganisationVersions),
+	"GET /organisation/{hash}/lockout":          handler.GetOrganisationLockout,
+}
+
+// Routes that do not operate on a specific hash
+var noHashMapping = map[string]HandlerFunc{
+	"POST /address/resolve":          handler.PostAddressResolve,
+	"GET /log/sth":                   handler.GetLogTreeHead,
+	"GET /log/entries":               handler.GetLogEntries,
+	"GET /log/proof/inclusion":       handler.GetLogInclusionProof,
+	"GET /log/proof/consistency":     handler.GetLogConsistencyProof,
+	"GET /changes":                   handler.GetChanges,
+	"GET /fingerprint/{fingerprint}": handler.GetFingerprint,
 }
 
 // HandleRequest checks the incoming route and calls the correct handler for it
@@ -71,6 +107,13 @@ func HandleRequest(req events.APIGatewayV2HTTPRequest) (*events.APIGatewayV2HTTP
 		return internal.ExportMetric(), nil
 	}
 
+	if f, ok := noHashMapping[req.RouteKey]; ok {
+		httpResp := handler.RateLimited(f)("", *apigateway.ReqToHTTP(&req))
+
+		internal.LogMetric(req.RouteKey, httpResp.StatusCode)
+		return apigateway.HTTPToResp(httpResp), nil
+	}
+
 	h, err := hash.NewFromHash(req.PathParameters["hash"])
 	if err != nil {
 		resp := http.CreateError("Incorrect hash address", 400)
@@ -85,7 +128,7 @@ func HandleRequest(req events.APIGatewayV2HTTPRequest) (*events.APIGatewayV2HTTP
 	// Check mapping and call correct handler func
 	f, ok := handlerMapping[req.RouteKey]
 	if ok {
-		httpResp = f(*h, *httpReq)
+		httpResp = handler.RateLimited(f)(*h, *httpReq)
 	}
 
 	if httpResp == nil {
@@ -114,10 +157,24 @@ func getConfig(_ events.APIGatewayV2HTTPRequest) *events.APIGatewayV2HTTPRespons
 	headers["Content-Type"] = "application/json"
 
 	data := http.RawJSONOut{
-		"proof_of_work": http.RawJSONOut{
-			"address":      handler.MinimumProofBitsAddress,
-			"organisation": handler.MinimumProofBitsOrganisation,
-		},
+		"proof_of_work": handler.ProofOfWorkConfig(),
+		"key_policy":    handler.KeyPolicy,
+	}
+
+	// Publish the key that signs our responses, so clients can verify them
+	pubKey, err := handler.SigningPublicKey()
+	if err != nil {
+		log.Print(err)
+	}
+	if pubKey != nil {
+		data["signing_key"] = pubKey.String()
+	}
+
+	// Proofs of work must be computed over a challenge from /challenge/{hash} when challenges are enabled
+	if len(handler.ChallengeSecret) > 0 {
+		data["challenge"] = http.RawJSONOut{
+			"expiry": int64(handler.ChallengeExpiry.Seconds()),
+		}
 	}
 
 	strJson, _ := json.MarshalIndent(data, "", "  ")
@@ -133,5 +190,176 @@ func getConfig(_ events.APIGatewayV2HTTPRequest) *events.APIGatewayV2HTTPRespons
 
 func main() {
 	rand.Seed(time.Now().UnixNano())
+
+	// The signing key is optional, but without it no signed tree heads can be served
+	if os.Getenv("SIGNING_KEY") != "" {
+		key, err := bmcrypto.NewPrivKey(os.Getenv("SIGNING_KEY"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.SigningKey = key
+	}
+
+	if os.Getenv("REDIRECT_DELETE_POLICY") != "" {
+		if !handler.IsValidRedirectDeletePolicy(os.Getenv("REDIRECT_DELETE_POLICY")) {
+			log.Fatal("invalid redirect delete policy: " + os.Getenv("REDIRECT_DELETE_POLICY"))
+		}
+		handler.RedirectDeletePolicy = os.Getenv("REDIRECT_DELETE_POLICY")
+	}
+
+	// Without an admin key, the fingerprint index can only be queried by the owners of the keys
+	if os.Getenv("ADMIN_KEY") != "" {
+		key, err := bmcrypto.NewPubKey(os.Getenv("ADMIN_KEY"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.AdminKey = key
+	}
+
+	if os.Getenv("KEY_TYPES") != "" {
+		types, err := keypolicy.ParseTypes(os.Getenv("KEY_TYPES"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.KeyPolicy.AllowedTypes = types
+	}
+
+	if os.Getenv("MIN_RSA_BITS") != "" {
+		bits, err := strconv.Atoi(os.Getenv("MIN_RSA_BITS"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.KeyPolicy.MinRSABits = bits
+	}
+
+	if os.Getenv("MAX_RECORDS_PER_KEY") != "" {
+		max, err := strconv.Atoi(os.Getenv("MAX_RECORDS_PER_KEY"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.KeyPolicy.MaxRecordsPerKey = max
+	}
+
+	handler.KeyPolicy.Denylist = keypolicy.ParseDenylist(os.Getenv("KEY_DENYLIST"))
+
+	if os.Getenv("DIFFICULTY_SCHEDULE") != "" {
+		schedule, err := difficulty.Parse([]byte(os.Getenv("DIFFICULTY_SCHEDULE")))
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.DifficultySchedule = schedule
+	}
+
+	// Argon2id proofs of work are only accepted when the memory to use is configured
+	if os.Getenv("ARGON2_MEMORY") != "" {
+		params := proof.Argon2Params{
+			Time:    1,
+			Threads: 4,
+		}
+
+		memory, err := strconv.ParseUint(os.Getenv("ARGON2_MEMORY"), 10, 32)
+		if err != nil {
+			log.Fatal(err)
+		}
+		params.Memory = uint32(memory)
+		if os.Getenv("ARGON2_TIME") != "" {
+			passes, err := strconv.ParseUint(os.Getenv("ARGON2_TIME"), 10, 32)
+			if err != nil {
+				log.Fatal(err)
+			}
+			params.Time = uint32(passes)
+		}
+		if os.Getenv("ARGON2_THREADS") != "" {
+			threads, err := strconv.ParseUint(os.Getenv("ARGON2_THREADS"), 10, 8)
+			if err != nil {
+				log.Fatal(err)
+			}
+			params.Threads = uint8(threads)
+		}
+
+		if err = params.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		handler.Argon2Params = &params
+	}
+
+	if os.Getenv("ARGON2_BIT_REDUCTION") != "" {
+		reduction, err := strconv.Atoi(os.Getenv("ARGON2_BIT_REDUCTION"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.Argon2BitReduction = reduction
+	}
+
+	// Without a secret, proofs of work are computed over the hash only
+	handler.ChallengeSecret = []byte(os.Getenv("CHALLENGE_SECRET"))
+
+	if os.Getenv("CHALLENGE_EXPIRY") != "" {
+		expiry, err := time.ParseDuration(os.Getenv("CHALLENGE_EXPIRY"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.ChallengeExpiry = expiry
+	}
+
+	// Buckets are kept in DynamoDB (or BoltDB), so the limits are shared by all lambda instances
+	clientBudgets, err := ratelimit.ParseBudgets(os.Getenv("RATE_LIMIT_CLIENT_READ"), os.Getenv("RATE_LIMIT_CLIENT_WRITE"), os.Getenv("RATE_LIMIT_CLIENT_AUTH"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	hashBudgets, err := ratelimit.ParseBudgets(os.Getenv("RATE_LIMIT_HASH_READ"), os.Getenv("RATE_LIMIT_HASH_WRITE"), os.Getenv("RATE_LIMIT_HASH_AUTH"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	rateLimits := ratelimit.Config{Client: clientBudgets, Hash: hashBudgets}
+	if rateLimits.Enabled() {
+		handler.RateLimits = &rateLimits
+	}
+
+	// Failed authentications are only tracked when a threshold is configured
+	if os.Getenv("LOCKOUT_THRESHOLD") != "" {
+		policy := lockout.Policy{
+			Window:      15 * time.Minute,
+			Duration:    time.Minute,
+			MaxDuration: 24 * time.Hour,
+		}
+
+		policy.Threshold, err = strconv.Atoi(os.Getenv("LOCKOUT_THRESHOLD"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if os.Getenv("LOCKOUT_WINDOW") != "" {
+			policy.Window, err = time.ParseDuration(os.Getenv("LOCKOUT_WINDOW"))
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if os.Getenv("LOCKOUT_DURATION") != "" {
+			policy.Duration, err = time.ParseDuration(os.Getenv("LOCKOUT_DURATION"))
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if os.Getenv("LOCKOUT_MAX_DURATION") != "" {
+			policy.MaxDuration, err = time.ParseDuration(os.Getenv("LOCKOUT_MAX_DURATION"))
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if err = policy.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		handler.LockoutPolicy = &policy
+	}
+
+	if os.Getenv("VERSION_RETENTION") != "" {
+		retention, err := time.ParseDuration(os.Getenv("VERSION_RETENTION"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.VersionRetention = retention
+	}
+
 	lambda.Start(HandleRequest)
 }
@@ -23,6 +23,8 @@ import (
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/bitmaelum/key-resolver-go/internal/handler"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -80,5 +82,23 @@ func TestHandleConfig(t *testing.T) {
 
 	assert.Equal(t, 200, res.StatusCode)
 	assert.Equal(t, "application/json", res.Headers["Content-Type"])
-	assert.JSONEq(t, res.Body, "{\"proof_of_work\":{\"address\": 27,\"organisation\":29}}")
+	assert.JSONEq(t, res.Body, "{\"proof_of_work\":{\"address\": 27,\"organisation\":29,\"routing\":27,\"algorithms\":{\"sha256\":{}}},\"key_policy\":{\"allowed_types\":[\"rsa\",\"ecdsa\",\"ed25519\"],\"min_rsa_bits\":2048,\"max_records_per_key\":0}}")
+}
+
+func TestHandleConfigWithSigningKey(t *testing.T) {
+	privKey, pubKey, _ := testing2.ReadTestKey("../../testdata/key-7.json")
+	handler.SigningKey = privKey
+	defer func() {
+		handler.SigningKey = nil
+	}()
+
+	req := &events.APIGatewayV2HTTPRequest{
+		RouteKey: "GET /config.json",
+	}
+
+	res, err := HandleRequest(*req)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 200, res.StatusCode)
+	assert.JSONEq(t, res.Body, "{\"proof_of_work\":{\"address\": 27,\"organisation\":29,\"routing\":27,\"algorithms\":{\"sha256\":{}}},\"key_policy\":{\"allowed_types\":[\"rsa\",\"ecdsa\",\"ed25519\"],\"min_rsa_bits\":2048,\"max_records_per_key\":0},\"signing_key\":\""+pubKey.String()+"\"}")
 }
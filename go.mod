@@ -11,4 +11,5 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.1
 	github.com/stretchr/testify v1.6.1
 	go.etcd.io/bbolt v1.3.5
+	golang.org/x/crypto v0.0.0-20200728195943-123391ffb6de
 )
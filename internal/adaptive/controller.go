@@ -0,0 +1,245 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package adaptive
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of slots a window is divided in. Creations are counted per slot, so the window slides with the slot size.
+const slotsPerWindow = 10
+
+// Decisions made by the controller
+const (
+	DecisionRaise = "raise"
+	DecisionLower = "lower"
+)
+
+// Config holds the settings of the adaptive difficulty controller
+type Config struct {
+	Window       time.Duration // Length of the sliding window over which creations are counted
+	Threshold    int           // Number of creations within the window above which the difficulty is raised
+	MaxExtraBits int           // Maximum number of bits on top of the regular difficulty
+	Interval     time.Duration // Minimum time between two changes of the difficulty of a record type
+}
+
+// Validate checks if the configuration can be used
+func (c Config) Validate() error {
+	if c.Window <= 0 {
+		return errors.New("window must be positive")
+	}
+	if c.Threshold <= 0 {
+		return errors.New("threshold must be positive")
+	}
+	if c.MaxExtraBits <= 0 {
+		return errors.New("max extra bits must be positive")
+	}
+	if c.Interval < 0 {
+		return errors.New("interval cannot be negative")
+	}
+
+	return nil
+}
+
+type slot struct {
+	start time.Time
+	count int
+}
+
+// state is the state of the controller for a single record type
+type state struct {
+	slots      []slot
+	extra      int
+	lastChange time.Time
+	decisions  map[string]int
+}
+
+// Controller raises the proof-of-work difficulty of a record type when many records of that type are created, and
+// lowers it again when the load subsides. The difficulty is raised when the number of creations within the window
+// exceeds the threshold, and lowered when it drops below half of the threshold.
+type Controller struct {
+	cfg    Config
+	now    func() time.Time
+	mu     sync.Mutex
+	states map[string]*state
+}
+
+// New returns a new controller. The clock is passed, so the controller can be tested with a fake clock.
+func New(cfg Config, now func() time.Time) *Controller {
+	return &Controller{
+		cfg:    cfg,
+		now:    now,
+		states: make(map[string]*state),
+	}
+}
+
+// Record records the creation of a record of the given type
+func (c *Controller) Record(typ string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	s := c.state(typ)
+	s.prune(now, c.cfg.Window)
+
+	start := now.Truncate(c.slotSize())
+	if len(s.slots) > 0 && s.slots[len(s.slots)-1].start.Equal(start) {
+		s.slots[len(s.slots)-1].count++
+	} else {
+		s.slots = append(s.slots, slot{start: start, count: 1})
+	}
+
+	c.evaluate(typ, s, now)
+}
+
+// ExtraBits returns the number of bits that are currently added to the difficulty of the record type
+func (c *Controller) ExtraBits(typ string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	s := c.state(typ)
+	s.prune(now, c.cfg.Window)
+	c.evaluate(typ, s, now)
+
+	return s.extra
+}
+
+// Rate returns the number of creations of the record type within the current window
+func (c *Controller) Rate(typ string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.state(typ)
+	s.prune(c.now(), c.cfg.Window)
+
+	return s.count()
+}
+
+// Metrics returns the state and the decisions of the controller in the prometheus text format
+func (c *Controller) Metrics() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	types := make([]string, 0, len(c.states))
+	for typ := range c.states {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+
+	now := c.now()
+	var sb strings.Builder
+
+	sb.WriteString("# HELP keyresolver_pow_extra_bits Bits added to the proof-of-work difficulty by the adaptive controller\n")
+	sb.WriteString("# TYPE keyresolver_pow_extra_bits gauge\n")
+	for _, typ := range types {
+		sb.WriteString(fmt.Sprintf("keyresolver_pow_extra_bits{type=\"%s\"} %d\n", typ, c.states[typ].extra))
+	}
+
+	sb.WriteString("# HELP keyresolver_pow_creations Records created within the sliding window\n")
+	sb.WriteString("# TYPE keyresolver_pow_creations gauge\n")
+	for _, typ := range types {
+		s := c.states[typ]
+		s.prune(now, c.cfg.Window)
+		sb.WriteString(fmt.Sprintf("keyresolver_pow_creations{type=\"%s\"} %d\n", typ, s.count()))
+	}
+
+	sb.WriteString("# HELP keyresolver_pow_decisions Difficulty changes made by the adaptive controller\n")
+	sb.WriteString("# TYPE keyresolver_pow_decisions counter\n")
+	for _, typ := range types {
+		for _, d := range []string{DecisionRaise, DecisionLower} {
+			sb.WriteString(fmt.Sprintf("keyresolver_pow_decisions{type=\"%s\", decision=\"%s\"} %d\n", typ, d, c.states[typ].decisions[d]))
+		}
+	}
+
+	return sb.String()
+}
+
+// evaluate raises or lowers the difficulty of the record type by a single bit, when the last change is at least an
+// interval ago
+func (c *Controller) evaluate(typ string, s *state, now time.Time) {
+	if !s.lastChange.IsZero() && now.Sub(s.lastChange) < c.cfg.Interval {
+		return
+	}
+
+	count := s.count()
+
+	decision := ""
+	switch {
+	case count > c.cfg.Threshold && s.extra < c.cfg.MaxExtraBits:
+		decision = DecisionRaise
+		s.extra++
+	case count*2 < c.cfg.Threshold && s.extra > 0:
+		decision = DecisionLower
+		s.extra--
+	default:
+		return
+	}
+
+	s.lastChange = now
+	s.decisions[decision]++
+
+	log.Printf("adaptive difficulty: %s %s to %d extra bits (%d creations within %s)", decision, typ, s.extra, count, c.cfg.Window)
+}
+
+func (c *Controller) slotSize() time.Duration {
+	size := c.cfg.Window / slotsPerWindow
+	if size <= 0 {
+		return 1
+	}
+
+	return size
+}
+
+func (c *Controller) state(typ string) *state {
+	s, ok := c.states[typ]
+	if !ok {
+		s = &state{
+			decisions: make(map[string]int),
+		}
+		c.states[typ] = s
+	}
+
+	return s
+}
+
+// prune removes the slots that have fallen out of the window
+func (s *state) prune(now time.Time, window time.Duration) {
+	i := 0
+	for i < len(s.slots) && !s.slots[i].start.After(now.Add(-window)) {
+		i++
+	}
+	s.slots = s.slots[i:]
+}
+
+func (s *state) count() int {
+	total := 0
+	for _, sl := range s.slots {
+		total += sl.count
+	}
+
+	return total
+}
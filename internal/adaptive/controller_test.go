@@ -0,0 +1,124 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package adaptive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Config{Window: time.Minute, Threshold: 10, MaxExtraBits: 4, Interval: time.Minute}
+	assert.NoError(t, cfg.Validate())
+
+	assert.Error(t, Config{Threshold: 10, MaxExtraBits: 4}.Validate())
+	assert.Error(t, Config{Window: time.Minute, MaxExtraBits: 4}.Validate())
+	assert.Error(t, Config{Window: time.Minute, Threshold: 10}.Validate())
+	assert.Error(t, Config{Window: time.Minute, Threshold: 10, MaxExtraBits: 4, Interval: -time.Second}.Validate())
+}
+
+func TestController(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2010, 04, 07, 12, 00, 00, 0, time.UTC)}
+	c := New(Config{
+		Window:       10 * time.Minute,
+		Threshold:    10,
+		MaxExtraBits: 2,
+		Interval:     5 * time.Minute,
+	}, clock.Now)
+
+	assert.Equal(t, 0, c.ExtraBits("address"))
+
+	// Reaching the threshold does not raise the difficulty
+	for i := 0; i < 10; i++ {
+		c.Record("address")
+	}
+	assert.Equal(t, 10, c.Rate("address"))
+	assert.Equal(t, 0, c.ExtraBits("address"))
+
+	// Exceeding it does
+	c.Record("address")
+	assert.Equal(t, 1, c.ExtraBits("address"))
+	assert.Equal(t, 0, c.ExtraBits("organisation"))
+
+	// No further change within the interval
+	for i := 0; i < 10; i++ {
+		c.Record("address")
+	}
+	assert.Equal(t, 1, c.ExtraBits("address"))
+
+	clock.Advance(5 * time.Minute)
+	c.Record("address")
+	assert.Equal(t, 2, c.ExtraBits("address"))
+
+	// Never above the maximum
+	clock.Advance(4 * time.Minute)
+	for i := 0; i < 20; i++ {
+		c.Record("address")
+	}
+	clock.Advance(time.Minute)
+	assert.Equal(t, 21, c.Rate("address"))
+	assert.Equal(t, 2, c.ExtraBits("address"))
+
+	// Load subsides: the first creations fall out of the window, but the rate is still above half the threshold
+	clock.Advance(5*time.Minute + time.Second)
+	assert.Equal(t, 20, c.Rate("address"))
+	assert.Equal(t, 2, c.ExtraBits("address"))
+
+	// All creations fall out of the window, so the difficulty decays one bit per interval
+	clock.Advance(5 * time.Minute)
+	assert.Equal(t, 0, c.Rate("address"))
+	assert.Equal(t, 1, c.ExtraBits("address"))
+	clock.Advance(time.Minute)
+	assert.Equal(t, 1, c.ExtraBits("address"))
+	clock.Advance(4 * time.Minute)
+	assert.Equal(t, 0, c.ExtraBits("address"))
+	clock.Advance(time.Hour)
+	assert.Equal(t, 0, c.ExtraBits("address"))
+
+	assert.Equal(t, `# HELP keyresolver_pow_extra_bits Bits added to the proof-of-work difficulty by the adaptive controller
+# TYPE keyresolver_pow_extra_bits gauge
+keyresolver_pow_extra_bits{type="address"} 0
+keyresolver_pow_extra_bits{type="organisation"} 0
+# HELP keyresolver_pow_creations Records created within the sliding window
+# TYPE keyresolver_pow_creations gauge
+keyresolver_pow_creations{type="address"} 0
+keyresolver_pow_creations{type="organisation"} 0
+# HELP keyresolver_pow_decisions Difficulty changes made by the adaptive controller
+# TYPE keyresolver_pow_decisions counter
+keyresolver_pow_decisions{type="address", decision="raise"} 2
+keyresolver_pow_decisions{type="address", decision="lower"} 2
+keyresolver_pow_decisions{type="organisation", decision="raise"} 0
+keyresolver_pow_decisions{type="organisation", decision="lower"} 0
+`, c.Metrics())
+}
@@ -20,6 +20,7 @@
 package address
 
 import (
+	"bytes"
 	"encoding/json"
 	"time"
 
@@ -29,15 +30,17 @@ import (
 )
 
 type boltResolver struct {
-	client     *bolt.DB
-	bucketName []byte
+	client             *bolt.DB
+	bucketName         []byte
+	redirectBucketName []byte
 }
 
 // NewBoltResolver returns a new resolver based on BoltDB
 func NewBoltResolver() Repository {
 	return &boltResolver{
-		client:     internal.GetBoltDb(),
-		bucketName: []byte("address"),
+		client:             internal.GetBoltDb(),
+		bucketName:         []byte("address"),
+		redirectBucketName: []byte("address_redirects"),
 	}
 }
 
@@ -65,6 +68,32 @@ func (b boltResolver) Get(hash string) (*ResolveInfoType, error) {
 	return rec, nil
 }
 
+func (b boltResolver) GetMany(hashes []string) (map[string]*ResolveInfoType, error) {
+	recs := make(map[string]*ResolveInfoType)
+
+	err := b.client.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil {
+			return nil
+		}
+
+		for _, hash := range hashes {
+			rec, err := getFromBucket(bucket, hash)
+			if err == nil {
+				recs[hash] = rec
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return recs, nil
+}
+
 func (b boltResolver) Create(hash, routing string, publicKey *bmcrypto.PubKey, proof string, redirHash string) (bool, error) {
 	err := b.client.Update(func(tx *bolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists(b.bucketName)
@@ -92,6 +121,11 @@ func (b boltResolver) Create(hash, routing string, publicKey *bmcrypto.PubKey, p
 			return err
 		}
 
+		err = b.setRedirect(tx, hash, "", redirHash)
+		if err != nil {
+			return err
+		}
+
 		// Store in history
 		bucket, err = tx.CreateBucketIfNotExists([]byte(hash + "fingerprints"))
 		if err != nil {
@@ -128,9 +162,15 @@ func (b boltResolver) Update(info *ResolveInfoType, routing string, publicKey *b
 			return ErrNotFound
 		}
 
+		err = b.setRedirect(tx, info.Hash, rec.RedirHash, redirHash)
+		if err != nil {
+			return err
+		}
+
 		rec.RoutingID = routing
 		rec.PubKey = publicKey.String()
 		rec.RedirHash = redirHash
+		rec.Serial = uint64(time.Now().UnixNano())
 		buf, err := json.Marshal(rec)
 		if err != nil {
 			return err
@@ -230,6 +270,14 @@ func (b boltResolver) Delete(hash string) (bool, error) {
 			return nil
 		}
 
+		rec, err := getFromBucket(bucket, hash)
+		if err == nil {
+			err = b.setRedirect(tx, hash, rec.RedirHash, "")
+			if err != nil {
+				return err
+			}
+		}
+
 		return bucket.Delete([]byte(hash))
 	})
 
@@ -287,6 +335,232 @@ func (b boltResolver) SetKeyStatus(hash string, fingerprint string, status KeySt
 	})
 }
 
+func (b boltResolver) SetRecoveryKey(hash string, recoveryKey *bmcrypto.PubKey) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) {
+		rec.RecoveryKey = recoveryKey.String()
+	})
+}
+
+func (b boltResolver) RequestKeyReset(hash string, publicKey *bmcrypto.PubKey, resetAt time.Time) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) {
+		rec.ResetKey = publicKey.String()
+		rec.ResetAt = resetAt
+	})
+}
+
+func (b boltResolver) CancelKeyReset(hash string) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) {
+		rec.ResetKey = ""
+		rec.ResetAt = time.Time{}
+	})
+}
+
+func (b boltResolver) CompleteKeyReset(info *ResolveInfoType) (bool, error) {
+	err := b.replaceKey(info, func(rec *ResolveInfoType) string {
+		key := rec.ResetKey
+		rec.ResetKey = ""
+		rec.ResetAt = time.Time{}
+		return key
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (b boltResolver) SetProtected(hash string, protected bool) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) {
+		rec.Protected = protected
+	})
+}
+
+func (b boltResolver) SetPendingKey(hash string, publicKey *bmcrypto.PubKey, activateAt time.Time) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) {
+		rec.PendingKey = publicKey.String()
+		rec.PendingAt = activateAt
+	})
+}
+
+func (b boltResolver) CancelPendingKey(hash string) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) {
+		rec.PendingKey = ""
+		rec.PendingAt = time.Time{}
+	})
+}
+
+func (b boltResolver) ActivatePendingKey(info *ResolveInfoType) (bool, error) {
+	err := b.replaceKey(info, func(rec *ResolveInfoType) string {
+		key := rec.PendingKey
+		rec.PendingKey = ""
+		rec.PendingAt = time.Time{}
+		return key
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (b boltResolver) SetPendingRouting(hash string, routing, redirHash string, activateAt time.Time) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) {
+		rec.PendingRouting = routing
+		rec.PendingRedirHash = redirHash
+		rec.PendingRoutingAt = activateAt
+	})
+}
+
+func (b boltResolver) CancelPendingRouting(hash string) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) {
+		rec.PendingRouting = ""
+		rec.PendingRedirHash = ""
+		rec.PendingRoutingAt = time.Time{}
+	})
+}
+
+func (b boltResolver) SetDelegates(hash string, delegates []DelegateType) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) {
+		rec.Delegates = delegates
+	})
+}
+
+func (b boltResolver) SetOrganisation(hash string, orgHash string) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) {
+		rec.OrgHash = orgHash
+	})
+}
+
+func (b boltResolver) GetRedirectedBy(hash string) ([]string, error) {
+	var hashes []string
+
+	err := b.client.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.redirectBucketName)
+		if bucket == nil {
+			return nil
+		}
+
+		prefix := redirectKey(hash, "")
+		c := bucket.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			hashes = append(hashes, string(k[len(prefix):]))
+		}
+
+		return nil
+	})
+
+	return hashes, err
+}
+
+// setRedirect moves the entry of hash in the redirect index from the old target to the new target
+func (b boltResolver) setRedirect(tx *bolt.Tx, hash, oldTarget, newTarget string) error {
+	if oldTarget == newTarget {
+		return nil
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists(b.redirectBucketName)
+	if err != nil {
+		return err
+	}
+
+	if oldTarget != "" {
+		err = bucket.Delete(redirectKey(oldTarget, hash))
+		if err != nil {
+			return err
+		}
+	}
+
+	if newTarget != "" {
+		return bucket.Put(redirectKey(newTarget, hash), []byte{})
+	}
+
+	return nil
+}
+
+// redirectKey returns the key in the redirect index for an address that redirects to the target
+func redirectKey(target, hash string) []byte {
+	return []byte(target + "/" + hash)
+}
+
+// replaceKey will replace the public key of the record with the key returned by f, as long as the record has not
+// been changed since info was fetched.
+func (b boltResolver) replaceKey(info *ResolveInfoType, f func(rec *ResolveInfoType) string) error {
+	return b.client.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil {
+			return ErrNotFound
+		}
+
+		rec, err := getFromBucket(bucket, info.Hash)
+		if err != nil {
+			return ErrNotFound
+		}
+
+		if rec.Serial != info.Serial {
+			return ErrCannotUpdate
+		}
+
+		key := f(rec)
+		if key == "" {
+			return ErrCannotUpdate
+		}
+
+		pk, err := bmcrypto.NewPubKey(key)
+		if err != nil {
+			return err
+		}
+
+		rec.PubKey = key
+		rec.Serial = uint64(time.Now().UnixNano())
+		buf, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+
+		err = bucket.Put([]byte(info.Hash), buf)
+		if err != nil {
+			return err
+		}
+
+		// Store in history
+		bucket, err = tx.CreateBucketIfNotExists([]byte(info.Hash + "fingerprints"))
+		if err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(KSNormal)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(pk.Fingerprint()), b)
+	})
+}
+
+// updateRecord will fetch the record, let f modify it, and store it again in a single transaction
+func (b boltResolver) updateRecord(hash string, f func(rec *ResolveInfoType)) error {
+	return b.client.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil {
+			return ErrNotFound
+		}
+
+		rec, err := getFromBucket(bucket, hash)
+		if err != nil {
+			return ErrNotFound
+		}
+
+		f(rec)
+
+		buf, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(hash), buf)
+	})
+}
+
 func getFromBucket(bucket *bolt.Bucket, hash string) (*ResolveInfoType, error) {
 	data := bucket.Get([]byte(hash))
 	if data == nil {
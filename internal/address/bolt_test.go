@@ -24,6 +24,10 @@ import (
 	"math/rand"
 	"os"
 	"testing"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/stretchr/testify/assert"
 )
 
 const tmpDbPath = "/tmp/mockboltdb-%d.db"
@@ -53,4 +57,54 @@ func TestBoltResolver(t *testing.T) {
 	runRepositoryHistoryKeyStatus(t, db)
 
 	_ = os.Remove(p)
+	db = NewBoltResolver()
+	runRepositoryKeyResetTests(t, db)
+
+	_ = os.Remove(p)
+	db = NewBoltResolver()
+	runRepositoryPendingKeyTests(t, db)
+
+	_ = os.Remove(p)
+	db = NewBoltResolver()
+	runRepositoryDelegateTests(t, db)
+
+	_ = os.Remove(p)
+	db = NewBoltResolver()
+	runRepositoryGetManyTests(t, db)
+
+	_ = os.Remove(p)
+	db = NewBoltResolver()
+	runRepositoryOrganisationTests(t, db)
+
+	_ = os.Remove(p)
+	db = NewBoltResolver()
+	runRepositoryRedirectTests(t, db)
+
+	_ = os.Remove(p)
+	db = NewBoltResolver()
+	runBoltSerialTests(t, db)
+
+	_ = os.Remove(p)
+}
+
+func runBoltSerialTests(t *testing.T, db Repository) {
+	h := hash.New("serial!")
+	_, pubkey, _ := bmcrypto.GenerateKeyPair("ed25519")
+
+	ok, err := db.Create(h.String(), "12345678", pubkey, "proof", "")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	info, _ := db.Get(h.String())
+	ok, err = db.Update(info, "11112222", pubkey, "")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Every update gets a new serial, so the old serial cannot be used anymore
+	updated, _ := db.Get(h.String())
+	assert.NotEqual(t, info.Serial, updated.Serial)
+
+	ok, err = db.Update(info, "33334444", pubkey, "")
+	assert.Error(t, err)
+	assert.False(t, ok)
 }
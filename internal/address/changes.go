@@ -0,0 +1,146 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package address
+
+import (
+	"time"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/key-resolver-go/internal/changefeed"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+)
+
+// changeRecorder adds every change that is made through the repository to the change feed
+type changeRecorder struct {
+	Repository
+}
+
+// withChangeFeed returns the repository with every change made through it recorded in the change feed
+func withChangeFeed(r Repository) Repository {
+	return &changeRecorder{Repository: r}
+}
+
+func (c *changeRecorder) Create(hash, routing string, publicKey *bmcrypto.PubKey, proof string, redirHash string) (bool, error) {
+	ok, err := c.Repository.Create(hash, routing, publicKey, proof, redirHash)
+	if ok && err == nil {
+		c.record(translog.ActionCreate, hash)
+	}
+	return ok, err
+}
+
+func (c *changeRecorder) Update(info *ResolveInfoType, routing string, publicKey *bmcrypto.PubKey, redirHash string) (bool, error) {
+	ok, err := c.Repository.Update(info, routing, publicKey, redirHash)
+	if ok && err == nil {
+		c.record(translog.ActionUpdate, info.Hash)
+	}
+	return ok, err
+}
+
+func (c *changeRecorder) SoftDelete(hash string) (bool, error) {
+	ok, err := c.Repository.SoftDelete(hash)
+	if ok && err == nil {
+		c.record(translog.ActionSoftDelete, hash)
+	}
+	return ok, err
+}
+
+func (c *changeRecorder) SoftUndelete(hash string) (bool, error) {
+	ok, err := c.Repository.SoftUndelete(hash)
+	if ok && err == nil {
+		c.record(translog.ActionUndelete, hash)
+	}
+	return ok, err
+}
+
+func (c *changeRecorder) Delete(hash string) (bool, error) {
+	ok, err := c.Repository.Delete(hash)
+	if ok && err == nil {
+		c.record(translog.ActionDelete, hash)
+	}
+	return ok, err
+}
+
+func (c *changeRecorder) SetKeyStatus(hash string, fingerprint string, status KeyStatus) error {
+	err := c.Repository.SetKeyStatus(hash, fingerprint, status)
+	if err == nil {
+		c.record(translog.ActionKeyStatus, hash)
+	}
+	return err
+}
+
+func (c *changeRecorder) RequestKeyReset(hash string, publicKey *bmcrypto.PubKey, resetAt time.Time) error {
+	err := c.Repository.RequestKeyReset(hash, publicKey, resetAt)
+	if err == nil {
+		c.record(changefeed.ActionResetRequest, hash)
+	}
+	return err
+}
+
+func (c *changeRecorder) CancelKeyReset(hash string) error {
+	err := c.Repository.CancelKeyReset(hash)
+	if err == nil {
+		c.record(changefeed.ActionResetCancel, hash)
+	}
+	return err
+}
+
+func (c *changeRecorder) CompleteKeyReset(info *ResolveInfoType) (bool, error) {
+	ok, err := c.Repository.CompleteKeyReset(info)
+	if ok && err == nil {
+		c.record(translog.ActionUpdate, info.Hash)
+	}
+	return ok, err
+}
+
+func (c *changeRecorder) ActivatePendingKey(info *ResolveInfoType) (bool, error) {
+	ok, err := c.Repository.ActivatePendingKey(info)
+	if ok && err == nil {
+		c.record(translog.ActionUpdate, info.Hash)
+	}
+	return ok, err
+}
+
+func (c *changeRecorder) CancelPendingKey(hash string) error {
+	err := c.Repository.CancelPendingKey(hash)
+	if err == nil {
+		c.record(changefeed.ActionPendingCancel, hash)
+	}
+	return err
+}
+
+func (c *changeRecorder) CancelPendingRouting(hash string) error {
+	err := c.Repository.CancelPendingRouting(hash)
+	if err == nil {
+		c.record(changefeed.ActionPendingCancel, hash)
+	}
+	return err
+}
+
+// record adds the change to the feed together with the serial the record has after the change, or 0 when the record
+// does not exist anymore
+func (c *changeRecorder) record(action, hash string) {
+	var serial uint64
+	info, err := c.Repository.Get(hash)
+	if err == nil && info != nil {
+		serial = info.Serial
+	}
+
+	changefeed.Record(translog.TypeAddress, action, hash, serial)
+}
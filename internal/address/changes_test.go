@@ -0,0 +1,88 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package address
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/changefeed"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeRecorder(t *testing.T) {
+	changefeed.SetDefaultRepository(changefeed.NewSqliteRepository(":memory:"))
+	changefeed.TimeNow = func() time.Time {
+		return time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+	}
+	defer func() {
+		changefeed.SetDefaultRepository(nil)
+		changefeed.TimeNow = time.Now
+	}()
+
+	var notified []changefeed.Change
+	changefeed.Notify = func(change changefeed.Change) {
+		notified = append(notified, change)
+	}
+	defer func() {
+		changefeed.Notify = nil
+	}()
+
+	sr := NewSqliteResolver(":memory:").(*SqliteDbResolver)
+	sr.TimeNow = time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+	db := withChangeFeed(sr)
+
+	h1 := hash.Hash("address1!")
+	_, pub1, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	_, pub2, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+
+	ok, err := db.Create(h1.String(), "12345678", pub1, "proof", "")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Failed changes are not recorded
+	info, _ := db.Get(h1.String())
+	stale := *info
+	stale.Serial = 1
+	ok, _ = db.Update(&stale, "87654321", pub1, "")
+	assert.False(t, ok)
+
+	err = db.SetKeyStatus(h1.String(), pub1.Fingerprint(), KSCompromised)
+	assert.NoError(t, err)
+
+	err = db.RequestKeyReset(h1.String(), pub2, time.Now())
+	assert.NoError(t, err)
+
+	ok, err = db.Delete(h1.String())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	changes, err := changefeed.GetRepository().Since(0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []changefeed.Change{
+		{Cursor: 1, Type: "address", Action: "create", Hash: h1.String(), Serial: 1270643696000000000, Timestamp: 1270643696},
+		{Cursor: 2, Type: "address", Action: "key-status", Hash: h1.String(), Serial: 1270643696000000000, Timestamp: 1270643696},
+		{Cursor: 3, Type: "address", Action: "reset-request", Hash: h1.String(), Serial: 1270643696000000000, Timestamp: 1270643696},
+		{Cursor: 4, Type: "address", Action: "delete", Hash: h1.String(), Serial: 0, Timestamp: 1270643696},
+	}, changes)
+	assert.Len(t, notified, 4)
+}
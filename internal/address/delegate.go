@@ -0,0 +1,57 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package address
+
+// Scopes that can be given to a delegate
+const (
+	ScopeRoutingOnly = "routing-only" // Delegate can only change the routing ID of the address
+)
+
+var validScopes = []string{
+	ScopeRoutingOnly,
+}
+
+// DelegateType is a public key that is allowed to update parts of the address on behalf of the owner
+type DelegateType struct {
+	PublicKey string   `json:"public_key" dynamodbav:"public_key"`
+	Scopes    []string `json:"scopes" dynamodbav:"scopes"`
+}
+
+// HasScope returns true when the delegate has been given the scope
+func (d DelegateType) HasScope(scope string) bool {
+	for i := range d.Scopes {
+		if d.Scopes[i] == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsValidScope returns true when the scope is a known delegate scope
+func IsValidScope(scope string) bool {
+	for i := range validScopes {
+		if validScopes[i] == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -38,6 +38,12 @@ type dynamoDbResolver struct {
 	HistoryTableName string
 }
 
+// redirectIndexName is the global secondary index on redirect_target, which makes up the reverse redirect index
+const redirectIndexName = "redirect_target_index"
+
+// maxBatchGetItems is the maximum number of keys DynamoDB accepts in a single BatchGetItem call
+const maxBatchGetItems = 100
+
 // Error codes
 var (
 	ErrNotFound     = errors.New("record not found")
@@ -54,6 +60,25 @@ type recordType struct {
 	Serial    uint64 `dynamodbav:"sn"`
 	Deleted   bool   `dynamodbav:"deleted"`
 	DeletedAt uint64 `dynamodbav:"deleted_at"`
+
+	RecoveryKey string `dynamodbav:"recovery_key,omitempty"`
+	ResetKey    string `dynamodbav:"reset_key,omitempty"`
+	ResetAt     int64  `dynamodbav:"reset_at,omitempty"`
+
+	Protected  bool   `dynamodbav:"protected,omitempty"`
+	PendingKey string `dynamodbav:"pending_key,omitempty"`
+	PendingAt  int64  `dynamodbav:"pending_at,omitempty"`
+
+	PendingRouting   string `dynamodbav:"pending_routing,omitempty"`
+	PendingRedirHash string `dynamodbav:"pending_redir_hash,omitempty"`
+	PendingRoutingAt int64  `dynamodbav:"pending_routing_at,omitempty"`
+
+	Delegates []DelegateType `dynamodbav:"delegates,omitempty"`
+
+	OrgHash string `dynamodbav:"org_hash,omitempty"`
+
+	// Copy of the redirect hash that is only present when set, so it can be used as a sparse index key
+	RedirectTarget string `dynamodbav:"redirect_target,omitempty"`
 }
 
 type historyRecordType struct {
@@ -61,6 +86,40 @@ type historyRecordType struct {
 	Status          KeyStatus `dynamodbav:"status"`
 }
 
+// toInfo converts the DynamoDB record into a resolve info structure
+func (record recordType) toInfo() *ResolveInfoType {
+	info := &ResolveInfoType{
+		Hash:        record.Hash,
+		RedirHash:   record.RedirHash,
+		RoutingID:   record.Routing,
+		PubKey:      record.PublicKey,
+		Proof:       record.Proof,
+		Serial:      record.Serial,
+		Deleted:     record.Deleted,
+		DeletedAt:   time.Unix(int64(record.DeletedAt), 0),
+		RecoveryKey: record.RecoveryKey,
+		ResetKey:    record.ResetKey,
+		Protected:   record.Protected,
+		PendingKey:  record.PendingKey,
+		Delegates:   record.Delegates,
+		OrgHash:     record.OrgHash,
+
+		PendingRouting:   record.PendingRouting,
+		PendingRedirHash: record.PendingRedirHash,
+	}
+	if record.ResetKey != "" {
+		info.ResetAt = time.Unix(record.ResetAt, 0)
+	}
+	if record.PendingKey != "" {
+		info.PendingAt = time.Unix(record.PendingAt, 0)
+	}
+	if record.PendingRoutingAt != 0 {
+		info.PendingRoutingAt = time.Unix(record.PendingRoutingAt, 0)
+	}
+
+	return info
+}
+
 // NewDynamoDBResolver returns a new resolver based on DynamoDB
 func NewDynamoDBResolver(client dynamodbiface.DynamoDBAPI, tableName, historyTableName string) Repository {
 	return &dynamoDbResolver{
@@ -73,6 +132,12 @@ func NewDynamoDBResolver(client dynamodbiface.DynamoDBAPI, tableName, historyTab
 func (r *dynamoDbResolver) Update(info *ResolveInfoType, routing string, publicKey *bmcrypto.PubKey, redirHash string) (bool, error) {
 	serial := strconv.FormatUint(uint64(time.Now().UnixNano()), 10)
 
+	// The redirect target must be removed instead of emptied, otherwise it would end up in the index
+	expr := "SET routing=:s, public_key=:pk, sn=:sn, redir_hash=:rh REMOVE redirect_target"
+	if redirHash != "" {
+		expr = "SET routing=:s, public_key=:pk, sn=:sn, redir_hash=:rh, redirect_target=:rh"
+	}
+
 	input := &dynamodb.UpdateItemInput{
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":rh":  {S: aws.String(redirHash)},
@@ -82,7 +147,7 @@ func (r *dynamoDbResolver) Update(info *ResolveInfoType, routing string, publicK
 			":csn": {N: aws.String(strconv.FormatUint(info.Serial, 10))},
 		},
 		TableName:           aws.String(r.TableName),
-		UpdateExpression:    aws.String("SET routing=:s, public_key=:pk, sn=:sn, redir_hash=:rh"),
+		UpdateExpression:    aws.String(expr),
 		ConditionExpression: aws.String("sn = :csn"),
 		Key: map[string]*dynamodb.AttributeValue{
 			"hash": {S: aws.String(info.Hash)},
@@ -107,12 +172,13 @@ func (r *dynamoDbResolver) Update(info *ResolveInfoType, routing string, publicK
 
 func (r *dynamoDbResolver) Create(hash, routing string, publicKey *bmcrypto.PubKey, proof, redirHash string) (bool, error) {
 	record := recordType{
-		Hash:      hash,
-		RedirHash: redirHash,
-		Routing:   routing,
-		PublicKey: publicKey.String(),
-		Proof:     proof,
-		Serial:    uint64(TimeNow().UnixNano()),
+		Hash:           hash,
+		RedirHash:      redirHash,
+		Routing:        routing,
+		PublicKey:      publicKey.String(),
+		Proof:          proof,
+		Serial:         uint64(TimeNow().UnixNano()),
+		RedirectTarget: redirHash,
 	}
 
 	av, err := dynamodbattribute.MarshalMap(record)
@@ -162,16 +228,66 @@ func (r *dynamoDbResolver) Get(hash string) (*ResolveInfoType, error) {
 		return nil, ErrNotFound
 	}
 
-	return &ResolveInfoType{
-		Hash:      record.Hash,
-		RedirHash: record.RedirHash,
-		RoutingID: record.Routing,
-		PubKey:    record.PublicKey,
-		Proof:     record.Proof,
-		Serial:    record.Serial,
-		Deleted:   record.Deleted,
-		DeletedAt: time.Unix(int64(record.DeletedAt), 0),
-	}, nil
+	return record.toInfo(), nil
+}
+
+func (r *dynamoDbResolver) GetMany(hashes []string) (map[string]*ResolveInfoType, error) {
+	infos := make(map[string]*ResolveInfoType)
+
+	// BatchGetItem does not allow duplicate keys
+	var unique []string
+	seen := make(map[string]bool)
+	for _, hash := range hashes {
+		if !seen[hash] {
+			seen[hash] = true
+			unique = append(unique, hash)
+		}
+	}
+
+	// A single BatchGetItem call can fetch at most maxBatchGetItems keys
+	for start := 0; start < len(unique); start += maxBatchGetItems {
+		end := start + maxBatchGetItems
+		if end > len(unique) {
+			end = len(unique)
+		}
+
+		keys := make([]map[string]*dynamodb.AttributeValue, 0, end-start)
+		for _, hash := range unique[start:end] {
+			keys = append(keys, map[string]*dynamodb.AttributeValue{
+				"hash": {S: aws.String(hash)},
+			})
+		}
+
+		request := map[string]*dynamodb.KeysAndAttributes{
+			r.TableName: {Keys: keys},
+		}
+
+		// Keys that could not be processed (due to throttling or size limits) are returned and must be requested again
+		for len(request) > 0 {
+			result, err := r.Dyna.BatchGetItem(&dynamodb.BatchGetItemInput{
+				RequestItems: request,
+			})
+			if err != nil {
+				log.Print(err)
+				return nil, err
+			}
+
+			for _, item := range result.Responses[r.TableName] {
+				record := recordType{}
+				err = dynamodbattribute.UnmarshalMap(item, &record)
+				if err != nil {
+					log.Print(err)
+					continue
+				}
+
+				infos[record.Hash] = record.toInfo()
+			}
+
+			request = result.UnprocessedKeys
+		}
+	}
+
+	return infos, nil
 }
 
 func (r *dynamoDbResolver) Delete(hash string) (bool, error) {
@@ -285,6 +401,185 @@ func (r *dynamoDbResolver) SetKeyStatus(hash string, fingerprint string, status
 	return nil
 }
 
+func (r *dynamoDbResolver) SetRecoveryKey(hash string, recoveryKey *bmcrypto.PubKey) error {
+	return r.updateItem(hash, "SET recovery_key=:rk", map[string]*dynamodb.AttributeValue{
+		":rk": {S: aws.String(recoveryKey.String())},
+	})
+}
+
+func (r *dynamoDbResolver) RequestKeyReset(hash string, publicKey *bmcrypto.PubKey, resetAt time.Time) error {
+	return r.updateItem(hash, "SET reset_key=:rk, reset_at=:ra", map[string]*dynamodb.AttributeValue{
+		":rk": {S: aws.String(publicKey.String())},
+		":ra": {N: aws.String(strconv.FormatInt(resetAt.Unix(), 10))},
+	})
+}
+
+func (r *dynamoDbResolver) CancelKeyReset(hash string) error {
+	return r.updateItem(hash, "REMOVE reset_key, reset_at", nil)
+}
+
+func (r *dynamoDbResolver) CompleteKeyReset(info *ResolveInfoType) (bool, error) {
+	return r.replaceKey(info, info.ResetKey, "reset_key", "reset_at")
+}
+
+func (r *dynamoDbResolver) SetProtected(hash string, protected bool) error {
+	if !protected {
+		return r.updateItem(hash, "REMOVE protected", nil)
+	}
+
+	return r.updateItem(hash, "SET protected=:p", map[string]*dynamodb.AttributeValue{
+		":p": {BOOL: aws.Bool(true)},
+	})
+}
+
+func (r *dynamoDbResolver) SetPendingKey(hash string, publicKey *bmcrypto.PubKey, activateAt time.Time) error {
+	return r.updateItem(hash, "SET pending_key=:pk, pending_at=:pa", map[string]*dynamodb.AttributeValue{
+		":pk": {S: aws.String(publicKey.String())},
+		":pa": {N: aws.String(strconv.FormatInt(activateAt.Unix(), 10))},
+	})
+}
+
+func (r *dynamoDbResolver) CancelPendingKey(hash string) error {
+	return r.updateItem(hash, "REMOVE pending_key, pending_at", nil)
+}
+
+func (r *dynamoDbResolver) ActivatePendingKey(info *ResolveInfoType) (bool, error) {
+	return r.replaceKey(info, info.PendingKey, "pending_key", "pending_at")
+}
+
+func (r *dynamoDbResolver) SetPendingRouting(hash string, routing, redirHash string, activateAt time.Time) error {
+	return r.updateItem(hash, "SET pending_routing=:pr, pending_redir_hash=:prh, pending_routing_at=:pra", map[string]*dynamodb.AttributeValue{
+		":pr":  {S: aws.String(routing)},
+		":prh": {S: aws.String(redirHash)},
+		":pra": {N: aws.String(strconv.FormatInt(activateAt.Unix(), 10))},
+	})
+}
+
+func (r *dynamoDbResolver) CancelPendingRouting(hash string) error {
+	return r.updateItem(hash, "REMOVE pending_routing, pending_redir_hash, pending_routing_at", nil)
+}
+
+func (r *dynamoDbResolver) SetDelegates(hash string, delegates []DelegateType) error {
+	if len(delegates) == 0 {
+		return r.updateItem(hash, "REMOVE delegates", nil)
+	}
+
+	av, err := dynamodbattribute.Marshal(delegates)
+	if err != nil {
+		return err
+	}
+
+	return r.updateItem(hash, "SET delegates=:d", map[string]*dynamodb.AttributeValue{
+		":d": av,
+	})
+}
+
+// replaceKey moves the key found in keyAttr to the public key, and removes both keyAttr and atAttr from the record
+func (r *dynamoDbResolver) SetOrganisation(hash string, orgHash string) error {
+	return r.updateItem(hash, "SET org_hash=:o", map[string]*dynamodb.AttributeValue{
+		":o": {S: aws.String(orgHash)},
+	})
+}
+
+func (r *dynamoDbResolver) GetRedirectedBy(hash string) ([]string, error) {
+	var hashes []string
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.TableName),
+		IndexName:              aws.String(redirectIndexName),
+		KeyConditionExpression: aws.String("redirect_target = :h"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":h": {S: aws.String(hash)},
+		},
+	}
+
+	for {
+		result, err := r.Dyna.Query(input)
+		if err != nil {
+			log.Print(err)
+			return nil, err
+		}
+
+		for _, item := range result.Items {
+			if item["hash"] != nil && item["hash"].S != nil {
+				hashes = append(hashes, *item["hash"].S)
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return hashes, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+func (r *dynamoDbResolver) replaceKey(info *ResolveInfoType, key, keyAttr, atAttr string) (bool, error) {
+	if key == "" {
+		return false, ErrCannotUpdate
+	}
+
+	pk, err := bmcrypto.NewPubKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	serial := strconv.FormatUint(uint64(TimeNow().UnixNano()), 10)
+
+	input := &dynamodb.UpdateItemInput{
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk":  {S: aws.String(pk.String())},
+			":sn":  {N: aws.String(serial)},
+			":csn": {N: aws.String(strconv.FormatUint(info.Serial, 10))},
+		},
+		TableName:           aws.String(r.TableName),
+		UpdateExpression:    aws.String("SET public_key=:pk, sn=:sn REMOVE " + keyAttr + ", " + atAttr),
+		ConditionExpression: aws.String("sn = :csn AND " + keyAttr + " = :pk"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"hash": {S: aws.String(info.Hash)},
+		},
+	}
+
+	// Update key history
+	_, err = r.updateKeyHistory(info.Hash, pk.Fingerprint(), KSNormal)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = r.Dyna.UpdateItem(input)
+	if err != nil {
+		log.Print(err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// updateItem runs the update expression on an existing address record
+func (r *dynamoDbResolver) updateItem(hash, expr string, values map[string]*dynamodb.AttributeValue) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.TableName),
+		UpdateExpression:    aws.String(expr),
+		ConditionExpression: aws.String("attribute_exists(#h)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#h": aws.String("hash"),
+		},
+		Key: map[string]*dynamodb.AttributeValue{
+			"hash": {S: aws.String(hash)},
+		},
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+
+	_, err := r.Dyna.UpdateItem(input)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
 func (r *dynamoDbResolver) updateKeyHistory(hash, fingerprint string, status KeyStatus) (bool, error) {
 	av, err := dynamodbattribute.MarshalMap(historyRecordType{
 		HashFingerprint: hash + fingerprint,
@@ -86,6 +86,53 @@ func TestGet(t *testing.T) {
 	assert.Nil(t, ri)
 }
 
+func TestGetMany(t *testing.T) {
+	var client dynamodbiface.DynamoDBAPI
+	client, mock = dynamock.New()
+	resolver := NewDynamoDBResolver(client, "mock_address_table", "mock_history_table")
+
+	h1 := "cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2"
+	h2 := "000000000000000000000000000000000000000009c5adaad23fb17572ca1ea2"
+
+	key1 := map[string]*dynamodb.AttributeValue{"hash": {S: aws.String(h1)}}
+	key2 := map[string]*dynamodb.AttributeValue{"hash": {S: aws.String(h2)}}
+
+	// Duplicate keys are only requested once, and unprocessed keys are requested again
+	mock.ExpectBatchGetItem().WithRequest(map[string]*dynamodb.KeysAndAttributes{
+		"mock_address_table": {Keys: []map[string]*dynamodb.AttributeValue{key1, key2}},
+	}).WillReturns(dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]*dynamodb.AttributeValue{
+			"mock_address_table": {
+				{
+					"hash":       {S: aws.String(h1)},
+					"routing":    {S: aws.String("12345678")},
+					"public_key": {S: aws.String("pubkey")},
+					"proof":      {S: aws.String("proof")},
+					"sn":         {N: aws.String("42")},
+				},
+			},
+		},
+		UnprocessedKeys: map[string]*dynamodb.KeysAndAttributes{
+			"mock_address_table": {Keys: []map[string]*dynamodb.AttributeValue{key2}},
+		},
+	})
+	mock.ExpectBatchGetItem().WithRequest(map[string]*dynamodb.KeysAndAttributes{
+		"mock_address_table": {Keys: []map[string]*dynamodb.AttributeValue{key2}},
+	}).WillReturns(dynamodb.BatchGetItemOutput{})
+
+	infos, err := resolver.GetMany([]string{h1, h2, h1})
+	assert.NoError(t, err)
+	assert.Len(t, infos, 1)
+	assert.Equal(t, "12345678", infos[h1].RoutingID)
+	assert.Equal(t, "pubkey", infos[h1].PubKey)
+	assert.Equal(t, uint64(42), infos[h1].Serial)
+
+	// No expectations left
+	infos, err = resolver.GetMany([]string{h1})
+	assert.Error(t, err)
+	assert.Nil(t, infos)
+}
+
 func TestDelete(t *testing.T) {
 	var client dynamodbiface.DynamoDBAPI
 	client, mock = dynamock.New()
@@ -155,14 +202,15 @@ func TestCreateWithRedir(t *testing.T) {
 	mock.ExpectPutItem().ToTable("mock_history_table").WithItems(historyItems).WillReturns(dynamodb.PutItemOutput{})
 
 	items := map[string]*dynamodb.AttributeValue{
-		"hash":       {S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")},
-		"proof":      {S: aws.String("proof")},
-		"public_key": {S: aws.String("ed25519 MCowBQYDK2VwAyEAS2/hs2jf0QJgpuNklMnN/A7EHj26DDpRfvcZyettOjU=")},
-		"routing":    {S: aws.String("12345678")},
-		"sn":         {N: aws.String("1273494896000000000")},
-		"redir_hash": {S: aws.String("foobar")},
-		"deleted_at": {N: aws.String("0")},
-		"deleted":    {BOOL: aws.Bool(false)},
+		"hash":            {S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")},
+		"proof":           {S: aws.String("proof")},
+		"public_key":      {S: aws.String("ed25519 MCowBQYDK2VwAyEAS2/hs2jf0QJgpuNklMnN/A7EHj26DDpRfvcZyettOjU=")},
+		"routing":         {S: aws.String("12345678")},
+		"sn":              {N: aws.String("1273494896000000000")},
+		"redir_hash":      {S: aws.String("foobar")},
+		"redirect_target": {S: aws.String("foobar")},
+		"deleted_at":      {N: aws.String("0")},
+		"deleted":         {BOOL: aws.Bool(false)},
 	}
 	mock.ExpectPutItem().ToTable("mock_address_table").WithItems(items).WillReturns(dynamodb.PutItemOutput{})
 
@@ -204,6 +252,155 @@ func TestUpdate(t *testing.T) {
 	assert.True(t, ok)
 }
 
+func TestKeyReset(t *testing.T) {
+	var client dynamodbiface.DynamoDBAPI
+	client, mock = dynamock.New()
+	resolver := NewDynamoDBResolver(client, "mock_address_table", "mock_history_table")
+
+	pubkey, _ := bmcrypto.NewPubKey("ed25519 MCowBQYDK2VwAyEAS2/hs2jf0QJgpuNklMnN/A7EHj26DDpRfvcZyettOjU=")
+
+	expectKey := map[string]*dynamodb.AttributeValue{
+		"hash": {
+			S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2"),
+		},
+	}
+
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	err := resolver.SetRecoveryKey("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", pubkey)
+	assert.NoError(t, err)
+
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	err = resolver.RequestKeyReset("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", pubkey, time.Now())
+	assert.NoError(t, err)
+
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	err = resolver.CancelKeyReset("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")
+	assert.NoError(t, err)
+
+	info := &ResolveInfoType{
+		Hash:     "cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2",
+		PubKey:   "ed25519 MCowBQYDK2VwAyEAbRpv3o6/dvhcYwZTHM/+q8FPbz+U/qgsXDxISQv5Ab8=",
+		ResetKey: pubkey.String(),
+		Serial:   1273494896000000000,
+	}
+
+	expectedItems := map[string]*dynamodb.AttributeValue{
+		"hash_fingerprint": {S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2" + pubkey.Fingerprint())},
+		"status":           {N: aws.String(fmt.Sprintf("%d", KSNormal))},
+	}
+	mock.ExpectPutItem().ToTable("mock_history_table").WithItems(expectedItems)
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	ok, err := resolver.CompleteKeyReset(info)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// No pending reset
+	info.ResetKey = ""
+	ok, err = resolver.CompleteKeyReset(info)
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestPendingKey(t *testing.T) {
+	var client dynamodbiface.DynamoDBAPI
+	client, mock = dynamock.New()
+	resolver := NewDynamoDBResolver(client, "mock_address_table", "mock_history_table")
+
+	pubkey, _ := bmcrypto.NewPubKey("ed25519 MCowBQYDK2VwAyEAS2/hs2jf0QJgpuNklMnN/A7EHj26DDpRfvcZyettOjU=")
+
+	expectKey := map[string]*dynamodb.AttributeValue{
+		"hash": {
+			S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2"),
+		},
+	}
+
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	err := resolver.SetProtected("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", true)
+	assert.NoError(t, err)
+
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	err = resolver.SetPendingKey("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", pubkey, time.Now())
+	assert.NoError(t, err)
+
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	err = resolver.CancelPendingKey("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")
+	assert.NoError(t, err)
+
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	err = resolver.SetPendingRouting("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", "87654321", "", time.Now())
+	assert.NoError(t, err)
+
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	err = resolver.CancelPendingRouting("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")
+	assert.NoError(t, err)
+
+	info := &ResolveInfoType{
+		Hash:       "cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2",
+		PubKey:     "ed25519 MCowBQYDK2VwAyEAbRpv3o6/dvhcYwZTHM/+q8FPbz+U/qgsXDxISQv5Ab8=",
+		PendingKey: pubkey.String(),
+		Serial:     1273494896000000000,
+	}
+
+	expectedItems := map[string]*dynamodb.AttributeValue{
+		"hash_fingerprint": {S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2" + pubkey.Fingerprint())},
+		"status":           {N: aws.String(fmt.Sprintf("%d", KSNormal))},
+	}
+	mock.ExpectPutItem().ToTable("mock_history_table").WithItems(expectedItems)
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	ok, err := resolver.ActivatePendingKey(info)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// No pending key
+	info.PendingKey = ""
+	ok, err = resolver.ActivatePendingKey(info)
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestDelegates(t *testing.T) {
+	var client dynamodbiface.DynamoDBAPI
+	client, mock = dynamock.New()
+	resolver := NewDynamoDBResolver(client, "mock_address_table", "mock_history_table")
+
+	expectKey := map[string]*dynamodb.AttributeValue{
+		"hash": {
+			S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2"),
+		},
+	}
+
+	delegates := []DelegateType{
+		{PublicKey: "ed25519 MCowBQYDK2VwAyEAS2/hs2jf0QJgpuNklMnN/A7EHj26DDpRfvcZyettOjU=", Scopes: []string{ScopeRoutingOnly}},
+	}
+
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	err := resolver.SetDelegates("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", delegates)
+	assert.NoError(t, err)
+
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	err = resolver.SetDelegates("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", nil)
+	assert.NoError(t, err)
+
+	result := dynamodb.GetItemOutput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"hash":       {S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")},
+			"public_key": {S: aws.String("ed25519 MCowBQYDK2VwAyEAbRpv3o6/dvhcYwZTHM/+q8FPbz+U/qgsXDxISQv5Ab8=")},
+			"sn":         {N: aws.String("1")},
+			"delegates": {L: []*dynamodb.AttributeValue{
+				{M: map[string]*dynamodb.AttributeValue{
+					"public_key": {S: aws.String(delegates[0].PublicKey)},
+					"scopes":     {L: []*dynamodb.AttributeValue{{S: aws.String(ScopeRoutingOnly)}}},
+				}},
+			}},
+		},
+	}
+	mock.ExpectGetItem().ToTable("mock_address_table").WithKeys(expectKey).WillReturns(result)
+
+	info, err := resolver.Get("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")
+	assert.NoError(t, err)
+	assert.Equal(t, delegates, info.Delegates)
+}
+
 func TestHistory(t *testing.T) {
 	var client dynamodbiface.DynamoDBAPI
 	client, mock = dynamock.New()
@@ -255,3 +452,61 @@ func TestResolver(t *testing.T) {
 	r := GetResolveRepository()
 	assert.NotNil(t, r)
 }
+
+func TestOrganisation(t *testing.T) {
+	var client dynamodbiface.DynamoDBAPI
+	client, mock = dynamock.New()
+	resolver := NewDynamoDBResolver(client, "mock_address_table", "mock_history_table")
+
+	expectKey := map[string]*dynamodb.AttributeValue{
+		"hash": {
+			S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2"),
+		},
+	}
+
+	mock.ExpectUpdateItem().ToTable("mock_address_table").WithKeys(expectKey)
+	err := resolver.SetOrganisation("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", "org")
+	assert.NoError(t, err)
+
+	result := dynamodb.GetItemOutput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"hash":       {S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")},
+			"public_key": {S: aws.String("ed25519 MCowBQYDK2VwAyEAbRpv3o6/dvhcYwZTHM/+q8FPbz+U/qgsXDxISQv5Ab8=")},
+			"sn":         {N: aws.String("1")},
+			"org_hash":   {S: aws.String("org")},
+		},
+	}
+	mock.ExpectGetItem().ToTable("mock_address_table").WithKeys(expectKey).WillReturns(result)
+
+	info, err := resolver.Get("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")
+	assert.NoError(t, err)
+	assert.Equal(t, "org", info.OrgHash)
+}
+
+func TestGetRedirectedBy(t *testing.T) {
+	var client dynamodbiface.DynamoDBAPI
+	client, mock = dynamock.New()
+	resolver := NewDynamoDBResolver(client, "mock_address_table", "mock_history_table")
+
+	mock.ExpectQuery().Table("mock_address_table").WillReturns(dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			{"hash": {S: aws.String("111")}},
+		},
+		LastEvaluatedKey: map[string]*dynamodb.AttributeValue{
+			"hash": {S: aws.String("111")},
+		},
+	})
+	mock.ExpectQuery().Table("mock_address_table").WillReturns(dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			{"hash": {S: aws.String("222")}},
+		},
+	})
+
+	hashes, err := resolver.GetRedirectedBy("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"111", "222"}, hashes)
+
+	hashes, err = resolver.GetRedirectedBy("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")
+	assert.Error(t, err)
+	assert.Nil(t, hashes)
+}
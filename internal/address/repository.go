@@ -39,6 +39,22 @@ type ResolveInfoType struct {
 	Serial    uint64
 	Deleted   bool
 	DeletedAt time.Time
+
+	RecoveryKey string    // Optional key that can reset the public key
+	ResetKey    string    // Pending public key set through the recovery key
+	ResetAt     time.Time // Time from which the pending key reset can be completed
+
+	Protected  bool      // Key and routing changes are delayed and can be cancelled by the current key
+	PendingKey string    // Public key that will replace the current key in protected mode
+	PendingAt  time.Time // Time from which the pending key becomes active
+
+	PendingRouting   string    // Routing ID that will replace the current routing in protected mode
+	PendingRedirHash string    // Redirect hash that will replace the current redirect in protected mode
+	PendingRoutingAt time.Time // Time from which the pending routing becomes active, zero when nothing is pending
+
+	Delegates []DelegateType // Keys that are allowed to update parts of the address
+
+	OrgHash string // Hash of the organisation when this is an organisational address
 }
 
 type KeyStatus int
@@ -71,6 +87,8 @@ func StringToKeyStatus(s string) (KeyStatus, error) {
 type Repository interface {
 	// Retrieve from hash
 	Get(hash string) (*ResolveInfoType, error)
+	// Retrieve multiple records at once. Hashes that are not found are not present in the result
+	GetMany(hashes []string) (map[string]*ResolveInfoType, error)
 	// Create a new entry
 	Create(hash, routing string, publicKey *bmcrypto.PubKey, proof string, redirHash string) (bool, error)
 	// Update an existing entry
@@ -86,27 +104,61 @@ type Repository interface {
 	GetKeyStatus(hash string, fingerprint string) (KeyStatus, error)
 	// Set the given key status
 	SetKeyStatus(hash string, fingerprint string, status KeyStatus) error
+
+	// Set the recovery key that is allowed to reset the public key
+	SetRecoveryKey(hash string, recoveryKey *bmcrypto.PubKey) error
+	// Start a key reset that can be completed from resetAt
+	RequestKeyReset(hash string, publicKey *bmcrypto.PubKey, resetAt time.Time) error
+	// Cancel a pending key reset
+	CancelKeyReset(hash string) error
+	// Replace the public key with the pending reset key
+	CompleteKeyReset(info *ResolveInfoType) (bool, error)
+
+	// Enable or disable protected mode
+	SetProtected(hash string, protected bool) error
+	// Store a key change that becomes active from activateAt
+	SetPendingKey(hash string, publicKey *bmcrypto.PubKey, activateAt time.Time) error
+	// Cancel a pending key change
+	CancelPendingKey(hash string) error
+	// Replace the public key with the pending key
+	ActivatePendingKey(info *ResolveInfoType) (bool, error)
+	// Store a routing and redirect change that becomes active from activateAt
+	SetPendingRouting(hash string, routing, redirHash string, activateAt time.Time) error
+	// Cancel a pending routing change
+	CancelPendingRouting(hash string) error
+
+	// Replace the delegates of the address
+	SetDelegates(hash string, delegates []DelegateType) error
+
+	// Set the organisation the address belongs to
+	SetOrganisation(hash string, orgHash string) error
+
+	// Retrieve the hashes of the addresses that redirect to this hash (including soft-deleted addresses)
+	GetRedirectedBy(hash string) ([]string, error)
 }
 
 var resolver Repository
 
-// GetResolveRepository returns a new repository based on DynamoDB
+// GetResolveRepository returns a new repository based on DynamoDB. Every change made through the repository is
+// recorded in the change feed.
 func GetResolveRepository() Repository {
-	if resolver != nil {
-		return resolver
+	if resolver == nil {
+		resolver = newResolveRepository()
 	}
 
+	return withChangeFeed(resolver)
+}
+
+func newResolveRepository() Repository {
 	if os.Getenv("USE_BOLT") == "1" {
-		resolver = NewBoltResolver()
-		return resolver
+		return NewBoltResolver()
 	}
 
 	sess := session.Must(session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable,
 	}))
 
-	resolver = NewDynamoDBResolver(dynamodb.New(sess), os.Getenv("ADDRESS_TABLE_NAME"), os.Getenv("HISTORY_TABLE_NAME"))
-	return resolver
+	return NewDynamoDBResolver(dynamodb.New(sess), os.Getenv("ADDRESS_TABLE_NAME"), os.Getenv("HISTORY_TABLE_NAME"))
 }
 
 // Sets the default repository for resolving. Can be used to override for mocking/testing purposes
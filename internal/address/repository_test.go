@@ -21,6 +21,7 @@ package address
 
 import (
 	"os"
+	"time"
 
 	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
 	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
@@ -36,7 +37,8 @@ func TestDynamoRepo(t *testing.T) {
 	SetDefaultRepository(nil)
 
 	r := GetResolveRepository()
-	assert.IsType(t, r, NewDynamoDBResolver(nil, "", ""))
+	assert.IsType(t, &changeRecorder{}, r)
+	assert.IsType(t, r.(*changeRecorder).Repository, NewDynamoDBResolver(nil, "", ""))
 }
 
 func TestBoltResolverRepo(t *testing.T) {
@@ -45,7 +47,8 @@ func TestBoltResolverRepo(t *testing.T) {
 	SetDefaultRepository(nil)
 
 	r := GetResolveRepository()
-	assert.IsType(t, r, NewBoltResolver())
+	assert.IsType(t, &changeRecorder{}, r)
+	assert.IsType(t, r.(*changeRecorder).Repository, NewBoltResolver())
 }
 
 func runRepositoryHistoryCheck(t *testing.T, db Repository) {
@@ -328,3 +331,318 @@ func runRepositoryDeletionTests(t *testing.T, db Repository) {
 	assert.Error(t, err)
 	assert.Nil(t, info)
 }
+
+func runRepositoryKeyResetTests(t *testing.T, db Repository) {
+	h1 := hash.Hash("address1!")
+
+	_, pub1, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	_, pub2, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+	_, pub3, _ := testing2.ReadTestKey("../../testdata/key-3.json")
+
+	ok, err := db.Create(h1.String(), "12345678", pub1, "proof", "")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Cannot set recovery key on unknown entry
+	err = db.SetRecoveryKey("unknown", pub2)
+	assert.Error(t, err)
+
+	err = db.SetRecoveryKey(h1.String(), pub2)
+	assert.NoError(t, err)
+
+	info, err := db.Get(h1.String())
+	assert.NoError(t, err)
+	assert.Equal(t, pub2.String(), info.RecoveryKey)
+	assert.Equal(t, "", info.ResetKey)
+
+	// Cannot complete without a pending reset
+	ok, err = db.CompleteKeyReset(info)
+	assert.Error(t, err)
+	assert.False(t, ok)
+
+	// Request and cancel a reset
+	resetAt := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+	err = db.RequestKeyReset(h1.String(), pub3, resetAt)
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, pub3.String(), info.ResetKey)
+	assert.True(t, resetAt.Equal(info.ResetAt))
+
+	err = db.CancelKeyReset(h1.String())
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, "", info.ResetKey)
+	assert.True(t, info.ResetAt.IsZero())
+	assert.Equal(t, pub1.String(), info.PubKey)
+
+	// Request and complete a reset
+	err = db.RequestKeyReset(h1.String(), pub3, resetAt)
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	ok, err = db.CompleteKeyReset(info)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, pub3.String(), info.PubKey)
+	assert.Equal(t, pub2.String(), info.RecoveryKey)
+	assert.Equal(t, "", info.ResetKey)
+
+	res, err := db.GetKeyStatus(h1.String(), pub3.Fingerprint())
+	assert.NoError(t, err)
+	assert.Equal(t, KSNormal, res)
+}
+
+func runRepositoryPendingKeyTests(t *testing.T, db Repository) {
+	h1 := hash.Hash("address1!")
+
+	_, pub1, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	_, pub2, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+
+	ok, err := db.Create(h1.String(), "12345678", pub1, "proof", "")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	info, _ := db.Get(h1.String())
+	assert.False(t, info.Protected)
+
+	err = db.SetProtected("unknown", true)
+	assert.Error(t, err)
+
+	err = db.SetProtected(h1.String(), true)
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.True(t, info.Protected)
+	assert.Equal(t, "", info.PendingKey)
+
+	// Cannot activate without a pending key
+	ok, err = db.ActivatePendingKey(info)
+	assert.Error(t, err)
+	assert.False(t, ok)
+
+	// Set and cancel a pending key
+	activateAt := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+	err = db.SetPendingKey(h1.String(), pub2, activateAt)
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, pub2.String(), info.PendingKey)
+	assert.True(t, activateAt.Equal(info.PendingAt))
+	assert.Equal(t, pub1.String(), info.PubKey)
+
+	err = db.CancelPendingKey(h1.String())
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, "", info.PendingKey)
+	assert.True(t, info.PendingAt.IsZero())
+
+	// Set and activate a pending key
+	err = db.SetPendingKey(h1.String(), pub2, activateAt)
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	ok, err = db.ActivatePendingKey(info)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, pub2.String(), info.PubKey)
+	assert.Equal(t, "", info.PendingKey)
+	assert.True(t, info.Protected)
+
+	res, err := db.GetKeyStatus(h1.String(), pub2.Fingerprint())
+	assert.NoError(t, err)
+	assert.Equal(t, KSNormal, res)
+
+	// Set and cancel a pending routing
+	assert.True(t, info.PendingRoutingAt.IsZero())
+	err = db.SetPendingRouting(h1.String(), "87654321", "", activateAt)
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, "87654321", info.PendingRouting)
+	assert.Equal(t, "", info.PendingRedirHash)
+	assert.True(t, activateAt.Equal(info.PendingRoutingAt))
+	assert.Equal(t, "12345678", info.RoutingID)
+
+	err = db.CancelPendingRouting(h1.String())
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, "", info.PendingRouting)
+	assert.True(t, info.PendingRoutingAt.IsZero())
+}
+
+func runRepositoryDelegateTests(t *testing.T, db Repository) {
+	h1 := hash.Hash("address1!")
+
+	_, pub1, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	_, pub2, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+
+	ok, err := db.Create(h1.String(), "12345678", pub1, "proof", "")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	info, _ := db.Get(h1.String())
+	assert.Len(t, info.Delegates, 0)
+
+	delegates := []DelegateType{
+		{PublicKey: pub2.String(), Scopes: []string{ScopeRoutingOnly}},
+	}
+
+	err = db.SetDelegates("unknown", delegates)
+	assert.Error(t, err)
+
+	err = db.SetDelegates(h1.String(), delegates)
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, delegates, info.Delegates)
+	assert.True(t, info.Delegates[0].HasScope(ScopeRoutingOnly))
+
+	// Delegates are kept on updates
+	ok, err = db.Update(info, "87654321", pub1, "")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, delegates, info.Delegates)
+
+	err = db.SetDelegates(h1.String(), nil)
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.Len(t, info.Delegates, 0)
+}
+
+func runRepositoryGetManyTests(t *testing.T, db Repository) {
+	h1 := hash.Hash("batch1!")
+	h2 := hash.Hash("batch2!")
+	h3 := hash.Hash("batch3!")
+
+	_, pub1, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	_, pub2, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+
+	infos, err := db.GetMany([]string{h1.String(), h2.String()})
+	assert.NoError(t, err)
+	assert.Len(t, infos, 0)
+
+	ok, err := db.Create(h1.String(), "12345678", pub1, "proof", "")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = db.Create(h2.String(), "87654321", pub2, "proof", h1.String())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	infos, err = db.GetMany(nil)
+	assert.NoError(t, err)
+	assert.Len(t, infos, 0)
+
+	infos, err = db.GetMany([]string{h1.String(), h2.String(), h3.String(), h1.String()})
+	assert.NoError(t, err)
+	assert.Len(t, infos, 2)
+	assert.Equal(t, "12345678", infos[h1.String()].RoutingID)
+	assert.Equal(t, pub1.String(), infos[h1.String()].PubKey)
+	assert.Equal(t, "87654321", infos[h2.String()].RoutingID)
+	assert.Equal(t, h1.String(), infos[h2.String()].RedirHash)
+	assert.Nil(t, infos[h3.String()])
+}
+
+func runRepositoryOrganisationTests(t *testing.T, db Repository) {
+	h1 := hash.Hash("orgaddress1!")
+
+	_, pub1, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+
+	ok, err := db.Create(h1.String(), "", pub1, "proof", "redir")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	info, _ := db.Get(h1.String())
+	assert.Equal(t, "", info.OrgHash)
+
+	err = db.SetOrganisation("unknown", "org")
+	assert.Error(t, err)
+
+	err = db.SetOrganisation(h1.String(), "org")
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, "org", info.OrgHash)
+
+	// Organisation is kept on updates
+	ok, err = db.Update(info, "", pub1, "redir2")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, "org", info.OrgHash)
+
+	infos, _ := db.GetMany([]string{h1.String()})
+	assert.Equal(t, "org", infos[h1.String()].OrgHash)
+}
+
+func runRepositoryRedirectTests(t *testing.T, db Repository) {
+	target1 := hash.Hash("target1!")
+	target2 := hash.Hash("target2!")
+	h1 := hash.Hash("redirect1!")
+	h2 := hash.Hash("redirect2!")
+
+	_, pub1, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+
+	hashes, err := db.GetRedirectedBy(target1.String())
+	assert.NoError(t, err)
+	assert.Len(t, hashes, 0)
+
+	ok, err := db.Create(target1.String(), "12345678", pub1, "proof", "")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = db.Create(h1.String(), "", pub1, "proof", target1.String())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = db.Create(h2.String(), "", pub1, "proof", target1.String())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	hashes, err = db.GetRedirectedBy(target1.String())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{h1.String(), h2.String()}, hashes)
+
+	// Redirect moves to another target
+	info, _ := db.Get(h1.String())
+	ok, err = db.Update(info, "", pub1, target2.String())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	hashes, _ = db.GetRedirectedBy(target1.String())
+	assert.Equal(t, []string{h2.String()}, hashes)
+	hashes, _ = db.GetRedirectedBy(target2.String())
+	assert.Equal(t, []string{h1.String()}, hashes)
+
+	// Soft-deleted addresses are still in the index
+	ok, err = db.SoftDelete(h2.String())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	hashes, _ = db.GetRedirectedBy(target1.String())
+	assert.Equal(t, []string{h2.String()}, hashes)
+
+	// Removed redirection
+	info, _ = db.Get(h1.String())
+	ok, err = db.Update(info, "12345678", pub1, "")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	hashes, _ = db.GetRedirectedBy(target2.String())
+	assert.Len(t, hashes, 0)
+
+	// Deleted addresses are removed from the index
+	ok, err = db.Delete(h2.String())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	hashes, _ = db.GetRedirectedBy(target1.String())
+	assert.Len(t, hashes, 0)
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package address
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+)
+
+// GenerateRevocationCertificate generates a certificate that revokes the key with the given fingerprint on the
+// address. It should be generated up front with the current key, and kept offline until it is needed.
+func GenerateRevocationCertificate(addrHash hash.Hash, fingerprint string, pk bmcrypto.PrivKey) string {
+	h := sha256.Sum256([]byte("revoke" + addrHash.String() + fingerprint))
+	sig, _ := bmcrypto.Sign(pk, h[:])
+
+	s := addrHash.String() + ":" + fingerprint + ":" + string(sig)
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// VerifyRevocationCertificate returns true when the certificate revokes the given key on the address
+func VerifyRevocationCertificate(cert string, addrHash hash.Hash, key bmcrypto.PubKey) bool {
+	certData, err := base64.StdEncoding.DecodeString(cert)
+	if err != nil {
+		return false
+	}
+
+	parts := strings.SplitN(string(certData), ":", 3)
+	if len(parts) != 3 {
+		return false
+	}
+
+	// Check address
+	if addrHash.String() != parts[0] {
+		return false
+	}
+
+	// Check if the certificate is meant for this key
+	if key.Fingerprint() != parts[1] {
+		return false
+	}
+
+	// Check signature
+	h := sha256.Sum256([]byte("revoke" + parts[0] + parts[1]))
+	ok, err := bmcrypto.Verify(key, h[:], []byte(parts[2]))
+	if err != nil {
+		return false
+	}
+
+	return ok
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package address
+
+import (
+	"testing"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevocationCertificate(t *testing.T) {
+	privKey, pubKey, err := testing2.ReadTestKey("../../testdata/key-3.json")
+	assert.NoError(t, err)
+	privKey2, pubKey2, err := testing2.ReadTestKey("../../testdata/key-4.json")
+	assert.NoError(t, err)
+
+	addrHash := hash.New("jay@acme!")
+	addrHash2 := hash.New("jane@acme!")
+
+	cert := GenerateRevocationCertificate(addrHash, pubKey.Fingerprint(), *privKey)
+
+	// Verify correct
+	ok := VerifyRevocationCertificate(cert, addrHash, *pubKey)
+	assert.True(t, ok)
+
+	// Verify incorrect certificate
+	ok = VerifyRevocationCertificate("32532522632$$$$@@$$@", addrHash, *pubKey)
+	assert.False(t, ok)
+	ok = VerifyRevocationCertificate("d3Jvbmd0b2tlbjp3aXRod3JvbmdkYXRh", addrHash, *pubKey)
+	assert.False(t, ok)
+
+	// Verify incorrect address
+	ok = VerifyRevocationCertificate(cert, addrHash2, *pubKey)
+	assert.False(t, ok)
+
+	// Verify against another key
+	ok = VerifyRevocationCertificate(cert, addrHash, *pubKey2)
+	assert.False(t, ok)
+
+	// Certificate for the key, but signed by another key
+	cert = GenerateRevocationCertificate(addrHash, pubKey.Fingerprint(), *privKey2)
+	ok = VerifyRevocationCertificate(cert, addrHash, *pubKey)
+	assert.False(t, ok)
+}
@@ -20,6 +20,7 @@
 package address
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
@@ -60,7 +61,12 @@ func NewSqliteResolver(dsn string) Repository {
 		TimeNow: time.Now(),
 	}
 
-	_, err = db.conn.Exec("CREATE TABLE IF NOT EXISTS mock_address (hash VARCHAR(64) PRIMARY KEY, redir_hash VARCHAR(64), pubkey TEXT, routing_id VARCHAR(64), proof TEXT, serial INTEGER, deleted INTEGER, deleted_at INTEGER)")
+	_, err = db.conn.Exec("CREATE TABLE IF NOT EXISTS mock_address (hash VARCHAR(64) PRIMARY KEY, redir_hash VARCHAR(64), pubkey TEXT, routing_id VARCHAR(64), proof TEXT, serial INTEGER, deleted INTEGER, deleted_at INTEGER, recovery_key TEXT, reset_key TEXT, reset_at INTEGER, protected INTEGER, pending_key TEXT, pending_at INTEGER, delegates TEXT, org_hash VARCHAR(64), pending_routing VARCHAR(64), pending_redir_hash VARCHAR(64), pending_routing_at INTEGER)")
+	if err != nil {
+		return nil
+	}
+
+	_, err = db.conn.Exec("CREATE INDEX IF NOT EXISTS mock_address_redir_hash ON mock_address (redir_hash)")
 	if err != nil {
 		return nil
 	}
@@ -103,7 +109,7 @@ func (r *SqliteDbResolver) Create(hash, routing string, publicKey *bmcrypto.PubK
 
 	_ = r.updateKeyHistory(hash, publicKey.Fingerprint(), KSNormal)
 
-	res, err := r.conn.Exec("INSERT INTO mock_address VALUES (?, ?, ?, ?, ?, ?, ?, ?)", hash, redirHash, publicKey.String(), routing, proof, serial, 0, 0)
+	res, err := r.conn.Exec("INSERT INTO mock_address VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", hash, redirHash, publicKey.String(), routing, proof, serial, 0, 0, "", "", 0, 0, "", 0, "", "", "", "", 0)
 	if err != nil {
 		return false, err
 	}
@@ -118,7 +124,55 @@ func (r *SqliteDbResolver) Create(hash, routing string, publicKey *bmcrypto.PubK
 	return true, nil
 }
 
+const addressColumns = "hash, redir_hash, pubkey, routing_id, proof, serial, deleted, deleted_at, recovery_key, reset_key, reset_at, protected, pending_key, pending_at, delegates, org_hash, pending_routing, pending_redir_hash, pending_routing_at"
+
 func (r *SqliteDbResolver) Get(hash string) (*ResolveInfoType, error) {
+	info, err := scanRecord(r.conn.QueryRow("SELECT "+addressColumns+" FROM mock_address WHERE hash LIKE ?", hash))
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	return info, nil
+}
+
+func (r *SqliteDbResolver) GetMany(hashes []string) (map[string]*ResolveInfoType, error) {
+	infos := make(map[string]*ResolveInfoType)
+	if len(hashes) == 0 {
+		return infos, nil
+	}
+
+	args := make([]interface{}, len(hashes))
+	for i := range hashes {
+		args[i] = hashes[i]
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(hashes)), ", ")
+
+	rows, err := r.conn.Query("SELECT "+addressColumns+" FROM mock_address WHERE hash IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		info, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		infos[info.Hash] = info
+	}
+
+	return infos, rows.Err()
+}
+
+// rowScanner is implemented by both sql.Row and sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRecord converts a single row with the address columns into a record
+func scanRecord(row rowScanner) (*ResolveInfoType, error) {
 	var (
 		h   string
 		rh  string
@@ -128,23 +182,59 @@ func (r *SqliteDbResolver) Get(hash string) (*ResolveInfoType, error) {
 		sn  uint64
 		d   int
 		da  int64
+		rck string
+		rsk string
+		rsa int64
+		pr  int
+		pdk string
+		pda int64
+		dlg string
+		org string
+		prt string
+		prh string
+		pra int64
 	)
 
-	err := r.conn.QueryRow("SELECT hash, redir_hash, pubkey, routing_id, proof, serial, deleted, deleted_at FROM mock_address WHERE hash LIKE ?", hash).Scan(&h, &rh, &pk, &rt, &pow, &sn, &d, &da)
+	err := row.Scan(&h, &rh, &pk, &rt, &pow, &sn, &d, &da, &rck, &rsk, &rsa, &pr, &pdk, &pda, &dlg, &org, &prt, &prh, &pra)
 	if err != nil {
-		return nil, ErrNotFound
+		return nil, err
 	}
 
-	return &ResolveInfoType{
-		Hash:      h,
-		RedirHash: rh,
-		RoutingID: rt,
-		PubKey:    pk,
-		Proof:     pow,
-		Serial:    sn,
-		Deleted:   d == 1,
-		DeletedAt: time.Unix(da, 0),
-	}, nil
+	info := &ResolveInfoType{
+		Hash:        h,
+		RedirHash:   rh,
+		RoutingID:   rt,
+		PubKey:      pk,
+		Proof:       pow,
+		Serial:      sn,
+		Deleted:     d == 1,
+		DeletedAt:   time.Unix(da, 0),
+		RecoveryKey: rck,
+		ResetKey:    rsk,
+		Protected:   pr == 1,
+		PendingKey:  pdk,
+		OrgHash:     org,
+
+		PendingRouting:   prt,
+		PendingRedirHash: prh,
+	}
+	if rsk != "" {
+		info.ResetAt = time.Unix(rsa, 0)
+	}
+	if pdk != "" {
+		info.PendingAt = time.Unix(pda, 0)
+	}
+	if pra != 0 {
+		info.PendingRoutingAt = time.Unix(pra, 0)
+	}
+	if dlg != "" {
+		err = json.Unmarshal([]byte(dlg), &info.Delegates)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return info, nil
 }
 
 func (r *SqliteDbResolver) Delete(hash string) (bool, error) {
@@ -169,7 +259,7 @@ func (r *SqliteDbResolver) SoftDelete(hash string) (bool, error) {
 		return false, err
 	}
 
-	dt := time.Now().Unix()
+	dt := r.TimeNow.Unix()
 	res, err := st.Exec(dt, hash)
 	if err != nil {
 		return false, err
@@ -234,3 +324,135 @@ func (r *SqliteDbResolver) SetKeyStatus(hash string, fingerprint string, status
 
 	return r.updateKeyHistory(hash, fingerprint, status)
 }
+
+func (r *SqliteDbResolver) SetRecoveryKey(hash string, recoveryKey *bmcrypto.PubKey) error {
+	return r.exec("UPDATE mock_address SET recovery_key=? WHERE hash=?", recoveryKey.String(), hash)
+}
+
+func (r *SqliteDbResolver) RequestKeyReset(hash string, publicKey *bmcrypto.PubKey, resetAt time.Time) error {
+	return r.exec("UPDATE mock_address SET reset_key=?, reset_at=? WHERE hash=?", publicKey.String(), resetAt.Unix(), hash)
+}
+
+func (r *SqliteDbResolver) CancelKeyReset(hash string) error {
+	return r.exec("UPDATE mock_address SET reset_key='', reset_at=0 WHERE hash=?", hash)
+}
+
+func (r *SqliteDbResolver) CompleteKeyReset(info *ResolveInfoType) (bool, error) {
+	current, err := r.Get(info.Hash)
+	if err != nil {
+		return false, err
+	}
+
+	return r.replaceKey(info, current.ResetKey, "pubkey=reset_key, reset_key='', reset_at=0")
+}
+
+func (r *SqliteDbResolver) SetProtected(hash string, protected bool) error {
+	p := 0
+	if protected {
+		p = 1
+	}
+
+	return r.exec("UPDATE mock_address SET protected=? WHERE hash=?", p, hash)
+}
+
+func (r *SqliteDbResolver) SetPendingKey(hash string, publicKey *bmcrypto.PubKey, activateAt time.Time) error {
+	return r.exec("UPDATE mock_address SET pending_key=?, pending_at=? WHERE hash=?", publicKey.String(), activateAt.Unix(), hash)
+}
+
+func (r *SqliteDbResolver) CancelPendingKey(hash string) error {
+	return r.exec("UPDATE mock_address SET pending_key='', pending_at=0 WHERE hash=?", hash)
+}
+
+func (r *SqliteDbResolver) ActivatePendingKey(info *ResolveInfoType) (bool, error) {
+	current, err := r.Get(info.Hash)
+	if err != nil {
+		return false, err
+	}
+
+	return r.replaceKey(info, current.PendingKey, "pubkey=pending_key, pending_key='', pending_at=0")
+}
+
+func (r *SqliteDbResolver) SetPendingRouting(hash string, routing, redirHash string, activateAt time.Time) error {
+	return r.exec("UPDATE mock_address SET pending_routing=?, pending_redir_hash=?, pending_routing_at=? WHERE hash=?", routing, redirHash, activateAt.Unix(), hash)
+}
+
+func (r *SqliteDbResolver) CancelPendingRouting(hash string) error {
+	return r.exec("UPDATE mock_address SET pending_routing='', pending_redir_hash='', pending_routing_at=0 WHERE hash=?", hash)
+}
+
+func (r *SqliteDbResolver) SetDelegates(hash string, delegates []DelegateType) error {
+	dlg := ""
+	if len(delegates) > 0 {
+		b, err := json.Marshal(delegates)
+		if err != nil {
+			return err
+		}
+		dlg = string(b)
+	}
+
+	return r.exec("UPDATE mock_address SET delegates=? WHERE hash=?", dlg, hash)
+}
+
+// replaceKey sets the public key to key with the given assignments, as long as the serial has not been changed
+func (r *SqliteDbResolver) SetOrganisation(hash string, orgHash string) error {
+	return r.exec("UPDATE mock_address SET org_hash=? WHERE hash=?", orgHash, hash)
+}
+
+func (r *SqliteDbResolver) GetRedirectedBy(hash string) ([]string, error) {
+	rows, err := r.conn.Query("SELECT hash FROM mock_address WHERE redir_hash = ? ORDER BY hash", hash)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var hashes []string
+	for rows.Next() {
+		var h string
+		err = rows.Scan(&h)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, h)
+	}
+
+	return hashes, rows.Err()
+}
+
+func (r *SqliteDbResolver) replaceKey(info *ResolveInfoType, key, assignments string) (bool, error) {
+	if key == "" {
+		return false, ErrCannotUpdate
+	}
+
+	pk, err := bmcrypto.NewPubKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	newSerial := strconv.FormatUint(uint64(r.TimeNow.UnixNano()), 10)
+	err = r.exec("UPDATE mock_address SET "+assignments+", serial=? WHERE hash=? AND serial=?", newSerial, info.Hash, info.Serial)
+	if err != nil {
+		return false, err
+	}
+
+	_ = r.updateKeyHistory(info.Hash, pk.Fingerprint(), KSNormal)
+	return true, nil
+}
+
+// exec runs the given query and returns ErrNotFound when no rows are affected
+func (r *SqliteDbResolver) exec(query string, args ...interface{}) error {
+	res, err := r.conn.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -35,4 +35,22 @@ func TestSqliteDbResolver(t *testing.T) {
 
 	db = NewSqliteResolver(":memory:")
 	runRepositoryHistoryKeyStatus(t, db)
+
+	db = NewSqliteResolver(":memory:")
+	runRepositoryKeyResetTests(t, db)
+
+	db = NewSqliteResolver(":memory:")
+	runRepositoryPendingKeyTests(t, db)
+
+	db = NewSqliteResolver(":memory:")
+	runRepositoryDelegateTests(t, db)
+
+	db = NewSqliteResolver(":memory:")
+	runRepositoryGetManyTests(t, db)
+
+	db = NewSqliteResolver(":memory:")
+	runRepositoryOrganisationTests(t, db)
+
+	db = NewSqliteResolver(":memory:")
+	runRepositoryRedirectTests(t, db)
 }
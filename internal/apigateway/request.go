@@ -33,22 +33,35 @@ func ReqToHTTP(req *events.APIGatewayV2HTTPRequest) *http.Request {
 		req.Body,
 		req.PathParameters,
 	)
+	httpReq.ClientIP = req.RequestContext.HTTP.SourceIP
 
 	// Add headers
 	for k, v := range req.Headers {
 		httpReq.Headers.Set(k, v)
 	}
 
+	// Add query parameters
+	for k, v := range req.QueryStringParameters {
+		httpReq.Query[k] = v
+	}
+
 	return &httpReq
 }
 
 // HTTPToResp converts an internal http response to an api gateway http response
 func HTTPToResp(resp *http.Response) *events.APIGatewayV2HTTPResponse {
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	// Add headers
+	for k, v := range resp.Headers.Headers {
+		headers[k] = v
+	}
+
 	return &events.APIGatewayV2HTTPResponse{
 		StatusCode: resp.StatusCode,
-		Headers: map[string]string{
-			"Content-Type": "application/json",
-		},
-		Body: resp.Body,
+		Headers:    headers,
+		Body:       resp.Body,
 	}
 }
@@ -48,6 +48,9 @@ func TestReqToHTTP(t *testing.T) {
 				UserAgent: "gotest",
 			},
 		},
+		QueryStringParameters: map[string]string{
+			"start": "10",
+		},
 		Body: "body",
 	}
 
@@ -55,9 +58,11 @@ func TestReqToHTTP(t *testing.T) {
 	assert.Equal(t, httpReq.Body, "body")
 	assert.Equal(t, httpReq.URL, "/foobar")
 	assert.Equal(t, httpReq.Method, "GET")
+	assert.Equal(t, "127.2.3.4", httpReq.ClientIP)
 	assert.Len(t, httpReq.Headers.Headers, 2)
 	assert.Equal(t, "value-1", httpReq.Headers.Get("header-1"))
 	assert.Equal(t, "value-2", httpReq.Headers.Get("header-2"))
+	assert.Equal(t, "10", httpReq.Query["start"])
 }
 
 func TestHTTPToResp(t *testing.T) {
@@ -76,5 +81,7 @@ func TestHTTPToResp(t *testing.T) {
 	assert.Equal(t, 123, apigwResp.StatusCode)
 	assert.Equal(t, "this is body", apigwResp.Body)
 	assert.Equal(t, "application/json", apigwResp.Headers["Content-Type"])
-	assert.Len(t, apigwResp.Headers, 1)
+	assert.Equal(t, "v1", apigwResp.Headers["h1"])
+	assert.Equal(t, "v2", apigwResp.Headers["h2"])
+	assert.Len(t, apigwResp.Headers, 3)
 }
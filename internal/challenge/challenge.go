@@ -0,0 +1,129 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package challenge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Separator between the hash and the challenge in the proof-of-work data
+const Separator = ":"
+
+var (
+	// ErrInvalid is returned when the challenge is malformed or not issued by us
+	ErrInvalid = errors.New("invalid challenge")
+	// ErrExpired is returned when the challenge has been issued by us, but is not valid anymore
+	ErrExpired = errors.New("challenge expired")
+)
+
+// Challenge is a time-limited nonce for a hash. The MAC binds the nonce and the expiry to the hash, so the resolver
+// can verify a challenge without storing it.
+type Challenge struct {
+	Hash    string
+	Expires time.Time
+	Nonce   string
+	MAC     string
+}
+
+// New issues a new challenge for the hash that expires at the given time
+func New(secret []byte, h string, expires time.Time) (*Challenge, error) {
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Challenge{
+		Hash:    h,
+		Expires: time.Unix(expires.Unix(), 0),
+		Nonce:   hex.EncodeToString(nonce),
+	}
+	c.MAC = c.mac(secret)
+
+	return c, nil
+}
+
+// String returns the challenge as it is sent to the client, without the hash
+func (c *Challenge) String() string {
+	return fmt.Sprintf("%d.%s.%s", c.Expires.Unix(), c.Nonce, c.MAC)
+}
+
+// ProofData returns the data that the proof-of-work must be computed over
+func (c *Challenge) ProofData() string {
+	return c.Hash + Separator + c.String()
+}
+
+// Parse parses the challenge for the hash
+func Parse(h, s string) (*Challenge, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalid
+	}
+
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	return &Challenge{
+		Hash:    h,
+		Expires: time.Unix(expires, 0),
+		Nonce:   parts[1],
+		MAC:     parts[2],
+	}, nil
+}
+
+// Verify checks if the proof-of-work data holds a challenge for the hash that is issued with the secret and has not
+// expired yet
+func Verify(secret []byte, h, data string, now time.Time) error {
+	if !strings.HasPrefix(data, h+Separator) {
+		return ErrInvalid
+	}
+
+	c, err := Parse(h, strings.TrimPrefix(data, h+Separator))
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal([]byte(c.MAC), []byte(c.mac(secret))) {
+		return ErrInvalid
+	}
+
+	if !now.Before(c.Expires) {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+func (c *Challenge) mac(secret []byte) string {
+	m := hmac.New(sha256.New, secret)
+	_, _ = m.Write([]byte(fmt.Sprintf("%s|%d|%s", c.Hash, c.Expires.Unix(), c.Nonce)))
+
+	return hex.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package challenge
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChallenge(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+
+	c, err := New(secret, "2244643da7475120bf84d744435d15ea297c36ca165ea0baaa69ec818d0e952f", now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, c.Nonce, 32)
+	assert.True(t, strings.HasPrefix(c.String(), "1270647296."))
+	assert.Equal(t, "2244643da7475120bf84d744435d15ea297c36ca165ea0baaa69ec818d0e952f:"+c.String(), c.ProofData())
+
+	// Every challenge has its own nonce
+	c2, _ := New(secret, "2244643da7475120bf84d744435d15ea297c36ca165ea0baaa69ec818d0e952f", now.Add(time.Hour))
+	assert.NotEqual(t, c.String(), c2.String())
+
+	assert.NoError(t, Verify(secret, c.Hash, c.ProofData(), now))
+	assert.NoError(t, Verify(secret, c.Hash, c.ProofData(), now.Add(59*time.Minute)))
+	assert.Equal(t, ErrExpired, Verify(secret, c.Hash, c.ProofData(), now.Add(time.Hour)))
+
+	// Another secret
+	assert.Equal(t, ErrInvalid, Verify([]byte("other"), c.Hash, c.ProofData(), now))
+
+	// Another hash
+	assert.Equal(t, ErrInvalid, Verify(secret, "c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2", c.ProofData(), now))
+	assert.Equal(t, ErrInvalid, Verify(secret, "c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2", "c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2:"+c.String(), now))
+
+	// Extended expiry
+	parts := strings.Split(c.String(), ".")
+	assert.Equal(t, ErrInvalid, Verify(secret, c.Hash, c.Hash+":1270650896."+parts[1]+"."+parts[2], now))
+
+	// Malformed challenges
+	assert.Equal(t, ErrInvalid, Verify(secret, c.Hash, c.Hash, now))
+	assert.Equal(t, ErrInvalid, Verify(secret, c.Hash, c.Hash+":", now))
+	assert.Equal(t, ErrInvalid, Verify(secret, c.Hash, c.Hash+":foo.bar.baz", now))
+	assert.Equal(t, ErrInvalid, Verify(secret, c.Hash, c.Hash+":1.2", now))
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package changefeed
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/bitmaelum/key-resolver-go/internal"
+	bolt "go.etcd.io/bbolt"
+)
+
+type boltRepository struct {
+	client     *bolt.DB
+	bucketName []byte
+}
+
+// NewBoltRepository returns a new change feed repository based on BoltDB
+func NewBoltRepository() Repository {
+	return &boltRepository{
+		client:     internal.GetBoltDb(),
+		bucketName: []byte("changes"),
+	}
+}
+
+func (b boltRepository) Append(change Change) (uint64, error) {
+	err := b.client.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.bucketName)
+		if err != nil {
+			return err
+		}
+
+		change.Cursor, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(change)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(cursorKey(change.Cursor), data)
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return change.Cursor, nil
+}
+
+func (b boltRepository) Since(cursor uint64, limit int) ([]Change, error) {
+	var ret []Change
+
+	err := b.client.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Seek(cursorKey(cursor + 1)); k != nil && len(ret) < limit; k, v = c.Next() {
+			change := Change{}
+			err := json.Unmarshal(v, &change)
+			if err != nil {
+				return err
+			}
+			ret = append(ret, change)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func cursorKey(cursor uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, cursor)
+	return k
+}
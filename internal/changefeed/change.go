@@ -0,0 +1,38 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package changefeed
+
+// Actions for changes that only touch the pending state of a record. Other changes use the actions of the
+// transparency log.
+const (
+	ActionResetRequest  = "reset-request"  // A key reset through the recovery key has been requested
+	ActionResetCancel   = "reset-cancel"   // A pending key reset has been cancelled
+	ActionPendingCancel = "pending-cancel" // The pending key and routing changes of a protected address have been cancelled
+)
+
+// Change is a single change of a record in the feed
+type Change struct {
+	Cursor    uint64 `json:"cursor"`        // Position of the change in the feed, starting at 1
+	Type      string `json:"type"`          // Type of the record (address, organisation or routing)
+	Action    string `json:"action"`        // Kind of change (create, update, delete etc)
+	Hash      string `json:"hash"`          // Hash of the record
+	Serial    uint64 `json:"serial_number"` // Serial of the record after the change, or 0 when it has been removed
+	Timestamp int64  `json:"timestamp"`     // Time of the change
+}
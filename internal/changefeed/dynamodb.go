@@ -0,0 +1,174 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package changefeed
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// The feed is stored in a single table with "feed" as partition key and "cursor" as sort key. The changes are stored
+// in the "changes" partition, and the last cursor is kept in a counter item in the "counter" partition.
+const (
+	changesPartition = "changes"
+	counterPartition = "counter"
+)
+
+// GapTimeout is the time after which a missing change in the feed is skipped. A change is missing when its cursor has
+// been reserved but it has not been written (yet).
+var GapTimeout = time.Minute
+
+type dynamoDbRepository struct {
+	Dyna      dynamodbiface.DynamoDBAPI
+	TableName string
+}
+
+// Record in dynamodb
+type dynamoChangeRecord struct {
+	Feed      string `dynamodbav:"feed"`
+	Cursor    uint64 `dynamodbav:"cursor"`
+	Type      string `dynamodbav:"type"`
+	Action    string `dynamodbav:"action"`
+	Hash      string `dynamodbav:"hash"`
+	Serial    uint64 `dynamodbav:"serial"`
+	Timestamp int64  `dynamodbav:"timestamp"`
+}
+
+// NewDynamoDBRepository returns a new change feed repository based on DynamoDB
+func NewDynamoDBRepository(client dynamodbiface.DynamoDBAPI, tableName string) Repository {
+	return &dynamoDbRepository{
+		Dyna:      client,
+		TableName: tableName,
+	}
+}
+
+func (r *dynamoDbRepository) Append(change Change) (uint64, error) {
+	// Atomically increase the counter to reserve our cursor
+	out, err := r.Dyna.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:        aws.String(r.TableName),
+		Key:              counterKey(),
+		UpdateExpression: aws.String("ADD #c :one"),
+		ExpressionAttributeNames: map[string]*string{
+			"#c": aws.String("last"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one": {N: aws.String("1")},
+		},
+		ReturnValues: aws.String("UPDATED_NEW"),
+	})
+	if err != nil {
+		log.Print(err)
+		return 0, err
+	}
+
+	cursor, err := strconv.ParseUint(aws.StringValue(out.Attributes["last"].N), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	record := dynamoChangeRecord{
+		Feed:      changesPartition,
+		Cursor:    cursor,
+		Type:      change.Type,
+		Action:    change.Action,
+		Hash:      change.Hash,
+		Serial:    change.Serial,
+		Timestamp: change.Timestamp,
+	}
+
+	av, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		log.Print(err)
+		return 0, err
+	}
+
+	_, err = r.Dyna.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(r.TableName),
+		Item:      av,
+	})
+	if err != nil {
+		log.Print(err)
+		return 0, err
+	}
+
+	return cursor, nil
+}
+
+func (r *dynamoDbRepository) Since(cursor uint64, limit int) ([]Change, error) {
+	out, err := r.Dyna.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(r.TableName),
+		KeyConditionExpression: aws.String("#f = :f AND #c > :c"),
+		ExpressionAttributeNames: map[string]*string{
+			"#f": aws.String("feed"),
+			"#c": aws.String("cursor"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":f": {S: aws.String(changesPartition)},
+			":c": {N: aws.String(strconv.FormatUint(cursor, 10))},
+		},
+		Limit:          aws.Int64(int64(limit)),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		log.Print(err)
+		return nil, err
+	}
+
+	var ret []Change
+	expected := cursor + 1
+	for _, item := range out.Items {
+		record := &dynamoChangeRecord{}
+		err = dynamodbattribute.UnmarshalMap(item, record)
+		if err != nil {
+			return nil, err
+		}
+
+		// A change that is still being written would be skipped by consumers when we return the changes after it.
+		// Only when the change has not shown up for a while, we assume its write has failed and skip it.
+		if record.Cursor != expected && TimeNow().Sub(time.Unix(record.Timestamp, 0)) < GapTimeout {
+			break
+		}
+		expected = record.Cursor + 1
+
+		ret = append(ret, Change{
+			Cursor:    record.Cursor,
+			Type:      record.Type,
+			Action:    record.Action,
+			Hash:      record.Hash,
+			Serial:    record.Serial,
+			Timestamp: record.Timestamp,
+		})
+	}
+
+	return ret, nil
+}
+
+func counterKey() map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"feed":   {S: aws.String(counterPartition)},
+		"cursor": {N: aws.String("0")},
+	}
+}
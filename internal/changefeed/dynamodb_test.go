@@ -0,0 +1,101 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package changefeed
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	dynamock "github.com/gusaul/go-dynamock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBRepository(t *testing.T) {
+	client, mock := dynamock.New()
+	repo := NewDynamoDBRepository(client, "mock_changes_table")
+
+	TimeNow = func() time.Time {
+		return time.Unix(1000, 0)
+	}
+	defer func() {
+		TimeNow = time.Now
+	}()
+
+	// Append reserves the cursor through the counter
+	mock.ExpectUpdateItem().ToTable("mock_changes_table").WithKeys(counterKey()).WillReturns(dynamodb.UpdateItemOutput{
+		Attributes: map[string]*dynamodb.AttributeValue{
+			"last": {N: aws.String("3")},
+		},
+	})
+	mock.ExpectPutItem().ToTable("mock_changes_table").WithItems(changeItem(3, 999))
+	cursor, err := repo.Append(Change{Type: "routing", Action: "create", Hash: "hash", Serial: 1234, Timestamp: 999})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), cursor)
+
+	mock.ExpectQuery().Table("mock_changes_table").WillReturns(dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			changeItem(2, 999),
+			changeItem(3, 999),
+		},
+	})
+	changes, err := repo.Since(1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, Change{Cursor: 2, Type: "routing", Action: "create", Hash: "hash", Serial: 1234, Timestamp: 999}, changes[0])
+
+	// Change 3 is still being written, so do not return change 4 yet
+	mock.ExpectQuery().Table("mock_changes_table").WillReturns(dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			changeItem(2, 999),
+			changeItem(4, 999),
+		},
+	})
+	changes, err = repo.Since(1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, uint64(2), changes[0].Cursor)
+
+	// Change 3 has been missing for too long
+	mock.ExpectQuery().Table("mock_changes_table").WillReturns(dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			changeItem(2, 800),
+			changeItem(4, 800),
+		},
+	})
+	changes, err = repo.Since(1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, uint64(4), changes[1].Cursor)
+}
+
+func changeItem(cursor uint64, ts int64) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"feed":      {S: aws.String("changes")},
+		"cursor":    {N: aws.String(fmt.Sprintf("%d", cursor))},
+		"type":      {S: aws.String("routing")},
+		"action":    {S: aws.String("create")},
+		"hash":      {S: aws.String("hash")},
+		"serial":    {N: aws.String("1234")},
+		"timestamp": {N: aws.String(fmt.Sprintf("%d", ts))},
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package changefeed
+
+import (
+	"log"
+)
+
+// Notify is called with every change that has been recorded, for instance to deliver it to webhook subscribers
+var Notify func(change Change)
+
+// Record adds the change of a record to the feed. The change itself has already been made at this point, so a failure
+// to record it is reported but not returned.
+func Record(typ, action, hash string, serial uint64) {
+	change := Change{
+		Type:      typ,
+		Action:    action,
+		Hash:      hash,
+		Serial:    serial,
+		Timestamp: TimeNow().Unix(),
+	}
+
+	_, err := GetRepository().Append(change)
+	if err != nil {
+		log.Print(err)
+	}
+
+	if Notify != nil {
+		Notify(change)
+	}
+}
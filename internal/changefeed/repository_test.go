@@ -0,0 +1,66 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package changefeed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runRepositoryTests(t *testing.T, repo Repository) {
+	changes, err := repo.Since(0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 0)
+
+	for i := 1; i <= 5; i++ {
+		cursor, err := repo.Append(Change{
+			Type:      "address",
+			Action:    "update",
+			Hash:      "hash",
+			Serial:    uint64(i * 100),
+			Timestamp: int64(i),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(i), cursor)
+	}
+
+	changes, err = repo.Since(0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 5)
+	assert.Equal(t, Change{Cursor: 1, Type: "address", Action: "update", Hash: "hash", Serial: 100, Timestamp: 1}, changes[0])
+
+	// Paginate through the feed
+	changes, err = repo.Since(1, 2)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, uint64(2), changes[0].Cursor)
+	assert.Equal(t, uint64(3), changes[1].Cursor)
+
+	changes, err = repo.Since(3, 2)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, uint64(400), changes[0].Serial)
+	assert.Equal(t, uint64(5), changes[1].Cursor)
+
+	changes, err = repo.Since(5, 2)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 0)
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package difficulty
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+)
+
+// Step is the proof-of-work difficulty per record type, which takes effect from a given time
+type Step struct {
+	From         time.Time `json:"from"`
+	Address      int       `json:"address"`
+	Organisation int       `json:"organisation"`
+	Routing      int       `json:"routing"`
+}
+
+// Bits returns the number of bits required for the record type
+func (s Step) Bits(typ string) int {
+	switch typ {
+	case translog.TypeAddress:
+		return s.Address
+	case translog.TypeOrganisation:
+		return s.Organisation
+	case translog.TypeRouting:
+		return s.Routing
+	}
+
+	return 0
+}
+
+// Schedule is a list of difficulty steps. When a step takes effect, proofs at the level of the previous step are
+// still accepted during the grace period.
+type Schedule struct {
+	Steps       []Step
+	GracePeriod time.Duration
+}
+
+type scheduleFile struct {
+	GracePeriod string `json:"grace_period"`
+	Steps       []Step `json:"steps"`
+}
+
+// Parse parses a JSON encoded schedule
+func Parse(data []byte) (*Schedule, error) {
+	f := &scheduleFile{}
+	err := json.Unmarshal(data, f)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Schedule{
+		Steps: f.Steps,
+	}
+
+	if f.GracePeriod != "" {
+		s.GracePeriod, err = time.ParseDuration(f.GracePeriod)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(s.Steps) == 0 {
+		return nil, errors.New("schedule has no steps")
+	}
+
+	for _, step := range s.Steps {
+		if step.From.IsZero() || step.Address <= 0 || step.Organisation <= 0 || step.Routing <= 0 {
+			return nil, fmt.Errorf("incomplete step in schedule: %v", step)
+		}
+	}
+
+	sort.SliceStable(s.Steps, func(i, j int) bool {
+		return s.Steps[i].From.Before(s.Steps[j].From)
+	})
+
+	return s, nil
+}
+
+// LoadFile loads a JSON encoded schedule from a file
+func LoadFile(path string) (*Schedule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// At returns the previous, current and next step at the given time. Any of them can be nil.
+func (s *Schedule) At(now time.Time) (prev, cur, next *Step) {
+	for i := range s.Steps {
+		if s.Steps[i].From.After(now) {
+			return prev, cur, &s.Steps[i]
+		}
+
+		prev, cur = cur, &s.Steps[i]
+	}
+
+	return prev, cur, nil
+}
+
+// InGracePeriod returns true when the step has taken effect less than the grace period ago
+func (s *Schedule) InGracePeriod(step *Step, now time.Time) bool {
+	return step != nil && now.Before(step.From.Add(s.GracePeriod))
+}
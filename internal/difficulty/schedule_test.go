@@ -0,0 +1,89 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package difficulty
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(`{
+		"grace_period": "72h",
+		"steps": [
+			{"from": "2021-06-01T00:00:00Z", "address": 29, "organisation": 31, "routing": 29},
+			{"from": "2021-01-01T00:00:00Z", "address": 28, "organisation": 30, "routing": 28}
+		]
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 72*time.Hour, s.GracePeriod)
+	assert.Len(t, s.Steps, 2)
+
+	// Steps are sorted
+	assert.Equal(t, 28, s.Steps[0].Address)
+	assert.Equal(t, 29, s.Steps[1].Address)
+
+	_, err = Parse([]byte(`{"steps": []}`))
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`{"grace_period": "foo", "steps": [{"from": "2021-01-01T00:00:00Z", "address": 28, "organisation": 30, "routing": 28}]}`))
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`{"steps": [{"from": "2021-01-01T00:00:00Z", "address": 28}]}`))
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestAt(t *testing.T) {
+	s := &Schedule{
+		GracePeriod: 24 * time.Hour,
+		Steps: []Step{
+			{From: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Address: 28, Organisation: 30, Routing: 27},
+			{From: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), Address: 29, Organisation: 31, Routing: 28},
+		},
+	}
+
+	prev, cur, next := s.At(time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, prev)
+	assert.Nil(t, cur)
+	assert.Equal(t, 28, next.Address)
+
+	prev, cur, next = s.At(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, prev)
+	assert.Equal(t, 28, cur.Address)
+	assert.Equal(t, 29, next.Address)
+	assert.True(t, s.InGracePeriod(cur, time.Date(2021, 1, 1, 23, 59, 59, 0, time.UTC)))
+	assert.False(t, s.InGracePeriod(cur, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)))
+
+	prev, cur, next = s.At(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 28, prev.Address)
+	assert.Equal(t, 29, cur.Address)
+	assert.Nil(t, next)
+
+	assert.Equal(t, 29, cur.Bits("address"))
+	assert.Equal(t, 31, cur.Bits("organisation"))
+	assert.Equal(t, 28, cur.Bits("routing"))
+	assert.Equal(t, 0, cur.Bits("foo"))
+	assert.False(t, s.InGracePeriod(nil, time.Now()))
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package fingerprint
+
+import (
+	"encoding/json"
+
+	"github.com/bitmaelum/key-resolver-go/internal"
+	bolt "go.etcd.io/bbolt"
+)
+
+type boltRepository struct {
+	client     *bolt.DB
+	bucketName []byte
+}
+
+// NewBoltRepository returns a new fingerprint index based on BoltDB. Every fingerprint has its own nested bucket, with
+// the entries stored under their type and hash.
+func NewBoltRepository() Repository {
+	return &boltRepository{
+		client:     internal.GetBoltDb(),
+		bucketName: []byte("fingerprint_index"),
+	}
+}
+
+func (b boltRepository) Add(e Entry) error {
+	return b.client.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(b.bucketName)
+		if err != nil {
+			return err
+		}
+
+		bucket, err := root.CreateBucketIfNotExists([]byte(e.Fingerprint))
+		if err != nil {
+			return err
+		}
+
+		if bucket.Get(recordKey(e.Type, e.Hash)) != nil {
+			return nil
+		}
+
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(recordKey(e.Type, e.Hash), data)
+	})
+}
+
+func (b boltRepository) Get(fingerprint string) ([]Entry, error) {
+	var ret []Entry
+
+	err := b.client.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(b.bucketName)
+		if root == nil {
+			return nil
+		}
+
+		bucket := root.Bucket([]byte(fingerprint))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(_, data []byte) error {
+			e := Entry{}
+			err := json.Unmarshal(data, &e)
+			if err != nil {
+				return err
+			}
+			ret = append(ret, e)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func recordKey(typ, hash string) []byte {
+	return []byte(typ + "/" + hash)
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package fingerprint
+
+import (
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// The index is stored in a single table with "fingerprint" as partition key and "record" (type and hash) as sort key
+
+type dynamoDbRepository struct {
+	Dyna      dynamodbiface.DynamoDBAPI
+	TableName string
+}
+
+// Record in dynamodb
+type dynamoEntryRecord struct {
+	Fingerprint string `dynamodbav:"fingerprint"`
+	Record      string `dynamodbav:"record"`
+	Type        string `dynamodbav:"type"`
+	Hash        string `dynamodbav:"hash"`
+	PublicKey   string `dynamodbav:"public_key"`
+}
+
+// NewDynamoDBRepository returns a new fingerprint index based on DynamoDB
+func NewDynamoDBRepository(client dynamodbiface.DynamoDBAPI, tableName string) Repository {
+	return &dynamoDbRepository{
+		Dyna:      client,
+		TableName: tableName,
+	}
+}
+
+func (r *dynamoDbRepository) Add(e Entry) error {
+	av, err := dynamodbattribute.MarshalMap(dynamoEntryRecord{
+		Fingerprint: e.Fingerprint,
+		Record:      e.Type + "/" + e.Hash,
+		Type:        e.Type,
+		Hash:        e.Hash,
+		PublicKey:   e.PublicKey,
+	})
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	_, err = r.Dyna.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(r.TableName),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(fingerprint)"),
+	})
+
+	// The entry is already stored
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *dynamoDbRepository) Get(fingerprint string) ([]Entry, error) {
+	var ret []Entry
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.TableName),
+		KeyConditionExpression: aws.String("fingerprint = :fp"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":fp": {S: aws.String(fingerprint)},
+		},
+	}
+
+	for {
+		out, err := r.Dyna.Query(input)
+		if err != nil {
+			log.Print(err)
+			return nil, err
+		}
+
+		for _, item := range out.Items {
+			record := &dynamoEntryRecord{}
+			err = dynamodbattribute.UnmarshalMap(item, record)
+			if err != nil {
+				return nil, err
+			}
+
+			ret = append(ret, Entry{
+				Fingerprint: record.Fingerprint,
+				Type:        record.Type,
+				Hash:        record.Hash,
+				PublicKey:   record.PublicKey,
+			})
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return ret, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package fingerprint
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	dynamock "github.com/gusaul/go-dynamock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBRepository(t *testing.T) {
+	client, mock := dynamock.New()
+	repo := NewDynamoDBRepository(client, "mock_fingerprint_table")
+
+	item := map[string]*dynamodb.AttributeValue{
+		"fingerprint": {S: aws.String("fp1")},
+		"record":      {S: aws.String("address/hash1")},
+		"type":        {S: aws.String("address")},
+		"hash":        {S: aws.String("hash1")},
+		"public_key":  {S: aws.String("key1")},
+	}
+
+	mock.ExpectPutItem().ToTable("mock_fingerprint_table").WithItems(item).WillReturns(dynamodb.PutItemOutput{})
+	err := repo.Add(Entry{Fingerprint: "fp1", Type: "address", Hash: "hash1", PublicKey: "key1"})
+	assert.NoError(t, err)
+
+	mock.ExpectQuery().Table("mock_fingerprint_table").WillReturns(dynamodb.QueryOutput{
+		Items:            []map[string]*dynamodb.AttributeValue{item},
+		LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"fingerprint": {S: aws.String("fp1")}},
+	})
+	mock.ExpectQuery().Table("mock_fingerprint_table").WillReturns(dynamodb.QueryOutput{})
+	entries, err := repo.Get("fp1")
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{{Fingerprint: "fp1", Type: "address", Hash: "hash1", PublicKey: "key1"}}, entries)
+
+	entries, err = repo.Get("fp1")
+	assert.Error(t, err)
+	assert.Nil(t, entries)
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package fingerprint
+
+import (
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Entry links the fingerprint of a public key to a record that uses, or has used, the key
+type Entry struct {
+	Fingerprint string `json:"fingerprint"`
+	Type        string `json:"type"`       // Type of the record (address, organisation or routing)
+	Hash        string `json:"hash"`       // Hash of the record
+	PublicKey   string `json:"public_key"` // The public key itself
+}
+
+// Repository is the index from public key fingerprints to the records that use them
+type Repository interface {
+	// Add the entry to the index. Adding an entry that already exists is a no-op
+	Add(e Entry) error
+	// Return all entries with the given fingerprint
+	Get(fingerprint string) ([]Entry, error)
+}
+
+var repository Repository
+
+// GetRepository returns the repository for the fingerprint index
+func GetRepository() Repository {
+	if repository != nil {
+		return repository
+	}
+
+	if os.Getenv("USE_BOLT") == "1" {
+		repository = NewBoltRepository()
+		return repository
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	repository = NewDynamoDBRepository(dynamodb.New(sess), os.Getenv("FINGERPRINT_TABLE_NAME"))
+	return repository
+}
+
+// Sets the default repository for the index. Can be used to override for mocking/testing purposes
+func SetDefaultRepository(r Repository) {
+	repository = r
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package fingerprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runRepositoryTests(t *testing.T, repo Repository) {
+	entries, err := repo.Get("fp1")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 0)
+
+	err = repo.Add(Entry{Fingerprint: "fp1", Type: "address", Hash: "hash1", PublicKey: "key1"})
+	assert.NoError(t, err)
+	err = repo.Add(Entry{Fingerprint: "fp1", Type: "organisation", Hash: "hash2", PublicKey: "key1"})
+	assert.NoError(t, err)
+	err = repo.Add(Entry{Fingerprint: "fp2", Type: "address", Hash: "hash1", PublicKey: "key2"})
+	assert.NoError(t, err)
+
+	// Adding an existing entry again
+	err = repo.Add(Entry{Fingerprint: "fp1", Type: "address", Hash: "hash1", PublicKey: "key1"})
+	assert.NoError(t, err)
+
+	entries, err = repo.Get("fp1")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, Entry{Fingerprint: "fp1", Type: "address", Hash: "hash1", PublicKey: "key1"}, entries[0])
+	assert.Equal(t, Entry{Fingerprint: "fp1", Type: "organisation", Hash: "hash2", PublicKey: "key1"}, entries[1])
+
+	entries, err = repo.Get("fp2")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "key2", entries[0].PublicKey)
+}
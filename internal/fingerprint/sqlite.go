@@ -0,0 +1,88 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package fingerprint
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+type SqliteRepository struct {
+	conn *sql.DB
+	dsn  string
+}
+
+// NewSqliteRepository returns a new fingerprint index based on SQLite
+func NewSqliteRepository(dsn string) Repository {
+	if !strings.HasPrefix(dsn, "file:") {
+		if dsn == ":memory:" {
+			dsn = "file::memory:?mode=memory"
+		} else {
+			dsn = fmt.Sprintf("file:%s?cache=shared&mode=rwc", dsn)
+		}
+	}
+
+	conn, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil
+	}
+
+	db := &SqliteRepository{
+		conn: conn,
+		dsn:  dsn,
+	}
+
+	_, err = db.conn.Exec("CREATE TABLE IF NOT EXISTS mock_fingerprints (fingerprint VARCHAR(64), type TEXT, hash VARCHAR(64), pubkey TEXT, PRIMARY KEY (fingerprint, type, hash))")
+	if err != nil {
+		return nil
+	}
+
+	return db
+}
+
+func (r *SqliteRepository) Add(e Entry) error {
+	_, err := r.conn.Exec("INSERT OR IGNORE INTO mock_fingerprints VALUES (?, ?, ?, ?)", e.Fingerprint, e.Type, e.Hash, e.PublicKey)
+	return err
+}
+
+func (r *SqliteRepository) Get(fingerprint string) ([]Entry, error) {
+	rows, err := r.conn.Query("SELECT fingerprint, type, hash, pubkey FROM mock_fingerprints WHERE fingerprint = ? ORDER BY type, hash", fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var ret []Entry
+	for rows.Next() {
+		e := Entry{}
+		err = rows.Scan(&e.Fingerprint, &e.Type, &e.Hash, &e.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, e)
+	}
+
+	return ret, rows.Err()
+}
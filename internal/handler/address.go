@@ -21,7 +21,6 @@ package handler
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
 	"regexp"
 	"strconv"
@@ -29,19 +28,26 @@ import (
 
 	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
 	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
-	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
 	"github.com/bitmaelum/key-resolver-go/internal/address"
 	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/proof"
 	"github.com/bitmaelum/key-resolver-go/internal/reservation"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
 )
 
 type addressUploadBody struct {
-	UserHash  hash.Hash                `json:"user_hash"`
-	OrgHash   hash.Hash                `json:"org_hash"`
-	PublicKey *bmcrypto.PubKey         `json:"public_key"`
-	RoutingID string                   `json:"routing_id,omitempty"`
-	Proof     *proofofwork.ProofOfWork `json:"proof"`
-	RedirHash string                   `json:"redir_hash,omitempty"`
+	UserHash  hash.Hash        `json:"user_hash"`
+	OrgHash   hash.Hash        `json:"org_hash"`
+	PublicKey *bmcrypto.PubKey `json:"public_key"`
+	RoutingID string           `json:"routing_id,omitempty"`
+	Proof     *proof.Proof     `json:"proof"`
+	RedirHash string           `json:"redir_hash,omitempty"`
+	KeySig    []byte           `json:"key_signature"`
+
+	RecoveryKey *bmcrypto.PubKey `json:"recovery_key,omitempty"`
+	Protected   bool             `json:"protected,omitempty"`
+
+	Delegates []delegateUploadBody `json:"delegates"`
 }
 
 var (
@@ -54,12 +60,90 @@ var (
 	routeIDRegex            = regexp.MustCompile("[a-f0-9]{64}")
 )
 
-func GetAddressHash(hash hash.Hash, _ http.Request) *http.Response {
-	info, httpErr := recursiveGet(hash, MaxRedirectDepth)
+func GetAddressHash(hash hash.Hash, req http.Request) *http.Response {
+	includes, httpErr := parseIncludes(req.Query["include"])
 	if httpErr != nil {
 		return httpErr
 	}
 
+	// Prior versions are returned as they were stored, without following redirections or embedding other records
+	if isVersionLookup(req) {
+		if len(includes) > 0 {
+			return http.CreateError("include cannot be combined with serial or at", 400)
+		}
+		return getVersion(translog.TypeAddress, hash.String(), req)
+	}
+
+	follow := true
+	if req.Query["follow"] != "" {
+		var err error
+		follow, err = strconv.ParseBool(req.Query["follow"])
+		if err != nil {
+			return http.CreateError("invalid follow", 400)
+		}
+	}
+
+	var chain []*address.ResolveInfoType
+	if follow {
+		chain, httpErr = resolveChain(hash, MaxRedirectDepth)
+	} else {
+		chain, httpErr = fetchUnfollowed(hash)
+	}
+	if httpErr != nil {
+		return httpErr
+	}
+
+	info := chain[len(chain)-1]
+	data := addressOutput(info)
+	if len(chain) > 1 {
+		data["redirect_chain"] = redirectChainOutput(chain)
+	}
+
+	missing := http.RawJSONOut{}
+	if includes[includeRouting] {
+		if rt, reason := includedRouting(info); rt != nil {
+			data["routing"] = rt
+		} else {
+			missing[includeRouting] = reason
+		}
+	}
+	if includes[includeOrganisation] {
+		// The organisation belongs to the requested address, not to the address it redirects to
+		if org, reason := includedOrganisation(chain[0]); org != nil {
+			data["organisation"] = org
+		} else {
+			missing[includeOrganisation] = reason
+		}
+	}
+	if len(missing) > 0 {
+		data["missing"] = missing
+	}
+
+	return http.CreateOutput(data, 200)
+}
+
+// redirectChainOutput returns the hops that were taken to resolve an address, starting with the requested address
+func redirectChainOutput(chain []*address.ResolveInfoType) []http.RawJSONOut {
+	hops := make([]http.RawJSONOut, 0, len(chain))
+	for _, info := range chain {
+		hop := http.RawJSONOut{
+			"hash":          info.Hash,
+			"serial_number": info.Serial,
+		}
+
+		pk, err := bmcrypto.NewPubKey(info.PubKey)
+		if err == nil {
+			hop["fingerprint"] = pk.Fingerprint()
+		}
+
+		hops = append(hops, hop)
+	}
+
+	return hops
+}
+
+// addressOutput returns the public representation of an address record
+func addressOutput(info *address.ResolveInfoType) http.RawJSONOut {
 	data := http.RawJSONOut{
 		"hash":          info.Hash,
 		"public_key":    info.PubKey,
@@ -74,8 +158,26 @@ func GetAddressHash(hash hash.Hash, _ http.Request) *http.Response {
 	if info.RoutingID != "" {
 		data["routing_id"] = info.RoutingID
 	}
+	if info.RecoveryKey != "" {
+		data["recovery_key"] = info.RecoveryKey
+	}
+	if reset := keyResetOutput(info.ResetKey, info.ResetAt); reset != nil {
+		data["key_reset"] = reset
+	}
+	if info.Protected {
+		data["protected"] = true
+	}
+	if pending := pendingKeyOutput(info.PendingKey, info.PendingAt); pending != nil {
+		data["pending_key"] = pending
+	}
+	if pending := pendingRoutingOutput(info); pending != nil {
+		data["pending_routing"] = pending
+	}
+	if delegates := delegatesOutput(info.Delegates); delegates != nil {
+		data["delegates"] = delegates
+	}
 
-	return http.CreateOutput(data, 200)
+	return data
 }
 
 func PostAddressHash(addrHash hash.Hash, req http.Request) *http.Response {
@@ -91,8 +193,7 @@ func PostAddressHash(addrHash hash.Hash, req http.Request) *http.Response {
 		return httpErr
 	}
 
-	repo := address.GetResolveRepository()
-	current, err := repo.Get(addrHash.String())
+	current, err := fetchAddress(addrHash.String())
 	if err != nil && err != address.ErrNotFound {
 		log.Print(err)
 		return http.CreateError("error while posting record", 500)
@@ -126,19 +227,33 @@ func PostAddressHash(addrHash hash.Hash, req http.Request) *http.Response {
 
 func DeleteAddressHash(addrHash hash.Hash, req http.Request) *http.Response {
 	repo := address.GetResolveRepository()
-	current, err := repo.Get(addrHash.String())
+	current, err := fetchAddress(addrHash.String())
 	if err != nil {
 		log.Print(err)
 		return http.CreateError("error while fetching record", 500)
 	}
 
-	if current == nil || current.Deleted {
+	// Protected addresses must stay soft-deleted for the rotation delay, so the deletion can still be undone
+	if current == nil || (current.Deleted && !current.Protected) {
 		log.Print(err)
 		return http.CreateError("cannot find record", 404)
 	}
 
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
 	if !req.ValidateAuthenticationToken(current.PubKey, current.Hash+current.RoutingID+strconv.FormatUint(current.Serial, 10)) {
-		return http.CreateError("unauthenticated", 401)
+		return failedAuth(translog.TypeAddress, current.Hash, "unauthenticated", req)
+	}
+
+	if current.Protected && (!current.Deleted || timeNow().Before(current.DeletedAt.Add(KeyRotationDelay))) {
+		return http.CreateError("protected address must be soft-deleted before it can be deleted", 400)
+	}
+
+	dependents, httpErr := checkDependents(current.Hash)
+	if httpErr != nil {
+		return httpErr
 	}
 
 	res, err := repo.Delete(current.Hash)
@@ -146,35 +261,48 @@ func DeleteAddressHash(addrHash hash.Hash, req http.Request) *http.Response {
 		return http.CreateError("error while deleting record", 500)
 	}
 
-	return http.CreateMessage("address has been deleted", 200)
+	logMutation(translog.TypeAddress, translog.ActionDelete, current.Hash, "")
+
+	return deletedMessage("address has been deleted", dependents)
 }
 
 func SoftDeleteAddressHash(addrHash hash.Hash, req http.Request) *http.Response {
 	repo := address.GetResolveRepository()
-	current, err := repo.Get(addrHash.String())
+	current, err := fetchAddress(addrHash.String())
 	if err != nil {
 		return http.CreateError("error while fetching record", 500)
 	}
 
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
 	if !req.ValidateAuthenticationToken(current.PubKey, current.Hash+current.RoutingID+strconv.FormatUint(current.Serial, 10)) {
-		return http.CreateError("unauthenticated", 401)
+		return failedAuth(translog.TypeAddress, current.Hash, "unauthenticated", req)
 	}
 
 	if current == nil || current.Deleted {
 		return http.CreateError("cannot find record", 404)
 	}
 
+	dependents, httpErr := checkDependents(current.Hash)
+	if httpErr != nil {
+		return httpErr
+	}
+
 	res, err := repo.SoftDelete(current.Hash)
 	if err != nil || !res {
 		return http.CreateError("error while deleting record", 500)
 	}
 
-	return http.CreateMessage("address has been soft-deleted", 200)
+	logMutation(translog.TypeAddress, translog.ActionSoftDelete, current.Hash, current.PubKey)
+
+	return deletedMessage("address has been soft-deleted", dependents)
 }
 
 func SoftUndeleteAddressHash(addrHash hash.Hash, req http.Request) *http.Response {
 	repo := address.GetResolveRepository()
-	current, err := repo.Get(addrHash.String())
+	current, err := fetchAddress(addrHash.String())
 	if err != nil {
 		return http.CreateError("error while fetching record", 500)
 	}
@@ -187,8 +315,17 @@ func SoftUndeleteAddressHash(addrHash hash.Hash, req http.Request) *http.Respons
 		return http.CreateError("not deleted", 400)
 	}
 
+	// A revoked key must not be able to bring the record back
+	if isKeyCompromised(current) {
+		return http.CreateError("key has been compromised", 403)
+	}
+
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
 	if !req.ValidateAuthenticationToken(current.PubKey, current.Hash+current.RoutingID+strconv.FormatUint(current.Serial, 10)) {
-		return http.CreateError("unauthenticated", 401)
+		return failedAuth(translog.TypeAddress, current.Hash, "unauthenticated", req)
 	}
 
 	res, err := repo.SoftUndelete(current.Hash)
@@ -196,9 +333,234 @@ func SoftUndeleteAddressHash(addrHash hash.Hash, req http.Request) *http.Respons
 		return http.CreateError("error while undeleting record", 500)
 	}
 
+	logMutation(translog.TypeAddress, translog.ActionUndelete, current.Hash, current.PubKey)
+
 	return http.CreateMessage("address has been undeleted", 200)
 }
 
+func RevokeAddressHash(addrHash hash.Hash, req http.Request) *http.Response {
+	type revokeRequestBody struct {
+		Certificate string `json:"certificate"`
+	}
+
+	body := &revokeRequestBody{}
+	err := json.Unmarshal([]byte(req.Body), body)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("invalid body data", 400)
+	}
+
+	repo := address.GetResolveRepository()
+	current, err := fetchAddress(addrHash.String())
+	if err != nil && err != address.ErrNotFound {
+		log.Print(err)
+		return http.CreateError("error while fetching record", 500)
+	}
+
+	if current == nil || current.Deleted {
+		return http.CreateError("cannot find record", 404)
+	}
+
+	pk, err := bmcrypto.NewPubKey(current.PubKey)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching record", 500)
+	}
+
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
+	// The revocation certificate is the authentication, so anyone holding it can revoke the key
+	if !address.VerifyRevocationCertificate(body.Certificate, addrHash, *pk) {
+		return failedAuth(translog.TypeAddress, current.Hash, "invalid revocation certificate", req)
+	}
+
+	res, err := repo.SoftDelete(current.Hash)
+	if err != nil || !res {
+		log.Print(err)
+		return http.CreateError("error while revoking record", 500)
+	}
+
+	err = repo.SetKeyStatus(current.Hash, pk.Fingerprint(), address.KSCompromised)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while revoking record", 500)
+	}
+
+	logMutation(translog.TypeAddress, translog.ActionSoftDelete, current.Hash, current.PubKey)
+	logKeyStatus(current.Hash, pk.Fingerprint(), address.KSCompromised)
+
+	return http.CreateMessage("address has been revoked", 200)
+}
+
+func RequestAddressKeyReset(addrHash hash.Hash, req http.Request) *http.Response {
+	body := &keyResetUploadBody{}
+	err := json.Unmarshal([]byte(req.Body), body)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("invalid data", 400)
+	}
+
+	repo := address.GetResolveRepository()
+	current, err := fetchAddress(addrHash.String())
+	if err != nil && err != address.ErrNotFound {
+		log.Print(err)
+		return http.CreateError("error while fetching record", 500)
+	}
+
+	if current == nil || current.Deleted {
+		return http.CreateError("cannot find record", 404)
+	}
+
+	if current.RecoveryKey == "" {
+		return http.CreateError("no recovery key registered", 400)
+	}
+
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
+	// Key resets are authenticated by the recovery key instead of the current key
+	if !req.ValidateAuthenticationToken(current.RecoveryKey, current.Hash+current.RoutingID+strconv.FormatUint(current.Serial, 10)) {
+		return failedAuth(translog.TypeAddress, current.Hash, "unauthenticated", req)
+	}
+
+	if !validateKeyPossession(body.PublicKey, current.Hash, current.Serial, body.KeySig) {
+		return http.CreateError("proof of key possession failed", 400)
+	}
+
+	if httpErr := checkKeyPolicy(body.PublicKey, translog.TypeAddress, current.Hash); httpErr != nil {
+		return httpErr
+	}
+
+	err = repo.RequestKeyReset(current.Hash, body.PublicKey, timeNow().Add(KeyResetDelay))
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while requesting key reset", 500)
+	}
+
+	return http.CreateMessage("key reset has been requested", 200)
+}
+
+func CancelAddressKeyReset(addrHash hash.Hash, req http.Request) *http.Response {
+	repo := address.GetResolveRepository()
+	current, err := fetchAddress(addrHash.String())
+	if err != nil && err != address.ErrNotFound {
+		log.Print(err)
+		return http.CreateError("error while fetching record", 500)
+	}
+
+	if current == nil || current.Deleted {
+		return http.CreateError("cannot find record", 404)
+	}
+
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
+	if !req.ValidateAuthenticationToken(current.PubKey, current.Hash+current.RoutingID+strconv.FormatUint(current.Serial, 10)) {
+		return failedAuth(translog.TypeAddress, current.Hash, "unauthenticated", req)
+	}
+
+	if current.ResetKey == "" {
+		return http.CreateError("no pending key reset", 404)
+	}
+
+	err = repo.CancelKeyReset(current.Hash)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while cancelling key reset", 500)
+	}
+
+	return http.CreateMessage("key reset has been cancelled", 200)
+}
+
+func CompleteAddressKeyReset(addrHash hash.Hash, req http.Request) *http.Response {
+	repo := address.GetResolveRepository()
+	current, err := fetchAddress(addrHash.String())
+	if err != nil && err != address.ErrNotFound {
+		log.Print(err)
+		return http.CreateError("error while fetching record", 500)
+	}
+
+	if current == nil || current.Deleted {
+		return http.CreateError("cannot find record", 404)
+	}
+
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
+	if !req.ValidateAuthenticationToken(current.RecoveryKey, current.Hash+current.RoutingID+strconv.FormatUint(current.Serial, 10)) {
+		return failedAuth(translog.TypeAddress, current.Hash, "unauthenticated", req)
+	}
+
+	if current.ResetKey == "" {
+		return http.CreateError("no pending key reset", 404)
+	}
+
+	if isKeyResetPending(current.ResetAt) {
+		return http.CreateError("key reset is still pending", 400)
+	}
+
+	res, err := repo.CompleteKeyReset(current)
+	if err != nil || !res {
+		log.Print(err)
+		return http.CreateError("error while completing key reset", 500)
+	}
+
+	logMutation(translog.TypeAddress, translog.ActionUpdate, current.Hash, current.ResetKey)
+	recordVersion(translog.TypeAddress, current.Hash)
+	indexFingerprint(translog.TypeAddress, current.Hash, current.ResetKey)
+
+	return http.CreateMessage("key has been reset", 200)
+}
+
+func CancelAddressPendingKey(addrHash hash.Hash, req http.Request) *http.Response {
+	repo := address.GetResolveRepository()
+	current, err := fetchAddress(addrHash.String())
+	if err != nil && err != address.ErrNotFound {
+		log.Print(err)
+		return http.CreateError("error while fetching record", 500)
+	}
+
+	if current == nil || current.Deleted {
+		return http.CreateError("cannot find record", 404)
+	}
+
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
+	// The pending changes are not active yet, so the current (previous) key is able to veto them
+	if !req.ValidateAuthenticationToken(current.PubKey, current.Hash+current.RoutingID+strconv.FormatUint(current.Serial, 10)) {
+		return failedAuth(translog.TypeAddress, current.Hash, "unauthenticated", req)
+	}
+
+	if current.PendingKey == "" && current.PendingRoutingAt.IsZero() {
+		return http.CreateError("no pending changes", 404)
+	}
+
+	if current.PendingKey != "" {
+		err = repo.CancelPendingKey(current.Hash)
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while cancelling pending changes", 500)
+		}
+	}
+
+	if !current.PendingRoutingAt.IsZero() {
+		err = repo.CancelPendingRouting(current.Hash)
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while cancelling pending changes", 500)
+		}
+	}
+
+	return http.CreateMessage("pending changes have been cancelled", 200)
+}
+
 func GetKeyStatus(hash hash.Hash, req http.Request) *http.Response {
 	fp, ok := req.Params["fingerprint"]
 	if !ok {
@@ -248,21 +610,93 @@ func SetKeyStatus(hash hash.Hash, req http.Request) *http.Response {
 		return http.CreateError("invalid status", 400)
 	}
 
+	current, err := fetchAddress(hash.String())
+	if err != nil || current == nil {
+		return http.CreateError("cannot find record", 404)
+	}
+
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
+	// Key statuses decide whether the record can still be changed, so only the owner can set them
+	if !req.ValidateAuthenticationToken(current.PubKey, current.Hash+current.RoutingID+strconv.FormatUint(current.Serial, 10)) {
+		return failedAuth(translog.TypeAddress, current.Hash, "unauthenticated", req)
+	}
+
+	if isKeyCompromised(current) {
+		return http.CreateError("key has been compromised", 403)
+	}
+
 	repo := address.GetResolveRepository()
 	err = repo.SetKeyStatus(hash.String(), fp, ks)
 	if err != nil {
 		return http.CreateError("error while updating", 400)
 	}
 
+	logKeyStatus(hash.String(), fp, ks)
+
 	return http.CreateMessage("key status has been updated", 200)
 }
 
 func updateAddress(uploadBody addressUploadBody, req http.Request, current *address.ResolveInfoType) *http.Response {
+	// Records with a revoked key can only be recovered through a key reset
+	if isKeyCompromised(current) {
+		return http.CreateError("key has been compromised", 403)
+	}
+
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
 	if !req.ValidateAuthenticationToken(current.PubKey, current.Hash+current.RoutingID+strconv.FormatUint(current.Serial, 10)) {
-		return http.CreateError("unauthenticated", 401)
+		// Not the owner, but it could be one of the delegates
+		delegate := findDelegate(req, current)
+		if delegate == nil {
+			return failedAuth(translog.TypeAddress, current.Hash, "unauthenticated", req)
+		}
+
+		return updateAddressByDelegate(uploadBody, current, delegate)
+	}
+
+	if !validateKeyPossession(uploadBody.PublicKey, current.Hash, current.Serial, uploadBody.KeySig) {
+		return http.CreateError("proof of key possession failed", 400)
+	}
+
+	// Keys that are already in use by the record are not checked again
+	if uploadBody.PublicKey.String() != current.PubKey {
+		if httpErr := checkKeyPolicy(uploadBody.PublicKey, translog.TypeAddress, current.Hash); httpErr != nil {
+			return httpErr
+		}
+	}
+
+	delegates, httpErr := convertDelegates(uploadBody.Delegates)
+	if httpErr != nil {
+		return httpErr
 	}
 
 	repo := address.GetResolveRepository()
+	if uploadBody.Delegates != nil {
+		err := repo.SetDelegates(current.Hash, delegates)
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while updating: ", 500)
+		}
+	}
+
+	if uploadBody.Protected && !current.Protected {
+		err := repo.SetProtected(current.Hash, true)
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while updating: ", 500)
+		}
+	}
+
+	// Key and routing changes on protected addresses do not take effect immediately
+	if current.Protected && (uploadBody.PublicKey.String() != current.PubKey || isRoutingChanged(uploadBody, current)) {
+		return updateProtectedAddress(uploadBody, current)
+	}
+
 	res, err := repo.Update(current, uploadBody.RoutingID, uploadBody.PublicKey, uploadBody.RedirHash)
 
 	if err != nil || !res {
@@ -270,18 +704,92 @@ func updateAddress(uploadBody addressUploadBody, req http.Request, current *addr
 		return http.CreateError("error while updating: ", 500)
 	}
 
-	return http.CreateMessage("address has been updated", 200)
+	index, logged := logMutation(translog.TypeAddress, translog.ActionUpdate, current.Hash, uploadBody.PublicKey.String())
+	recordVersion(translog.TypeAddress, current.Hash)
+	indexFingerprint(translog.TypeAddress, current.Hash, uploadBody.PublicKey.String())
+
+	return receiptMessage("address has been updated", 200, addressReceipt(current.Hash, index, logged))
+}
+
+// isKeyCompromised returns true when the current key of the record has been marked as compromised
+func isKeyCompromised(current *address.ResolveInfoType) bool {
+	pk, err := bmcrypto.NewPubKey(current.PubKey)
+	if err != nil {
+		return false
+	}
+
+	ks, err := address.GetResolveRepository().GetKeyStatus(current.Hash, pk.Fingerprint())
+	return err == nil && ks == address.KSCompromised
+}
+
+func updateProtectedAddress(uploadBody addressUploadBody, current *address.ResolveInfoType) *http.Response {
+	currentKey, err := bmcrypto.NewPubKey(current.PubKey)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while updating: ", 500)
+	}
+
+	// Keep the current key and routing, the changes become pending instead. The serial is still updated, so the
+	// authentication token cannot be used again.
+	repo := address.GetResolveRepository()
+	res, err := repo.Update(current, current.RoutingID, currentKey, current.RedirHash)
+	if err != nil || !res {
+		log.Print(err)
+		return http.CreateError("error while updating: ", 500)
+	}
+
+	if uploadBody.PublicKey.String() != current.PubKey {
+		err = repo.SetPendingKey(current.Hash, uploadBody.PublicKey, timeNow().Add(KeyRotationDelay))
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while updating: ", 500)
+		}
+	}
+
+	if isRoutingChanged(uploadBody, current) {
+		err = repo.SetPendingRouting(current.Hash, uploadBody.RoutingID, uploadBody.RedirHash, timeNow().Add(KeyRotationDelay))
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while updating: ", 500)
+		}
+	}
+
+	index, logged := logMutation(translog.TypeAddress, translog.ActionUpdate, current.Hash, current.PubKey)
+	recordVersion(translog.TypeAddress, current.Hash)
+	indexFingerprint(translog.TypeAddress, current.Hash, current.PubKey)
+
+	return receiptMessage("address has been updated, changes are pending", 200, addressReceipt(current.Hash, index, logged))
+}
+
+// isRoutingChanged returns true when the upload changes the routing ID or redirect of the record
+func isRoutingChanged(uploadBody addressUploadBody, current *address.ResolveInfoType) bool {
+	return uploadBody.RoutingID != current.RoutingID || uploadBody.RedirHash != current.RedirHash
 }
 
 func createAddress(addrHash hash.Hash, uploadBody addressUploadBody) *http.Response {
 	// Validate proof of work
-	if !uploadBody.Proof.IsValid() || uploadBody.Proof.Data != addrHash.String() {
-		return http.CreateError("incorrect proof-of-work", 400)
+	if httpErr := validateProof(uploadBody.Proof, translog.TypeAddress, addrHash.String()); httpErr != nil {
+		return httpErr
 	}
 
-	// Check minimum number of work bits
-	if uploadBody.Proof.Bits < MinimumProofBitsAddress {
-		return http.CreateError(fmt.Sprintf("proof-of-work too weak (need %d bits)", MinimumProofBitsAddress), 400)
+	if !validateKeyPossession(uploadBody.PublicKey, addrHash.String(), 0, uploadBody.KeySig) {
+		return http.CreateError("proof of key possession failed", 400)
+	}
+
+	if httpErr := checkKeyPolicy(uploadBody.PublicKey, translog.TypeAddress, addrHash.String()); httpErr != nil {
+		return httpErr
+	}
+
+	// The recovery key is stored by setAddressOptions after the address is created, so it is checked up front
+	if uploadBody.RecoveryKey != nil {
+		if httpErr := checkAdditionalKeyPolicy(uploadBody.RecoveryKey); httpErr != nil {
+			return httpErr
+		}
+	}
+
+	delegates, httpErr := convertDelegates(uploadBody.Delegates)
+	if httpErr != nil {
+		return httpErr
 	}
 
 	repo := address.GetResolveRepository()
@@ -291,7 +799,57 @@ func createAddress(addrHash hash.Hash, uploadBody addressUploadBody) *http.Respo
 		return http.CreateError("error while creating: ", 500)
 	}
 
-	return http.CreateMessage("address has been created", 201)
+	err = setAddressOptions(addrHash.String(), uploadBody, delegates)
+	if err != nil {
+		log.Print(err)
+
+		// Remove the half-created record, so a retry creates the address again instead of updating it
+		_, err = repo.Delete(addrHash.String())
+		if err != nil {
+			log.Print(err)
+		}
+
+		return http.CreateError("error while creating: ", 500)
+	}
+
+	index, logged := logMutation(translog.TypeAddress, translog.ActionCreate, addrHash.String(), uploadBody.PublicKey.String())
+	recordVersion(translog.TypeAddress, addrHash.String())
+	indexFingerprint(translog.TypeAddress, addrHash.String(), uploadBody.PublicKey.String())
+	recordCreation(translog.TypeAddress)
+
+	return receiptMessage("address has been created", 201, addressReceipt(addrHash.String(), index, logged))
+}
+
+// setAddressOptions stores the optional settings of a newly created address
+func setAddressOptions(h string, uploadBody addressUploadBody, delegates []address.DelegateType) error {
+	repo := address.GetResolveRepository()
+
+	if uploadBody.RecoveryKey != nil {
+		err := repo.SetRecoveryKey(h, uploadBody.RecoveryKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	if uploadBody.Protected {
+		err := repo.SetProtected(h, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(delegates) > 0 {
+		err := repo.SetDelegates(h, delegates)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !uploadBody.OrgHash.IsEmpty() {
+		return repo.SetOrganisation(h, uploadBody.OrgHash.String())
+	}
+
+	return nil
 }
 
 func validateAddress(addrHash hash.Hash, body *addressUploadBody) *http.Response {
@@ -331,12 +889,37 @@ func validateRedirection(addrHash string) *http.Response {
 }
 
 func recursiveGet(h hash.Hash, depth int) (*address.ResolveInfoType, *http.Response) {
+	chain, httpErr := resolveChain(h, depth)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	return chain[len(chain)-1], nil
+}
+
+// fetchUnfollowed returns the record of h as a chain of one, without following its redirection
+func fetchUnfollowed(h hash.Hash) ([]*address.ResolveInfoType, *http.Response) {
+	info, err := fetchAddress(h.String())
+	if err != nil && err != address.ErrNotFound {
+		return nil, http.CreateError("hash not found", 404)
+	}
+
+	if info == nil || info.Deleted {
+		return nil, http.CreateError("hash not found", 404)
+	}
+
+	return []*address.ResolveInfoType{info}, nil
+}
+
+// resolveChain follows the redirections of h and returns every record on the way, starting with the record of h and
+// ending with the record that does not redirect any further.
+func resolveChain(h hash.Hash, depth int) ([]*address.ResolveInfoType, *http.Response) {
 	curDepth := 0
 	var cyclicHashes []string
+	var chain []*address.ResolveInfoType
 
 	for {
-		repo := address.GetResolveRepository()
-		info, err := repo.Get(h.String())
+		info, err := fetchAddress(h.String())
 		if err != nil && err != address.ErrNotFound {
 			return nil, http.CreateError("hash not found", 404)
 		}
@@ -345,9 +928,11 @@ func recursiveGet(h hash.Hash, depth int) (*address.ResolveInfoType, *http.Respo
 			return nil, http.CreateError("hash not found", 404)
 		}
 
+		chain = append(chain, info)
+
 		// Not redirect, so we can break our loop
 		if info.RedirHash == "" {
-			return info, nil
+			return chain, nil
 		}
 
 		// Increase depth and see if we are allowed to continue
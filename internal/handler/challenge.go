@@ -0,0 +1,77 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"log"
+	"time"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/challenge"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+)
+
+var (
+	// ChallengeSecret is the secret used to issue and verify proof-of-work challenges. Without a secret, no challenges
+	// are issued and proofs are computed over the hash only.
+	ChallengeSecret []byte
+	// ChallengeExpiry is the time a client has to compute the proof-of-work for a challenge
+	ChallengeExpiry = 24 * time.Hour
+)
+
+func GetChallenge(h hash.Hash, _ http.Request) *http.Response {
+	if len(ChallengeSecret) == 0 {
+		return http.CreateError("challenges are not enabled", 404)
+	}
+
+	c, err := challenge.New(ChallengeSecret, h.String(), timeNow().Add(ChallengeExpiry))
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while creating challenge", 500)
+	}
+
+	return http.CreateOutput(http.RawJSONOut{
+		"hash":       c.Hash,
+		"challenge":  c.String(),
+		"proof_data": c.ProofData(),
+		"expires":    c.Expires.Unix(),
+	}, 200)
+}
+
+// validateProofData checks if the proof-of-work has been computed over the right data. When challenges are enabled,
+// this is the hash together with a challenge we issued for it.
+func validateProofData(data, h string) *http.Response {
+	if len(ChallengeSecret) == 0 {
+		if data != h {
+			return http.CreateError("incorrect proof-of-work", 400)
+		}
+
+		return nil
+	}
+
+	switch challenge.Verify(ChallengeSecret, h, data, timeNow()) {
+	case nil:
+		return nil
+	case challenge.ErrExpired:
+		return http.CreateError("proof-of-work challenge expired", 400)
+	default:
+		return http.CreateError("incorrect proof-of-work", 400)
+	}
+}
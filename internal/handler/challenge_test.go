@@ -0,0 +1,88 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChallenge(t *testing.T) {
+	setupRepo()
+	defer func() {
+		ChallengeSecret = nil
+	}()
+
+	addr, _ := pkgAddress.NewAddress("example!")
+
+	// Challenges are disabled by default
+	req := http.NewRequest("GET", "/", "", nil)
+	res := GetChallenge(addr.Hash(), req)
+	assert.Equal(t, 404, res.StatusCode)
+
+	ChallengeSecret = []byte("secret")
+	ChallengeExpiry = time.Hour
+
+	res = GetChallenge(addr.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	type challengeOutput struct {
+		Hash      string `json:"hash"`
+		Challenge string `json:"challenge"`
+		ProofData string `json:"proof_data"`
+		Expires   int64  `json:"expires"`
+	}
+	out := &challengeOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, addr.Hash().String(), out.Hash)
+	assert.Equal(t, addr.Hash().String()+":"+out.Challenge, out.ProofData)
+	assert.Equal(t, time.Date(2010, 04, 07, 13, 34, 56, 0, time.UTC).Unix(), out.Expires)
+
+	// Proofs over the hash only are not accepted anymore
+	pow := proofofwork.New(22, addr.Hash().String(), 1540921)
+	res = insertAddressRecord(*addr, "../../testdata/key-1.json", fakeRoutingId.String(), pow, "")
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "incorrect proof-of-work", "status": "error"}`, res.Body)
+
+	pow = proofofwork.New(MinimumProofBitsAddress, out.ProofData, 0)
+	pow.WorkMulticore()
+
+	// Challenge has expired
+	setRepoTime(time.Date(2010, 04, 07, 13, 34, 56, 0, time.UTC))
+	res = insertAddressRecord(*addr, "../../testdata/key-1.json", fakeRoutingId.String(), pow, "")
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "proof-of-work challenge expired", "status": "error"}`, res.Body)
+
+	// Challenge issued for another hash
+	addr2, _ := pkgAddress.NewAddress("foo!")
+	setRepoTime(time.Date(2010, 04, 07, 13, 00, 00, 0, time.UTC))
+	res = insertAddressRecord(*addr2, "../../testdata/key-1.json", fakeRoutingId.String(), pow, "")
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "incorrect proof-of-work", "status": "error"}`, res.Body)
+
+	res = insertAddressRecord(*addr, "../../testdata/key-1.json", fakeRoutingId.String(), pow, "")
+	assert.Equal(t, 201, res.StatusCode)
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/changefeed"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+)
+
+var (
+	// DefaultChangesLimit is the number of changes returned when no limit is given
+	DefaultChangesLimit = 100
+	// MaxChangesLimit is the maximum number of changes that can be fetched in a single request
+	MaxChangesLimit = 1000
+)
+
+func GetChanges(_ hash.Hash, req http.Request) *http.Response {
+	var err error
+
+	var since uint64
+	if req.Query["since"] != "" {
+		since, err = strconv.ParseUint(req.Query["since"], 10, 64)
+		if err != nil {
+			return http.CreateError("invalid cursor", 400)
+		}
+	}
+
+	limit := DefaultChangesLimit
+	if req.Query["limit"] != "" {
+		limit, err = strconv.Atoi(req.Query["limit"])
+		if err != nil || limit < 1 {
+			return http.CreateError("invalid limit", 400)
+		}
+	}
+	if limit > MaxChangesLimit {
+		limit = MaxChangesLimit
+	}
+
+	changes, err := changefeed.GetRepository().Since(since, limit)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching changes", 500)
+	}
+
+	// The cursor to use for the next page. Stays the same when there are no new changes.
+	cursor := since
+	if len(changes) > 0 {
+		cursor = changes[len(changes)-1].Cursor
+	}
+
+	if changes == nil {
+		changes = []changefeed.Change{}
+	}
+
+	return http.CreateOutput(http.RawJSONOut{
+		"changes":  changes,
+		"cursor":   cursor,
+		"has_more": len(changes) == limit,
+	}, 200)
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/changefeed"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+type changesOutput struct {
+	Changes []changefeed.Change `json:"changes"`
+	Cursor  uint64              `json:"cursor"`
+	HasMore bool                `json:"has_more"`
+}
+
+func TestChanges(t *testing.T) {
+	setupRepo()
+
+	routingHash1 := hash.New("routing1")
+	routingHash2 := hash.New("routing2")
+	res := insertRoutingRecord(routingHash1, "../../testdata/key-1.json", "127.0.0.1")
+	assert.Equal(t, 201, res.StatusCode)
+	res = insertRoutingRecord(routingHash2, "../../testdata/key-2.json", "127.0.0.2")
+	assert.Equal(t, 201, res.StatusCode)
+
+	// Delete the first record
+	privKey, _, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	req := http.NewRequest("DELETE", "/", "", nil)
+	req.Headers.Set("Authorization", "Bearer "+http.GenerateAuthenticationToken([]byte(routingHash1.String()+"1270643696000000000"), *privKey))
+	res = DeleteRoutingHash(routingHash1, req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	// Fetch the first page
+	req = http.NewRequest("GET", "/changes", "", nil)
+	req.Query["limit"] = "2"
+	res = GetChanges("", req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	out := &changesOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Len(t, out.Changes, 2)
+	assert.True(t, out.HasMore)
+	assert.Equal(t, uint64(2), out.Cursor)
+	assert.Equal(t, changefeed.Change{
+		Cursor:    1,
+		Type:      "routing",
+		Action:    "create",
+		Hash:      routingHash1.String(),
+		Serial:    1270643696000000000,
+		Timestamp: 1270643696,
+	}, out.Changes[0])
+	assert.Equal(t, routingHash2.String(), out.Changes[1].Hash)
+
+	// Fetch the next page
+	req.Query["since"] = "2"
+	res = GetChanges("", req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	out = &changesOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Len(t, out.Changes, 1)
+	assert.False(t, out.HasMore)
+	assert.Equal(t, uint64(3), out.Cursor)
+	assert.Equal(t, "delete", out.Changes[0].Action)
+	assert.Equal(t, uint64(0), out.Changes[0].Serial)
+
+	// No new changes keeps the cursor
+	req.Query["since"] = "3"
+	res = GetChanges("", req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.JSONEq(t, `{"changes": [], "cursor": 3, "has_more": false}`, res.Body)
+
+	req.Query["since"] = "foo"
+	res = GetChanges("", req)
+	assert.Equal(t, 400, res.StatusCode)
+
+	req.Query["since"] = "0"
+	req.Query["limit"] = "0"
+	res = GetChanges("", req)
+	assert.Equal(t, 400, res.StatusCode)
+}
+
+// lastChange returns the most recent change in the feed
+func lastChange(t *testing.T) changefeed.Change {
+	changes, err := changefeed.GetRepository().Since(0, MaxChangesLimit)
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, changes) {
+		return changefeed.Change{}
+	}
+
+	return changes[len(changes)-1]
+}
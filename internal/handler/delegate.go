@@ -0,0 +1,142 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+)
+
+type delegateUploadBody struct {
+	PublicKey *bmcrypto.PubKey `json:"public_key"`
+	Scopes    []string         `json:"scopes"`
+}
+
+// convertDelegates validates the uploaded delegates and converts them to address delegates
+func convertDelegates(delegates []delegateUploadBody) ([]address.DelegateType, *http.Response) {
+	var ret []address.DelegateType
+
+	for _, d := range delegates {
+		if d.PublicKey == nil || len(d.Scopes) == 0 {
+			return nil, http.CreateError("invalid delegate", 400)
+		}
+
+		if httpErr := checkAdditionalKeyPolicy(d.PublicKey); httpErr != nil {
+			return nil, httpErr
+		}
+
+		for _, scope := range d.Scopes {
+			if !address.IsValidScope(scope) {
+				return nil, http.CreateError("invalid delegate scope: "+scope, 400)
+			}
+		}
+
+		ret = append(ret, address.DelegateType{
+			PublicKey: d.PublicKey.String(),
+			Scopes:    d.Scopes,
+		})
+	}
+
+	return ret, nil
+}
+
+// delegatesOutput returns the delegates of an address, or nil when there are none
+func delegatesOutput(delegates []address.DelegateType) []http.RawJSONOut {
+	var ret []http.RawJSONOut
+
+	for _, d := range delegates {
+		ret = append(ret, http.RawJSONOut{
+			"public_key": d.PublicKey,
+			"scopes":     d.Scopes,
+		})
+	}
+
+	return ret
+}
+
+// findDelegate returns the delegate that created the authentication token, or nil when no delegate matches
+func findDelegate(req http.Request, current *address.ResolveInfoType) *address.DelegateType {
+	for i := range current.Delegates {
+		if req.ValidateAuthenticationToken(current.Delegates[i].PublicKey, current.Hash+current.RoutingID+strconv.FormatUint(current.Serial, 10)) {
+			return &current.Delegates[i]
+		}
+	}
+
+	return nil
+}
+
+// updateAddressByDelegate updates the address on behalf of the owner. Only the changes allowed by the scopes of the
+// delegate are accepted.
+func updateAddressByDelegate(uploadBody addressUploadBody, current *address.ResolveInfoType, delegate *address.DelegateType) *http.Response {
+	if !delegate.HasScope(address.ScopeRoutingOnly) {
+		return http.CreateError("delegate is not allowed to update the routing", 403)
+	}
+
+	// Routing-only delegates cannot touch anything else than the routing ID
+	if uploadBody.PublicKey != nil && uploadBody.PublicKey.String() != current.PubKey {
+		return http.CreateError("delegate is not allowed to change the public key", 403)
+	}
+	if uploadBody.RedirHash != current.RedirHash || uploadBody.Delegates != nil || (uploadBody.Protected && !current.Protected) {
+		return http.CreateError("delegate is only allowed to change the routing", 403)
+	}
+
+	currentKey, err := bmcrypto.NewPubKey(current.PubKey)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while updating: ", 500)
+	}
+
+	// Routing changes on protected addresses do not take effect immediately
+	pending := current.Protected && uploadBody.RoutingID != current.RoutingID
+	routing := uploadBody.RoutingID
+	if pending {
+		routing = current.RoutingID
+	}
+
+	repo := address.GetResolveRepository()
+	res, err := repo.Update(current, routing, currentKey, current.RedirHash)
+	if err != nil || !res {
+		log.Print(err)
+		return http.CreateError("error while updating: ", 500)
+	}
+
+	if pending {
+		err = repo.SetPendingRouting(current.Hash, uploadBody.RoutingID, current.RedirHash, timeNow().Add(KeyRotationDelay))
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while updating: ", 500)
+		}
+	}
+
+	index, logged := logMutation(translog.TypeAddress, translog.ActionUpdate, current.Hash, current.PubKey)
+	recordVersion(translog.TypeAddress, current.Hash)
+	indexFingerprint(translog.TypeAddress, current.Hash, current.PubKey)
+
+	if pending {
+		return receiptMessage("address has been updated, changes are pending", 200, addressReceipt(current.Hash, index, logged))
+	}
+
+	return receiptMessage("address has been updated", 200, addressReceipt(current.Hash, index, logged))
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"github.com/bitmaelum/key-resolver-go/internal/adaptive"
+	"github.com/bitmaelum/key-resolver-go/internal/difficulty"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+)
+
+// DifficultySchedule holds the scheduled proof-of-work difficulty increases. Before the first step takes effect, or
+// without a schedule, the MinimumProofBits variables are used.
+var DifficultySchedule *difficulty.Schedule
+
+// AdaptiveDifficulty raises the difficulty on top of the schedule when many records are created. It is disabled
+// when nil. Creations are counted in memory, so it is only enabled in the standalone resolver: every Lambda instance
+// would count its own creations and advertise its own difficulty.
+var AdaptiveDifficulty *adaptive.Controller
+
+// staticProofBits returns the number of bits for the record type when no schedule step is in effect
+func staticProofBits(typ string) int {
+	switch typ {
+	case translog.TypeAddress:
+		return MinimumProofBitsAddress
+	case translog.TypeOrganisation:
+		return MinimumProofBitsOrganisation
+	case translog.TypeRouting:
+		return MinimumProofBitsRouting
+	}
+
+	return 0
+}
+
+// currentProofBits returns the number of bits that new proofs for the record type must have
+func currentProofBits(typ string) int {
+	return scheduledProofBits(typ) + adaptiveExtraBits(typ)
+}
+
+// scheduledProofBits returns the number of bits for the record type according to the schedule
+func scheduledProofBits(typ string) int {
+	if DifficultySchedule == nil {
+		return staticProofBits(typ)
+	}
+
+	_, cur, _ := DifficultySchedule.At(timeNow())
+	if cur == nil {
+		return staticProofBits(typ)
+	}
+
+	return cur.Bits(typ)
+}
+
+// previousProofBits returns the number of bits of the previous difficulty level, as long as it is still accepted
+// because the current level has taken effect less than the grace period ago. It returns 0 otherwise.
+func previousProofBits(typ string) int {
+	if DifficultySchedule == nil {
+		return 0
+	}
+
+	prev, cur, _ := DifficultySchedule.At(timeNow())
+	if !DifficultySchedule.InGracePeriod(cur, timeNow()) {
+		return 0
+	}
+
+	if prev == nil {
+		return staticProofBits(typ) + adaptiveExtraBits(typ)
+	}
+
+	return prev.Bits(typ) + adaptiveExtraBits(typ)
+}
+
+// adaptiveExtraBits returns the number of bits the adaptive controller currently adds for the record type
+func adaptiveExtraBits(typ string) int {
+	if AdaptiveDifficulty == nil {
+		return 0
+	}
+
+	return AdaptiveDifficulty.ExtraBits(typ)
+}
+
+// recordCreation counts the creation of a record of the given type for the adaptive controller
+func recordCreation(typ string) {
+	if AdaptiveDifficulty == nil {
+		return
+	}
+
+	AdaptiveDifficulty.Record(typ)
+}
+
+// AdaptiveDifficultyMetrics returns the metrics of the adaptive controller in the prometheus text format
+func AdaptiveDifficultyMetrics() string {
+	if AdaptiveDifficulty == nil {
+		return ""
+	}
+
+	return AdaptiveDifficulty.Metrics()
+}
+
+// acceptedProofBits returns the minimum number of bits a proof for the record type must have
+func acceptedProofBits(typ string) int {
+	bits := currentProofBits(typ)
+
+	prev := previousProofBits(typ)
+	if prev > 0 && prev < bits {
+		return prev
+	}
+
+	return bits
+}
+
+// ProofOfWorkConfig returns the current and upcoming proof-of-work difficulty, as published in the config.json
+func ProofOfWorkConfig() http.RawJSONOut {
+	types := []string{translog.TypeAddress, translog.TypeOrganisation, translog.TypeRouting}
+
+	data := http.RawJSONOut{}
+	for _, typ := range types {
+		data[typ] = currentProofBits(typ)
+	}
+	data["algorithms"] = algorithmsConfig(types)
+
+	if AdaptiveDifficulty != nil {
+		extra := http.RawJSONOut{}
+		for _, typ := range types {
+			extra[typ] = adaptiveExtraBits(typ)
+		}
+		data["adaptive"] = extra
+	}
+
+	if DifficultySchedule == nil {
+		return data
+	}
+
+	_, cur, next := DifficultySchedule.At(timeNow())
+	if next != nil {
+		nextData := http.RawJSONOut{
+			"from": next.From.Unix(),
+		}
+		for _, typ := range types {
+			nextData[typ] = next.Bits(typ)
+		}
+		data["next"] = nextData
+	}
+
+	if DifficultySchedule.InGracePeriod(cur, timeNow()) {
+		prevData := http.RawJSONOut{
+			"until": cur.From.Add(DifficultySchedule.GracePeriod).Unix(),
+		}
+		for _, typ := range types {
+			prevData[typ] = previousProofBits(typ)
+		}
+		data["previous"] = prevData
+	}
+
+	return data
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"github.com/bitmaelum/key-resolver-go/internal/adaptive"
+	"github.com/bitmaelum/key-resolver-go/internal/difficulty"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDifficultySchedule(t *testing.T) {
+	setupRepo()
+	defer func() {
+		DifficultySchedule = nil
+	}()
+
+	// Without a schedule, the static bits are used
+	assert.Equal(t, http.RawJSONOut{
+		"address":      5,
+		"organisation": 5,
+		"routing":      5,
+		"algorithms":   http.RawJSONOut{"sha256": http.RawJSONOut{}},
+	}, ProofOfWorkConfig())
+
+	DifficultySchedule = &difficulty.Schedule{
+		GracePeriod: time.Hour,
+		Steps: []difficulty.Step{
+			{From: time.Date(2010, 04, 01, 0, 0, 0, 0, time.UTC), Address: 6, Organisation: 7, Routing: 6},
+			{From: time.Date(2010, 04, 07, 12, 0, 0, 0, time.UTC), Address: 8, Organisation: 9, Routing: 8},
+			{From: time.Date(2010, 05, 01, 0, 0, 0, 0, time.UTC), Address: 10, Organisation: 11, Routing: 10},
+		},
+	}
+
+	// Second step took effect 35 minutes ago, so the first step is still accepted
+	assert.Equal(t, 8, currentProofBits(translog.TypeAddress))
+	assert.Equal(t, 6, acceptedProofBits(translog.TypeAddress))
+	assert.Equal(t, 7, acceptedProofBits(translog.TypeOrganisation))
+	assert.Equal(t, http.RawJSONOut{
+		"address":      8,
+		"organisation": 9,
+		"routing":      8,
+		"algorithms":   http.RawJSONOut{"sha256": http.RawJSONOut{}},
+		"next": http.RawJSONOut{
+			"from":         time.Date(2010, 05, 01, 0, 0, 0, 0, time.UTC).Unix(),
+			"address":      10,
+			"organisation": 11,
+			"routing":      10,
+		},
+		"previous": http.RawJSONOut{
+			"until":        time.Date(2010, 04, 07, 13, 0, 0, 0, time.UTC).Unix(),
+			"address":      6,
+			"organisation": 7,
+			"routing":      6,
+		},
+	}, ProofOfWorkConfig())
+
+	addr1, _ := pkgAddress.NewAddress("foo!")
+	pow1 := proofofwork.New(6, addr1.Hash().String(), 0)
+	pow1.WorkMulticore()
+
+	addr2, _ := pkgAddress.NewAddress("bar!")
+	pow2 := proofofwork.New(6, addr2.Hash().String(), 0)
+	pow2.WorkMulticore()
+
+	res := insertAddressRecord(*addr1, "../../testdata/key-1.json", fakeRoutingId.String(), pow1, "")
+	assert.Equal(t, 201, res.StatusCode)
+
+	// After the grace period, only the current level is accepted
+	setRepoTime(time.Date(2010, 04, 07, 13, 0, 0, 0, time.UTC))
+	assert.Equal(t, 8, acceptedProofBits(translog.TypeAddress))
+	assert.NotContains(t, ProofOfWorkConfig(), "previous")
+
+	res = insertAddressRecord(*addr2, "../../testdata/key-2.json", fakeRoutingId.String(), pow2, "")
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "proof-of-work too weak (need 8 bits)", "status": "error"}`, res.Body)
+
+	// Before the first step, the static bits are used, and the first step is still to come
+	setRepoTime(time.Date(2010, 03, 01, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 5, acceptedProofBits(translog.TypeAddress))
+	assert.Equal(t, 6, ProofOfWorkConfig()["next"].(http.RawJSONOut)["address"])
+
+	// The static bits are accepted during the grace period of the first step
+	setRepoTime(time.Date(2010, 04, 01, 0, 30, 0, 0, time.UTC))
+	assert.Equal(t, 6, currentProofBits(translog.TypeAddress))
+	assert.Equal(t, 5, acceptedProofBits(translog.TypeAddress))
+}
+
+func TestAdaptiveDifficulty(t *testing.T) {
+	setupRepo()
+	defer func() {
+		AdaptiveDifficulty = nil
+	}()
+
+	AdaptiveDifficulty = adaptive.New(adaptive.Config{
+		Window:       time.Hour,
+		Threshold:    1,
+		MaxExtraBits: 2,
+		Interval:     time.Minute,
+	}, func() time.Time {
+		return timeNow()
+	})
+
+	assert.Equal(t, http.RawJSONOut{
+		"address":      5,
+		"organisation": 5,
+		"routing":      5,
+		"algorithms":   http.RawJSONOut{"sha256": http.RawJSONOut{}},
+		"adaptive":     http.RawJSONOut{"address": 0, "organisation": 0, "routing": 0},
+	}, ProofOfWorkConfig())
+
+	addr1, _ := pkgAddress.NewAddress("foo!")
+	pow1 := proofofwork.New(22, addr1.Hash().String(), 1310761)
+	addr2, _ := pkgAddress.NewAddress("bar!")
+	pow2 := proofofwork.New(22, addr2.Hash().String(), 1019732)
+	addr3, _ := pkgAddress.NewAddress("example!")
+	pow3 := proofofwork.New(5, addr3.Hash().String(), 0)
+	pow3.WorkMulticore()
+
+	res := insertAddressRecord(*addr1, "../../testdata/key-1.json", fakeRoutingId.String(), pow1, "")
+	assert.Equal(t, 201, res.StatusCode)
+	assert.Equal(t, 5, acceptedProofBits(translog.TypeAddress))
+
+	// Exceeding the threshold raises the difficulty of addresses only
+	res = insertAddressRecord(*addr2, "../../testdata/key-2.json", fakeRoutingId.String(), pow2, "")
+	assert.Equal(t, 201, res.StatusCode)
+	assert.Equal(t, 6, acceptedProofBits(translog.TypeAddress))
+	assert.Equal(t, 5, acceptedProofBits(translog.TypeOrganisation))
+	assert.Equal(t, 6, ProofOfWorkConfig()["address"])
+	assert.Equal(t, 1, ProofOfWorkConfig()["adaptive"].(http.RawJSONOut)["address"])
+	assert.Contains(t, AdaptiveDifficultyMetrics(), `keyresolver_pow_decisions{type="address", decision="raise"} 1`)
+
+	res = insertAddressRecord(*addr3, "../../testdata/key-3.json", fakeRoutingId.String(), pow3, "")
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "proof-of-work too weak (need 6 bits)", "status": "error"}`, res.Body)
+
+	// Decays when the creations have left the window
+	setRepoTime(time.Date(2010, 04, 07, 14, 00, 00, 0, time.UTC))
+	assert.Equal(t, 5, acceptedProofBits(translog.TypeAddress))
+
+	res = insertAddressRecord(*addr3, "../../testdata/key-3.json", fakeRoutingId.String(), pow3, "")
+	assert.Equal(t, 201, res.StatusCode)
+}
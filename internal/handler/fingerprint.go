@@ -0,0 +1,181 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/fingerprint"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/organisation"
+	"github.com/bitmaelum/key-resolver-go/internal/routing"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+)
+
+// Status of a record in the fingerprint index
+const (
+	fingerprintActive  = "active"  // The record currently uses the key
+	fingerprintRotated = "rotated" // The record has used the key, but has switched to another key
+	fingerprintDeleted = "deleted" // The record has been (soft) deleted
+)
+
+// AdminKey is the public key of the resolver operator. It is allowed to query the fingerprint index for any key.
+var AdminKey *bmcrypto.PubKey
+
+// FingerprintAuthWindow is the time a signed query of the fingerprint index stays valid, before or after the
+// timestamp that is signed together with the fingerprint
+var FingerprintAuthWindow = 5 * time.Minute
+
+// indexFingerprint adds the key of the record to the fingerprint index. Like the transparency log, a failure to index
+// the key is reported but will not fail the request.
+func indexFingerprint(typ, h, pubKey string) {
+	pk, err := bmcrypto.NewPubKey(pubKey)
+	if err != nil {
+		return
+	}
+
+	err = fingerprint.GetRepository().Add(fingerprint.Entry{
+		Fingerprint: pk.Fingerprint(),
+		Type:        typ,
+		Hash:        h,
+		PublicKey:   pubKey,
+	})
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+func GetFingerprint(_ hash.Hash, req http.Request) *http.Response {
+	fp, ok := req.Params["fingerprint"]
+	if !ok {
+		return http.CreateError("not found", 404)
+	}
+
+	ts, httpErr := fingerprintTimestamp(req)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	entries, err := fingerprint.GetRepository().Get(fp)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching fingerprint", 500)
+	}
+
+	if !isFingerprintAuthenticated(req, fp+ts, entries) {
+		return http.CreateError("unauthenticated", 401)
+	}
+
+	records := make([]http.RawJSONOut, 0, len(entries))
+	for _, e := range entries {
+		records = append(records, fingerprintRecordOutput(e))
+	}
+
+	return http.CreateOutput(http.RawJSONOut{
+		"fingerprint": fp,
+		"records":     records,
+	}, 200)
+}
+
+// fingerprintTimestamp returns the timestamp of the query, which must be within the FingerprintAuthWindow of the
+// current time. Signing it together with the fingerprint keeps a captured token from being replayed later on.
+func fingerprintTimestamp(req http.Request) (string, *http.Response) {
+	ts, err := strconv.ParseInt(req.Query["timestamp"], 10, 64)
+	if err != nil {
+		return "", http.CreateError("incorrect timestamp", 400)
+	}
+
+	d := timeNow().Sub(time.Unix(ts, 0))
+	if d > FingerprintAuthWindow || d < -FingerprintAuthWindow {
+		return "", http.CreateError("timestamp expired", 401)
+	}
+
+	return req.Query["timestamp"], nil
+}
+
+// isFingerprintAuthenticated returns true when the data is signed by the admin key, or by the key with the
+// fingerprint itself
+func isFingerprintAuthenticated(req http.Request, data string, entries []fingerprint.Entry) bool {
+	if AdminKey != nil && req.ValidateAuthenticationToken(AdminKey.String(), data) {
+		return true
+	}
+
+	// All entries hold the same key, so any of them can be used to verify the request
+	return len(entries) > 0 && req.ValidateAuthenticationToken(entries[0].PublicKey, data)
+}
+
+// fingerprintRecordOutput returns the record of the entry together with its current status
+func fingerprintRecordOutput(e fingerprint.Entry) http.RawJSONOut {
+	data := http.RawJSONOut{
+		"type":   e.Type,
+		"hash":   e.Hash,
+		"status": fingerprintStatus(e),
+	}
+
+	if e.Type == translog.TypeAddress {
+		ks, err := address.GetResolveRepository().GetKeyStatus(e.Hash, e.Fingerprint)
+		if err == nil {
+			data["key_status"] = ks.ToString()
+		}
+	}
+
+	return data
+}
+
+// fingerprintStatus returns the status of the key of the entry within its record
+func fingerprintStatus(e fingerprint.Entry) string {
+	var (
+		found   bool
+		deleted bool
+		pubKey  string
+	)
+
+	switch e.Type {
+	case translog.TypeAddress:
+		info, err := address.GetResolveRepository().Get(e.Hash)
+		if err == nil && info != nil {
+			found, deleted, pubKey = true, info.Deleted, info.PubKey
+		}
+	case translog.TypeOrganisation:
+		info, err := organisation.GetResolveRepository().Get(e.Hash)
+		if err == nil && info != nil {
+			found, deleted, pubKey = true, info.Deleted, info.PubKey
+		}
+	case translog.TypeRouting:
+		info, err := routing.GetResolveRepository().Get(e.Hash)
+		if err == nil && info != nil {
+			found, pubKey = true, info.PubKey
+		}
+	}
+
+	switch {
+	case !found || deleted:
+		return fingerprintDeleted
+	case pubKey == e.PublicKey:
+		return fingerprintActive
+	default:
+		return fingerprintRotated
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	setupRepo()
+	defer func() {
+		AdminKey = nil
+	}()
+
+	addr, _ := pkgAddress.NewAddress("example!")
+	pow := proofofwork.New(22, addr.Hash().String(), 1540921)
+
+	res := insertAddressRecord(*addr, "../../testdata/key-1.json", fakeRoutingId.String(), pow, "")
+	assert.Equal(t, 201, res.StatusCode)
+	res = insertRoutingRecord(fakeRoutingId, "../../testdata/key-1.json", "127.0.0.1")
+	assert.Equal(t, 201, res.StatusCode)
+
+	privKey1, pubKey1, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	privKey2, pubKey2, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+	fp := pubKey1.Fingerprint()
+
+	type fingerprintOutput struct {
+		Fingerprint string `json:"fingerprint"`
+		Records     []struct {
+			Type      string `json:"type"`
+			Hash      string `json:"hash"`
+			Status    string `json:"status"`
+			KeyStatus string `json:"key_status"`
+		} `json:"records"`
+	}
+
+	now := timeNow()
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	// Without a timestamp
+	req := http.NewRequest("GET", "/", "", map[string]string{"fingerprint": fp})
+	req.Headers.Set("authorization", "BEARER "+http.GenerateAuthenticationToken([]byte(fp), *privKey1))
+	res = GetFingerprint("", req)
+	assert.Equal(t, 400, res.StatusCode)
+
+	// Unauthenticated
+	req = http.NewRequest("GET", "/", "", map[string]string{"fingerprint": fp})
+	req.Query["timestamp"] = ts
+	res = GetFingerprint("", req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	// Signed by another key
+	req.Headers.Set("authorization", "BEARER "+http.GenerateAuthenticationToken([]byte(fp+ts), *privKey2))
+	res = GetFingerprint("", req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	// Signed without the timestamp
+	req.Headers.Set("authorization", "BEARER "+http.GenerateAuthenticationToken([]byte(fp), *privKey1))
+	res = GetFingerprint("", req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	// Signed by the key itself
+	req.Headers.Set("authorization", "BEARER "+http.GenerateAuthenticationToken([]byte(fp+ts), *privKey1))
+	res = GetFingerprint("", req)
+	assert.Equal(t, 200, res.StatusCode)
+	out := &fingerprintOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, fp, out.Fingerprint)
+	assert.Len(t, out.Records, 2)
+	assert.Equal(t, "address", out.Records[0].Type)
+	assert.Equal(t, addr.Hash().String(), out.Records[0].Hash)
+	assert.Equal(t, "active", out.Records[0].Status)
+	assert.Equal(t, "normal", out.Records[0].KeyStatus)
+	assert.Equal(t, "routing", out.Records[1].Type)
+	assert.Equal(t, fakeRoutingId.String(), out.Records[1].Hash)
+	assert.Equal(t, "active", out.Records[1].Status)
+
+	// Rotated and deleted records
+	repo := address.GetResolveRepository()
+	info, _ := repo.Get(addr.Hash().String())
+	_, _ = repo.Update(info, fakeRoutingId.String(), pubKey2, "")
+	res = GetFingerprint("", req)
+	out = &fingerprintOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, "rotated", out.Records[0].Status)
+
+	_, _ = repo.SoftDelete(addr.Hash().String())
+	res = GetFingerprint("", req)
+	out = &fingerprintOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, "deleted", out.Records[0].Status)
+
+	// The token cannot be replayed once the timestamp has expired
+	timeNow = func() time.Time {
+		return now.Add(FingerprintAuthWindow + time.Second)
+	}
+	res = GetFingerprint("", req)
+	assert.Equal(t, 401, res.StatusCode)
+	timeNow = func() time.Time {
+		return now
+	}
+
+	// The admin key can query any fingerprint, including unknown ones
+	AdminKey = pubKey2
+	req = http.NewRequest("GET", "/", "", map[string]string{"fingerprint": "unknown"})
+	req.Query["timestamp"] = ts
+	req.Headers.Set("authorization", "BEARER "+http.GenerateAuthenticationToken([]byte("unknown"+ts), *privKey2))
+	res = GetFingerprint("", req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.JSONEq(t, `{"fingerprint": "unknown", "records": []}`, res.Body)
+}
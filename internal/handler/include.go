@@ -0,0 +1,87 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package handler
+
+import (
+	"log"
+	"strings"
+
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/organisation"
+	"github.com/bitmaelum/key-resolver-go/internal/routing"
+)
+
+// Records that can be embedded in an address lookup with ?include=
+const (
+	includeRouting      = "routing"
+	includeOrganisation = "organisation"
+)
+
+// parseIncludes parses a comma separated list of records to embed
+func parseIncludes(s string) (map[string]bool, *http.Response) {
+	includes := make(map[string]bool)
+	if s == "" {
+		return includes, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part != includeRouting && part != includeOrganisation {
+			return nil, http.CreateError("invalid include", 400)
+		}
+		includes[part] = true
+	}
+
+	return includes, nil
+}
+
+// includedRouting returns the routing record of the address, or the reason why it cannot be included
+func includedRouting(info *address.ResolveInfoType) (http.RawJSONOut, string) {
+	if info.RoutingID == "" {
+		return nil, "address has no routing id"
+	}
+
+	rt, err := routing.GetResolveRepository().Get(info.RoutingID)
+	if err != nil {
+		if err != routing.ErrNotFound {
+			log.Print(err)
+		}
+		return nil, "routing not found"
+	}
+
+	return routingOutput(rt), ""
+}
+
+// includedOrganisation returns the organisation record of the address, or the reason why it cannot be included
+func includedOrganisation(info *address.ResolveInfoType) (http.RawJSONOut, string) {
+	if info.OrgHash == "" {
+		return nil, "address has no organisation"
+	}
+
+	org, err := organisation.GetResolveRepository().Get(info.OrgHash)
+	if err != nil || org.Deleted {
+		if err != nil && err != organisation.ErrNotFound {
+			log.Print(err)
+		}
+		return nil, "organisation not found"
+	}
+
+	return organisationOutput(org), ""
+}
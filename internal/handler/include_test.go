@@ -0,0 +1,124 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/stretchr/testify/assert"
+)
+
+type includeOutput struct {
+	Hash         string           `json:"hash"`
+	Routing      *routingInfoType `json:"routing"`
+	Organisation *struct {
+		Hash         string `json:"hash"`
+		SerialNumber uint64 `json:"serial_number"`
+	} `json:"organisation"`
+	Missing map[string]string `json:"missing"`
+}
+
+func TestAddressIncludes(t *testing.T) {
+	setupRepo()
+	MaxRedirectDepth = 10
+
+	routingHash := hash.New("routing1")
+	res := insertRoutingRecord(routingHash, "../../testdata/key-3.json", "127.0.0.1")
+	assert.Equal(t, 201, res.StatusCode)
+
+	// Regular address with routing
+	addr1, _ := pkgAddress.NewAddress("john!")
+	pow1 := proofofwork.New(MinimumProofBitsAddress, addr1.Hash().String(), 0)
+	pow1.WorkMulticore()
+	res = insertAddressRecord(*addr1, "../../testdata/key-1.json", routingHash.String(), pow1, "")
+	assert.Equal(t, 201, res.StatusCode)
+
+	// Organisational address that redirects to the regular address
+	addr2, _ := pkgAddress.NewAddress("john@acme!")
+	pow2 := proofofwork.New(MinimumProofBitsAddress, addr2.Hash().String(), 0)
+	pow2.WorkMulticore()
+	res = insertAddressRecord(*addr2, "../../testdata/key-2.json", "", pow2, addr1.Hash().String())
+	assert.Equal(t, 201, res.StatusCode)
+
+	// Without includes, nothing is embedded
+	req := http.NewRequest("GET", "/", "", nil)
+	res = GetAddressHash(addr2.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	out := &includeOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Nil(t, out.Routing)
+	assert.Nil(t, out.Organisation)
+	assert.Nil(t, out.Missing)
+
+	// Organisation does not exist yet
+	req.Query["include"] = "routing,organisation"
+	res = GetAddressHash(addr2.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	out = &includeOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, addr1.Hash().String(), out.Hash)
+	assert.Equal(t, routingHash.String(), out.Routing.Hash)
+	assert.Equal(t, "127.0.0.1", out.Routing.Routing)
+	assert.Equal(t, uint64(1270643696000000000), out.Routing.SerialNumber)
+	assert.Nil(t, out.Organisation)
+	assert.Equal(t, map[string]string{"organisation": "organisation not found"}, out.Missing)
+
+	orgHash := addr2.OrgHash()
+	pow := proofofwork.New(MinimumProofBitsOrganisation, orgHash.String(), 0)
+	pow.WorkMulticore()
+	res = insertOrganisationRecord(orgHash, "../../testdata/key-5.json", pow, nil)
+	assert.Equal(t, 201, res.StatusCode)
+
+	res = GetAddressHash(addr2.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	out = &includeOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, orgHash.String(), out.Organisation.Hash)
+	assert.Equal(t, uint64(1270643696000000000), out.Organisation.SerialNumber)
+	assert.Nil(t, out.Missing)
+
+	// Regular address has no organisation
+	res = GetAddressHash(addr1.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	out = &includeOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.NotNil(t, out.Routing)
+	assert.Equal(t, map[string]string{"organisation": "address has no organisation"}, out.Missing)
+
+	// Missing routing record
+	routingHash2 := hash.New("routing2")
+	updateAddressRecord(*addr1, "../../testdata/key-1.json", routingHash2.String(), "")
+	req.Query["include"] = "routing"
+	res = GetAddressHash(addr1.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	out = &includeOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Nil(t, out.Routing)
+	assert.Equal(t, map[string]string{"routing": "routing not found"}, out.Missing)
+
+	req.Query["include"] = "routing,foo"
+	res = GetAddressHash(addr1.Hash(), req)
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "invalid include", "status": "error"}`, res.Body)
+}
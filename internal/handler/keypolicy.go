@@ -0,0 +1,93 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"log"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/key-resolver-go/internal/fingerprint"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/keypolicy"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+)
+
+// KeyPolicy is the policy that public keys of addresses and organisations must comply with
+var KeyPolicy = keypolicy.Default()
+
+// checkKeyPolicy checks if the key can be used by the record with the given type and hash. It returns an error
+// response when the key does not comply with the key policy.
+func checkKeyPolicy(pk *bmcrypto.PubKey, typ, h string) *http.Response {
+	records, err := countKeyRecords(pk, typ, h)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while checking key policy", 500)
+	}
+
+	v := KeyPolicy.Check(pk, records)
+	if v != nil {
+		return http.CreateErrorWithCode(v.Message, v.Code, 400)
+	}
+
+	return nil
+}
+
+// checkAdditionalKeyPolicy checks a recovery, delegate or admin key against the key policy. These keys do not belong
+// to a record of their own, so the reuse limit does not apply to them.
+func checkAdditionalKeyPolicy(pk *bmcrypto.PubKey) *http.Response {
+	v := KeyPolicy.Check(pk, 0)
+	if v != nil {
+		return http.CreateErrorWithCode(v.Message, v.Code, 400)
+	}
+
+	return nil
+}
+
+// countKeyRecords returns the number of other address and organisation records that actively use the key. Counting
+// stops when the reuse limit has been reached.
+func countKeyRecords(pk *bmcrypto.PubKey, typ, h string) (int, error) {
+	if pk == nil || KeyPolicy.MaxRecordsPerKey <= 0 {
+		return 0, nil
+	}
+
+	entries, err := fingerprint.GetRepository().Get(pk.Fingerprint())
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, e := range entries {
+		if e.Type != translog.TypeAddress && e.Type != translog.TypeOrganisation {
+			continue
+		}
+		if e.Type == typ && e.Hash == h {
+			continue
+		}
+
+		if fingerprintStatus(e) == fingerprintActive {
+			count++
+		}
+		if count >= KeyPolicy.MaxRecordsPerKey {
+			break
+		}
+	}
+
+	return count, nil
+}
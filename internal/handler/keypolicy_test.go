@@ -0,0 +1,207 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/keypolicy"
+	"github.com/bitmaelum/key-resolver-go/internal/proof"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyPolicy(t *testing.T) {
+	setupRepo()
+
+	type errorOutput struct {
+		Status  string `json:"status"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+
+	addr1, _ := pkgAddress.NewAddress("foo!")
+	pow1 := proofofwork.New(22, addr1.Hash().String(), 1310761)
+
+	addr2, _ := pkgAddress.NewAddress("bar!")
+	pow2 := proofofwork.New(22, addr2.Hash().String(), 1019732)
+
+	// Key-1 is a 2048 bit RSA key
+	KeyPolicy.MinRSABits = 4096
+	res := insertAddressRecord(*addr1, "../../testdata/key-1.json", fakeRoutingId.String(), pow1, "")
+	assert.Equal(t, 400, res.StatusCode)
+	out := &errorOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, "error", out.Status)
+	assert.Equal(t, keypolicy.CodeRSAKeySize, out.Code)
+	assert.Equal(t, "rsa key too small (need 4096 bits)", out.Message)
+
+	// Only a single record per key
+	KeyPolicy = keypolicy.Default()
+	KeyPolicy.MaxRecordsPerKey = 1
+	res = insertAddressRecord(*addr1, "../../testdata/key-1.json", fakeRoutingId.String(), pow1, "")
+	assert.Equal(t, 201, res.StatusCode)
+
+	res = insertAddressRecord(*addr2, "../../testdata/key-1.json", fakeRoutingId.String(), pow2, "")
+	assert.Equal(t, 400, res.StatusCode)
+	out = &errorOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, keypolicy.CodeReuseLimit, out.Code)
+
+	// Updating the record with its own key is still allowed
+	res = postAddressUpdate(*addr1, "../../testdata/key-1.json", "../../testdata/key-1.json")
+	assert.Equal(t, 200, res.StatusCode)
+
+	// Denylisted keys cannot be used
+	_, pubKey2, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+	KeyPolicy.Denylist = keypolicy.ParseDenylist(pubKey2.Fingerprint())
+	res = insertAddressRecord(*addr2, "../../testdata/key-2.json", fakeRoutingId.String(), pow2, "")
+	assert.Equal(t, 400, res.StatusCode)
+	out = &errorOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, keypolicy.CodeDenylisted, out.Code)
+
+	res = postAddressUpdate(*addr1, "../../testdata/key-1.json", "../../testdata/key-2.json")
+	assert.Equal(t, 400, res.StatusCode)
+	out = &errorOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, keypolicy.CodeDenylisted, out.Code)
+
+	// Disallowed key types
+	KeyPolicy = keypolicy.Default()
+	KeyPolicy.AllowedTypes = []string{"ed25519"}
+	res = insertAddressRecord(*addr2, "../../testdata/key-2.json", fakeRoutingId.String(), pow2, "")
+	assert.Equal(t, 400, res.StatusCode)
+	out = &errorOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, keypolicy.CodeKeyType, out.Code)
+
+	KeyPolicy = keypolicy.Default()
+	res = insertAddressRecord(*addr2, "../../testdata/key-2.json", fakeRoutingId.String(), pow2, "")
+	assert.Equal(t, 201, res.StatusCode)
+}
+
+func TestAdditionalKeyPolicy(t *testing.T) {
+	setupRepo()
+	defer func() {
+		KeyPolicy = keypolicy.Default()
+	}()
+
+	MinimumProofBitsOrganisation = 22
+
+	addr, _ := pkgAddress.NewAddress("foo!")
+	pow := proofofwork.New(22, addr.Hash().String(), 1310761)
+	orgHash := hash.New("acme-inc")
+	orgPow := proofofwork.New(22, orgHash.String(), 1305874)
+
+	privKey, pubKey, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	_, deniedPubKey, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+	KeyPolicy.Denylist = keypolicy.ParseDenylist(deniedPubKey.Fingerprint())
+
+	isDenylisted := func(res *http.Response) {
+		assert.Equal(t, 400, res.StatusCode)
+		assert.Contains(t, res.Body, keypolicy.CodeDenylisted)
+	}
+
+	addressBody := addressUploadBody{
+		UserHash:  addr.LocalHash(),
+		OrgHash:   addr.OrgHash(),
+		PublicKey: pubKey,
+		RoutingID: fakeRoutingId.String(),
+		Proof:     proof.FromProofOfWork(pow),
+		KeySig:    GenerateKeyPossessionSignature(addr.Hash().String(), 0, *privKey),
+	}
+
+	// Recovery key of an address
+	body := addressBody
+	body.RecoveryKey = deniedPubKey
+	b, _ := json.Marshal(body)
+	isDenylisted(PostAddressHash(addr.Hash(), http.NewRequest("POST", "/", string(b), nil)))
+
+	// Delegate of an address
+	body = addressBody
+	body.Delegates = []delegateUploadBody{{PublicKey: deniedPubKey, Scopes: []string{address.ScopeRoutingOnly}}}
+	b, _ = json.Marshal(body)
+	isDenylisted(PostAddressHash(addr.Hash(), http.NewRequest("POST", "/", string(b), nil)))
+
+	orgBody := organisationUploadBody{
+		PublicKey: pubKey,
+		Proof:     proof.FromProofOfWork(orgPow),
+		KeySig:    GenerateKeyPossessionSignature(orgHash.String(), 0, *privKey),
+	}
+
+	// Recovery key of an organisation
+	org := orgBody
+	org.RecoveryKey = deniedPubKey
+	b, _ = json.Marshal(org)
+	isDenylisted(PostOrganisationHash(orgHash, http.NewRequest("POST", "/", string(b), nil)))
+
+	// Admin key of an organisation
+	org = orgBody
+	org.AdminKeys = []*bmcrypto.PubKey{deniedPubKey}
+	org.Threshold = 1
+	b, _ = json.Marshal(org)
+	isDenylisted(PostOrganisationHash(orgHash, http.NewRequest("POST", "/", string(b), nil)))
+
+	// The reuse limit does not apply to additional keys, as they do not belong to a record of their own
+	KeyPolicy = keypolicy.Default()
+	KeyPolicy.MaxRecordsPerKey = 1
+	res := insertAddressRecord(*addr, "../../testdata/key-2.json", fakeRoutingId.String(), pow, "")
+	assert.Equal(t, 201, res.StatusCode)
+
+	org = orgBody
+	org.RecoveryKey = deniedPubKey
+	org.AdminKeys = []*bmcrypto.PubKey{deniedPubKey}
+	org.Threshold = 1
+	b, _ = json.Marshal(org)
+	res = PostOrganisationHash(orgHash, http.NewRequest("POST", "/", string(b), nil))
+	assert.Equal(t, 201, res.StatusCode)
+}
+
+// postAddressUpdate updates the key of the address, authenticated by the current key
+func postAddressUpdate(addr pkgAddress.Address, currentKeyPath, newKeyPath string) *http.Response {
+	privKey, _, _ := testing2.ReadTestKey(currentKeyPath)
+	newPrivKey, newPubKey, _ := testing2.ReadTestKey(newKeyPath)
+
+	req := http.NewRequest("GET", "/", "", nil)
+	current := getAddressRecord(GetAddressHash(addr.Hash(), req))
+
+	b, _ := json.Marshal(addressUploadBody{
+		UserHash:  addr.LocalHash(),
+		OrgHash:   addr.OrgHash(),
+		PublicKey: newPubKey,
+		RoutingID: current.RoutingID,
+		KeySig:    GenerateKeyPossessionSignature(current.Hash, current.Serial, *newPrivKey),
+	})
+
+	tokenData := current.Hash + current.RoutingID + strconv.FormatUint(current.Serial, 10)
+	req = http.NewRequest("POST", "/", string(b), nil)
+	req.Headers.Set("authorization", "BEARER "+http.GenerateAuthenticationToken([]byte(tokenData), *privKey))
+
+	return PostAddressHash(addr.Hash(), req)
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/lockout"
+	"github.com/bitmaelum/key-resolver-go/internal/organisation"
+	"github.com/bitmaelum/key-resolver-go/internal/routing"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+	"github.com/bitmaelum/key-resolver-go/internal/webhook"
+)
+
+// LockoutPolicy decides when clients and hashes are locked out after failed authentications. Failed authentications
+// are not tracked when nil.
+var LockoutPolicy *lockout.Policy
+
+// actionLockout is the action of the webhook event that is sent when a record is locked out
+const actionLockout = "lockout"
+
+// lockoutAuditEvent is logged whenever a lockout starts
+type lockoutAuditEvent struct {
+	Event       string `json:"event"`
+	Scope       string `json:"scope"` // Locked out client or hash
+	Type        string `json:"type,omitempty"`
+	Hash        string `json:"hash,omitempty"`
+	Client      string `json:"client,omitempty"`
+	Lockouts    int    `json:"lockouts"`
+	LockedUntil int64  `json:"locked_until"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+func hashLockoutKey(h string) string {
+	return "hash/" + h
+}
+
+func clientLockoutKey(ip string) string {
+	return "client/" + ip
+}
+
+// checkClientLockout returns an error response when the client is locked out. It is checked before the signature of a
+// request is verified, so a locked out client cannot find out whether a signature is valid.
+func checkClientLockout(req http.Request) *http.Response {
+	if LockoutPolicy == nil || req.ClientIP == "" {
+		return nil
+	}
+
+	return checkLockout(clientLockoutKey(req.ClientIP))
+}
+
+// failedAuth returns the response for a request that failed to authenticate for the record. While the hash is locked
+// out, the request is refused with a 429 without counting it. Otherwise the failure is counted, and a 401 with the
+// message is returned. The lockout of the hash is only looked at after authentication failed, so a correctly signed
+// request by the owner is never locked out.
+func failedAuth(typ, h, msg string, req http.Request) *http.Response {
+	if LockoutPolicy != nil {
+		if resp := checkLockout(hashLockoutKey(h)); resp != nil {
+			return resp
+		}
+	}
+
+	recordFailedAuth(typ, h, req)
+	return http.CreateError(msg, 401)
+}
+
+// checkLockout returns an error response when the client or hash of the key is locked out
+func checkLockout(key string) *http.Response {
+	s, err := lockout.GetRepository().Get(key, timeNow())
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+
+	if !s.IsLocked(timeNow()) {
+		return nil
+	}
+
+	resp := http.CreateErrorWithCode("too many failed authentication attempts", "locked_out", 429)
+	resp.Headers.Set("retry-after", strconv.FormatInt(s.LockedUntil-timeNow().Unix(), 10))
+	return resp
+}
+
+// recordFailedAuth counts a failed authentication for the hash of the record and for the client. An audit event is
+// emitted for every lockout this causes.
+func recordFailedAuth(typ, h string, req http.Request) {
+	if LockoutPolicy == nil {
+		return
+	}
+
+	if s, locked := addFailure(hashLockoutKey(h)); locked {
+		auditLockout(lockoutAuditEvent{Scope: "hash", Type: typ, Hash: h}, s)
+		notifyLockout(typ, h)
+	}
+
+	if req.ClientIP == "" {
+		return
+	}
+
+	if s, locked := addFailure(clientLockoutKey(req.ClientIP)); locked {
+		auditLockout(lockoutAuditEvent{Scope: "client", Client: req.ClientIP}, s)
+	}
+}
+
+// addFailure adds a failed authentication to the state stored under the key. It returns the new state, and true when
+// a lockout started.
+func addFailure(key string) (lockout.State, bool) {
+	var state lockout.State
+	var locked bool
+
+	// The update can be retried, so nothing else must be done before it has succeeded
+	err := lockout.GetRepository().Update(key, timeNow(), func(s *lockout.State) {
+		locked = LockoutPolicy.RecordFailure(s, timeNow())
+		state = *s
+	})
+	if err != nil {
+		log.Print(err)
+		return state, false
+	}
+
+	return state, locked
+}
+
+// auditLockout writes the audit event of a lockout to the log
+func auditLockout(event lockoutAuditEvent, s lockout.State) {
+	event.Event = actionLockout
+	event.Lockouts = s.Lockouts
+	event.LockedUntil = s.LockedUntil
+	event.Timestamp = timeNow().Unix()
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	log.Printf("audit: %s", data)
+}
+
+// notifyLockout tells the webhook subscribers of the record that it has been locked out
+func notifyLockout(typ, h string) {
+	if webhooks == nil {
+		return
+	}
+
+	err := webhooks.Notify(webhook.Event{
+		Type:      typ,
+		Action:    actionLockout,
+		Hash:      h,
+		Serial:    currentSerial(typ, h),
+		Timestamp: timeNow().Unix(),
+	})
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+func GetAddressLockout(addrHash hash.Hash, req http.Request) *http.Response {
+	return getLockout(translog.TypeAddress, addrHash, req)
+}
+
+func GetOrganisationLockout(orgHash hash.Hash, req http.Request) *http.Response {
+	return getLockout(translog.TypeOrganisation, orgHash, req)
+}
+
+func GetRoutingLockout(routingHash hash.Hash, req http.Request) *http.Response {
+	return getLockout(translog.TypeRouting, routingHash, req)
+}
+
+// getLockout returns the lockout state of the record to its owner. It is not locked itself, so the owner can always
+// find out why changes are refused.
+func getLockout(typ string, h hash.Hash, req http.Request) *http.Response {
+	if httpErr := authenticateRecord(typ, scopeLockoutGet, h, req); httpErr != nil {
+		return httpErr
+	}
+
+	s := lockout.State{}
+	if LockoutPolicy != nil {
+		var err error
+		s, err = lockout.GetRepository().Get(hashLockoutKey(h.String()), timeNow())
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while fetching lockout", 500)
+		}
+	}
+
+	return http.CreateOutput(http.RawJSONOut{
+		"locked":       s.IsLocked(timeNow()),
+		"locked_until": s.LockedUntil,
+		"failures":     s.Failures,
+		"lockouts":     s.Lockouts,
+		"last_failure": s.LastFailure,
+	}, 200)
+}
+
+// currentSerial returns the serial of the record as it is currently stored, or 0 when the record does not exist
+func currentSerial(typ, h string) uint64 {
+	switch typ {
+	case translog.TypeAddress:
+		info, err := address.GetResolveRepository().Get(h)
+		if err == nil && info != nil {
+			return info.Serial
+		}
+	case translog.TypeOrganisation:
+		info, err := organisation.GetResolveRepository().Get(h)
+		if err == nil && info != nil {
+			return info.Serial
+		}
+	case translog.TypeRouting:
+		info, err := routing.GetResolveRepository().Get(h)
+		if err == nil && info != nil {
+			return info.Serial
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/lockout"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockout(t *testing.T) {
+	setupRepo()
+	lockout.SetDefaultRepository(lockout.NewMemoryRepository())
+	defer func() {
+		LockoutPolicy = nil
+	}()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	addr1, _ := pkgAddress.NewAddress("foo!")
+	pow1 := proofofwork.New(22, addr1.Hash().String(), 1310761)
+	res := insertAddressRecord(*addr1, "../../testdata/key-3.json", fakeRoutingId.String(), pow1, "")
+	assert.Equal(t, 201, res.StatusCode)
+
+	privKey, _, _ := testing2.ReadTestKey("../../testdata/key-3.json")
+	token := http.GenerateAuthenticationToken([]byte(addr1.Hash().String()+fakeRoutingId.String()+"1270643696000000000"), *privKey)
+	lockoutToken := http.GenerateAuthenticationToken([]byte(scopeLockoutGet+addr1.Hash().String()+fakeRoutingId.String()+"1270643696000000000"), *privKey)
+
+	newRequest := func(method, ip, auth string) http.Request {
+		req := http.NewRequest(method, "/", "", nil)
+		req.ClientIP = ip
+		req.Headers.Set("authorization", auth)
+		return req
+	}
+
+	type lockoutOutput struct {
+		Locked      bool  `json:"locked"`
+		LockedUntil int64 `json:"locked_until"`
+		Failures    int   `json:"failures"`
+		Lockouts    int   `json:"lockouts"`
+	}
+	getLockoutState := func() lockoutOutput {
+		res := GetAddressLockout(addr1.Hash(), newRequest("GET", "127.0.0.9", "Bearer "+lockoutToken))
+		assert.Equal(t, 200, res.StatusCode)
+		out := lockoutOutput{}
+		_ = json.Unmarshal([]byte(res.Body), &out)
+		return out
+	}
+
+	// Failures are not tracked without a policy
+	res = DeleteAddressHash(addr1.Hash(), newRequest("DELETE", "127.0.0.1", "Bearer foobar"))
+	assert.Equal(t, 401, res.StatusCode)
+	assert.Equal(t, lockoutOutput{}, getLockoutState())
+
+	LockoutPolicy = &lockout.Policy{
+		Threshold:   2,
+		Window:      time.Hour,
+		Duration:    time.Minute,
+		MaxDuration: time.Hour,
+	}
+
+	res = DeleteAddressHash(addr1.Hash(), newRequest("DELETE", "127.0.0.1", "Bearer foobar"))
+	assert.Equal(t, 401, res.StatusCode)
+	assert.Equal(t, lockoutOutput{Failures: 1}, getLockoutState())
+	assert.NotContains(t, logs.String(), "audit:")
+
+	// The second failure locks out the hash and the client
+	res = SoftDeleteAddressHash(addr1.Hash(), newRequest("POST", "127.0.0.1", "Bearer foobar"))
+	assert.Equal(t, 401, res.StatusCode)
+	assert.Contains(t, logs.String(), `audit: {"event":"lockout","scope":"hash","type":"address","hash":"`+addr1.Hash().String()+`","lockouts":1,"locked_until":1270643756,"timestamp":1270643696}`)
+	assert.Contains(t, logs.String(), `audit: {"event":"lockout","scope":"client","client":"127.0.0.1","lockouts":1,"locked_until":1270643756,"timestamp":1270643696}`)
+
+	// Failed authentications are refused during the lockout, without being counted
+	res = DeleteAddressHash(addr1.Hash(), newRequest("DELETE", "127.0.0.2", "Bearer foobar"))
+	assert.Equal(t, 429, res.StatusCode)
+	assert.Equal(t, "60", res.Headers.Get("retry-after"))
+	assert.JSONEq(t, `{"code": "locked_out", "message": "too many failed authentication attempts", "status": "error"}`, res.Body)
+	assert.Equal(t, lockoutOutput{Locked: true, LockedUntil: 1270643756, Lockouts: 1}, getLockoutState())
+
+	// The owner can still make changes with a valid signature while the hash is locked out
+	res = SoftDeleteAddressHash(addr1.Hash(), newRequest("POST", "127.0.0.2", "Bearer "+token))
+	assert.Equal(t, 200, res.StatusCode)
+	res = SoftUndeleteAddressHash(addr1.Hash(), newRequest("POST", "127.0.0.2", "Bearer "+token))
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, lockoutOutput{Locked: true, LockedUntil: 1270643756, Lockouts: 1}, getLockoutState())
+
+	// A locked out client is refused before the signature is verified, so it cannot find out if a token is valid
+	res = SoftDeleteAddressHash(addr1.Hash(), newRequest("POST", "127.0.0.1", "Bearer "+token))
+	assert.Equal(t, 429, res.StatusCode)
+	assert.Equal(t, lockoutOutput{Locked: true, LockedUntil: 1270643756, Lockouts: 1}, getLockoutState())
+
+	// The client is locked out for other hashes as well
+	addr2, _ := pkgAddress.NewAddress("bar!")
+	pow2 := proofofwork.New(22, addr2.Hash().String(), 1019732)
+	res = insertAddressRecord(*addr2, "../../testdata/key-4.json", fakeRoutingId.String(), pow2, "")
+	assert.Equal(t, 201, res.StatusCode)
+	res = DeleteAddressHash(addr2.Hash(), newRequest("DELETE", "127.0.0.1", "Bearer foobar"))
+	assert.Equal(t, 429, res.StatusCode)
+	res = DeleteAddressHash(addr2.Hash(), newRequest("DELETE", "127.0.0.2", "Bearer foobar"))
+	assert.Equal(t, 401, res.StatusCode)
+
+	// The lockout state can only be seen by the owner
+	res = GetAddressLockout(addr1.Hash(), newRequest("GET", "127.0.0.3", "Bearer foobar"))
+	assert.Equal(t, 429, res.StatusCode)
+	assert.Equal(t, 0, getLockoutState().Failures)
+
+	// After the lockout, failures lead to longer lockouts
+	setRepoTime(time.Date(2010, 04, 07, 12, 36, 00, 0, time.UTC))
+	res = DeleteAddressHash(addr1.Hash(), newRequest("DELETE", "127.0.0.4", "Bearer foobar"))
+	assert.Equal(t, 401, res.StatusCode)
+	res = DeleteAddressHash(addr1.Hash(), newRequest("DELETE", "127.0.0.4", "Bearer foobar"))
+	assert.Equal(t, 401, res.StatusCode)
+	state := getLockoutState()
+	assert.True(t, state.Locked)
+	assert.Equal(t, 2, state.Lockouts)
+	assert.Equal(t, time.Date(2010, 04, 07, 12, 38, 00, 0, time.UTC).Unix(), state.LockedUntil)
+
+	// Once the lockout is over, the owner can make changes again
+	setRepoTime(time.Date(2010, 04, 07, 12, 38, 00, 0, time.UTC))
+	res = DeleteAddressHash(addr1.Hash(), newRequest("DELETE", "127.0.0.2", "Bearer "+token))
+	assert.Equal(t, 200, res.StatusCode)
+}
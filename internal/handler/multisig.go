@@ -0,0 +1,77 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"strconv"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/organisation"
+)
+
+// convertAdminKeys validates the uploaded admin keys and threshold, and returns the admin keys as strings
+func convertAdminKeys(adminKeys []*bmcrypto.PubKey, threshold int) ([]string, *http.Response) {
+	if len(adminKeys) == 0 {
+		return nil, nil
+	}
+
+	if threshold < 1 || threshold > len(adminKeys) {
+		return nil, http.CreateError("threshold must be between 1 and the number of admin keys", 400)
+	}
+
+	var ret []string
+	seen := make(map[string]bool)
+	for _, pk := range adminKeys {
+		if pk == nil {
+			return nil, http.CreateError("invalid admin key", 400)
+		}
+
+		if httpErr := checkAdditionalKeyPolicy(pk); httpErr != nil {
+			return nil, httpErr
+		}
+
+		// Every admin key counts as a single signer, so duplicates would lower the actual threshold
+		if seen[pk.Fingerprint()] {
+			return nil, http.CreateError("duplicate admin key", 400)
+		}
+		seen[pk.Fingerprint()] = true
+
+		ret = append(ret, pk.String())
+	}
+
+	return ret, nil
+}
+
+// isOrganisationAuthenticated checks the authorization of the request. When the organisation has admin keys, at least
+// threshold distinct admin keys must have signed the request. Otherwise the public key of the organisation must have
+// signed it.
+func isOrganisationAuthenticated(req http.Request, current *organisation.ResolveInfoType) bool {
+	return validateOrganisationToken(req, current, current.Hash+strconv.FormatUint(current.Serial, 10))
+}
+
+// validateOrganisationToken checks if the request is signed over the data by the keys that control the organisation
+func validateOrganisationToken(req http.Request, current *organisation.ResolveInfoType, data string) bool {
+	if len(current.AdminKeys) == 0 {
+		return req.ValidateAuthenticationToken(current.PubKey, data)
+	}
+
+	return req.CountAuthenticationSigners(current.AdminKeys, data) >= current.Threshold
+}
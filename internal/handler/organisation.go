@@ -21,16 +21,16 @@ package handler
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
 	"strconv"
 
 	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
 	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
-	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
 	"github.com/bitmaelum/key-resolver-go/internal/http"
 	"github.com/bitmaelum/key-resolver-go/internal/organisation"
+	"github.com/bitmaelum/key-resolver-go/internal/proof"
 	"github.com/bitmaelum/key-resolver-go/internal/reservation"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
 )
 
 var (
@@ -38,12 +38,21 @@ var (
 )
 
 type organisationUploadBody struct {
-	PublicKey   *bmcrypto.PubKey         `json:"public_key"`
-	Proof       *proofofwork.ProofOfWork `json:"proof"`
-	Validations []string                 `json:"validations"`
+	PublicKey   *bmcrypto.PubKey `json:"public_key"`
+	Proof       *proof.Proof     `json:"proof"`
+	Validations []string         `json:"validations"`
+	KeySig      []byte           `json:"key_signature"`
+	RecoveryKey *bmcrypto.PubKey `json:"recovery_key,omitempty"`
+
+	AdminKeys []*bmcrypto.PubKey `json:"admin_keys"`
+	Threshold int                `json:"threshold,omitempty"`
 }
 
-func GetOrganisationHash(orgHash hash.Hash, _ http.Request) *http.Response {
+func GetOrganisationHash(orgHash hash.Hash, req http.Request) *http.Response {
+	if isVersionLookup(req) {
+		return getVersion(translog.TypeOrganisation, orgHash.String(), req)
+	}
+
 	repo := organisation.GetResolveRepository()
 	info, err := repo.Get(orgHash.String())
 	if err != nil && err != organisation.ErrNotFound {
@@ -56,6 +65,11 @@ func GetOrganisationHash(orgHash hash.Hash, _ http.Request) *http.Response {
 		return http.CreateError("hash not found", 404)
 	}
 
+	return http.CreateOutput(organisationOutput(info), 200)
+}
+
+// organisationOutput returns the public representation of an organisation record
+func organisationOutput(info *organisation.ResolveInfoType) http.RawJSONOut {
 	data := http.RawJSONOut{
 		"hash":          info.Hash,
 		"public_key":    info.PubKey,
@@ -64,7 +78,18 @@ func GetOrganisationHash(orgHash hash.Hash, _ http.Request) *http.Response {
 		"serial_number": info.Serial,
 	}
 
-	return http.CreateOutput(data, 200)
+	if info.RecoveryKey != "" {
+		data["recovery_key"] = info.RecoveryKey
+	}
+	if reset := keyResetOutput(info.ResetKey, info.ResetAt); reset != nil {
+		data["key_reset"] = reset
+	}
+	if len(info.AdminKeys) > 0 {
+		data["admin_keys"] = info.AdminKeys
+		data["threshold"] = info.Threshold
+	}
+
+	return data
 }
 
 func PostOrganisationHash(orgHash hash.Hash, req http.Request) *http.Response {
@@ -107,8 +132,12 @@ func DeleteOrganisationHash(orgHash hash.Hash, req http.Request) *http.Response
 		return http.CreateError("cannot find record", 404)
 	}
 
-	if !req.ValidateAuthenticationToken(current.PubKey, current.Hash+strconv.FormatUint(current.Serial, 10)) {
-		return http.CreateError("unauthenticated", 401)
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
+	if !isOrganisationAuthenticated(req, current) {
+		return failedAuth(translog.TypeOrganisation, current.Hash, "unauthenticated", req)
 	}
 
 	res, err := repo.Delete(current.Hash)
@@ -117,15 +146,50 @@ func DeleteOrganisationHash(orgHash hash.Hash, req http.Request) *http.Response
 		return http.CreateError("error while deleting record", 500)
 	}
 
+	logMutation(translog.TypeOrganisation, translog.ActionDelete, current.Hash, "")
+
 	return http.CreateMessage("organisation has been deleted", 200)
 }
 
 func updateOrganisation(uploadBody organisationUploadBody, req http.Request, current *organisation.ResolveInfoType) *http.Response {
-	if !req.ValidateAuthenticationToken(current.PubKey, current.Hash+strconv.FormatUint(current.Serial, 10)) {
-		return http.CreateError("unauthenticated", 401)
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
+	if !isOrganisationAuthenticated(req, current) {
+		return failedAuth(translog.TypeOrganisation, current.Hash, "unauthenticated", req)
+	}
+
+	// With admin keys, the admin signatures authorise the update, so the unchanged organisation key does not need to
+	// prove possession again. A new key must always prove possession.
+	keyChanged := uploadBody.PublicKey.String() != current.PubKey
+	if keyChanged || len(current.AdminKeys) == 0 {
+		if !validateKeyPossession(uploadBody.PublicKey, current.Hash, current.Serial, uploadBody.KeySig) {
+			return http.CreateError("proof of key possession failed", 400)
+		}
+	}
+
+	// Keys that are already in use by the record are not checked again
+	if keyChanged {
+		if httpErr := checkKeyPolicy(uploadBody.PublicKey, translog.TypeOrganisation, current.Hash); httpErr != nil {
+			return httpErr
+		}
+	}
+
+	adminKeys, httpErr := convertAdminKeys(uploadBody.AdminKeys, uploadBody.Threshold)
+	if httpErr != nil {
+		return httpErr
 	}
 
 	repo := organisation.GetResolveRepository()
+	if uploadBody.AdminKeys != nil {
+		err := repo.SetAdminKeys(current.Hash, adminKeys, uploadBody.Threshold)
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while updating: ", 500)
+		}
+	}
+
 	res, err := repo.Update(current, uploadBody.PublicKey.String(), uploadBody.Proof.String(), uploadBody.Validations)
 
 	if err != nil || !res {
@@ -133,16 +197,35 @@ func updateOrganisation(uploadBody organisationUploadBody, req http.Request, cur
 		return http.CreateError("error while updating: ", 500)
 	}
 
-	return http.CreateMessage("organisation has been updated", 200)
+	index, logged := logMutation(translog.TypeOrganisation, translog.ActionUpdate, current.Hash, uploadBody.PublicKey.String())
+	recordVersion(translog.TypeOrganisation, current.Hash)
+	indexFingerprint(translog.TypeOrganisation, current.Hash, uploadBody.PublicKey.String())
+
+	return receiptMessage("organisation has been updated", 200, organisationReceipt(current.Hash, index, logged))
 }
 
 func createOrganisation(orgHash hash.Hash, uploadBody organisationUploadBody) *http.Response {
-	if !uploadBody.Proof.IsValid() || uploadBody.Proof.Data != orgHash.String() {
-		return http.CreateError("incorrect proof-of-work", 400)
+	if httpErr := validateProof(uploadBody.Proof, translog.TypeOrganisation, orgHash.String()); httpErr != nil {
+		return httpErr
 	}
 
-	if uploadBody.Proof.Bits < MinimumProofBitsOrganisation {
-		return http.CreateError(fmt.Sprintf("proof-of-work too weak (need %d bits)", MinimumProofBitsAddress), 400)
+	if !validateKeyPossession(uploadBody.PublicKey, orgHash.String(), 0, uploadBody.KeySig) {
+		return http.CreateError("proof of key possession failed", 400)
+	}
+
+	if httpErr := checkKeyPolicy(uploadBody.PublicKey, translog.TypeOrganisation, orgHash.String()); httpErr != nil {
+		return httpErr
+	}
+
+	if uploadBody.RecoveryKey != nil {
+		if httpErr := checkAdditionalKeyPolicy(uploadBody.RecoveryKey); httpErr != nil {
+			return httpErr
+		}
+	}
+
+	adminKeys, httpErr := convertAdminKeys(uploadBody.AdminKeys, uploadBody.Threshold)
+	if httpErr != nil {
+		return httpErr
 	}
 
 	ok, err := reservation.ReservationService.IsValidated(orgHash, uploadBody.PublicKey)
@@ -158,7 +241,151 @@ func createOrganisation(orgHash hash.Hash, uploadBody organisationUploadBody) *h
 		return http.CreateError("error while creating: ", 500)
 	}
 
-	return http.CreateMessage("organisation has been created", 201)
+	if uploadBody.RecoveryKey != nil {
+		err = repo.SetRecoveryKey(orgHash.String(), uploadBody.RecoveryKey)
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while creating: ", 500)
+		}
+	}
+
+	if len(adminKeys) > 0 {
+		err = repo.SetAdminKeys(orgHash.String(), adminKeys, uploadBody.Threshold)
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while creating: ", 500)
+		}
+	}
+
+	index, logged := logMutation(translog.TypeOrganisation, translog.ActionCreate, orgHash.String(), uploadBody.PublicKey.String())
+	recordVersion(translog.TypeOrganisation, orgHash.String())
+	indexFingerprint(translog.TypeOrganisation, orgHash.String(), uploadBody.PublicKey.String())
+	recordCreation(translog.TypeOrganisation)
+
+	return receiptMessage("organisation has been created", 201, organisationReceipt(orgHash.String(), index, logged))
+}
+
+func RequestOrganisationKeyReset(orgHash hash.Hash, req http.Request) *http.Response {
+	body := &keyResetUploadBody{}
+	err := json.Unmarshal([]byte(req.Body), body)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("invalid data", 400)
+	}
+
+	repo := organisation.GetResolveRepository()
+	current, err := repo.Get(orgHash.String())
+	if err != nil && err != organisation.ErrNotFound {
+		log.Print(err)
+		return http.CreateError("error while fetching record", 500)
+	}
+
+	if current == nil {
+		return http.CreateError("cannot find record", 404)
+	}
+
+	if current.RecoveryKey == "" {
+		return http.CreateError("no recovery key registered", 400)
+	}
+
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
+	// Key resets are authenticated by the recovery key instead of the current key
+	if !req.ValidateAuthenticationToken(current.RecoveryKey, current.Hash+strconv.FormatUint(current.Serial, 10)) {
+		return failedAuth(translog.TypeOrganisation, current.Hash, "unauthenticated", req)
+	}
+
+	if !validateKeyPossession(body.PublicKey, current.Hash, current.Serial, body.KeySig) {
+		return http.CreateError("proof of key possession failed", 400)
+	}
+
+	if httpErr := checkKeyPolicy(body.PublicKey, translog.TypeOrganisation, current.Hash); httpErr != nil {
+		return httpErr
+	}
+
+	err = repo.RequestKeyReset(current.Hash, body.PublicKey, timeNow().Add(KeyResetDelay))
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while requesting key reset", 500)
+	}
+
+	return http.CreateMessage("key reset has been requested", 200)
+}
+
+func CancelOrganisationKeyReset(orgHash hash.Hash, req http.Request) *http.Response {
+	repo := organisation.GetResolveRepository()
+	current, err := repo.Get(orgHash.String())
+	if err != nil && err != organisation.ErrNotFound {
+		log.Print(err)
+		return http.CreateError("error while fetching record", 500)
+	}
+
+	if current == nil {
+		return http.CreateError("cannot find record", 404)
+	}
+
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
+	if !isOrganisationAuthenticated(req, current) {
+		return failedAuth(translog.TypeOrganisation, current.Hash, "unauthenticated", req)
+	}
+
+	if current.ResetKey == "" {
+		return http.CreateError("no pending key reset", 404)
+	}
+
+	err = repo.CancelKeyReset(current.Hash)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while cancelling key reset", 500)
+	}
+
+	return http.CreateMessage("key reset has been cancelled", 200)
+}
+
+func CompleteOrganisationKeyReset(orgHash hash.Hash, req http.Request) *http.Response {
+	repo := organisation.GetResolveRepository()
+	current, err := repo.Get(orgHash.String())
+	if err != nil && err != organisation.ErrNotFound {
+		log.Print(err)
+		return http.CreateError("error while fetching record", 500)
+	}
+
+	if current == nil {
+		return http.CreateError("cannot find record", 404)
+	}
+
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
+	if !req.ValidateAuthenticationToken(current.RecoveryKey, current.Hash+strconv.FormatUint(current.Serial, 10)) {
+		return failedAuth(translog.TypeOrganisation, current.Hash, "unauthenticated", req)
+	}
+
+	if current.ResetKey == "" {
+		return http.CreateError("no pending key reset", 404)
+	}
+
+	if isKeyResetPending(current.ResetAt) {
+		return http.CreateError("key reset is still pending", 400)
+	}
+
+	res, err := repo.CompleteKeyReset(current)
+	if err != nil || !res {
+		log.Print(err)
+		return http.CreateError("error while completing key reset", 500)
+	}
+
+	logMutation(translog.TypeOrganisation, translog.ActionUpdate, current.Hash, current.ResetKey)
+	recordVersion(translog.TypeOrganisation, current.Hash)
+	indexFingerprint(translog.TypeOrganisation, current.Hash, current.ResetKey)
+
+	return http.CreateMessage("key has been reset", 200)
 }
 
 func validateOrganisationBody(_ organisationUploadBody) bool {
@@ -30,6 +30,7 @@ import (
 	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
 	"github.com/bitmaelum/key-resolver-go/internal/http"
 	"github.com/bitmaelum/key-resolver-go/internal/organisation"
+	"github.com/bitmaelum/key-resolver-go/internal/proof"
 	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
 	"github.com/stretchr/testify/assert"
 )
@@ -108,7 +109,7 @@ func TestOrganisationUpdate(t *testing.T) {
 	pk, _ := bmcrypto.NewPubKey(current.PubKey)
 	body := &organisationUploadBody{
 		PublicKey: pk,
-		Proof:     pow1,
+		Proof:     proof.FromProofOfWork(pow1),
 	}
 
 	res = updateOrganisation(*body, req, &current)
@@ -121,6 +122,7 @@ func TestOrganisationUpdate(t *testing.T) {
 	authToken := http.GenerateAuthenticationToken([]byte(sig), *privKey)
 
 	// Update record with correct auth
+	body.KeySig = GenerateKeyPossessionSignature(current.Hash, current.Serial, *privKey)
 	req = http.NewRequest("GET", "/", "", nil)
 	req.Headers.Set("authorization", "BEARER "+authToken)
 	setRepoTime(time.Date(2010, 12, 13, 12, 34, 56, 1241511, time.UTC))
@@ -207,16 +209,219 @@ func TestOrganisationDeletion(t *testing.T) {
 	assert.Equal(t, 200, res.StatusCode)
 }
 
+func TestOrganisationKeyReset(t *testing.T) {
+	setupRepo()
+
+	MinimumProofBitsOrganisation = 22
+
+	orgHash := hash.New("acme-inc")
+	pow := proofofwork.New(22, orgHash.String(), 1305874)
+
+	privKey, pubKey, _ := testing2.ReadTestKey("../../testdata/key-5.json")
+	recoveryPrivKey, recoveryPubKey, _ := testing2.ReadTestKey("../../testdata/key-6.json")
+	newPrivKey, newPubKey, _ := testing2.ReadTestKey("../../testdata/key-7.json")
+
+	b, _ := json.Marshal(organisationUploadBody{
+		PublicKey:   pubKey,
+		Proof:       proof.FromProofOfWork(pow),
+		KeySig:      GenerateKeyPossessionSignature(orgHash.String(), 0, *privKey),
+		RecoveryKey: recoveryPubKey,
+	})
+	req := http.NewRequest("POST", "/", string(b), nil)
+	res := PostOrganisationHash(orgHash, req)
+	assert.Equal(t, 201, res.StatusCode)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	res = GetOrganisationHash(orgHash, req)
+	assert.Contains(t, res.Body, recoveryPubKey.String())
+	current := getOrganisationRecord(res)
+
+	tokenData := current.Hash + strconv.FormatUint(current.Serial, 10)
+	ownerToken := http.GenerateAuthenticationToken([]byte(tokenData), *privKey)
+	recoveryToken := http.GenerateAuthenticationToken([]byte(tokenData), *recoveryPrivKey)
+
+	b, _ = json.Marshal(keyResetUploadBody{
+		PublicKey: newPubKey,
+		KeySig:    GenerateKeyPossessionSignature(current.Hash, current.Serial, *newPrivKey),
+	})
+
+	// Request reset authenticated with the current key instead of the recovery key
+	req = http.NewRequest("POST", "/", string(b), nil)
+	req.Headers.Set("authorization", "BEARER "+ownerToken)
+	res = RequestOrganisationKeyReset(orgHash, req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	req = http.NewRequest("POST", "/", string(b), nil)
+	req.Headers.Set("authorization", "BEARER "+recoveryToken)
+	res = RequestOrganisationKeyReset(orgHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	// Cannot complete during the delay
+	req = http.NewRequest("POST", "/", "", nil)
+	req.Headers.Set("authorization", "BEARER "+recoveryToken)
+	res = CompleteOrganisationKeyReset(orgHash, req)
+	assert.Equal(t, 400, res.StatusCode)
+
+	// Current key can cancel
+	req = http.NewRequest("POST", "/", "", nil)
+	req.Headers.Set("authorization", "BEARER "+ownerToken)
+	res = CancelOrganisationKeyReset(orgHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	// Request again and complete after the delay
+	req = http.NewRequest("POST", "/", string(b), nil)
+	req.Headers.Set("authorization", "BEARER "+recoveryToken)
+	res = RequestOrganisationKeyReset(orgHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	setRepoTime(time.Date(2010, 04, 30, 12, 34, 56, 0, time.UTC))
+
+	req = http.NewRequest("POST", "/", "", nil)
+	req.Headers.Set("authorization", "BEARER "+recoveryToken)
+	res = CompleteOrganisationKeyReset(orgHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	res = GetOrganisationHash(orgHash, req)
+	info := getOrganisationRecord(res)
+	assert.Equal(t, newPubKey.String(), info.PubKey)
+}
+
+func TestOrganisationMultiSig(t *testing.T) {
+	setupRepo()
+
+	MinimumProofBitsOrganisation = 22
+
+	orgHash := hash.New("acme-inc")
+	pow := proofofwork.New(22, orgHash.String(), 1305874)
+
+	orgPrivKey, orgPubKey, _ := testing2.ReadTestKey("../../testdata/key-5.json")
+	admin1PrivKey, admin1PubKey, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	admin2PrivKey, admin2PubKey, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+	_, admin3PubKey, _ := testing2.ReadTestKey("../../testdata/key-3.json")
+
+	body := organisationUploadBody{
+		PublicKey: orgPubKey,
+		Proof:     proof.FromProofOfWork(pow),
+		KeySig:    GenerateKeyPossessionSignature(orgHash.String(), 0, *orgPrivKey),
+		AdminKeys: []*bmcrypto.PubKey{admin1PubKey, admin2PubKey, admin3PubKey},
+		Threshold: 4,
+	}
+
+	// Threshold higher than the number of admin keys
+	b, _ := json.Marshal(body)
+	req := http.NewRequest("POST", "/", string(b), nil)
+	res := PostOrganisationHash(orgHash, req)
+	assert.Equal(t, 400, res.StatusCode)
+
+	// Duplicate admin keys
+	body.AdminKeys = []*bmcrypto.PubKey{admin1PubKey, admin1PubKey, admin3PubKey}
+	body.Threshold = 2
+	b, _ = json.Marshal(body)
+	req = http.NewRequest("POST", "/", string(b), nil)
+	res = PostOrganisationHash(orgHash, req)
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, "{\"message\": \"duplicate admin key\",\"status\": \"error\"}", res.Body)
+
+	body.AdminKeys = []*bmcrypto.PubKey{admin1PubKey, admin2PubKey, admin3PubKey}
+	b, _ = json.Marshal(body)
+	req = http.NewRequest("POST", "/", string(b), nil)
+	res = PostOrganisationHash(orgHash, req)
+	assert.Equal(t, 201, res.StatusCode)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	res = GetOrganisationHash(orgHash, req)
+	assert.Contains(t, res.Body, admin2PubKey.String())
+	assert.Contains(t, res.Body, "\"threshold\": 2")
+	current := getOrganisationRecord(res)
+
+	data := []byte(current.Hash + strconv.FormatUint(current.Serial, 10))
+	orgToken := http.GenerateAuthenticationToken(data, *orgPrivKey)
+	admin1Token := http.GenerateAuthenticationToken(data, *admin1PrivKey)
+	admin2Token := http.GenerateAuthenticationToken(data, *admin2PrivKey)
+
+	// The admin keys authorise the update, so no signature of the organisation key is needed
+	body.AdminKeys = nil
+	body.Validations = []string{"dns: example.com"}
+	body.KeySig = nil
+	b, _ = json.Marshal(body)
+
+	// The organisation key alone is not enough anymore
+	req = http.NewRequest("POST", "/", string(b), nil)
+	req.Headers.Set("authorization", "BEARER "+orgToken)
+	res = PostOrganisationHash(orgHash, req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	// Not enough distinct signers
+	req = http.NewRequest("POST", "/", string(b), nil)
+	req.Headers.Set("authorization", "BEARER "+admin1Token+","+admin1Token)
+	res = PostOrganisationHash(orgHash, req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	req = http.NewRequest("POST", "/", string(b), nil)
+	req.Headers.Set("authorization", "BEARER "+admin1Token+","+admin2Token)
+	res = PostOrganisationHash(orgHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.JSONEq(t, "{\"message\": \"organisation has been updated\",\"status\": \"ok\"}", res.Body)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	res = GetOrganisationHash(orgHash, req)
+	current = getOrganisationRecord(res)
+	assert.Equal(t, []string{"dns: example.com"}, current.Validations)
+	assert.Contains(t, res.Body, admin2PubKey.String())
+
+	// A new organisation key must still prove possession
+	newPrivKey, newPubKey, _ := testing2.ReadTestKey("../../testdata/key-6.json")
+	data = []byte(current.Hash + strconv.FormatUint(current.Serial, 10))
+	admin1Token = http.GenerateAuthenticationToken(data, *admin1PrivKey)
+	admin2Token = http.GenerateAuthenticationToken(data, *admin2PrivKey)
+
+	body.PublicKey = newPubKey
+	b, _ = json.Marshal(body)
+	req = http.NewRequest("POST", "/", string(b), nil)
+	req.Headers.Set("authorization", "BEARER "+admin1Token+","+admin2Token)
+	res = PostOrganisationHash(orgHash, req)
+	assert.Equal(t, 400, res.StatusCode)
+
+	body.KeySig = GenerateKeyPossessionSignature(current.Hash, current.Serial, *newPrivKey)
+	b, _ = json.Marshal(body)
+	req = http.NewRequest("POST", "/", string(b), nil)
+	req.Headers.Set("authorization", "BEARER "+admin1Token+","+admin2Token)
+	res = PostOrganisationHash(orgHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	res = GetOrganisationHash(orgHash, req)
+	current = getOrganisationRecord(res)
+	assert.Equal(t, newPubKey.String(), current.PubKey)
+
+	// Deletion needs the threshold as well
+	data = []byte(current.Hash + strconv.FormatUint(current.Serial, 10))
+	admin1Token = http.GenerateAuthenticationToken(data, *admin1PrivKey)
+	admin2Token = http.GenerateAuthenticationToken(data, *admin2PrivKey)
+
+	req = http.NewRequest("DELETE", "/", "", nil)
+	req.Headers.Set("authorization", "BEARER "+admin2Token)
+	res = DeleteOrganisationHash(orgHash, req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	req = http.NewRequest("DELETE", "/", "", nil)
+	req.Headers.Set("authorization", "BEARER "+admin2Token+","+admin1Token)
+	res = DeleteOrganisationHash(orgHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+}
+
 func insertOrganisationRecord(orgHash hash.Hash, keyPath string, pow *proofofwork.ProofOfWork, validations []string) *http.Response {
-	_, pubKey, err := testing2.ReadTestKey(keyPath)
+	privKey, pubKey, err := testing2.ReadTestKey(keyPath)
 	if err != nil {
 		return nil
 	}
 
 	b, err := json.Marshal(organisationUploadBody{
 		PublicKey:   pubKey,
-		Proof:       pow,
+		Proof:       proof.FromProofOfWork(pow),
 		Validations: validations,
+		KeySig:      GenerateKeyPossessionSignature(orgHash.String(), 0, *privKey),
 	})
 	if err != nil {
 		return nil
@@ -0,0 +1,56 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"crypto/sha256"
+	"strconv"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+)
+
+// GenerateKeyPossessionSignature generates the signature that proves possession of the private key for the public key
+// that is uploaded. Serial is the serial number of the record that is being replaced, or 0 when creating a new record.
+func GenerateKeyPossessionSignature(h string, serial uint64, pk bmcrypto.PrivKey) []byte {
+	challenge := keyPossessionChallenge(h, serial)
+	sig, _ := bmcrypto.Sign(pk, challenge[:])
+
+	return sig
+}
+
+// validateKeyPossession checks if the signature is made by the private key of the given public key. This makes sure
+// that nobody can upload a key that they do not control.
+func validateKeyPossession(pk *bmcrypto.PubKey, h string, serial uint64, sig []byte) bool {
+	if pk == nil || len(sig) == 0 {
+		return false
+	}
+
+	challenge := keyPossessionChallenge(h, serial)
+	ok, err := bmcrypto.Verify(*pk, challenge[:], sig)
+	if err != nil {
+		return false
+	}
+
+	return ok
+}
+
+func keyPossessionChallenge(h string, serial uint64) [32]byte {
+	return sha256.Sum256([]byte(h + strconv.FormatUint(serial, 10)))
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/proof"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateKeyPossession(t *testing.T) {
+	privKey, pubKey, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	_, otherPubKey, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+
+	sig := GenerateKeyPossessionSignature("foobar", 1234, *privKey)
+	assert.True(t, validateKeyPossession(pubKey, "foobar", 1234, sig))
+
+	// Different hash or serial
+	assert.False(t, validateKeyPossession(pubKey, "foobaz", 1234, sig))
+	assert.False(t, validateKeyPossession(pubKey, "foobar", 1235, sig))
+
+	// Signed by another key
+	assert.False(t, validateKeyPossession(otherPubKey, "foobar", 1234, sig))
+
+	// Missing key or signature
+	assert.False(t, validateKeyPossession(nil, "foobar", 1234, sig))
+	assert.False(t, validateKeyPossession(pubKey, "foobar", 1234, nil))
+}
+
+func TestAddressCreateWithoutKeyPossession(t *testing.T) {
+	setupRepo()
+
+	addr, _ := pkgAddress.NewAddress("example!")
+	pow := proofofwork.New(22, addr.Hash().String(), 1540921)
+
+	// Signed with a key other than the uploaded key
+	privKey, _, _ := testing2.ReadTestKey("../../testdata/key-3.json")
+	_, pubKey, _ := testing2.ReadTestKey("../../testdata/key-4.json")
+
+	b, _ := json.Marshal(addressUploadBody{
+		UserHash:  addr.LocalHash(),
+		OrgHash:   addr.OrgHash(),
+		PublicKey: pubKey,
+		RoutingID: fakeRoutingId.String(),
+		Proof:     proof.FromProofOfWork(pow),
+		KeySig:    GenerateKeyPossessionSignature(addr.Hash().String(), 0, *privKey),
+	})
+
+	req := http.NewRequest("GET", "/", string(b), nil)
+	res := PostAddressHash(addr.Hash(), req)
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, "{\"message\": \"proof of key possession failed\",\"status\": \"error\"}", res.Body)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	res = GetAddressHash(addr.Hash(), req)
+	assert.Equal(t, 404, res.StatusCode)
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"fmt"
+
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/proof"
+)
+
+var (
+	// Argon2Params are the parameters that argon2id proofs must use. Argon2id proofs are not accepted when nil.
+	Argon2Params *proof.Argon2Params
+	// Argon2BitReduction is the number of bits an argon2id proof needs less than a sha256 proof, as every argon2id
+	// hash takes a lot more time and memory to compute
+	Argon2BitReduction = 16
+)
+
+// supportedAlgorithm returns true when proofs with the algorithm and parameters of the proof are accepted
+func supportedAlgorithm(p *proof.Proof) bool {
+	switch p.Algorithm {
+	case proof.AlgorithmSHA256:
+		return true
+	case proof.AlgorithmArgon2id:
+		return Argon2Params != nil && p.Params == *Argon2Params
+	}
+
+	return false
+}
+
+// algorithmProofBits returns the number of bits a proof for the record type needs with the given algorithm
+func algorithmProofBits(algorithm string, bits int) int {
+	if algorithm != proof.AlgorithmArgon2id {
+		return bits
+	}
+
+	bits -= Argon2BitReduction
+	if bits < 1 {
+		return 1
+	}
+
+	return bits
+}
+
+// validateProof checks the proof-of-work for a new record. The cheap checks are done first, so invalid proofs are
+// rejected before computing any (memory-hard) hash.
+func validateProof(p *proof.Proof, typ, h string) *http.Response {
+	if p == nil {
+		return http.CreateError("incorrect proof-of-work", 400)
+	}
+
+	if !supportedAlgorithm(p) {
+		return http.CreateError("proof-of-work algorithm not supported", 400)
+	}
+
+	if httpErr := validateProofData(p.Data, h); httpErr != nil {
+		return httpErr
+	}
+
+	// Check minimum number of work bits
+	if bits := algorithmProofBits(p.Algorithm, acceptedProofBits(typ)); p.Bits < bits {
+		return http.CreateError(fmt.Sprintf("proof-of-work too weak (need %d bits)", bits), 400)
+	}
+
+	if !p.IsValid() {
+		return http.CreateError("incorrect proof-of-work", 400)
+	}
+
+	return nil
+}
+
+// algorithmsConfig returns the supported proof-of-work algorithms, with the parameters and current number of bits
+func algorithmsConfig(types []string) http.RawJSONOut {
+	algorithms := http.RawJSONOut{
+		proof.AlgorithmSHA256: http.RawJSONOut{},
+	}
+
+	if Argon2Params != nil {
+		bits := http.RawJSONOut{}
+		for _, typ := range types {
+			bits[typ] = algorithmProofBits(proof.AlgorithmArgon2id, currentProofBits(typ))
+		}
+
+		algorithms[proof.AlgorithmArgon2id] = http.RawJSONOut{
+			"time":    Argon2Params.Time,
+			"memory":  Argon2Params.Memory,
+			"threads": Argon2Params.Threads,
+			"bits":    bits,
+		}
+	}
+
+	return algorithms
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/proof"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgon2idProof(t *testing.T) {
+	setupRepo()
+	defer func() {
+		Argon2Params = nil
+		Argon2BitReduction = 16
+	}()
+
+	params := proof.Argon2Params{Time: 1, Memory: 64, Threads: 1}
+	addr, _ := pkgAddress.NewAddress("example!")
+
+	p := proof.NewArgon2id(params, 3, addr.Hash().String(), 0)
+	p.Work()
+
+	// Argon2id proofs are disabled by default
+	res := insertAddressRecordWithProof(*addr, "../../testdata/key-1.json", p)
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "proof-of-work algorithm not supported", "status": "error"}`, res.Body)
+
+	Argon2Params = &proof.Argon2Params{Time: 1, Memory: 128, Threads: 1}
+	Argon2BitReduction = 2
+
+	// Only the configured parameters are accepted
+	res = insertAddressRecordWithProof(*addr, "../../testdata/key-1.json", p)
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "proof-of-work algorithm not supported", "status": "error"}`, res.Body)
+
+	Argon2Params = &params
+	assert.Equal(t, http.RawJSONOut{
+		"sha256": http.RawJSONOut{},
+		"argon2id": http.RawJSONOut{
+			"time":    uint32(1),
+			"memory":  uint32(64),
+			"threads": uint8(1),
+			"bits":    http.RawJSONOut{"address": 3, "organisation": 3, "routing": 3},
+		},
+	}, ProofOfWorkConfig()["algorithms"])
+
+	weak := proof.NewArgon2id(params, 2, addr.Hash().String(), 0)
+	weak.Work()
+	res = insertAddressRecordWithProof(*addr, "../../testdata/key-1.json", weak)
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "proof-of-work too weak (need 3 bits)", "status": "error"}`, res.Body)
+
+	// More bits than the hash has are refused without computing it
+	oversized := proof.NewArgon2id(params, 300, addr.Hash().String(), p.Nonce)
+	res = insertAddressRecordWithProof(*addr, "../../testdata/key-1.json", oversized)
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "invalid data", "status": "error"}`, res.Body)
+
+	oversized.Bits = 0
+	res = insertAddressRecordWithProof(*addr, "../../testdata/key-1.json", oversized)
+	assert.Equal(t, 400, res.StatusCode)
+
+	invalid := proof.NewArgon2id(params, 3, addr.Hash().String(), p.Nonce)
+	for invalid.IsValid() {
+		invalid.Nonce++
+	}
+	res = insertAddressRecordWithProof(*addr, "../../testdata/key-1.json", invalid)
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "incorrect proof-of-work", "status": "error"}`, res.Body)
+
+	res = insertAddressRecordWithProof(*addr, "../../testdata/key-1.json", p)
+	assert.Equal(t, 201, res.StatusCode)
+
+	req := http.NewRequest("GET", "/", "", nil)
+	res = GetAddressHash(addr.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, p.String(), getAddressRecord(res).Proof)
+}
+
+func insertAddressRecordWithProof(addr pkgAddress.Address, keyPath string, p *proof.Proof) *http.Response {
+	privKey, pubKey, err := testing2.ReadTestKey(keyPath)
+	if err != nil {
+		return nil
+	}
+
+	b, err := json.Marshal(addressUploadBody{
+		UserHash:  addr.LocalHash(),
+		OrgHash:   addr.OrgHash(),
+		PublicKey: pubKey,
+		RoutingID: fakeRoutingId.String(),
+		Proof:     p,
+		KeySig:    GenerateKeyPossessionSignature(addr.Hash().String(), 0, *privKey),
+	})
+	if err != nil {
+		return nil
+	}
+	req := http.NewRequest("GET", "/", string(b), nil)
+
+	return PostAddressHash(addr.Hash(), req)
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"log"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/ratelimit"
+)
+
+// RateLimits are the budgets for requests per client and per target hash. Requests are not limited when nil.
+var RateLimits *ratelimit.Config
+
+// rateLimitKey is a token bucket together with its limit
+type rateLimitKey struct {
+	key   string
+	limit ratelimit.Limit
+}
+
+// RateLimited wraps a handler so clients that go over their budget, or requests for a hash that is over its budget,
+// get a 429 response. Reads and writes have their own budget. Requests that fail authentication are counted in a
+// separate budget, and once that one is used up, failed authentications get a 429 instead of a 401. That budget is
+// only looked at after a request failed, so anyone who can sign their requests is never blocked by the failures of
+// others.
+func RateLimited(f func(hash.Hash, http.Request) *http.Response) func(hash.Hash, http.Request) *http.Response {
+	return func(h hash.Hash, req http.Request) *http.Response {
+		if RateLimits == nil {
+			return f(h, req)
+		}
+
+		class, cost := requestCost(req)
+		if resp := checkRateLimits(rateLimitKeys(h, req, class), cost); resp != nil {
+			return resp
+		}
+
+		resp := f(h, req)
+		if resp != nil && resp.StatusCode == 401 {
+			if limited := checkRateLimits(rateLimitKeys(h, req, ratelimit.ClassFailedAuth), 1); limited != nil {
+				return limited
+			}
+		}
+
+		return resp
+	}
+}
+
+// TakeRateLimit takes a token from the budgets of the client and the hash of the request, and returns a 429 response
+// when one of them is used up. It is used by handlers that cannot be wrapped by RateLimited, like streams.
+func TakeRateLimit(h hash.Hash, req http.Request) *http.Response {
+	if RateLimits == nil {
+		return nil
+	}
+
+	return checkRateLimits(rateLimitKeys(h, req, requestClass(req)), 1)
+}
+
+// requestClass returns the budget class of the request
+func requestClass(req http.Request) string {
+	if req.Method == "GET" {
+		return ratelimit.ClassRead
+	}
+
+	return ratelimit.ClassWrite
+}
+
+// requestCost returns the budget class of the request and the number of tokens it takes. A batch resolve is a read
+// that takes a token for every hash, so it does not allow more lookups than separate requests would.
+func requestCost(req http.Request) (string, float64) {
+	if req.Method == "POST" && isBatchResolve(req) {
+		return ratelimit.ClassRead, float64(resolveCost(req))
+	}
+
+	return requestClass(req), 1
+}
+
+// isBatchResolve returns true when the request is a POST /address/resolve. The path can have a stage prefix when
+// running behind an API gateway.
+func isBatchResolve(req http.Request) bool {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasSuffix(strings.TrimSuffix(u.Path, "/"), "/address/resolve")
+}
+
+// rateLimitKeys returns the buckets of the client and the target hash for the class of request
+func rateLimitKeys(h hash.Hash, req http.Request, class string) []rateLimitKey {
+	var keys []rateLimitKey
+
+	if req.ClientIP != "" {
+		keys = append(keys, rateLimitKey{
+			key:   "client/" + class + "/" + req.ClientIP,
+			limit: RateLimits.Client.Limit(class),
+		})
+	}
+
+	if h.String() != "" {
+		keys = append(keys, rateLimitKey{
+			key:   "hash/" + class + "/" + h.String(),
+			limit: RateLimits.Hash.Limit(class),
+		})
+	}
+
+	return keys
+}
+
+// checkRateLimits takes cost tokens from every bucket, and returns a 429 response when one of them is empty. Requests
+// are let through when the buckets cannot be reached, so an outage of the store does not take down the resolver.
+func checkRateLimits(keys []rateLimitKey, cost float64) *http.Response {
+	repo := ratelimit.GetRepository()
+
+	for _, k := range keys {
+		wait, err := ratelimit.TakeN(repo, k.key, k.limit, cost, timeNow())
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+
+		if wait > 0 {
+			resp := http.CreateError("too many requests", 429)
+			resp.Headers.Set("retry-after", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return resp
+		}
+	}
+
+	return nil
+}
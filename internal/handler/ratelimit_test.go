@@ -0,0 +1,176 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/ratelimit"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimited(t *testing.T) {
+	setupRepo()
+	ratelimit.SetDefaultRepository(ratelimit.NewMemoryRepository())
+	defer func() {
+		RateLimits = nil
+	}()
+
+	addr1, _ := pkgAddress.NewAddress("foo!")
+	pow1 := proofofwork.New(22, addr1.Hash().String(), 1310761)
+	addr2, _ := pkgAddress.NewAddress("bar!")
+	pow2 := proofofwork.New(22, addr2.Hash().String(), 1019732)
+
+	res := insertAddressRecord(*addr1, "../../testdata/key-3.json", fakeRoutingId.String(), pow1, "")
+	assert.Equal(t, 201, res.StatusCode)
+	res = insertAddressRecord(*addr2, "../../testdata/key-4.json", fakeRoutingId.String(), pow2, "")
+	assert.Equal(t, 201, res.StatusCode)
+
+	getAddress := RateLimited(GetAddressHash)
+	deleteAddress := RateLimited(DeleteAddressHash)
+
+	newRequest := func(method, ip string) http.Request {
+		req := http.NewRequest(method, "/", "", nil)
+		req.ClientIP = ip
+		return req
+	}
+
+	// Without limits, nothing is limited
+	for i := 0; i < 5; i++ {
+		res = getAddress(addr1.Hash(), newRequest("GET", "127.0.0.1"))
+		assert.Equal(t, 200, res.StatusCode)
+	}
+
+	RateLimits = &ratelimit.Config{
+		Client: ratelimit.Budgets{
+			Read:       ratelimit.Limit{Rate: 1, Burst: 2},
+			Write:      ratelimit.Limit{Rate: 1, Burst: 5},
+			FailedAuth: ratelimit.Limit{Rate: 0.01, Burst: 2},
+		},
+		Hash: ratelimit.Budgets{
+			Read:       ratelimit.Limit{Rate: 1, Burst: 3},
+			FailedAuth: ratelimit.Limit{Rate: 0.01, Burst: 3},
+		},
+	}
+
+	// Reads per client
+	res = getAddress(addr1.Hash(), newRequest("GET", "127.0.0.1"))
+	assert.Equal(t, 200, res.StatusCode)
+	res = getAddress(addr2.Hash(), newRequest("GET", "127.0.0.1"))
+	assert.Equal(t, 200, res.StatusCode)
+	res = getAddress(addr1.Hash(), newRequest("GET", "127.0.0.1"))
+	assert.Equal(t, 429, res.StatusCode)
+	assert.Equal(t, "1", res.Headers.Get("retry-after"))
+	assert.JSONEq(t, `{"message": "too many requests", "status": "error"}`, res.Body)
+
+	// Reads per hash, from other clients
+	res = getAddress(addr1.Hash(), newRequest("GET", "127.0.0.2"))
+	assert.Equal(t, 200, res.StatusCode)
+	res = getAddress(addr1.Hash(), newRequest("GET", "127.0.0.3"))
+	assert.Equal(t, 200, res.StatusCode)
+	res = getAddress(addr1.Hash(), newRequest("GET", "127.0.0.4"))
+	assert.Equal(t, 429, res.StatusCode)
+	res = getAddress(addr2.Hash(), newRequest("GET", "127.0.0.4"))
+	assert.Equal(t, 200, res.StatusCode)
+
+	// Buckets are refilled over time
+	setRepoTime(time.Date(2010, 04, 07, 12, 35, 56, 0, time.UTC))
+	res = getAddress(addr1.Hash(), newRequest("GET", "127.0.0.1"))
+	assert.Equal(t, 200, res.StatusCode)
+
+	// Failed authentications have their own budget, and are refused with a 429 once used up
+	req := newRequest("DELETE", "127.0.0.5")
+	req.Headers.Set("authorization", "Bearer sdfafsadf")
+	res = deleteAddress(addr1.Hash(), req)
+	assert.Equal(t, 401, res.StatusCode)
+	res = deleteAddress(addr1.Hash(), req)
+	assert.Equal(t, 401, res.StatusCode)
+	res = deleteAddress(addr1.Hash(), req)
+	assert.Equal(t, 429, res.StatusCode)
+	assert.Equal(t, "100", res.Headers.Get("retry-after"))
+
+	res = deleteAddress(addr1.Hash(), newRequest("DELETE", "127.0.0.5"))
+	assert.Equal(t, 429, res.StatusCode)
+
+	// Reads without authentication are still allowed
+	res = getAddress(addr1.Hash(), newRequest("GET", "127.0.0.5"))
+	assert.Equal(t, 200, res.StatusCode)
+
+	// Other clients have their own budget, until the budget of the hash is used up as well
+	req.ClientIP = "127.0.0.6"
+	res = deleteAddress(addr1.Hash(), req)
+	assert.Equal(t, 401, res.StatusCode)
+	res = deleteAddress(addr1.Hash(), req)
+	assert.Equal(t, 429, res.StatusCode)
+
+	// The owner is never blocked by the failures of others
+	current, _ := address.GetResolveRepository().Get(addr1.Hash().String())
+	privKey, _, _ := testing2.ReadTestKey("../../testdata/key-3.json")
+	sig := current.Hash + current.RoutingID + strconv.FormatUint(current.Serial, 10)
+	req = newRequest("DELETE", "127.0.0.5")
+	req.Headers.Set("authorization", "Bearer "+http.GenerateAuthenticationToken([]byte(sig), *privKey))
+	res = deleteAddress(addr1.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+}
+
+func TestRateLimitedResolve(t *testing.T) {
+	setupRepo()
+	ratelimit.SetDefaultRepository(ratelimit.NewMemoryRepository())
+	defer func() {
+		RateLimits = nil
+	}()
+
+	RateLimits = &ratelimit.Config{
+		Client: ratelimit.Budgets{
+			Read:  ratelimit.Limit{Rate: 1, Burst: 5},
+			Write: ratelimit.Limit{Rate: 1, Burst: 100},
+		},
+	}
+
+	resolve := RateLimited(PostAddressResolve)
+	getAddress := RateLimited(GetAddressHash)
+	hashes := `{"hashes": ["` + hash.New("a!").String() + `", "` + hash.New("b!").String() + `", "` + hash.New("c!").String() + `", "` + hash.New("d!").String() + `"]}`
+
+	// Every hash takes a read token
+	req := http.NewRequest("POST", "/address/resolve", hashes, nil)
+	req.ClientIP = "127.0.0.1"
+	res := resolve("", req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	req.ClientIP = "127.0.0.1"
+	res = getAddress(hash.New("a!"), req)
+	assert.Equal(t, 404, res.StatusCode)
+	res = getAddress(hash.New("a!"), req)
+	assert.Equal(t, 429, res.StatusCode)
+
+	req = http.NewRequest("POST", "/address/resolve", hashes, nil)
+	req.ClientIP = "127.0.0.1"
+	res = resolve("", req)
+	assert.Equal(t, 429, res.StatusCode)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"log"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/organisation"
+	"github.com/bitmaelum/key-resolver-go/internal/routing"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+	"github.com/bitmaelum/key-resolver-go/pkg/receipt"
+)
+
+// receiptMessage returns the message together with a receipt signed by the resolver. When no signing key is
+// configured, or the receipt could not be created, only the message is returned.
+func receiptMessage(msg string, statusCode int, r *receipt.Receipt) *http.Response {
+	if SigningKey == nil || r == nil {
+		return http.CreateMessage(msg, statusCode)
+	}
+
+	r.Timestamp = timeNow().Unix()
+	err := r.Sign(*SigningKey)
+	if err != nil {
+		log.Print(err)
+		return http.CreateMessage(msg, statusCode)
+	}
+
+	return http.CreateOutput(http.RawJSONOut{
+		"status":  "ok",
+		"message": msg,
+		"receipt": r,
+	}, statusCode)
+}
+
+// newReceipt creates an unsigned receipt for the record
+func newReceipt(typ, h string, serial uint64, pubKey string, logIndex uint64, logged bool) *receipt.Receipt {
+	pk, err := bmcrypto.NewPubKey(pubKey)
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+
+	r := &receipt.Receipt{
+		Type:        typ,
+		Hash:        h,
+		Serial:      serial,
+		Fingerprint: pk.Fingerprint(),
+	}
+
+	if logged {
+		r.LogIndex = &logIndex
+	}
+
+	return r
+}
+
+// addressReceipt fetches the address as it is stored after the registration, and creates a receipt for it
+func addressReceipt(h string, logIndex uint64, logged bool) *receipt.Receipt {
+	if SigningKey == nil {
+		return nil
+	}
+
+	info, err := fetchAddress(h)
+	if err != nil || info == nil {
+		log.Print(err)
+		return nil
+	}
+
+	return newReceipt(translog.TypeAddress, info.Hash, info.Serial, info.PubKey, logIndex, logged)
+}
+
+// organisationReceipt fetches the organisation as it is stored after the registration, and creates a receipt for it
+func organisationReceipt(h string, logIndex uint64, logged bool) *receipt.Receipt {
+	if SigningKey == nil {
+		return nil
+	}
+
+	info, err := organisation.GetResolveRepository().Get(h)
+	if err != nil || info == nil {
+		log.Print(err)
+		return nil
+	}
+
+	return newReceipt(translog.TypeOrganisation, info.Hash, info.Serial, info.PubKey, logIndex, logged)
+}
+
+// routingReceipt fetches the routing as it is stored after the registration, and creates a receipt for it
+func routingReceipt(h string, logIndex uint64, logged bool) *receipt.Receipt {
+	if SigningKey == nil {
+		return nil
+	}
+
+	info, err := routing.GetResolveRepository().Get(h)
+	if err != nil || info == nil {
+		log.Print(err)
+		return nil
+	}
+
+	return newReceipt(translog.TypeRouting, info.Hash, info.Serial, info.PubKey, logIndex, logged)
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/bitmaelum/key-resolver-go/pkg/receipt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrationReceipts(t *testing.T) {
+	setupRepo()
+
+	privKey, pubKey, _ := testing2.ReadTestKey("../../testdata/key-7.json")
+	SigningKey = privKey
+	defer func() {
+		SigningKey = nil
+	}()
+
+	type receiptResponse struct {
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+		Receipt json.RawMessage `json:"receipt"`
+	}
+
+	// Create a routing record
+	routingHash := hash.New("routing1")
+	res := insertRoutingRecord(routingHash, "../../testdata/key-1.json", "127.0.0.1")
+	assert.Equal(t, 201, res.StatusCode)
+
+	out := &receiptResponse{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, "ok", out.Status)
+	assert.Equal(t, "routing has been created", out.Message)
+
+	r, ok := receipt.VerifyJSON(out.Receipt, *pubKey)
+	assert.True(t, ok)
+	assert.Equal(t, "routing", r.Type)
+	assert.Equal(t, routingHash.String(), r.Hash)
+	assert.Equal(t, uint64(1270643696000000000), r.Serial)
+	assert.Equal(t, int64(1270643696), r.Timestamp)
+	assert.Equal(t, uint64(0), *r.LogIndex)
+
+	_, routingKey, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	assert.Equal(t, routingKey.Fingerprint(), r.Fingerprint)
+
+	// Create an address record
+	addr, _ := pkgAddress.NewAddress("example!")
+	pow := proofofwork.New(22, addr.Hash().String(), 1540921)
+	res = insertAddressRecord(*addr, "../../testdata/key-3.json", routingHash.String(), pow, "")
+	assert.Equal(t, 201, res.StatusCode)
+
+	out = &receiptResponse{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, "address has been created", out.Message)
+
+	r, ok = receipt.VerifyJSON(out.Receipt, *pubKey)
+	assert.True(t, ok)
+	assert.Equal(t, "address", r.Type)
+	assert.Equal(t, addr.Hash().String(), r.Hash)
+	assert.Equal(t, uint64(1), *r.LogIndex)
+
+	_, addrKey, _ := testing2.ReadTestKey("../../testdata/key-3.json")
+	assert.Equal(t, addrKey.Fingerprint(), r.Fingerprint)
+
+	// The receipt does not verify with another key
+	_, ok = receipt.VerifyJSON(out.Receipt, *routingKey)
+	assert.False(t, ok)
+
+	// Without a signing key, no receipts are handed out
+	SigningKey = nil
+	res = insertRoutingRecord(hash.New("routing2"), "../../testdata/key-2.json", "127.0.0.2")
+	assert.Equal(t, 201, res.StatusCode)
+	assert.JSONEq(t, `{"message": "routing has been created","status": "ok"}`, res.Body)
+}
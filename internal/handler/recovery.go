@@ -0,0 +1,58 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"time"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+)
+
+var (
+	// KeyResetDelay is the time a key reset through a recovery key stays pending. During this time, the current key
+	// is able to cancel the reset.
+	KeyResetDelay = 7 * 24 * time.Hour
+
+	// Can be overridden for testing purposes
+	timeNow = time.Now
+)
+
+type keyResetUploadBody struct {
+	PublicKey *bmcrypto.PubKey `json:"public_key"`
+	KeySig    []byte           `json:"key_signature"`
+}
+
+// keyResetOutput returns the pending key reset information, or nil when no reset is pending
+func keyResetOutput(resetKey string, resetAt time.Time) http.RawJSONOut {
+	if resetKey == "" {
+		return nil
+	}
+
+	return http.RawJSONOut{
+		"public_key": resetKey,
+		"reset_at":   resetAt.Unix(),
+	}
+}
+
+// isKeyResetPending returns true when the pending key reset cannot be completed yet
+func isKeyResetPending(resetAt time.Time) bool {
+	return timeNow().Before(resetAt)
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+)
+
+// Policies for deleting an address that other addresses still redirect to
+const (
+	// RedirectDeleteRefuse refuses the deletion until all dependent addresses have stopped redirecting
+	RedirectDeleteRefuse = "refuse"
+	// RedirectDeleteReport allows the deletion, and returns the dependent addresses that will stop resolving
+	RedirectDeleteReport = "report"
+)
+
+// RedirectDeletePolicy is the policy used when deleting or soft-deleting an address that is a redirect target. Anyone
+// can redirect their own address to any other address, so refusing lets a third party block the deletion of an address
+// it does not own. Reporting is therefore the default.
+var RedirectDeletePolicy = RedirectDeleteReport
+
+// IsValidRedirectDeletePolicy returns true when the policy is known
+func IsValidRedirectDeletePolicy(policy string) bool {
+	return policy == RedirectDeleteRefuse || policy == RedirectDeleteReport
+}
+
+func GetAddressRedirectedBy(addrHash hash.Hash, req http.Request) *http.Response {
+	if httpErr := authenticateRecord(translog.TypeAddress, scopeRedirectsGet, addrHash, req); httpErr != nil {
+		return httpErr
+	}
+
+	infos, err := redirectingAddresses(addrHash.String())
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching redirections", 500)
+	}
+
+	output := make([]http.RawJSONOut, 0, len(infos))
+	for _, info := range infos {
+		output = append(output, http.RawJSONOut{
+			"hash":    info.Hash,
+			"deleted": info.Deleted,
+		})
+	}
+
+	return http.CreateOutput(http.RawJSONOut{
+		"hash":          addrHash.String(),
+		"redirected_by": output,
+	}, 200)
+}
+
+// redirectingAddresses returns the records of all addresses that redirect to the hash, sorted on hash
+func redirectingAddresses(h string) ([]*address.ResolveInfoType, error) {
+	repo := address.GetResolveRepository()
+	hashes, err := repo.GetRedirectedBy(h)
+	if err != nil || len(hashes) == 0 {
+		return nil, err
+	}
+
+	records, err := repo.GetMany(hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*address.ResolveInfoType, 0, len(records))
+	for _, info := range records {
+		// The index is only a pointer, the record itself decides if it still redirects to us
+		if info.RedirHash == h {
+			infos = append(infos, info)
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Hash < infos[j].Hash
+	})
+
+	return infos, nil
+}
+
+// activeDependents returns the hashes of the non-deleted addresses that will stop resolving when h is deleted
+func activeDependents(h string) ([]string, error) {
+	infos, err := redirectingAddresses(h)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	for _, info := range infos {
+		if !info.Deleted {
+			hashes = append(hashes, info.Hash)
+		}
+	}
+
+	return hashes, nil
+}
+
+// checkDependents checks the dependent addresses of h against the redirect deletion policy. It returns the dependents
+// that must be reported, or an error response when the deletion is not allowed.
+func checkDependents(h string) ([]string, *http.Response) {
+	dependents, err := activeDependents(h)
+	if err != nil {
+		log.Print(err)
+		return nil, http.CreateError("error while fetching redirections", 500)
+	}
+
+	if len(dependents) == 0 || RedirectDeletePolicy == RedirectDeleteReport {
+		return dependents, nil
+	}
+
+	return nil, http.CreateOutput(http.RawJSONOut{
+		"status":        "error",
+		"message":       fmt.Sprintf("address is a redirect target for %d address(es)", len(dependents)),
+		"redirected_by": dependents,
+	}, 409)
+}
+
+// deletedMessage returns the message for a deleted address, together with the dependents that do not resolve anymore
+func deletedMessage(msg string, dependents []string) *http.Response {
+	if len(dependents) == 0 {
+		return http.CreateMessage(msg, 200)
+	}
+
+	return http.CreateOutput(http.RawJSONOut{
+		"status":        "ok",
+		"message":       msg,
+		"redirected_by": dependents,
+	}, 200)
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressRedirectedBy(t *testing.T) {
+	setupRepo()
+	MaxRedirectDepth = 10
+
+	addr1, _ := pkgAddress.NewAddress("aaa!")
+	pow1 := proofofwork.New(MinimumProofBitsAddress, addr1.Hash().String(), 0)
+	pow1.WorkMulticore()
+	addr2, _ := pkgAddress.NewAddress("bbb!")
+	pow2 := proofofwork.New(MinimumProofBitsAddress, addr2.Hash().String(), 0)
+	pow2.WorkMulticore()
+
+	insertAddressRecord(*addr1, "../../testdata/key-1.json", fakeRoutingId.String(), pow1, "")
+	insertAddressRecord(*addr2, "../../testdata/key-2.json", fakeRoutingId.String(), pow2, addr1.Hash().String())
+
+	type redirectedByOutput struct {
+		Hash         string `json:"hash"`
+		RedirectedBy []struct {
+			Hash    string `json:"hash"`
+			Deleted bool   `json:"deleted"`
+		} `json:"redirected_by"`
+	}
+
+	// Only the target itself is allowed to see who redirects to it
+	req := http.NewRequest("GET", "/", "", nil)
+	res := GetAddressRedirectedBy(addr1.Hash(), req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	// A token for an update or deletion of the address cannot be used
+	req = redirectAuthRequest("", addr1.Hash().String(), "../../testdata/key-1.json")
+	res = GetAddressRedirectedBy(addr1.Hash(), req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	req = redirectAuthRequest(scopeRedirectsGet, addr1.Hash().String(), "../../testdata/key-1.json")
+	res = GetAddressRedirectedBy(addr1.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	out := &redirectedByOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, addr1.Hash().String(), out.Hash)
+	assert.Len(t, out.RedirectedBy, 1)
+	assert.Equal(t, addr2.Hash().String(), out.RedirectedBy[0].Hash)
+	assert.False(t, out.RedirectedBy[0].Deleted)
+
+	req = redirectAuthRequest(scopeRedirectsGet, addr2.Hash().String(), "../../testdata/key-2.json")
+	res = GetAddressRedirectedBy(addr2.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.JSONEq(t, `{"hash": "`+addr2.Hash().String()+`", "redirected_by": []}`, res.Body)
+}
+
+func TestAddressDeleteRedirectTarget(t *testing.T) {
+	setupRepo()
+	MaxRedirectDepth = 10
+	defer func() {
+		RedirectDeletePolicy = RedirectDeleteReport
+	}()
+
+	addr1, _ := pkgAddress.NewAddress("aaa!")
+	pow1 := proofofwork.New(MinimumProofBitsAddress, addr1.Hash().String(), 0)
+	pow1.WorkMulticore()
+	addr2, _ := pkgAddress.NewAddress("bbb!")
+	pow2 := proofofwork.New(MinimumProofBitsAddress, addr2.Hash().String(), 0)
+	pow2.WorkMulticore()
+
+	insertAddressRecord(*addr1, "../../testdata/key-1.json", fakeRoutingId.String(), pow1, "")
+	insertAddressRecord(*addr2, "../../testdata/key-2.json", fakeRoutingId.String(), pow2, addr1.Hash().String())
+
+	// Refused while addr2 redirects to addr1
+	RedirectDeletePolicy = RedirectDeleteRefuse
+	req := redirectAuthRequest("", addr1.Hash().String(), "../../testdata/key-1.json")
+	res := DeleteAddressHash(addr1.Hash(), req)
+	assert.Equal(t, 409, res.StatusCode)
+	assert.JSONEq(t, `{"status": "error", "message": "address is a redirect target for 1 address(es)", "redirected_by": ["`+addr2.Hash().String()+`"]}`, res.Body)
+	res = SoftDeleteAddressHash(addr1.Hash(), req)
+	assert.Equal(t, 409, res.StatusCode)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	res = GetAddressHash(addr2.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	// Reported, but deleted anyway
+	RedirectDeletePolicy = RedirectDeleteReport
+	req = redirectAuthRequest("", addr1.Hash().String(), "../../testdata/key-1.json")
+	res = SoftDeleteAddressHash(addr1.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.JSONEq(t, `{"status": "ok", "message": "address has been soft-deleted", "redirected_by": ["`+addr2.Hash().String()+`"]}`, res.Body)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	res = GetAddressHash(addr2.Hash(), req)
+	assert.Equal(t, 404, res.StatusCode)
+
+	// Soft-deleted dependents do not block a deletion
+	RedirectDeletePolicy = RedirectDeleteRefuse
+	repo := address.GetResolveRepository()
+	_, _ = repo.SoftUndelete(addr1.Hash().String())
+	_, _ = repo.SoftDelete(addr2.Hash().String())
+
+	req = redirectAuthRequest("", addr1.Hash().String(), "../../testdata/key-1.json")
+	res = DeleteAddressHash(addr1.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.JSONEq(t, `{"status": "ok", "message": "address has been deleted"}`, res.Body)
+}
+
+func TestAddressDeleteThirdPartyRedirect(t *testing.T) {
+	setupRepo()
+	MaxRedirectDepth = 10
+
+	victim, _ := pkgAddress.NewAddress("victim!")
+	pow1 := proofofwork.New(MinimumProofBitsAddress, victim.Hash().String(), 0)
+	pow1.WorkMulticore()
+	insertAddressRecord(*victim, "../../testdata/key-1.json", fakeRoutingId.String(), pow1, "")
+
+	// A third party redirects its own address to the victim, without any involvement of the victim
+	other, _ := pkgAddress.NewAddress("other!")
+	pow2 := proofofwork.New(MinimumProofBitsAddress, other.Hash().String(), 0)
+	pow2.WorkMulticore()
+	res := insertAddressRecord(*other, "../../testdata/key-2.json", fakeRoutingId.String(), pow2, victim.Hash().String())
+	assert.Equal(t, 201, res.StatusCode)
+
+	// With the default policy the redirect cannot block the victim from deleting its own address
+	assert.Equal(t, RedirectDeleteReport, RedirectDeletePolicy)
+	req := redirectAuthRequest("", victim.Hash().String(), "../../testdata/key-1.json")
+	res = DeleteAddressHash(victim.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.JSONEq(t, `{"status": "ok", "message": "address has been deleted", "redirected_by": ["`+other.Hash().String()+`"]}`, res.Body)
+}
+
+// redirectAuthRequest returns a request that is authenticated with the key of the address
+func redirectAuthRequest(scope, h string, keyPath string) http.Request {
+	current, _ := address.GetResolveRepository().Get(h)
+	privKey, _, _ := testing2.ReadTestKey(keyPath)
+	sig := scope + current.Hash + current.RoutingID + strconv.FormatUint(current.Serial, 10)
+
+	req := http.NewRequest("GET", "/", "", nil)
+	req.Headers.Set("authorization", "BEARER "+http.GenerateAuthenticationToken([]byte(sig), *privKey))
+
+	return req
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package handler
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/routing"
+)
+
+// MaxResolveHashes is the maximum number of hashes that can be resolved in a single batch request
+var MaxResolveHashes = 100
+
+type resolveBody struct {
+	Hashes         []string `json:"hashes"`
+	IncludeRouting bool     `json:"include_routing"`
+}
+
+// resolveResult holds the outcome of resolving a single hash from a batch
+type resolveResult struct {
+	info    *address.ResolveInfoType
+	chain   []*address.ResolveInfoType
+	err     string
+	pending string // Hash that must be fetched before the redirect chain can be followed further
+}
+
+// PostAddressResolve resolves multiple address hashes at once. Every hash gets its own result or error, so a single
+// unknown hash does not fail the whole batch.
+func PostAddressResolve(_ hash.Hash, req http.Request) *http.Response {
+	body := &resolveBody{}
+	err := json.Unmarshal([]byte(req.Body), body)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("invalid data", 400)
+	}
+
+	if len(body.Hashes) > MaxResolveHashes {
+		return http.CreateError("too many hashes", 400)
+	}
+
+	results := make([]*resolveResult, len(body.Hashes))
+	records := make(map[string]*address.ResolveInfoType)
+
+	var fetch []string
+	for i, h := range body.Hashes {
+		results[i] = &resolveResult{}
+		if _, err := hash.NewFromHash(h); err != nil {
+			results[i].err = "incorrect hash address"
+			continue
+		}
+		results[i].pending = h
+		fetch = append(fetch, h)
+	}
+
+	// Every round fetches the next hop of all unfinished redirect chains, so the number of rounds is limited by the
+	// maximum redirect depth and not by the number of hashes.
+	for len(fetch) > 0 {
+		err = fetchAddresses(fetch, records)
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while fetching record", 500)
+		}
+
+		fetch = nil
+		for i, h := range body.Hashes {
+			if results[i].pending == "" {
+				continue
+			}
+
+			results[i] = followRedirects(h, records)
+			if results[i].pending != "" {
+				fetch = append(fetch, results[i].pending)
+			}
+		}
+	}
+
+	var routes map[string]*routing.ResolveInfoType
+	if body.IncludeRouting {
+		routes, err = fetchRoutings(results)
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while fetching record", 500)
+		}
+	}
+
+	out := make([]http.RawJSONOut, len(body.Hashes))
+	for i, h := range body.Hashes {
+		out[i] = http.RawJSONOut{"hash": h}
+		if results[i].err != "" {
+			out[i]["error"] = results[i].err
+			continue
+		}
+
+		out[i]["address"] = addressOutput(results[i].info)
+		if len(results[i].chain) > 1 {
+			out[i]["redirect_chain"] = redirectChainOutput(results[i].chain)
+		}
+		if rt, ok := routes[results[i].info.RoutingID]; ok {
+			out[i]["routing"] = routingOutput(rt)
+		}
+	}
+
+	return http.CreateOutput(http.RawJSONOut{
+		"results": out,
+	}, 200)
+}
+
+// fetchAddresses fetches the given hashes in bulk and adds them to records. Hashes that are not found are stored as
+// nil so they are not fetched again.
+func fetchAddresses(hashes []string, records map[string]*address.ResolveInfoType) error {
+	infos, err := address.GetResolveRepository().GetMany(hashes)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hashes {
+		info := infos[h]
+
+		// Pending changes are activated on read, just like a single fetch does
+		if info != nil && hasDueChanges(info) {
+			info, err = fetchAddress(h)
+			if err != nil && err != address.ErrNotFound {
+				return err
+			}
+		}
+
+		records[h] = info
+	}
+
+	return nil
+}
+
+// followRedirects follows the redirect chain of h through the fetched records, with the same rules as recursiveGet.
+func followRedirects(h string, records map[string]*address.ResolveInfoType) *resolveResult {
+	res := &resolveResult{}
+	curDepth := 0
+
+	for {
+		info, ok := records[h]
+		if !ok {
+			res.pending = h
+			return res
+		}
+
+		if info == nil || info.Deleted {
+			res.err = "hash not found"
+			return res
+		}
+
+		// Not redirect, so we are done
+		if info.RedirHash == "" {
+			res.chain = append(res.chain, info)
+			res.info = info
+			return res
+		}
+
+		curDepth++
+		if curDepth >= MaxRedirectDepth {
+			res.err = "maximum redirection reached"
+			return res
+		}
+
+		for i := range res.chain {
+			if res.chain[i].Hash == h {
+				res.err = "cyclic dependency detected"
+				return res
+			}
+		}
+		res.chain = append(res.chain, info)
+
+		h = info.RedirHash
+	}
+}
+
+// fetchRoutings fetches the routing records referenced by the resolved addresses in bulk
+func fetchRoutings(results []*resolveResult) (map[string]*routing.ResolveInfoType, error) {
+	var ids []string
+	for _, res := range results {
+		if res.info != nil && res.info.RoutingID != "" {
+			ids = append(ids, res.info.RoutingID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	return routing.GetResolveRepository().GetMany(ids)
+}
+
+// resolveCost returns the number of hashes in a batch resolve request, and at least 1
+func resolveCost(req http.Request) int {
+	body := &resolveBody{}
+	err := json.Unmarshal([]byte(req.Body), body)
+	if err != nil || len(body.Hashes) == 0 {
+		return 1
+	}
+
+	return len(body.Hashes)
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/stretchr/testify/assert"
+)
+
+type resolveOutput struct {
+	Results []struct {
+		Hash      string          `json:"hash"`
+		Error     string          `json:"error"`
+		Address   addressInfoType `json:"address"`
+		Redirects []struct {
+			Hash         string `json:"hash"`
+			SerialNumber uint64 `json:"serial_number"`
+			Fingerprint  string `json:"fingerprint"`
+		} `json:"redirect_chain"`
+		Routing *struct {
+			Hash    string `json:"hash"`
+			Routing string `json:"routing"`
+		} `json:"routing"`
+	} `json:"results"`
+}
+
+func TestAddressResolve(t *testing.T) {
+	setupRepo()
+	MaxRedirectDepth = 10
+
+	routingHash := hash.New("routing1")
+	res := insertRoutingRecord(routingHash, "../../testdata/key-3.json", "127.0.0.1")
+	assert.Equal(t, 201, res.StatusCode)
+
+	addr1, _ := pkgAddress.NewAddress("aaa!")
+	pow1 := proofofwork.New(MinimumProofBitsAddress, addr1.Hash().String(), 0)
+	pow1.WorkMulticore()
+	addr2, _ := pkgAddress.NewAddress("bbb!")
+	pow2 := proofofwork.New(MinimumProofBitsAddress, addr2.Hash().String(), 0)
+	pow2.WorkMulticore()
+	addr3, _ := pkgAddress.NewAddress("ccc!")
+	pow3 := proofofwork.New(MinimumProofBitsAddress, addr3.Hash().String(), 0)
+	pow3.WorkMulticore()
+	addr4, _ := pkgAddress.NewAddress("ddd!")
+	pow4 := proofofwork.New(MinimumProofBitsAddress, addr4.Hash().String(), 0)
+	pow4.WorkMulticore()
+
+	// addr3 -> addr2 -> addr1, and addr4 without routing record
+	res = insertAddressRecord(*addr1, "../../testdata/key-1.json", routingHash.String(), pow1, "")
+	assert.Equal(t, 201, res.StatusCode)
+	res = insertAddressRecord(*addr2, "../../testdata/key-2.json", fakeRoutingId.String(), pow2, addr1.Hash().String())
+	assert.Equal(t, 201, res.StatusCode)
+	res = insertAddressRecord(*addr3, "../../testdata/key-4.json", fakeRoutingId.String(), pow3, addr2.Hash().String())
+	assert.Equal(t, 201, res.StatusCode)
+	res = insertAddressRecord(*addr4, "../../testdata/key-5.json", fakeRoutingId.String(), pow4, "")
+	assert.Equal(t, 201, res.StatusCode)
+
+	unknown := hash.New("unknown!")
+	body, _ := json.Marshal(resolveBody{
+		Hashes: []string{
+			addr1.Hash().String(),
+			addr3.Hash().String(),
+			unknown.String(),
+			"foobar",
+			addr4.Hash().String(),
+		},
+		IncludeRouting: true,
+	})
+
+	req := http.NewRequest("POST", "/address/resolve", string(body), nil)
+	res = PostAddressResolve("", req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	out := &resolveOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Len(t, out.Results, 5)
+
+	assert.Equal(t, addr1.Hash().String(), out.Results[0].Hash)
+	assert.Equal(t, addr1.Hash().String(), out.Results[0].Address.Hash)
+	assert.Empty(t, out.Results[0].Redirects)
+	assert.Equal(t, routingHash.String(), out.Results[0].Routing.Hash)
+	assert.Equal(t, "127.0.0.1", out.Results[0].Routing.Routing)
+
+	assert.Equal(t, addr3.Hash().String(), out.Results[1].Hash)
+	assert.Equal(t, addr1.Hash().String(), out.Results[1].Address.Hash)
+	assert.Len(t, out.Results[1].Redirects, 3)
+	assert.Equal(t, addr3.Hash().String(), out.Results[1].Redirects[0].Hash)
+	assert.Equal(t, addr2.Hash().String(), out.Results[1].Redirects[1].Hash)
+	assert.Equal(t, addr1.Hash().String(), out.Results[1].Redirects[2].Hash)
+	assert.Equal(t, uint64(1270643696000000000), out.Results[1].Redirects[2].SerialNumber)
+	assert.NotEmpty(t, out.Results[1].Redirects[2].Fingerprint)
+	assert.Equal(t, routingHash.String(), out.Results[1].Routing.Hash)
+
+	assert.Equal(t, "hash not found", out.Results[2].Error)
+	assert.Equal(t, "incorrect hash address", out.Results[3].Error)
+
+	assert.Equal(t, addr4.Hash().String(), out.Results[4].Address.Hash)
+	assert.Nil(t, out.Results[4].Routing)
+
+	// Routing records are only added on request
+	body, _ = json.Marshal(resolveBody{Hashes: []string{addr1.Hash().String()}})
+	req = http.NewRequest("POST", "/address/resolve", string(body), nil)
+	res = PostAddressResolve("", req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	out = &resolveOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Len(t, out.Results, 1)
+	assert.Nil(t, out.Results[0].Routing)
+
+	// Redirect depth is checked per hash
+	MaxRedirectDepth = 2
+	body, _ = json.Marshal(resolveBody{Hashes: []string{addr3.Hash().String(), addr2.Hash().String()}})
+	req = http.NewRequest("POST", "/address/resolve", string(body), nil)
+	res = PostAddressResolve("", req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	out = &resolveOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, "maximum redirection reached", out.Results[0].Error)
+	assert.Equal(t, addr1.Hash().String(), out.Results[1].Address.Hash)
+	MaxRedirectDepth = 10
+
+	// Cyclic redirects
+	updateAddressRecord(*addr1, "../../testdata/key-1.json", routingHash.String(), addr3.Hash().String())
+	body, _ = json.Marshal(resolveBody{Hashes: []string{addr2.Hash().String()}})
+	req = http.NewRequest("POST", "/address/resolve", string(body), nil)
+	res = PostAddressResolve("", req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	out = &resolveOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Equal(t, "cyclic dependency detected", out.Results[0].Error)
+
+	// Too many hashes
+	MaxResolveHashes = 1
+	body, _ = json.Marshal(resolveBody{Hashes: []string{addr1.Hash().String(), addr2.Hash().String()}})
+	req = http.NewRequest("POST", "/address/resolve", string(body), nil)
+	res = PostAddressResolve("", req)
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "too many hashes", "status": "error"}`, res.Body)
+	MaxResolveHashes = 100
+
+	req = http.NewRequest("POST", "/address/resolve", "foobar", nil)
+	res = PostAddressResolve("", req)
+	assert.Equal(t, 400, res.StatusCode)
+}
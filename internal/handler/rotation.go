@@ -0,0 +1,139 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"log"
+	"time"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+)
+
+// KeyRotationDelay is the time a key or routing change on a protected address stays pending. During this time, the
+// previous key is able to cancel the change. Deletions of protected addresses are delayed for the same time.
+var KeyRotationDelay = 72 * time.Hour
+
+// pendingKeyOutput returns the pending key change information, or nil when no key change is pending
+func pendingKeyOutput(pendingKey string, pendingAt time.Time) http.RawJSONOut {
+	if pendingKey == "" {
+		return nil
+	}
+
+	return http.RawJSONOut{
+		"public_key":  pendingKey,
+		"activate_at": pendingAt.Unix(),
+	}
+}
+
+// pendingRoutingOutput returns the pending routing change information, or nil when no routing change is pending
+func pendingRoutingOutput(info *address.ResolveInfoType) http.RawJSONOut {
+	if info.PendingRoutingAt.IsZero() {
+		return nil
+	}
+
+	return http.RawJSONOut{
+		"routing_id":  info.PendingRouting,
+		"redirect":    info.PendingRedirHash,
+		"activate_at": info.PendingRoutingAt.Unix(),
+	}
+}
+
+// fetchAddress fetches the address record from the repository. When the record has a pending key or routing change
+// that has passed its activation time, the change will be activated first.
+func fetchAddress(hash string) (*address.ResolveInfoType, error) {
+	repo := address.GetResolveRepository()
+	info, err := repo.Get(hash)
+	if err != nil {
+		return info, err
+	}
+
+	if info.PendingKey != "" && !timeNow().Before(info.PendingAt) {
+		info, err = activatePendingKey(info)
+		if err != nil {
+			return info, err
+		}
+	}
+
+	if !info.PendingRoutingAt.IsZero() && !timeNow().Before(info.PendingRoutingAt) {
+		info, err = activatePendingRouting(info)
+	}
+
+	return info, err
+}
+
+// hasDueChanges returns true when the record has a pending key or routing change that has passed its activation time
+func hasDueChanges(info *address.ResolveInfoType) bool {
+	if info.PendingKey != "" && !timeNow().Before(info.PendingAt) {
+		return true
+	}
+
+	return !info.PendingRoutingAt.IsZero() && !timeNow().Before(info.PendingRoutingAt)
+}
+
+// activatePendingKey replaces the key of the record with its pending key and returns the record as it is stored now
+func activatePendingKey(info *address.ResolveInfoType) (*address.ResolveInfoType, error) {
+	repo := address.GetResolveRepository()
+	res, err := repo.ActivatePendingKey(info)
+	if err != nil {
+		// Another request could have activated or changed the record in the meantime, so just fetch it again
+		log.Print(err)
+	}
+
+	if res {
+		logMutation(translog.TypeAddress, translog.ActionUpdate, info.Hash, info.PendingKey)
+		recordVersion(translog.TypeAddress, info.Hash)
+		indexFingerprint(translog.TypeAddress, info.Hash, info.PendingKey)
+	}
+
+	return repo.Get(info.Hash)
+}
+
+// activatePendingRouting replaces the routing of the record with its pending routing and returns the record as it is
+// stored now
+func activatePendingRouting(info *address.ResolveInfoType) (*address.ResolveInfoType, error) {
+	pk, err := bmcrypto.NewPubKey(info.PubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := address.GetResolveRepository()
+	res, err := repo.Update(info, info.PendingRouting, pk, info.PendingRedirHash)
+	if err != nil {
+		// Another request could have activated or changed the record in the meantime, so just fetch it again
+		log.Print(err)
+	}
+
+	if res {
+		// Activating the same routing twice is harmless, so a failure here only means it is activated again later
+		err = repo.CancelPendingRouting(info.Hash)
+		if err != nil {
+			log.Print(err)
+		}
+
+		logMutation(translog.TypeAddress, translog.ActionUpdate, info.Hash, info.PubKey)
+		recordVersion(translog.TypeAddress, info.Hash)
+		indexFingerprint(translog.TypeAddress, info.Hash, info.PubKey)
+	}
+
+	return repo.Get(info.Hash)
+}
@@ -28,15 +28,27 @@ import (
 	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
 	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
 	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/proof"
 	"github.com/bitmaelum/key-resolver-go/internal/routing"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+)
+
+var (
+	MinimumProofBitsRouting = 27
 )
 
 type routingUploadBody struct {
 	PublicKey *bmcrypto.PubKey `json:"public_key"`
 	Routing   string           `json:"routing"`
+	Proof     *proof.Proof     `json:"proof"`
+	KeySig    []byte           `json:"key_signature"`
 }
 
-func GetRoutingHash(routingHash hash.Hash, _ http.Request) *http.Response {
+func GetRoutingHash(routingHash hash.Hash, req http.Request) *http.Response {
+	if isVersionLookup(req) {
+		return getVersion(translog.TypeRouting, routingHash.String(), req)
+	}
+
 	repo := routing.GetResolveRepository()
 	info, err := repo.Get(routingHash.String())
 	if err != nil && err != routing.ErrNotFound {
@@ -49,14 +61,18 @@ func GetRoutingHash(routingHash hash.Hash, _ http.Request) *http.Response {
 		return http.CreateError("hash not found", 404)
 	}
 
-	data := http.RawJSONOut{
+	return http.CreateOutput(routingOutput(info), 200)
+}
+
+// routingOutput returns the public representation of a routing record
+func routingOutput(info *routing.ResolveInfoType) http.RawJSONOut {
+	return http.RawJSONOut{
 		"hash":          info.Hash,
 		"routing":       info.Routing,
 		"public_key":    info.PubKey,
+		"proof":         info.Proof,
 		"serial_number": info.Serial,
 	}
-
-	return http.CreateOutput(data, 200)
 }
 
 func PostRoutingHash(routingHash hash.Hash, req http.Request) *http.Response {
@@ -88,8 +104,16 @@ func PostRoutingHash(routingHash hash.Hash, req http.Request) *http.Response {
 }
 
 func updateRouting(uploadBody routingUploadBody, req http.Request, current *routing.ResolveInfoType) *http.Response {
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
 	if !req.ValidateAuthenticationToken(current.PubKey, current.Hash+strconv.FormatUint(current.Serial, 10)) {
-		return http.CreateError("unauthenticated", 401)
+		return failedAuth(translog.TypeRouting, current.Hash, "unauthenticated", req)
+	}
+
+	if !validateKeyPossession(uploadBody.PublicKey, current.Hash, current.Serial, uploadBody.KeySig) {
+		return http.CreateError("proof of key possession failed", 400)
 	}
 
 	repo := routing.GetResolveRepository()
@@ -100,19 +124,37 @@ func updateRouting(uploadBody routingUploadBody, req http.Request, current *rout
 		return http.CreateError("error while updating: ", 500)
 	}
 
-	return http.CreateMessage("routing has been updated", 200)
+	index, logged := logMutation(translog.TypeRouting, translog.ActionUpdate, current.Hash, uploadBody.PublicKey.String())
+	recordVersion(translog.TypeRouting, current.Hash)
+	indexFingerprint(translog.TypeRouting, current.Hash, uploadBody.PublicKey.String())
+
+	return receiptMessage("routing has been updated", 200, routingReceipt(current.Hash, index, logged))
 }
 
 func createRouting(routingHash hash.Hash, uploadBody routingUploadBody) *http.Response {
+	// Validate proof of work
+	if httpErr := validateProof(uploadBody.Proof, translog.TypeRouting, routingHash.String()); httpErr != nil {
+		return httpErr
+	}
+
+	if !validateKeyPossession(uploadBody.PublicKey, routingHash.String(), 0, uploadBody.KeySig) {
+		return http.CreateError("proof of key possession failed", 400)
+	}
+
 	repo := routing.GetResolveRepository()
-	res, err := repo.Create(routingHash.String(), uploadBody.Routing, uploadBody.PublicKey.String())
+	res, err := repo.Create(routingHash.String(), uploadBody.Routing, uploadBody.PublicKey.String(), uploadBody.Proof.String())
 
 	if err != nil || !res {
 		log.Print(err)
 		return http.CreateError("error while creating: ", 500)
 	}
 
-	return http.CreateMessage("routing has been created", 201)
+	index, logged := logMutation(translog.TypeRouting, translog.ActionCreate, routingHash.String(), uploadBody.PublicKey.String())
+	recordVersion(translog.TypeRouting, routingHash.String())
+	indexFingerprint(translog.TypeRouting, routingHash.String(), uploadBody.PublicKey.String())
+	recordCreation(translog.TypeRouting)
+
+	return receiptMessage("routing has been created", 201, routingReceipt(routingHash.String(), index, logged))
 }
 
 func DeleteRoutingHash(routingHash hash.Hash, req http.Request) *http.Response {
@@ -127,8 +169,12 @@ func DeleteRoutingHash(routingHash hash.Hash, req http.Request) *http.Response {
 		return http.CreateError("cannot find record", 404)
 	}
 
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
 	if !req.ValidateAuthenticationToken(current.PubKey, current.Hash+strconv.FormatUint(current.Serial, 10)) {
-		return http.CreateError("unauthenticated", 401)
+		return failedAuth(translog.TypeRouting, current.Hash, "unauthenticated", req)
 	}
 
 	res, err := repo.Delete(current.Hash)
@@ -137,6 +183,8 @@ func DeleteRoutingHash(routingHash hash.Hash, req http.Request) *http.Response {
 		return http.CreateError("error while deleting record", 500)
 	}
 
+	logMutation(translog.TypeRouting, translog.ActionDelete, current.Hash, "")
+
 	return http.CreateMessage("routing has been deleted", 200)
 }
 
@@ -0,0 +1,161 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+)
+
+const (
+	// SignatureHeader holds the base64 encoded signature of the response
+	SignatureHeader = "X-Resolver-Signature"
+	// SignatureTimestampHeader holds the unix time at which the response was signed
+	SignatureTimestampHeader = "X-Resolver-Timestamp"
+)
+
+// SigningKey is the private key of the resolver operator. It is used to sign the tree heads of the transparency log
+// and the responses of the resolver.
+var SigningKey *bmcrypto.PrivKey
+
+// SigningPublicKey returns the public key of the resolver operator, or nil when no signing key is configured
+func SigningPublicKey() (*bmcrypto.PubKey, error) {
+	if SigningKey == nil {
+		return nil, nil
+	}
+
+	signer, ok := SigningKey.K.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("signing key has no public key")
+	}
+
+	return bmcrypto.NewPubKeyFromInterface(signer.Public())
+}
+
+// SignedResponse wraps a handler so its response carries a detached signature of the resolver operator. The
+// signature is made over the timestamp, the request and the canonical JSON encoding of the body, so clients can store
+// the body together with the signature and verify it later on, no matter how the JSON was formatted in transit. As the
+// request is signed as well, the response cannot be replayed as the answer to a request for another record.
+func SignedResponse(f func(hash.Hash, http.Request) *http.Response) func(hash.Hash, http.Request) *http.Response {
+	return func(h hash.Hash, req http.Request) *http.Response {
+		resp := f(h, req)
+		if resp == nil || SigningKey == nil {
+			return resp
+		}
+
+		headers, err := signatureHeaders(req.Method, requestTarget(req), resp.Body)
+		if err != nil {
+			log.Print(err)
+			return resp
+		}
+
+		for k, v := range headers {
+			resp.Headers.Set(k, v)
+		}
+
+		return resp
+	}
+}
+
+// signatureHeaders returns the headers with the signature of the resolver operator over the request and the JSON body
+func signatureHeaders(method, target, body string) (map[string]string, error) {
+	ts := timeNow().Unix()
+	digest, err := responseDigest(method, target, body, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := bmcrypto.Sign(*SigningKey, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		SignatureHeader:          base64.StdEncoding.EncodeToString(sig),
+		SignatureTimestampHeader: strconv.FormatInt(ts, 10),
+	}, nil
+}
+
+// responseDigest returns the hash over the timestamp, the method and target of the request, and the canonical JSON
+// encoding of the body that is signed
+func responseDigest(method, target, body string, ts int64) ([]byte, error) {
+	canonical, err := canonicalJSON([]byte(body))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strconv.FormatInt(ts, 10) + "\n" + method + " " + target + "\n"
+	digest := sha256.Sum256(append([]byte(prefix), canonical...))
+	return digest[:], nil
+}
+
+// requestTarget returns the path of the request, followed by the query parameters sorted by key. The path is taken
+// without the original query string, as not every frontend passes it along in the URL.
+func requestTarget(req http.Request) string {
+	target := req.URL
+	if i := strings.IndexByte(target, '?'); i >= 0 {
+		target = target[:i]
+	}
+
+	if len(req.Query) == 0 {
+		return target
+	}
+
+	q := url.Values{}
+	for k, v := range req.Query {
+		q.Set(k, v)
+	}
+
+	return target + "?" + q.Encode()
+}
+
+// canonicalJSON returns the compact JSON encoding of the data with all object keys sorted
+func canonicalJSON(data []byte) ([]byte, error) {
+	var v interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	err = enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningPublicKey(t *testing.T) {
+	SigningKey = nil
+	pubKey, err := SigningPublicKey()
+	assert.NoError(t, err)
+	assert.Nil(t, pubKey)
+
+	for _, p := range []string{"../../testdata/key-1.json", "../../testdata/key-7.json"} {
+		privKey, expected, _ := testing2.ReadTestKey(p)
+		SigningKey = privKey
+
+		pubKey, err = SigningPublicKey()
+		assert.NoError(t, err)
+		assert.Equal(t, expected.String(), pubKey.String())
+	}
+
+	SigningKey = nil
+}
+
+func TestSignedResponse(t *testing.T) {
+	setupRepo()
+
+	routingHash := hash.New("routing1")
+	res := insertRoutingRecord(routingHash, "../../testdata/key-1.json", "127.0.0.1")
+	assert.Equal(t, 201, res.StatusCode)
+
+	// Without a signing key, responses are not signed
+	SigningKey = nil
+	req := http.NewRequest("GET", "/", "", nil)
+	res = SignedResponse(GetRoutingHash)(routingHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.False(t, res.Headers.Has(SignatureHeader))
+
+	privKey, pubKey, _ := testing2.ReadTestKey("../../testdata/key-7.json")
+	SigningKey = privKey
+	defer func() {
+		SigningKey = nil
+	}()
+
+	res = SignedResponse(GetRoutingHash)(routingHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "1270643696", res.Headers.Get(SignatureTimestampHeader))
+
+	sig, err := base64.StdEncoding.DecodeString(res.Headers.Get(SignatureHeader))
+	assert.NoError(t, err)
+
+	// The signature does not depend on the formatting of the body
+	compact, _ := canonicalJSON([]byte(res.Body))
+	assert.NotEqual(t, res.Body, string(compact))
+	digest, err := responseDigest("GET", "/", string(compact), 1270643696)
+	assert.NoError(t, err)
+	ok, err := bmcrypto.Verify(*pubKey, digest, sig)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// A tampered body or timestamp does not verify
+	digest, _ = responseDigest("GET", "/", res.Body, 1270643697)
+	ok, _ = bmcrypto.Verify(*pubKey, digest, sig)
+	assert.False(t, ok)
+
+	digest, _ = responseDigest("GET", "/", `{"routing": "10.0.0.1"}`, 1270643696)
+	ok, _ = bmcrypto.Verify(*pubKey, digest, sig)
+	assert.False(t, ok)
+
+	// The response cannot be replayed for another request
+	digest, _ = responseDigest("GET", "/routing/"+hash.New("other").String(), res.Body, 1270643696)
+	ok, _ = bmcrypto.Verify(*pubKey, digest, sig)
+	assert.False(t, ok)
+
+	// The query is part of the signed request
+	req = http.NewRequest("GET", "/routing/"+routingHash.String()+"?version=1", "", nil)
+	req.Query["version"] = "1"
+	res = SignedResponse(GetRoutingHash)(routingHash, req)
+	sig, _ = base64.StdEncoding.DecodeString(res.Headers.Get(SignatureHeader))
+
+	digest, _ = responseDigest("GET", "/routing/"+routingHash.String()+"?version=1", res.Body, 1270643696)
+	ok, _ = bmcrypto.Verify(*pubKey, digest, sig)
+	assert.True(t, ok)
+
+	digest, _ = responseDigest("GET", "/routing/"+routingHash.String(), res.Body, 1270643696)
+	ok, _ = bmcrypto.Verify(*pubKey, digest, sig)
+	assert.False(t, ok)
+
+	// Errors are signed as well, so a "not found" cannot be forged
+	res = SignedResponse(GetRoutingHash)(hash.New("unknown"), req)
+	assert.Equal(t, 404, res.StatusCode)
+	assert.True(t, res.Headers.Has(SignatureHeader))
+}
+
+func TestRequestTarget(t *testing.T) {
+	req := http.NewRequest("GET", "/address/abc?b=2&a=1", "", nil)
+	req.Query["b"] = "2"
+	req.Query["a"] = "1"
+	assert.Equal(t, "/address/abc?a=1&b=2", requestTarget(req))
+
+	// API gateway passes the query separately from the path
+	req = http.NewRequest("GET", "/address/abc", "", nil)
+	req.Query["a"] = "x y"
+	assert.Equal(t, "/address/abc?a=x+y", requestTarget(req))
+
+	req = http.NewRequest("GET", "/address/abc", "", nil)
+	assert.Equal(t, "/address/abc", requestTarget(req))
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	data, err := canonicalJSON([]byte("{\n  \"b\": 1.50,\n  \"a\": \"<x>\",\n  \"c\": [ 2, { \"z\": true, \"y\": null } ]\n}"))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":"<x>","b":1.50,"c":[2,{"y":null,"z":true}]}`, string(data))
+
+	_, err = canonicalJSON([]byte("not json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+)
+
+// MaxLogEntries is the maximum number of log entries that can be fetched in a single request
+var MaxLogEntries uint64 = 1000
+
+// appendLog adds the entry to the transparency log and returns the index of the entry. The mutation itself has
+// already been done at this point, so a failure to log is reported but will not fail the request.
+func appendLog(entry translog.Entry) (uint64, bool) {
+	entry.Timestamp = timeNow().Unix()
+
+	index, err := translog.AppendEntry(translog.GetRepository(), entry)
+	if err != nil {
+		log.Print(err)
+		return 0, false
+	}
+
+	return index, true
+}
+
+// logMutation logs a change of a record in the transparency log, together with the public key the record holds after
+// the change
+func logMutation(typ, action, h, pubKey string) (uint64, bool) {
+	entry := translog.Entry{
+		Type:      typ,
+		Action:    action,
+		Hash:      h,
+		PublicKey: pubKey,
+	}
+
+	if pubKey != "" {
+		pk, err := bmcrypto.NewPubKey(pubKey)
+		if err == nil {
+			entry.Fingerprint = pk.Fingerprint()
+		}
+	}
+
+	return appendLog(entry)
+}
+
+// logKeyStatus logs a change of the status of a key of an address
+func logKeyStatus(h, fingerprint string, ks address.KeyStatus) (uint64, bool) {
+	return appendLog(translog.Entry{
+		Type:        translog.TypeAddress,
+		Action:      translog.ActionKeyStatus,
+		Hash:        h,
+		Fingerprint: fingerprint,
+		KeyStatus:   ks.ToString(),
+	})
+}
+
+func GetLogTreeHead(_ hash.Hash, _ http.Request) *http.Response {
+	if SigningKey == nil {
+		return http.CreateError("resolver signing key not configured", 500)
+	}
+
+	sth, err := translog.GetTreeHead(translog.GetRepository(), timeNow())
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching tree head", 500)
+	}
+
+	err = sth.Sign(*SigningKey)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while signing tree head", 500)
+	}
+
+	return http.CreateOutput(sth, 200)
+}
+
+func GetLogEntries(_ hash.Hash, req http.Request) *http.Response {
+	start, err1 := strconv.ParseUint(req.Query["start"], 10, 64)
+	end, err2 := strconv.ParseUint(req.Query["end"], 10, 64)
+	if err1 != nil || err2 != nil || start > end {
+		return http.CreateError("invalid range", 400)
+	}
+
+	repo := translog.GetRepository()
+	size, err := repo.Size()
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching entries", 500)
+	}
+
+	if start >= size {
+		return http.CreateError("invalid range", 400)
+	}
+
+	// Return less entries than requested when needed, just like certificate transparency logs do
+	if end >= size {
+		end = size - 1
+	}
+	if end-start >= MaxLogEntries {
+		end = start + MaxLogEntries - 1
+	}
+
+	data, err := repo.Get(start, end)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching entries", 500)
+	}
+
+	var entries []http.RawJSONOut
+	for i := range data {
+		entry, err := translog.DecodeEntry(data[i])
+		if err != nil {
+			log.Print(err)
+			return http.CreateError("error while fetching entries", 500)
+		}
+
+		entries = append(entries, http.RawJSONOut{
+			"index":      start + uint64(i),
+			"leaf_input": data[i],
+			"entry":      entry,
+		})
+	}
+
+	return http.CreateOutput(http.RawJSONOut{"entries": entries}, 200)
+}
+
+func GetLogInclusionProof(_ hash.Hash, req http.Request) *http.Response {
+	index, err1 := strconv.ParseUint(req.Query["index"], 10, 64)
+	size, err2 := strconv.ParseUint(req.Query["tree_size"], 10, 64)
+	if err1 != nil || err2 != nil {
+		return http.CreateError("invalid range", 400)
+	}
+
+	proof, err := translog.GetInclusionProof(translog.GetRepository(), index, size)
+	if err == translog.ErrInvalidRange {
+		return http.CreateError("invalid range", 400)
+	}
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching proof", 500)
+	}
+
+	return http.CreateOutput(http.RawJSONOut{
+		"leaf_index": index,
+		"tree_size":  size,
+		"audit_path": proof,
+	}, 200)
+}
+
+func GetLogConsistencyProof(_ hash.Hash, req http.Request) *http.Response {
+	first, err1 := strconv.ParseUint(req.Query["first"], 10, 64)
+	second, err2 := strconv.ParseUint(req.Query["second"], 10, 64)
+	if err1 != nil || err2 != nil {
+		return http.CreateError("invalid range", 400)
+	}
+
+	proof, err := translog.GetConsistencyProof(translog.GetRepository(), first, second)
+	if err == translog.ErrInvalidRange {
+		return http.CreateError("invalid range", 400)
+	}
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching proof", 500)
+	}
+
+	return http.CreateOutput(http.RawJSONOut{
+		"first":       first,
+		"second":      second,
+		"consistency": proof,
+	}, 200)
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransparencyLog(t *testing.T) {
+	setupRepo()
+
+	// No signing key configured
+	SigningKey = nil
+	req := http.NewRequest("GET", "/log/sth", "", nil)
+	res := GetLogTreeHead("", req)
+	assert.Equal(t, 500, res.StatusCode)
+
+	privKey, pubKey, _ := testing2.ReadTestKey("../../testdata/key-7.json")
+	SigningKey = privKey
+	defer func() {
+		SigningKey = nil
+	}()
+
+	routingHash1 := hash.New("routing1")
+	routingHash2 := hash.New("routing2")
+	res = insertRoutingRecord(routingHash1, "../../testdata/key-1.json", "127.0.0.1")
+	assert.Equal(t, 201, res.StatusCode)
+	res = insertRoutingRecord(routingHash2, "../../testdata/key-2.json", "127.0.0.2")
+	assert.Equal(t, 201, res.StatusCode)
+
+	// Fetch first tree head
+	res = GetLogTreeHead("", req)
+	assert.Equal(t, 200, res.StatusCode)
+	sth1 := &translog.SignedTreeHead{}
+	_ = json.Unmarshal([]byte(res.Body), sth1)
+	assert.Equal(t, uint64(2), sth1.TreeSize)
+	assert.True(t, sth1.Verify(*pubKey))
+
+	// Delete a record
+	privKey1, _, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	req = http.NewRequest("GET", "/", "", nil)
+	res = GetRoutingHash(routingHash1, req)
+	current := getRoutingRecord(res)
+	req = http.NewRequest("DELETE", "/", "", nil)
+	req.Headers.Set("Authorization", "Bearer "+http.GenerateAuthenticationToken([]byte(current.Hash+"1270643696000000000"), *privKey1))
+	res = DeleteRoutingHash(routingHash1, req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	req = http.NewRequest("GET", "/log/sth", "", nil)
+	res = GetLogTreeHead("", req)
+	sth2 := &translog.SignedTreeHead{}
+	_ = json.Unmarshal([]byte(res.Body), sth2)
+	assert.Equal(t, uint64(3), sth2.TreeSize)
+	assert.True(t, sth2.Verify(*pubKey))
+
+	// Fetch entries
+	req = http.NewRequest("GET", "/log/entries", "", nil)
+	req.Query["start"] = "0"
+	req.Query["end"] = "100"
+	res = GetLogEntries("", req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	type entriesType struct {
+		Entries []struct {
+			Index     uint64         `json:"index"`
+			LeafInput []byte         `json:"leaf_input"`
+			Entry     translog.Entry `json:"entry"`
+		} `json:"entries"`
+	}
+	entries := &entriesType{}
+	_ = json.Unmarshal([]byte(res.Body), entries)
+	assert.Len(t, entries.Entries, 3)
+	assert.Equal(t, translog.ActionCreate, entries.Entries[0].Entry.Action)
+	assert.Equal(t, translog.TypeRouting, entries.Entries[0].Entry.Type)
+	assert.Equal(t, routingHash1.String(), entries.Entries[0].Entry.Hash)
+	assert.NotEmpty(t, entries.Entries[0].Entry.Fingerprint)
+	assert.Equal(t, translog.ActionDelete, entries.Entries[2].Entry.Action)
+	assert.Equal(t, uint64(2), entries.Entries[2].Index)
+
+	req.Query["start"] = "3"
+	res = GetLogEntries("", req)
+	assert.Equal(t, 400, res.StatusCode)
+
+	// Inclusion proof of the deletion in the second tree
+	req = http.NewRequest("GET", "/log/proof/inclusion", "", nil)
+	req.Query["index"] = "2"
+	req.Query["tree_size"] = "3"
+	res = GetLogInclusionProof("", req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	proof := &struct {
+		AuditPath [][]byte `json:"audit_path"`
+	}{}
+	_ = json.Unmarshal([]byte(res.Body), proof)
+	leaf := translog.LeafHash(entries.Entries[2].LeafInput)
+	assert.True(t, translog.VerifyInclusion(2, 3, leaf, proof.AuditPath, sth2.RootHash))
+
+	req.Query["index"] = "3"
+	res = GetLogInclusionProof("", req)
+	assert.Equal(t, 400, res.StatusCode)
+
+	// The second tree is an extension of the first tree
+	req = http.NewRequest("GET", "/log/proof/consistency", "", nil)
+	req.Query["first"] = "2"
+	req.Query["second"] = "3"
+	res = GetLogConsistencyProof("", req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	consistency := &struct {
+		Consistency [][]byte `json:"consistency"`
+	}{}
+	_ = json.Unmarshal([]byte(res.Body), consistency)
+	assert.True(t, translog.VerifyConsistency(2, 3, sth1.RootHash, sth2.RootHash, consistency.Consistency))
+
+	req.Query["first"] = "4"
+	res = GetLogConsistencyProof("", req)
+	assert.Equal(t, 400, res.StatusCode)
+}
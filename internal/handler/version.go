@@ -0,0 +1,221 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/organisation"
+	"github.com/bitmaelum/key-resolver-go/internal/routing"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+	"github.com/bitmaelum/key-resolver-go/internal/version"
+)
+
+// VersionRetention is the time a version of a record is kept after it has been superseded by a newer version. When
+// set to 0, all versions are kept forever.
+var VersionRetention time.Duration
+
+// recordVersion stores the record as it is currently stored as a new version. Like the transparency log, a failure to
+// store the version is reported but will not fail the request.
+func recordVersion(typ, h string) {
+	serial, data := currentRecord(typ, h)
+	if data == nil {
+		return
+	}
+
+	record, err := json.Marshal(data)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	repo := version.GetRepository()
+	err = repo.Add(version.Version{
+		Type:      typ,
+		Hash:      h,
+		Serial:    serial,
+		Timestamp: timeNow().Unix(),
+		Record:    record,
+	})
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	pruneVersions(repo, typ, h)
+}
+
+// pruneVersions removes the versions of the record that are superseded for longer than the retention period
+func pruneVersions(repo version.Repository, typ, h string) {
+	if VersionRetention == 0 {
+		return
+	}
+
+	versions, err := repo.List(typ, h)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	expired := version.Expired(versions, VersionRetention, timeNow())
+	if len(expired) == 0 {
+		return
+	}
+
+	err = repo.Remove(typ, h, expired)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// currentRecord returns the serial and public representation of the record as it is currently stored, or nil when
+// the record does not exist
+func currentRecord(typ, h string) (uint64, http.RawJSONOut) {
+	switch typ {
+	case translog.TypeAddress:
+		info, err := address.GetResolveRepository().Get(h)
+		if err == nil && info != nil {
+			return info.Serial, addressOutput(info)
+		}
+	case translog.TypeOrganisation:
+		info, err := organisation.GetResolveRepository().Get(h)
+		if err == nil && info != nil {
+			return info.Serial, organisationOutput(info)
+		}
+	case translog.TypeRouting:
+		info, err := routing.GetResolveRepository().Get(h)
+		if err == nil && info != nil {
+			return info.Serial, routingOutput(info)
+		}
+	}
+
+	return 0, nil
+}
+
+func GetAddressVersions(addrHash hash.Hash, _ http.Request) *http.Response {
+	return getVersions(translog.TypeAddress, addrHash.String())
+}
+
+func GetOrganisationVersions(orgHash hash.Hash, _ http.Request) *http.Response {
+	return getVersions(translog.TypeOrganisation, orgHash.String())
+}
+
+func GetRoutingVersions(routingHash hash.Hash, _ http.Request) *http.Response {
+	return getVersions(translog.TypeRouting, routingHash.String())
+}
+
+func getVersions(typ, h string) *http.Response {
+	versions, err := version.GetRepository().List(typ, h)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching versions", 500)
+	}
+
+	if len(versions) == 0 {
+		return http.CreateError("no versions found", 404)
+	}
+
+	output := make([]http.RawJSONOut, 0, len(versions))
+	for i := range versions {
+		output = append(output, http.RawJSONOut{
+			"serial_number": versions[i].Serial,
+			"validity":      validityOutput(versions, i),
+			"record":        versions[i].Record,
+		})
+	}
+
+	return http.CreateOutput(http.RawJSONOut{
+		"hash":     h,
+		"versions": output,
+	}, 200)
+}
+
+// isVersionLookup returns true when the request asks for a prior version of the record instead of the current one
+func isVersionLookup(req http.Request) bool {
+	return req.Query["serial"] != "" || req.Query["at"] != ""
+}
+
+// getVersion returns the version of the record with the serial, or the version that was current at the timestamp,
+// as given in the query of the request
+func getVersion(typ, h string, req http.Request) *http.Response {
+	if req.Query["serial"] != "" && req.Query["at"] != "" {
+		return http.CreateError("serial and at cannot be combined", 400)
+	}
+
+	versions, err := version.GetRepository().List(typ, h)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching versions", 500)
+	}
+
+	var found *version.Version
+	if req.Query["serial"] != "" {
+		serial, err := strconv.ParseUint(req.Query["serial"], 10, 64)
+		if err != nil {
+			return http.CreateError("invalid serial", 400)
+		}
+		found = version.FindSerial(versions, serial)
+	} else {
+		at, err := strconv.ParseInt(req.Query["at"], 10, 64)
+		if err != nil {
+			return http.CreateError("invalid at", 400)
+		}
+		found = version.FindAt(versions, at)
+	}
+
+	if found == nil {
+		return http.CreateError("version not found", 404)
+	}
+
+	data := http.RawJSONOut{}
+	err = json.Unmarshal(found.Record, &data)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching versions", 500)
+	}
+
+	for i := range versions {
+		if versions[i].Serial == found.Serial {
+			data["validity"] = validityOutput(versions, i)
+		}
+	}
+
+	return http.CreateOutput(data, 200)
+}
+
+// validityOutput returns the period in which the version at index i was the current version of the record
+func validityOutput(versions []version.Version, i int) http.RawJSONOut {
+	validity := http.RawJSONOut{
+		"from": versions[i].Timestamp,
+	}
+
+	// The latest version is still valid
+	if i+1 < len(versions) {
+		validity["until"] = versions[i+1].Timestamp
+	}
+
+	return validity
+}
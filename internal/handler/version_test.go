@@ -0,0 +1,268 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	pkgAddress "github.com/bitmaelum/bitmaelum-suite/pkg/address"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/routing"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/bitmaelum/key-resolver-go/internal/version"
+	"github.com/stretchr/testify/assert"
+)
+
+const versionRoutingHash = "0CD8666848BF286D951C3D230E8B6E092FDE03C3A080E3454467E496E7B14E78"
+
+type versionsOutput struct {
+	Hash     string `json:"hash"`
+	Versions []struct {
+		SerialNumber uint64 `json:"serial_number"`
+		Validity     struct {
+			From  int64 `json:"from"`
+			Until int64 `json:"until"`
+		} `json:"validity"`
+		Record routingInfoType `json:"record"`
+	} `json:"versions"`
+}
+
+func TestRoutingVersions(t *testing.T) {
+	setupRepo()
+	defer func() {
+		VersionRetention = 0
+	}()
+
+	t1 := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+	t2 := time.Date(2010, 05, 07, 12, 34, 56, 0, time.UTC)
+	t3 := time.Date(2010, 06, 07, 12, 34, 56, 0, time.UTC)
+
+	req := http.NewRequest("GET", "/", "", nil)
+	res := GetRoutingVersions(versionRoutingHash, req)
+	assert.Equal(t, 404, res.StatusCode)
+
+	res = insertRoutingRecord(versionRoutingHash, "../../testdata/key-1.json", "127.0.0.1")
+	assert.Equal(t, 201, res.StatusCode)
+
+	setRepoTime(t2)
+	res = updateVersionRoutingRecord("192.168.1.5")
+	assert.Equal(t, 200, res.StatusCode)
+
+	res = GetRoutingVersions(versionRoutingHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+	out := &versionsOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Len(t, out.Versions, 2)
+	assert.Equal(t, uint64(t1.UnixNano()), out.Versions[0].SerialNumber)
+	assert.Equal(t, t1.Unix(), out.Versions[0].Validity.From)
+	assert.Equal(t, t2.Unix(), out.Versions[0].Validity.Until)
+	assert.Equal(t, "127.0.0.1", out.Versions[0].Record.Routing)
+	assert.Equal(t, t2.Unix(), out.Versions[1].Validity.From)
+	assert.Equal(t, int64(0), out.Versions[1].Validity.Until)
+	assert.Equal(t, "192.168.1.5", out.Versions[1].Record.Routing)
+
+	// Point-in-time lookups
+	req = http.NewRequest("GET", "/", "", nil)
+	req.Query["serial"] = strconv.FormatInt(t1.UnixNano(), 10)
+	res = GetRoutingHash(versionRoutingHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "127.0.0.1", getRoutingRecord(res).Routing)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	req.Query["at"] = strconv.FormatInt(t2.Unix()-1, 10)
+	res = GetRoutingHash(versionRoutingHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "127.0.0.1", getRoutingRecord(res).Routing)
+
+	req.Query["at"] = strconv.FormatInt(t2.Unix(), 10)
+	res = GetRoutingHash(versionRoutingHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "192.168.1.5", getRoutingRecord(res).Routing)
+
+	req.Query["at"] = strconv.FormatInt(t1.Unix()-1, 10)
+	res = GetRoutingHash(versionRoutingHash, req)
+	assert.Equal(t, 404, res.StatusCode)
+	assert.JSONEq(t, `{"message": "version not found", "status": "error"}`, res.Body)
+
+	req.Query["at"] = "yesterday"
+	res = GetRoutingHash(versionRoutingHash, req)
+	assert.Equal(t, 400, res.StatusCode)
+
+	req.Query["at"] = "1"
+	req.Query["serial"] = "1"
+	res = GetRoutingHash(versionRoutingHash, req)
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "serial and at cannot be combined", "status": "error"}`, res.Body)
+
+	// Versions superseded before the retention period are removed
+	VersionRetention = 24 * time.Hour
+	setRepoTime(t3)
+	res = updateVersionRoutingRecord("10.0.0.1")
+	assert.Equal(t, 200, res.StatusCode)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	res = GetRoutingVersions(versionRoutingHash, req)
+	out = &versionsOutput{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	assert.Len(t, out.Versions, 2)
+	assert.Equal(t, "192.168.1.5", out.Versions[0].Record.Routing)
+	assert.Equal(t, "10.0.0.1", out.Versions[1].Record.Routing)
+}
+
+func TestAddressVersions(t *testing.T) {
+	setupRepo()
+
+	addr, _ := pkgAddress.NewAddress("example!")
+	pow := proofofwork.New(22, addr.Hash().String(), 1540921)
+
+	res := insertAddressRecord(*addr, "../../testdata/key-1.json", fakeRoutingId.String(), pow, "")
+	assert.Equal(t, 201, res.StatusCode)
+
+	req := http.NewRequest("GET", "/", "", nil)
+	res = GetAddressVersions(addr.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	req.Query["serial"] = "1270643696000000000"
+	res = GetAddressHash(addr.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	info := getAddressRecord(res)
+	assert.Equal(t, addr.Hash().String(), info.Hash)
+	assert.Equal(t, fakeRoutingId.String(), info.RoutingID)
+
+	req.Query["include"] = "routing"
+	res = GetAddressHash(addr.Hash(), req)
+	assert.Equal(t, 400, res.StatusCode)
+	assert.JSONEq(t, `{"message": "include cannot be combined with serial or at", "status": "error"}`, res.Body)
+}
+
+func TestAddressVersionsBolt(t *testing.T) {
+	setupRepo()
+	defer setupRepo()
+
+	p := fmt.Sprintf("/tmp/mockboltdb-%d.db", rand.Int63())
+	_ = os.Setenv("BOLT_DB_FILE", p)
+	defer func() {
+		_ = os.Remove(p)
+	}()
+	address.SetDefaultRepository(address.NewBoltResolver())
+	version.SetDefaultRepository(version.NewBoltRepository())
+
+	t1 := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+	t2 := time.Date(2010, 05, 07, 12, 34, 56, 0, time.UTC)
+
+	addr, _ := pkgAddress.NewAddress("example!")
+	pow := proofofwork.New(22, addr.Hash().String(), 1540921)
+	res := insertAddressRecord(*addr, "../../testdata/key-1.json", fakeRoutingId.String(), pow, "")
+	assert.Equal(t, 201, res.StatusCode)
+
+	// Update the routing through the handler, so a new version is stored
+	timeNow = func() time.Time {
+		return t2
+	}
+	current, _ := address.GetResolveRepository().Get(addr.Hash().String())
+	privKey, pubKey, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	newRouting := hash.New("other routing").String()
+	body := addressUploadBody{
+		UserHash:  addr.LocalHash(),
+		OrgHash:   addr.OrgHash(),
+		PublicKey: pubKey,
+		RoutingID: newRouting,
+		KeySig:    GenerateKeyPossessionSignature(current.Hash, current.Serial, *privKey),
+	}
+	sig := current.Hash + current.RoutingID + strconv.FormatUint(current.Serial, 10)
+	req := http.NewRequest("POST", "/", "", nil)
+	req.Headers.Set("authorization", "BEARER "+http.GenerateAuthenticationToken([]byte(sig), *privKey))
+	res = updateAddress(body, req, current)
+	assert.Equal(t, 200, res.StatusCode)
+
+	// Both versions are kept, each with their own serial
+	req = http.NewRequest("GET", "/", "", nil)
+	res = GetAddressVersions(addr.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	out := &struct {
+		Versions []struct {
+			SerialNumber uint64          `json:"serial_number"`
+			Record       addressInfoType `json:"record"`
+		} `json:"versions"`
+	}{}
+	_ = json.Unmarshal([]byte(res.Body), out)
+	if !assert.Len(t, out.Versions, 2) {
+		return
+	}
+	assert.Equal(t, current.Serial, out.Versions[0].SerialNumber)
+	assert.Equal(t, fakeRoutingId.String(), out.Versions[0].Record.Routing)
+	assert.NotEqual(t, current.Serial, out.Versions[1].SerialNumber)
+	assert.Equal(t, newRouting, out.Versions[1].Record.Routing)
+
+	// Point-in-time lookups
+	req = http.NewRequest("GET", "/", "", nil)
+	req.Query["serial"] = strconv.FormatUint(current.Serial, 10)
+	res = GetAddressHash(addr.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, fakeRoutingId.String(), getAddressRecord(res).RoutingID)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	req.Query["serial"] = strconv.FormatUint(out.Versions[1].SerialNumber, 10)
+	res = GetAddressHash(addr.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, newRouting, getAddressRecord(res).RoutingID)
+
+	req = http.NewRequest("GET", "/", "", nil)
+	req.Query["at"] = strconv.FormatInt(t2.Unix()-1, 10)
+	res = GetAddressHash(addr.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, fakeRoutingId.String(), getAddressRecord(res).RoutingID)
+
+	req.Query["at"] = strconv.FormatInt(t2.Unix(), 10)
+	res = GetAddressHash(addr.Hash(), req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, newRouting, getAddressRecord(res).RoutingID)
+
+	req.Query["at"] = strconv.FormatInt(t1.Unix()-1, 10)
+	res = GetAddressHash(addr.Hash(), req)
+	assert.Equal(t, 404, res.StatusCode)
+}
+
+// updateVersionRoutingRecord updates the routing of the record that is used in the version tests
+func updateVersionRoutingRecord(r string) *http.Response {
+	current, _ := routing.GetResolveRepository().Get(versionRoutingHash)
+	privKey, pubKey, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+
+	body := routingUploadBody{
+		PublicKey: pubKey,
+		Routing:   r,
+		KeySig:    GenerateKeyPossessionSignature(current.Hash, current.Serial, *privKey),
+	}
+
+	sig := current.Hash + strconv.FormatUint(current.Serial, 10)
+	req := http.NewRequest("POST", "/", "", nil)
+	req.Headers.Set("authorization", "BEARER "+http.GenerateAuthenticationToken([]byte(sig), *privKey))
+
+	return updateRouting(body, req, current)
+}
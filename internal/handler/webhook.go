@@ -0,0 +1,279 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/address"
+	"github.com/bitmaelum/key-resolver-go/internal/changefeed"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	"github.com/bitmaelum/key-resolver-go/internal/organisation"
+	"github.com/bitmaelum/key-resolver-go/internal/routing"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+	"github.com/bitmaelum/key-resolver-go/internal/webhook"
+)
+
+// MaxWebhooksPerRecord is the maximum number of webhook subscriptions on a single record
+var MaxWebhooksPerRecord = 10
+
+// isValidWebhookURL checks if events can be delivered to the URL of a new subscription
+var isValidWebhookURL = webhook.IsValidURL
+
+// webhooks delivers the events to the subscribers. No events are delivered when it is not started.
+var webhooks *webhook.Worker
+
+// StartWebhooks starts the worker that delivers the events of record changes to the webhook subscribers. The worker
+// runs in the background, so this is only done by the standalone resolver.
+func StartWebhooks(ctx context.Context) *webhook.Worker {
+	webhooks = webhook.NewWorker(webhook.GetRepository(), signWebhookPayload)
+	go webhooks.Run(ctx)
+
+	changefeed.Notify = notifyWebhooks
+
+	return webhooks
+}
+
+// signWebhookPayload signs the payload in the same way as the resolver signs its responses, as a POST to the target
+func signWebhookPayload(target string, payload []byte) map[string]string {
+	if SigningKey == nil {
+		return nil
+	}
+
+	headers, err := signatureHeaders("POST", target, string(payload))
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+
+	return headers
+}
+
+// notifyWebhooks queues the change for delivery to the subscribers of the record
+func notifyWebhooks(change changefeed.Change) {
+	if webhooks == nil {
+		return
+	}
+
+	err := webhooks.Notify(webhook.Event{
+		Type:      change.Type,
+		Action:    change.Action,
+		Hash:      change.Hash,
+		Serial:    change.Serial,
+		Timestamp: change.Timestamp,
+	})
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+func PostAddressWebhook(addrHash hash.Hash, req http.Request) *http.Response {
+	return postWebhook(translog.TypeAddress, addrHash, req)
+}
+
+func GetAddressWebhooks(addrHash hash.Hash, req http.Request) *http.Response {
+	return getWebhooks(translog.TypeAddress, addrHash, req)
+}
+
+func DeleteAddressWebhook(addrHash hash.Hash, req http.Request) *http.Response {
+	return deleteWebhook(translog.TypeAddress, addrHash, req)
+}
+
+func PostOrganisationWebhook(orgHash hash.Hash, req http.Request) *http.Response {
+	return postWebhook(translog.TypeOrganisation, orgHash, req)
+}
+
+func GetOrganisationWebhooks(orgHash hash.Hash, req http.Request) *http.Response {
+	return getWebhooks(translog.TypeOrganisation, orgHash, req)
+}
+
+func DeleteOrganisationWebhook(orgHash hash.Hash, req http.Request) *http.Response {
+	return deleteWebhook(translog.TypeOrganisation, orgHash, req)
+}
+
+func PostRoutingWebhook(routingHash hash.Hash, req http.Request) *http.Response {
+	return postWebhook(translog.TypeRouting, routingHash, req)
+}
+
+func GetRoutingWebhooks(routingHash hash.Hash, req http.Request) *http.Response {
+	return getWebhooks(translog.TypeRouting, routingHash, req)
+}
+
+func DeleteRoutingWebhook(routingHash hash.Hash, req http.Request) *http.Response {
+	return deleteWebhook(translog.TypeRouting, routingHash, req)
+}
+
+func postWebhook(typ string, h hash.Hash, req http.Request) *http.Response {
+	if httpErr := authenticateRecord(typ, scopeWebhooksPost, h, req); httpErr != nil {
+		return httpErr
+	}
+
+	type webhookRequestBody struct {
+		URL string `json:"url"`
+	}
+
+	body := &webhookRequestBody{}
+	err := json.Unmarshal([]byte(req.Body), body)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("invalid body data", 400)
+	}
+
+	if !isValidWebhookURL(body.URL) {
+		return http.CreateError("invalid url", 400)
+	}
+
+	repo := webhook.GetRepository()
+	subs, err := repo.List(typ, h.String())
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching webhooks", 500)
+	}
+
+	if len(subs) >= MaxWebhooksPerRecord {
+		return http.CreateError("too many webhooks", 400)
+	}
+
+	sub := webhook.Subscription{
+		ID:        webhook.NewID(),
+		Type:      typ,
+		Hash:      h.String(),
+		URL:       body.URL,
+		CreatedAt: timeNow().Unix(),
+	}
+
+	err = repo.Create(sub)
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while creating webhook", 500)
+	}
+
+	return http.CreateOutput(webhookOutput(sub), 201)
+}
+
+func getWebhooks(typ string, h hash.Hash, req http.Request) *http.Response {
+	if httpErr := authenticateRecord(typ, scopeWebhooksGet, h, req); httpErr != nil {
+		return httpErr
+	}
+
+	subs, err := webhook.GetRepository().List(typ, h.String())
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while fetching webhooks", 500)
+	}
+
+	out := []http.RawJSONOut{}
+	for _, sub := range subs {
+		out = append(out, webhookOutput(sub))
+	}
+
+	return http.CreateOutput(http.RawJSONOut{"webhooks": out}, 200)
+}
+
+func deleteWebhook(typ string, h hash.Hash, req http.Request) *http.Response {
+	if httpErr := authenticateRecord(typ, scopeWebhooksDelete, h, req); httpErr != nil {
+		return httpErr
+	}
+
+	err := webhook.GetRepository().Remove(typ, h.String(), req.Params["id"])
+	if err == webhook.ErrNotFound {
+		return http.CreateError("webhook not found", 404)
+	}
+	if err != nil {
+		log.Print(err)
+		return http.CreateError("error while deleting webhook", 500)
+	}
+
+	return http.CreateMessage("webhook has been deleted", 200)
+}
+
+func webhookOutput(sub webhook.Subscription) http.RawJSONOut {
+	return http.RawJSONOut{
+		"id":         sub.ID,
+		"url":        sub.URL,
+		"created_at": sub.CreatedAt,
+	}
+}
+
+// Scopes of the requests that are authenticated by authenticateRecord. The scope is signed together with the regular
+// token data, so a token for one of these requests cannot be used for another request or to update the record itself.
+const (
+	scopeWebhooksGet    = "webhooks:GET"
+	scopeWebhooksPost   = "webhooks:POST"
+	scopeWebhooksDelete = "webhooks:DELETE"
+	scopeLockoutGet     = "lockout:GET"
+	scopeRedirectsGet   = "redirected-by:GET"
+)
+
+// authenticateRecord checks if the request is authenticated by the key of the record for the given scope. The token is
+// made in the same way as for updates of the record, but with the scope in front of the signed data. It returns an
+// error response when the request is not authenticated.
+func authenticateRecord(typ, scope string, h hash.Hash, req http.Request) *http.Response {
+	if httpErr := checkClientLockout(req); httpErr != nil {
+		return httpErr
+	}
+
+	switch typ {
+	case translog.TypeAddress:
+		current, err := fetchAddress(h.String())
+		if err != nil && err != address.ErrNotFound {
+			log.Print(err)
+			return http.CreateError("error while fetching record", 500)
+		}
+		if current == nil || current.Deleted {
+			return http.CreateError("cannot find record", 404)
+		}
+		if !req.ValidateAuthenticationToken(current.PubKey, scope+current.Hash+current.RoutingID+strconv.FormatUint(current.Serial, 10)) {
+			return failedAuth(typ, h.String(), "unauthenticated", req)
+		}
+
+	case translog.TypeOrganisation:
+		current, err := organisation.GetResolveRepository().Get(h.String())
+		if err != nil && err != organisation.ErrNotFound {
+			log.Print(err)
+			return http.CreateError("error while fetching record", 500)
+		}
+		if current == nil || current.Deleted {
+			return http.CreateError("cannot find record", 404)
+		}
+		if !validateOrganisationToken(req, current, scope+current.Hash+strconv.FormatUint(current.Serial, 10)) {
+			return failedAuth(typ, h.String(), "unauthenticated", req)
+		}
+
+	case translog.TypeRouting:
+		current, err := routing.GetResolveRepository().Get(h.String())
+		if err != nil && err != routing.ErrNotFound {
+			log.Print(err)
+			return http.CreateError("error while fetching record", 500)
+		}
+		if current == nil {
+			return http.CreateError("cannot find record", 404)
+		}
+		if !req.ValidateAuthenticationToken(current.PubKey, scope+current.Hash+strconv.FormatUint(current.Serial, 10)) {
+			return failedAuth(typ, h.String(), "unauthenticated", req)
+		}
+	}
+
+	return nil
+}
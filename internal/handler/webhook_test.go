@@ -0,0 +1,179 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	"github.com/bitmaelum/key-resolver-go/internal/http"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/bitmaelum/key-resolver-go/internal/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhooks(t *testing.T) {
+	setupRepo()
+
+	signKey, signPubKey, _ := testing2.ReadTestKey("../../testdata/key-7.json")
+	SigningKey = signKey
+	defer func() {
+		SigningKey = nil
+	}()
+
+	type delivery struct {
+		header nethttp.Header
+		path   string
+		body   []byte
+	}
+	received := make(chan delivery, 10)
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- delivery{header: r.Header, path: r.URL.RequestURI(), body: body}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		webhooks = nil
+		isValidWebhookURL = webhook.IsValidURL
+	}()
+	StartWebhooks(ctx)
+
+	routingHash := hash.New("routing1")
+	res := insertRoutingRecord(routingHash, "../../testdata/key-1.json", "127.0.0.1")
+	assert.Equal(t, 201, res.StatusCode)
+
+	privKey, _, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	tokenFor := func(scope string) string {
+		return http.GenerateAuthenticationToken([]byte(scope+routingHash.String()+"1270643696000000000"), *privKey)
+	}
+
+	// Subscribing needs the key of the record
+	req := http.NewRequest("POST", "/", `{"url": "`+srv.URL+`"}`, nil)
+	res = PostRoutingWebhook(routingHash, req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	// A token for an update of the record or for listing the webhooks cannot be used
+	req.Headers.Set("Authorization", "Bearer "+tokenFor(""))
+	res = PostRoutingWebhook(routingHash, req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	req.Headers.Set("Authorization", "Bearer "+tokenFor(scopeWebhooksGet))
+	res = PostRoutingWebhook(routingHash, req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	req.Headers.Set("Authorization", "Bearer "+tokenFor(scopeWebhooksPost))
+
+	// Internal addresses are refused
+	res = PostRoutingWebhook(routingHash, req)
+	assert.Equal(t, 400, res.StatusCode)
+
+	// Allow the test server on the loopback address
+	isValidWebhookURL = func(string) bool { return true }
+	webhooks.Client = &nethttp.Client{}
+
+	res = PostRoutingWebhook(routingHash, req)
+	assert.Equal(t, 201, res.StatusCode)
+
+	sub := &struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}{}
+	_ = json.Unmarshal([]byte(res.Body), sub)
+	assert.Len(t, sub.ID, 32)
+	assert.Equal(t, srv.URL, sub.URL)
+
+	isValidWebhookURL = webhook.IsValidURL
+	req = http.NewRequest("POST", "/", `{"url": "not-an-url"}`, nil)
+	req.Headers.Set("Authorization", "Bearer "+tokenFor(scopeWebhooksPost))
+	res = PostRoutingWebhook(routingHash, req)
+	assert.Equal(t, 400, res.StatusCode)
+
+	res = PostRoutingWebhook(hash.New("unknown"), req)
+	assert.Equal(t, 404, res.StatusCode)
+
+	// List subscriptions
+	req = http.NewRequest("GET", "/", "", nil)
+	req.Headers.Set("Authorization", "Bearer "+tokenFor(scopeWebhooksGet))
+	res = GetRoutingWebhooks(routingHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Contains(t, res.Body, sub.ID)
+
+	// Updating the record delivers a signed event
+	_, pubKey, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	b, _ := json.Marshal(routingUploadBody{
+		PublicKey: pubKey,
+		Routing:   "127.0.0.2",
+		KeySig:    GenerateKeyPossessionSignature(routingHash.String(), 1270643696000000000, *privKey),
+	})
+	req = http.NewRequest("POST", "/", string(b), nil)
+	req.Headers.Set("Authorization", "Bearer "+tokenFor(""))
+	res = PostRoutingHash(routingHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	var d delivery
+	select {
+	case d = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for webhook")
+	}
+
+	event := &webhook.Event{}
+	_ = json.Unmarshal(d.body, event)
+	assert.Equal(t, webhook.Event{
+		Subscription: sub.ID,
+		Type:         "routing",
+		Action:       "update",
+		Hash:         routingHash.String(),
+		Serial:       1270643696000000000,
+		Timestamp:    1270643696,
+	}, *event)
+
+	sig, _ := base64.StdEncoding.DecodeString(d.header.Get(SignatureHeader))
+	ts, _ := strconv.ParseInt(d.header.Get(SignatureTimestampHeader), 10, 64)
+	digest, _ := responseDigest("POST", d.path, string(d.body), ts)
+	ok, _ := bmcrypto.Verify(*signPubKey, digest, sig)
+	assert.True(t, ok)
+
+	// A token for listing the webhooks cannot be replayed to unsubscribe
+	req = http.NewRequest("DELETE", "/", "", map[string]string{"id": sub.ID})
+	req.Headers.Set("Authorization", "Bearer "+tokenFor(scopeWebhooksGet))
+	res = DeleteRoutingWebhook(routingHash, req)
+	assert.Equal(t, 401, res.StatusCode)
+
+	// Unsubscribe
+	req.Headers.Set("Authorization", "Bearer "+tokenFor(scopeWebhooksDelete))
+	res = DeleteRoutingWebhook(routingHash, req)
+	assert.Equal(t, 200, res.StatusCode)
+
+	res = DeleteRoutingWebhook(routingHash, req)
+	assert.Equal(t, 404, res.StatusCode)
+}
@@ -25,6 +25,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"strings"
 
@@ -62,6 +63,9 @@ type Request struct {
 	Body    string
 	Headers Headers
 	Params  map[string]string
+	Query   map[string]string
+
+	ClientIP string // IP address of the client, when known
 }
 
 func NewRequest(method, url, body string, params map[string]string) Request {
@@ -71,6 +75,7 @@ func NewRequest(method, url, body string, params map[string]string) Request {
 		Body:    body,
 		Headers: NewHeaders(),
 		Params:  params,
+		Query:   make(map[string]string),
 	}
 }
 
@@ -101,6 +106,17 @@ func CreateError(msg string, statusCode int) *Response {
 	return CreateOutput(errBody, statusCode)
 }
 
+// CreateErrorWithCode creates an error message json structure with a machine readable error code
+func CreateErrorWithCode(msg, code string, statusCode int) *Response {
+	errBody := JsonOut{
+		"status":  "error",
+		"code":    code,
+		"message": msg,
+	}
+
+	return CreateOutput(errBody, statusCode)
+}
+
 // CreateMessage creates an regular message json structure
 func CreateMessage(msg string, statusCode int) *Response {
 	msgBody := JsonOut{
@@ -142,6 +158,44 @@ func (r Request) ValidateAuthenticationToken(pubKey, hashData string) bool {
 		return false
 	}
 
+	return verifySignature(pubKey, hashData, requestSignature)
+}
+
+// CountAuthenticationSigners returns the number of distinct public keys that have signed the hash data. The
+// authorization header can hold multiple tokens, separated by commas.
+func (r Request) CountAuthenticationSigners(pubKeys []string, hashData string) int {
+	if !r.Headers.Has("authorization") {
+		return 0
+	}
+	authToken := r.Headers.Get("authorization")
+	if len(authToken) <= 6 || strings.ToUpper(authToken[0:7]) != "BEARER " {
+		return 0
+	}
+
+	var signatures [][]byte
+	for _, token := range strings.Split(authToken[7:], ",") {
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(token))
+		if err != nil {
+			log.Printf("err: %s", err)
+			continue
+		}
+		signatures = append(signatures, sig)
+	}
+
+	count := 0
+	for _, pubKey := range pubKeys {
+		for _, sig := range signatures {
+			if verifySignature(pubKey, hashData, sig) {
+				count++
+				break
+			}
+		}
+	}
+
+	return count
+}
+
+func verifySignature(pubKey, hashData string, signature []byte) bool {
 	pk, err := bmcrypto.NewPubKey(pubKey)
 	if err != nil {
 		log.Printf("err: %s", err)
@@ -149,7 +203,7 @@ func (r Request) ValidateAuthenticationToken(pubKey, hashData string) bool {
 	}
 
 	hash := sha256.Sum256([]byte(hashData))
-	verified, err := bmcrypto.Verify(*pk, hash[:], requestSignature)
+	verified, err := bmcrypto.Verify(*pk, hash[:], signature)
 	if err != nil {
 		log.Printf("err: %s", err)
 		return false
@@ -168,10 +222,19 @@ func NetReqToReq(r http.Request) Request {
 	params := mux.Vars(&r)
 	req := NewRequest(r.Method, r.URL.String(), string(b), params)
 
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		req.ClientIP = host
+	}
+
 	// Add headers
 	for k, v := range r.Header {
 		req.Headers.Set(k, v[0])
 	}
 
+	// Add query parameters
+	for k, v := range r.URL.Query() {
+		req.Query[k] = v[0]
+	}
+
 	return req
 }
@@ -87,6 +87,34 @@ func TestValidateSignature(t *testing.T) {
 	assert.False(t, req.ValidateAuthenticationToken(PubKeyData, hashData))
 }
 
+func TestCountAuthenticationSigners(t *testing.T) {
+	priv1, pub1, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	priv2, pub2, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+	_, pub3, _ := testing2.ReadTestKey("../../testdata/key-3.json")
+
+	keys := []string{pub1.String(), pub2.String(), pub3.String()}
+	token1 := GenerateAuthenticationToken([]byte("secret"), *priv1)
+	token2 := GenerateAuthenticationToken([]byte("secret"), *priv2)
+
+	req := NewRequest("GET", "/", "", nil)
+	assert.Equal(t, 0, req.CountAuthenticationSigners(keys, "secret"))
+
+	req.Headers.Set("authorization", "Bearer "+token1)
+	assert.Equal(t, 1, req.CountAuthenticationSigners(keys, "secret"))
+
+	req.Headers.Set("authorization", "Bearer "+token1+","+token2)
+	assert.Equal(t, 2, req.CountAuthenticationSigners(keys, "secret"))
+	assert.Equal(t, 0, req.CountAuthenticationSigners(keys, "other secret"))
+
+	// Same signer twice only counts once
+	req.Headers.Set("authorization", "Bearer "+token1+","+token1)
+	assert.Equal(t, 1, req.CountAuthenticationSigners(keys, "secret"))
+
+	// Invalid tokens are skipped
+	req.Headers.Set("authorization", "Bearer *&^(&^%,"+token2)
+	assert.Equal(t, 1, req.CountAuthenticationSigners(keys, "secret"))
+}
+
 func TestMain(m *testing.M) {
 	log.SetOutput(ioutil.Discard)
 	os.Exit(m.Run())
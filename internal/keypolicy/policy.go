@@ -0,0 +1,131 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package keypolicy
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"strings"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+)
+
+// Codes that are returned for keys that do not comply with the policy
+const (
+	CodeKeyType    = "key_type_not_allowed"
+	CodeRSAKeySize = "rsa_key_too_small"
+	CodeDenylisted = "key_denylisted"
+	CodeReuseLimit = "key_reuse_limit_reached"
+	CodeInvalidKey = "invalid_key"
+)
+
+// Policy describes the public keys that are accepted for address and organisation records
+type Policy struct {
+	AllowedTypes     []string        `json:"allowed_types"`       // Key types that are accepted
+	MinRSABits       int             `json:"min_rsa_bits"`        // Minimum modulus size of RSA keys
+	MaxRecordsPerKey int             `json:"max_records_per_key"` // Maximum number of records that use the same key, 0 for no limit
+	Denylist         map[string]bool `json:"-"`                   // Fingerprints of keys that are known to be bad
+}
+
+// Violation is returned when a key does not comply with the policy
+type Violation struct {
+	Code    string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
+
+// Default returns the policy that accepts all key types, with RSA keys of at least 2048 bits
+func Default() *Policy {
+	return &Policy{
+		AllowedTypes: []string{bmcrypto.KeyTypeRSA, bmcrypto.KeyTypeECDSA, bmcrypto.KeyTypeED25519},
+		MinRSABits:   2048,
+		Denylist:     make(map[string]bool),
+	}
+}
+
+// Check checks the key against the policy. The number of other records that already use the key is needed to check
+// the reuse limit.
+func (p *Policy) Check(pk *bmcrypto.PubKey, records int) *Violation {
+	if pk == nil {
+		return &Violation{Code: CodeInvalidKey, Message: "invalid public key"}
+	}
+
+	if !p.isAllowedType(pk.Type) {
+		return &Violation{Code: CodeKeyType, Message: fmt.Sprintf("key type %s is not allowed", pk.Type)}
+	}
+
+	if pk.Type == bmcrypto.KeyTypeRSA {
+		rsaKey, ok := pk.K.(*rsa.PublicKey)
+		if !ok {
+			return &Violation{Code: CodeInvalidKey, Message: "invalid public key"}
+		}
+
+		if rsaKey.N.BitLen() < p.MinRSABits {
+			return &Violation{Code: CodeRSAKeySize, Message: fmt.Sprintf("rsa key too small (need %d bits)", p.MinRSABits)}
+		}
+	}
+
+	if p.Denylist[pk.Fingerprint()] {
+		return &Violation{Code: CodeDenylisted, Message: "key is denylisted"}
+	}
+
+	if p.MaxRecordsPerKey > 0 && records >= p.MaxRecordsPerKey {
+		return &Violation{Code: CodeReuseLimit, Message: fmt.Sprintf("key is already used by %d records", records)}
+	}
+
+	return nil
+}
+
+func (p *Policy) isAllowedType(typ string) bool {
+	for _, t := range p.AllowedTypes {
+		if t == typ {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseTypes parses a comma separated list of key types
+func ParseTypes(s string) ([]string, error) {
+	var types []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != bmcrypto.KeyTypeRSA && t != bmcrypto.KeyTypeECDSA && t != bmcrypto.KeyTypeED25519 {
+			return nil, fmt.Errorf("unknown key type: %s", t)
+		}
+		types = append(types, t)
+	}
+
+	return types, nil
+}
+
+// ParseDenylist parses a list of fingerprints, separated by commas or whitespace
+func ParseDenylist(s string) map[string]bool {
+	denylist := make(map[string]bool)
+	for _, fp := range strings.Fields(strings.ReplaceAll(s, ",", " ")) {
+		denylist[strings.ToLower(fp)] = true
+	}
+
+	return denylist
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package keypolicy
+
+import (
+	"testing"
+
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	_, rsaKey, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	_, edKey, _ := testing2.ReadTestKey("../../testdata/key-3.json")
+
+	p := Default()
+	assert.Nil(t, p.Check(rsaKey, 0))
+	assert.Nil(t, p.Check(edKey, 100))
+
+	v := p.Check(nil, 0)
+	assert.Equal(t, CodeInvalidKey, v.Code)
+
+	// Only accept ed25519 keys
+	p.AllowedTypes = []string{"ed25519"}
+	v = p.Check(rsaKey, 0)
+	assert.Equal(t, CodeKeyType, v.Code)
+	assert.Equal(t, "key type rsa is not allowed", v.Error())
+	assert.Nil(t, p.Check(edKey, 0))
+
+	// Key-1 is a 2048 bit key
+	p = Default()
+	p.MinRSABits = 4096
+	v = p.Check(rsaKey, 0)
+	assert.Equal(t, CodeRSAKeySize, v.Code)
+	assert.Equal(t, "rsa key too small (need 4096 bits)", v.Message)
+	assert.Nil(t, p.Check(edKey, 0))
+
+	p = Default()
+	p.MaxRecordsPerKey = 2
+	assert.Nil(t, p.Check(rsaKey, 1))
+	v = p.Check(rsaKey, 2)
+	assert.Equal(t, CodeReuseLimit, v.Code)
+
+	p = Default()
+	p.Denylist = ParseDenylist(edKey.Fingerprint())
+	assert.Nil(t, p.Check(rsaKey, 0))
+	v = p.Check(edKey, 0)
+	assert.Equal(t, CodeDenylisted, v.Code)
+}
+
+func TestParseTypes(t *testing.T) {
+	types, err := ParseTypes("rsa, ED25519")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rsa", "ed25519"}, types)
+
+	_, err = ParseTypes("rsa,dsa")
+	assert.Error(t, err)
+
+	_, err = ParseTypes("")
+	assert.Error(t, err)
+}
+
+func TestParseDenylist(t *testing.T) {
+	denylist := ParseDenylist("AAAA,bbbb\ncccc  dddd\n")
+	assert.Len(t, denylist, 4)
+	assert.True(t, denylist["aaaa"])
+	assert.True(t, denylist["dddd"])
+
+	assert.Len(t, ParseDenylist(""), 0)
+}
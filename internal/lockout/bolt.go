@@ -0,0 +1,93 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package lockout
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/bitmaelum/key-resolver-go/internal"
+	bolt "go.etcd.io/bbolt"
+)
+
+type boltRepository struct {
+	client     *bolt.DB
+	bucketName []byte
+}
+
+// NewBoltRepository returns a new repository that keeps the lockout states in BoltDB
+func NewBoltRepository() Repository {
+	return &boltRepository{
+		client:     internal.GetBoltDb(),
+		bucketName: []byte("lockouts"),
+	}
+}
+
+func (b boltRepository) Get(key string, now time.Time) (State, error) {
+	s := State{}
+
+	err := b.client.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil {
+			return nil
+		}
+
+		data := bucket.Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &s)
+	})
+	if err != nil {
+		return State{}, err
+	}
+
+	if s.IsExpired(now) {
+		return State{}, nil
+	}
+
+	return s, nil
+}
+
+func (b boltRepository) Update(key string, now time.Time, f func(s *State)) error {
+	return b.client.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.bucketName)
+		if err != nil {
+			return err
+		}
+
+		s := State{}
+		if data := bucket.Get([]byte(key)); data != nil {
+			err = json.Unmarshal(data, &s)
+			if err != nil || s.IsExpired(now) {
+				s = State{}
+			}
+		}
+
+		f(&s)
+
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(key), data)
+	})
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package lockout
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// The states are stored in a single table with "key" as partition key. The "expires" attribute can be used as TTL
+// attribute, so DynamoDB removes states that are forgotten.
+
+// Number of times an update is retried when another request changed the state at the same time
+const maxAttempts = 5
+
+var errConflict = errors.New("lockout state changed concurrently")
+
+type dynamoDbRepository struct {
+	Dyna      dynamodbiface.DynamoDBAPI
+	TableName string
+}
+
+// Record in dynamodb
+type dynamoStateRecord struct {
+	Key         string `dynamodbav:"key"`
+	Version     int64  `dynamodbav:"version"`
+	Failures    int    `dynamodbav:"failures"`
+	Lockouts    int    `dynamodbav:"lockouts"`
+	LastFailure int64  `dynamodbav:"last_failure"`
+	LockedUntil int64  `dynamodbav:"locked_until"`
+	Expires     int64  `dynamodbav:"expires"`
+}
+
+// NewDynamoDBRepository returns a new repository that keeps the lockout states in DynamoDB
+func NewDynamoDBRepository(client dynamodbiface.DynamoDBAPI, tableName string) Repository {
+	return &dynamoDbRepository{
+		Dyna:      client,
+		TableName: tableName,
+	}
+}
+
+func (r *dynamoDbRepository) Get(key string, now time.Time) (State, error) {
+	record, err := r.get(key)
+	if err != nil || record == nil {
+		return State{}, err
+	}
+
+	s := record.state()
+	if s.IsExpired(now) {
+		return State{}, nil
+	}
+
+	return s, nil
+}
+
+// Update does an optimistic update: the state is only written when its version has not changed since it was read
+func (r *dynamoDbRepository) Update(key string, now time.Time, f func(s *State)) error {
+	for i := 0; i < maxAttempts; i++ {
+		record, err := r.get(key)
+		if err != nil {
+			return err
+		}
+
+		s := State{}
+		var version int64
+		if record != nil {
+			s = record.state()
+			version = record.Version
+		}
+		if s.IsExpired(now) {
+			s = State{}
+		}
+
+		f(&s)
+
+		av, err := dynamodbattribute.MarshalMap(dynamoStateRecord{
+			Key:         key,
+			Version:     version + 1,
+			Failures:    s.Failures,
+			Lockouts:    s.Lockouts,
+			LastFailure: s.LastFailure,
+			LockedUntil: s.LockedUntil,
+			Expires:     s.Expires,
+		})
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+
+		_, err = r.Dyna.PutItem(&dynamodb.PutItemInput{
+			TableName:           aws.String(r.TableName),
+			Item:                av,
+			ConditionExpression: aws.String("attribute_not_exists(version) OR version = :version"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":version": {N: aws.String(strconv.FormatInt(version, 10))},
+			},
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			continue
+		}
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+
+		return nil
+	}
+
+	return errConflict
+}
+
+func (r *dynamoDbRepository) get(key string) (*dynamoStateRecord, error) {
+	out, err := r.Dyna.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(r.TableName),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			"key": {S: aws.String(key)},
+		},
+	})
+	if err != nil {
+		log.Print(err)
+		return nil, err
+	}
+
+	if out.Item == nil {
+		return nil, nil
+	}
+
+	record := &dynamoStateRecord{}
+	err = dynamodbattribute.UnmarshalMap(out.Item, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (record dynamoStateRecord) state() State {
+	return State{
+		Failures:    record.Failures,
+		Lockouts:    record.Lockouts,
+		LastFailure: record.LastFailure,
+		LockedUntil: record.LockedUntil,
+		Expires:     record.Expires,
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	dynamock "github.com/gusaul/go-dynamock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBRepository(t *testing.T) {
+	client, mock := dynamock.New()
+	repo := NewDynamoDBRepository(client, "mock_lockout_table")
+
+	now := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+	key := map[string]*dynamodb.AttributeValue{
+		"key": {S: aws.String("key1")},
+	}
+	item := map[string]*dynamodb.AttributeValue{
+		"key":          {S: aws.String("key1")},
+		"version":      {N: aws.String("1")},
+		"failures":     {N: aws.String("1")},
+		"lockouts":     {N: aws.String("0")},
+		"last_failure": {N: aws.String("1270643696")},
+		"locked_until": {N: aws.String("0")},
+		"expires":      {N: aws.String("1270647296")},
+	}
+
+	mock.ExpectGetItem().ToTable("mock_lockout_table").WithKeys(key).WillReturns(dynamodb.GetItemOutput{})
+	s, err := repo.Get("key1", now)
+	assert.NoError(t, err)
+	assert.Equal(t, State{}, s)
+
+	mock.ExpectGetItem().ToTable("mock_lockout_table").WithKeys(key).WillReturns(dynamodb.GetItemOutput{})
+	mock.ExpectPutItem().ToTable("mock_lockout_table").WithItems(item).WillReturns(dynamodb.PutItemOutput{})
+	err = repo.Update("key1", now, func(s *State) {
+		testPolicy.RecordFailure(s, now)
+	})
+	assert.NoError(t, err)
+
+	mock.ExpectGetItem().ToTable("mock_lockout_table").WithKeys(key).WillReturns(dynamodb.GetItemOutput{Item: item})
+	s, err = repo.Get("key1", now)
+	assert.NoError(t, err)
+	assert.Equal(t, State{Failures: 1, LastFailure: now.Unix(), Expires: now.Add(time.Hour).Unix()}, s)
+
+	// Expired states are forgotten
+	mock.ExpectGetItem().ToTable("mock_lockout_table").WithKeys(key).WillReturns(dynamodb.GetItemOutput{Item: item})
+	s, err = repo.Get("key1", now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, State{}, s)
+
+	// Errors are passed on
+	_, err = repo.Get("key1", now)
+	assert.Error(t, err)
+	err = repo.Update("key1", now, func(s *State) {})
+	assert.Error(t, err)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package lockout
+
+import (
+	"errors"
+	"time"
+)
+
+// Policy decides when failed authentications lead to a lockout
+type Policy struct {
+	Threshold   int           // Number of failures after which a lockout starts
+	Window      time.Duration // Failures are forgotten after a quiet period of this length
+	Duration    time.Duration // Duration of the first lockout, which doubles for every next lockout
+	MaxDuration time.Duration // Maximum duration of a lockout
+}
+
+// Validate returns an error when the policy cannot be used
+func (p Policy) Validate() error {
+	if p.Threshold < 1 {
+		return errors.New("lockout threshold must be at least 1")
+	}
+	if p.Window <= 0 {
+		return errors.New("lockout window must be positive")
+	}
+	if p.Duration <= 0 {
+		return errors.New("lockout duration must be positive")
+	}
+	if p.MaxDuration < p.Duration {
+		return errors.New("maximum lockout duration must not be less than the lockout duration")
+	}
+
+	return nil
+}
+
+// State holds the failed authentications of a client or a hash. A zero state has no failures.
+type State struct {
+	Failures    int   `json:"failures"`     // Failures since the last lockout
+	Lockouts    int   `json:"lockouts"`     // Number of lockouts without a quiet period in between
+	LastFailure int64 `json:"last_failure"` // Unix time of the last failure
+	LockedUntil int64 `json:"locked_until"` // Unix time until which the lockout lasts
+	Expires     int64 `json:"expires"`      // Unix time after which the state is forgotten
+}
+
+// IsLocked returns true when the lockout lasts until after the given time
+func (s State) IsLocked(now time.Time) bool {
+	return s.LockedUntil > now.Unix()
+}
+
+// IsExpired returns true when the state is forgotten at the given time
+func (s State) IsExpired(now time.Time) bool {
+	return s.Expires > 0 && s.Expires <= now.Unix()
+}
+
+// RecordFailure adds a failed authentication to the state. It returns true when this starts a new lockout.
+func (p Policy) RecordFailure(s *State, now time.Time) bool {
+	if s.IsExpired(now) {
+		*s = State{}
+	}
+
+	s.Failures++
+	s.LastFailure = now.Unix()
+
+	locked := false
+	if s.Failures >= p.Threshold {
+		s.Failures = 0
+		s.Lockouts++
+		s.LockedUntil = now.Add(p.lockoutDuration(s.Lockouts)).Unix()
+		locked = true
+	}
+
+	s.Expires = s.LastFailure + int64(p.Window.Seconds())
+	if s.LockedUntil >= s.LastFailure {
+		s.Expires = s.LockedUntil + int64(p.Window.Seconds())
+	}
+
+	return locked
+}
+
+// lockoutDuration returns the duration of the n-th lockout in a row
+func (p Policy) lockoutDuration(n int) time.Duration {
+	d := p.Duration
+	for i := 1; i < n; i++ {
+		d *= 2
+		if d >= p.MaxDuration {
+			return p.MaxDuration
+		}
+	}
+
+	return d
+}
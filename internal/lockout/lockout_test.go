@@ -0,0 +1,100 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testPolicy = Policy{
+	Threshold:   3,
+	Window:      time.Hour,
+	Duration:    time.Minute,
+	MaxDuration: 5 * time.Minute,
+}
+
+func TestPolicyValidate(t *testing.T) {
+	assert.NoError(t, testPolicy.Validate())
+
+	p := testPolicy
+	p.Threshold = 0
+	assert.Error(t, p.Validate())
+
+	p = testPolicy
+	p.Window = 0
+	assert.Error(t, p.Validate())
+
+	p = testPolicy
+	p.Duration = 0
+	assert.Error(t, p.Validate())
+
+	p = testPolicy
+	p.MaxDuration = time.Second
+	assert.Error(t, p.Validate())
+}
+
+func TestRecordFailure(t *testing.T) {
+	now := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+	s := State{}
+
+	assert.False(t, testPolicy.RecordFailure(&s, now))
+	assert.False(t, testPolicy.RecordFailure(&s, now))
+	assert.False(t, s.IsLocked(now))
+	assert.Equal(t, 2, s.Failures)
+	assert.Equal(t, now.Add(time.Hour).Unix(), s.Expires)
+
+	// Third failure locks for the first duration
+	assert.True(t, testPolicy.RecordFailure(&s, now))
+	assert.True(t, s.IsLocked(now))
+	assert.Equal(t, 0, s.Failures)
+	assert.Equal(t, 1, s.Lockouts)
+	assert.Equal(t, now.Add(time.Minute).Unix(), s.LockedUntil)
+	assert.Equal(t, now.Add(time.Minute+time.Hour).Unix(), s.Expires)
+
+	// Every next lockout doubles, up to the maximum
+	now = now.Add(time.Minute)
+	assert.False(t, s.IsLocked(now))
+	for i := 0; i < 3; i++ {
+		testPolicy.RecordFailure(&s, now)
+	}
+	assert.Equal(t, 2, s.Lockouts)
+	assert.Equal(t, now.Add(2*time.Minute).Unix(), s.LockedUntil)
+
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 3; i++ {
+		testPolicy.RecordFailure(&s, now)
+	}
+	assert.Equal(t, now.Add(4*time.Minute).Unix(), s.LockedUntil)
+
+	now = now.Add(4 * time.Minute)
+	for i := 0; i < 3; i++ {
+		testPolicy.RecordFailure(&s, now)
+	}
+	assert.Equal(t, 4, s.Lockouts)
+	assert.Equal(t, now.Add(5*time.Minute).Unix(), s.LockedUntil)
+
+	// After a quiet period, everything is forgotten
+	now = now.Add(5*time.Minute + time.Hour)
+	assert.True(t, s.IsExpired(now))
+	assert.False(t, testPolicy.RecordFailure(&s, now))
+	assert.Equal(t, State{Failures: 1, LastFailure: now.Unix(), Expires: now.Add(time.Hour).Unix()}, s)
+}
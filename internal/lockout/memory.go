@@ -0,0 +1,64 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryRepository struct {
+	mu     sync.Mutex
+	states map[string]State
+}
+
+// NewMemoryRepository returns a new repository that keeps the lockout states in memory
+func NewMemoryRepository() Repository {
+	return &memoryRepository{
+		states: make(map[string]State),
+	}
+}
+
+func (r *memoryRepository) Get(key string, now time.Time) (State, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.states[key]
+	if s.IsExpired(now) {
+		delete(r.states, key)
+		return State{}, nil
+	}
+
+	return s, nil
+}
+
+func (r *memoryRepository) Update(key string, now time.Time, f func(s *State)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.states[key]
+	if s.IsExpired(now) {
+		s = State{}
+	}
+
+	f(&s)
+	r.states[key] = s
+
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package lockout
+
+import (
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Repository stores the failed authentications of clients and hashes
+type Repository interface {
+	// Get returns the state stored under the key. A missing or expired state is returned as a zero state.
+	Get(key string, now time.Time) (State, error)
+	// Update calls f with the state stored under the key, and stores the state afterwards. Concurrent updates of the
+	// same key must not overwrite each other.
+	Update(key string, now time.Time, f func(s *State)) error
+}
+
+var repository Repository
+
+// GetRepository returns the repository for the lockout states
+func GetRepository() Repository {
+	if repository != nil {
+		return repository
+	}
+
+	if os.Getenv("USE_BOLT") == "1" {
+		repository = NewBoltRepository()
+		return repository
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	repository = NewDynamoDBRepository(dynamodb.New(sess), os.Getenv("LOCKOUT_TABLE_NAME"))
+	return repository
+}
+
+// Sets the default repository for the lockout states. Can be used to override for mocking/testing purposes
+func SetDefaultRepository(r Repository) {
+	repository = r
+}
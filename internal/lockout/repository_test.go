@@ -0,0 +1,69 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runRepositoryTests(t *testing.T, repo Repository) {
+	now := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+
+	s, err := repo.Get("key1", now)
+	assert.NoError(t, err)
+	assert.Equal(t, State{}, s)
+
+	err = repo.Update("key1", now, func(s *State) {
+		assert.Equal(t, State{}, *s)
+		testPolicy.RecordFailure(s, now)
+	})
+	assert.NoError(t, err)
+
+	err = repo.Update("key1", now, func(s *State) {
+		assert.Equal(t, 1, s.Failures)
+		testPolicy.RecordFailure(s, now)
+	})
+	assert.NoError(t, err)
+
+	s, err = repo.Get("key1", now)
+	assert.NoError(t, err)
+	assert.Equal(t, State{Failures: 2, LastFailure: now.Unix(), Expires: now.Add(time.Hour).Unix()}, s)
+
+	s, err = repo.Get("key2", now)
+	assert.NoError(t, err)
+	assert.Equal(t, State{}, s)
+
+	// Expired states are forgotten
+	now = now.Add(time.Hour)
+	s, err = repo.Get("key1", now)
+	assert.NoError(t, err)
+	assert.Equal(t, State{}, s)
+
+	err = repo.Update("key1", now, func(s *State) {
+		assert.Equal(t, State{}, *s)
+	})
+	assert.NoError(t, err)
+}
+
+func TestMemoryRepository(t *testing.T) {
+	runRepositoryTests(t, NewMemoryRepository())
+}
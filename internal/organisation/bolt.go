@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
 	"github.com/bitmaelum/key-resolver-go/internal"
 	bolt "go.etcd.io/bbolt"
 )
@@ -96,7 +97,22 @@ func (b boltResolver) Create(hash, publicKey, proof string, validations []string
 }
 
 func (b boltResolver) Update(info *ResolveInfoType, publicKey, proof string, validations []string) (bool, error) {
-	return b.Create(info.Hash, publicKey, info.Proof, validations)
+	err := b.updateRecord(info.Hash, func(rec *ResolveInfoType) error {
+		if rec.Serial != info.Serial {
+			return ErrNotFound
+		}
+
+		rec.PubKey = publicKey
+		rec.Validations = validations
+		rec.Serial = uint64(time.Now().UnixNano())
+		return nil
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
 func (b boltResolver) Delete(hash string) (bool, error) {
@@ -178,6 +194,85 @@ func (b boltResolver) SoftUndelete(hash string) (bool, error) {
 	return true, nil
 }
 
+func (b boltResolver) SetRecoveryKey(hash string, recoveryKey *bmcrypto.PubKey) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) error {
+		rec.RecoveryKey = recoveryKey.String()
+		return nil
+	})
+}
+
+func (b boltResolver) RequestKeyReset(hash string, publicKey *bmcrypto.PubKey, resetAt time.Time) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) error {
+		rec.ResetKey = publicKey.String()
+		rec.ResetAt = resetAt
+		return nil
+	})
+}
+
+func (b boltResolver) CancelKeyReset(hash string) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) error {
+		rec.ResetKey = ""
+		rec.ResetAt = time.Time{}
+		return nil
+	})
+}
+
+func (b boltResolver) CompleteKeyReset(info *ResolveInfoType) (bool, error) {
+	err := b.updateRecord(info.Hash, func(rec *ResolveInfoType) error {
+		if rec.Serial != info.Serial || rec.ResetKey == "" {
+			return ErrCannotUpdate
+		}
+
+		rec.PubKey = rec.ResetKey
+		rec.ResetKey = ""
+		rec.ResetAt = time.Time{}
+		rec.AdminKeys = nil
+		rec.Threshold = 0
+		rec.Serial = uint64(time.Now().UnixNano())
+		return nil
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (b boltResolver) SetAdminKeys(hash string, adminKeys []string, threshold int) error {
+	return b.updateRecord(hash, func(rec *ResolveInfoType) error {
+		rec.AdminKeys = adminKeys
+		rec.Threshold = threshold
+		return nil
+	})
+}
+
+// updateRecord will fetch the record, let f modify it, and store it again in a single transaction
+func (b boltResolver) updateRecord(hash string, f func(rec *ResolveInfoType) error) error {
+	return b.client.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(b.bucketName))
+		if bucket == nil {
+			return ErrNotFound
+		}
+
+		rec, err := getFromBucket(bucket, hash)
+		if err != nil {
+			return ErrNotFound
+		}
+
+		err = f(rec)
+		if err != nil {
+			return err
+		}
+
+		buf, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(hash), buf)
+	})
+}
+
 func getFromBucket(bucket *bolt.Bucket, hash string) (*ResolveInfoType, error) {
 	data := bucket.Get([]byte(hash))
 	if data == nil {
@@ -0,0 +1,48 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package organisation
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+const tmpDbPath = "/tmp/mockboltdb-%d.db"
+
+func TestBoltResolver(t *testing.T) {
+	// Random path, otherwise we get into issues with running on github actions?
+	p := fmt.Sprintf(tmpDbPath, rand.Int63())
+
+	_ = os.Setenv("USE_BOLT", "1")
+	_ = os.Setenv("BOLT_DB_FILE", p)
+	SetDefaultRepository(nil)
+
+	_ = os.Remove(p)
+	db := NewBoltResolver()
+	runRepositoryKeyResetTests(t, db)
+
+	_ = os.Remove(p)
+	db = NewBoltResolver()
+	runRepositoryAdminKeyTests(t, db)
+
+	_ = os.Remove(p)
+}
@@ -28,15 +28,20 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
 )
 
 type dynamoDbResolver struct {
-	Dyna      *dynamodb.DynamoDB
+	Dyna      dynamodbiface.DynamoDBAPI
 	TableName string
 }
 
-// ErrNotFound will be returned when a record we are looking for is not found in the db
-var ErrNotFound = errors.New("record not found")
+// Error codes
+var (
+	ErrNotFound     = errors.New("record not found")
+	ErrCannotUpdate = errors.New("cannot update record")
+)
 
 // Record holds a DynamoDB record
 type Record struct {
@@ -47,10 +52,17 @@ type Record struct {
 	Serial      uint64   `dynamodbav:"sn"`
 	Deleted     bool     `dynamodbav:"deleted"`
 	DeletedAt   uint64   `dynamodbav:"deleted_at"`
+
+	RecoveryKey string `dynamodbav:"recovery_key,omitempty"`
+	ResetKey    string `dynamodbav:"reset_key,omitempty"`
+	ResetAt     int64  `dynamodbav:"reset_at,omitempty"`
+
+	AdminKeys []string `dynamodbav:"admin_keys,omitempty"`
+	Threshold int      `dynamodbav:"threshold,omitempty"`
 }
 
 // NewDynamoDBResolver returns a new resolver based on DynamoDB
-func NewDynamoDBResolver(client *dynamodb.DynamoDB, tableName string) Repository {
+func NewDynamoDBResolver(client dynamodbiface.DynamoDBAPI, tableName string) Repository {
 	return &dynamoDbResolver{
 		Dyna:      client,
 		TableName: tableName,
@@ -139,13 +151,22 @@ func (r *dynamoDbResolver) Get(hash string) (*ResolveInfoType, error) {
 		return nil, ErrNotFound
 	}
 
-	return &ResolveInfoType{
+	info := &ResolveInfoType{
 		Hash:        record.Hash,
 		PubKey:      record.PublicKey,
 		Proof:       record.Proof,
 		Validations: record.Validations,
 		Serial:      record.Serial,
-	}, nil
+		RecoveryKey: record.RecoveryKey,
+		ResetKey:    record.ResetKey,
+		AdminKeys:   record.AdminKeys,
+		Threshold:   record.Threshold,
+	}
+	if record.ResetKey != "" {
+		info.ResetAt = time.Unix(record.ResetAt, 0)
+	}
+
+	return info, nil
 }
 
 func (r *dynamoDbResolver) Delete(hash string) (bool, error) {
@@ -206,3 +227,92 @@ func (r *dynamoDbResolver) SoftUndelete(hash string) (bool, error) {
 
 	return true, nil
 }
+
+func (r *dynamoDbResolver) SetRecoveryKey(hash string, recoveryKey *bmcrypto.PubKey) error {
+	return r.updateItem(hash, "SET recovery_key=:rk", map[string]*dynamodb.AttributeValue{
+		":rk": {S: aws.String(recoveryKey.String())},
+	})
+}
+
+func (r *dynamoDbResolver) RequestKeyReset(hash string, publicKey *bmcrypto.PubKey, resetAt time.Time) error {
+	return r.updateItem(hash, "SET reset_key=:rk, reset_at=:ra", map[string]*dynamodb.AttributeValue{
+		":rk": {S: aws.String(publicKey.String())},
+		":ra": {N: aws.String(strconv.FormatInt(resetAt.Unix(), 10))},
+	})
+}
+
+func (r *dynamoDbResolver) CancelKeyReset(hash string) error {
+	return r.updateItem(hash, "REMOVE reset_key, reset_at", nil)
+}
+
+func (r *dynamoDbResolver) CompleteKeyReset(info *ResolveInfoType) (bool, error) {
+	if info.ResetKey == "" {
+		return false, ErrCannotUpdate
+	}
+
+	serial := strconv.FormatUint(uint64(time.Now().UnixNano()), 10)
+
+	input := &dynamodb.UpdateItemInput{
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk":  {S: aws.String(info.ResetKey)},
+			":sn":  {N: aws.String(serial)},
+			":csn": {N: aws.String(strconv.FormatUint(info.Serial, 10))},
+		},
+		TableName:           aws.String(r.TableName),
+		UpdateExpression:    aws.String("SET public_key=:pk, sn=:sn REMOVE reset_key, reset_at, admin_keys, threshold"),
+		ConditionExpression: aws.String("sn = :csn AND reset_key = :pk"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"hash": {S: aws.String(info.Hash)},
+		},
+	}
+
+	_, err := r.Dyna.UpdateItem(input)
+	if err != nil {
+		log.Print(err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *dynamoDbResolver) SetAdminKeys(hash string, adminKeys []string, threshold int) error {
+	if len(adminKeys) == 0 {
+		return r.updateItem(hash, "REMOVE admin_keys, threshold", nil)
+	}
+
+	av, err := dynamodbattribute.Marshal(adminKeys)
+	if err != nil {
+		return err
+	}
+
+	return r.updateItem(hash, "SET admin_keys=:ak, threshold=:th", map[string]*dynamodb.AttributeValue{
+		":ak": av,
+		":th": {N: aws.String(strconv.Itoa(threshold))},
+	})
+}
+
+// updateItem runs the update expression on an existing organisation record
+func (r *dynamoDbResolver) updateItem(hash, expr string, values map[string]*dynamodb.AttributeValue) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.TableName),
+		UpdateExpression:    aws.String(expr),
+		ConditionExpression: aws.String("attribute_exists(#h)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#h": aws.String("hash"),
+		},
+		Key: map[string]*dynamodb.AttributeValue{
+			"hash": {S: aws.String(hash)},
+		},
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+
+	_, err := r.Dyna.UpdateItem(input)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package organisation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+	dynamock "github.com/gusaul/go-dynamock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	mock *dynamock.DynaMock
+)
+
+func TestGet(t *testing.T) {
+	var client dynamodbiface.DynamoDBAPI
+	client, mock = dynamock.New()
+	resolver := NewDynamoDBResolver(client, "mock_organisation_table")
+
+	result := dynamodb.GetItemOutput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"hash":         {S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")},
+			"public_key":   {S: aws.String("pubkey")},
+			"proof":        {S: aws.String("proof")},
+			"sn":           {N: aws.String("42")},
+			"recovery_key": {S: aws.String("recoverykey")},
+			"reset_key":    {S: aws.String("resetkey")},
+			"reset_at":     {N: aws.String("1270643696")},
+			"admin_keys":   {L: []*dynamodb.AttributeValue{{S: aws.String("admin1")}, {S: aws.String("admin2")}}},
+			"threshold":    {N: aws.String("2")},
+		},
+	}
+
+	expectKey := map[string]*dynamodb.AttributeValue{
+		"hash": {
+			S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2"),
+		},
+	}
+	mock.ExpectGetItem().ToTable("mock_organisation_table").WithKeys(expectKey).WillReturns(result)
+	info, err := resolver.Get("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")
+	assert.NoError(t, err)
+	assert.Equal(t, "pubkey", info.PubKey)
+	assert.Equal(t, uint64(42), info.Serial)
+	assert.Equal(t, "recoverykey", info.RecoveryKey)
+	assert.Equal(t, "resetkey", info.ResetKey)
+	assert.Equal(t, int64(1270643696), info.ResetAt.Unix())
+	assert.Equal(t, []string{"admin1", "admin2"}, info.AdminKeys)
+	assert.Equal(t, 2, info.Threshold)
+}
+
+func TestKeyReset(t *testing.T) {
+	var client dynamodbiface.DynamoDBAPI
+	client, mock = dynamock.New()
+	resolver := NewDynamoDBResolver(client, "mock_organisation_table")
+
+	pubkey, _ := bmcrypto.NewPubKey("ed25519 MCowBQYDK2VwAyEAS2/hs2jf0QJgpuNklMnN/A7EHj26DDpRfvcZyettOjU=")
+
+	expectKey := map[string]*dynamodb.AttributeValue{
+		"hash": {
+			S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2"),
+		},
+	}
+
+	mock.ExpectUpdateItem().ToTable("mock_organisation_table").WithKeys(expectKey)
+	err := resolver.SetRecoveryKey("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", pubkey)
+	assert.NoError(t, err)
+
+	mock.ExpectUpdateItem().ToTable("mock_organisation_table").WithKeys(expectKey)
+	err = resolver.RequestKeyReset("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", pubkey, time.Now())
+	assert.NoError(t, err)
+
+	mock.ExpectUpdateItem().ToTable("mock_organisation_table").WithKeys(expectKey)
+	err = resolver.CancelKeyReset("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2")
+	assert.NoError(t, err)
+
+	info := &ResolveInfoType{
+		Hash:      "cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2",
+		PubKey:    "ed25519 MCowBQYDK2VwAyEAbRpv3o6/dvhcYwZTHM/+q8FPbz+U/qgsXDxISQv5Ab8=",
+		ResetKey:  pubkey.String(),
+		Serial:    1273494896000000000,
+		AdminKeys: []string{"admin1", "admin2"},
+		Threshold: 2,
+	}
+
+	mock.ExpectUpdateItem().ToTable("mock_organisation_table").WithKeys(expectKey)
+	ok, err := resolver.CompleteKeyReset(info)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// No pending reset
+	info.ResetKey = ""
+	ok, err = resolver.CompleteKeyReset(info)
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestAdminKeys(t *testing.T) {
+	var client dynamodbiface.DynamoDBAPI
+	client, mock = dynamock.New()
+	resolver := NewDynamoDBResolver(client, "mock_organisation_table")
+
+	expectKey := map[string]*dynamodb.AttributeValue{
+		"hash": {
+			S: aws.String("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2"),
+		},
+	}
+
+	mock.ExpectUpdateItem().ToTable("mock_organisation_table").WithKeys(expectKey)
+	err := resolver.SetAdminKeys("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", []string{"admin1", "admin2"}, 2)
+	assert.NoError(t, err)
+
+	mock.ExpectUpdateItem().ToTable("mock_organisation_table").WithKeys(expectKey)
+	err = resolver.SetAdminKeys("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", nil, 0)
+	assert.NoError(t, err)
+
+	// No expectation set
+	err = resolver.SetAdminKeys("cf99b895f350b77585881438ab38a935e68c9c7409c5adaad23fb17572ca1ea2", nil, 0)
+	assert.Error(t, err)
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
 )
 
 // ResolveInfoType returns information found in the resolver repository
@@ -36,6 +37,13 @@ type ResolveInfoType struct {
 	Serial      uint64
 	Deleted     bool
 	DeletedAt   time.Time
+
+	RecoveryKey string    // Optional key that can reset the public key
+	ResetKey    string    // Pending public key set through the recovery key
+	ResetAt     time.Time // Time from which the pending key reset can be completed
+
+	AdminKeys []string // Admin keys that must sign updates and deletions instead of the public key. Cleared by a key reset
+	Threshold int      // Number of distinct admin keys that must sign
 }
 
 // Repository to resolve records
@@ -46,27 +54,41 @@ type Repository interface {
 	SoftDelete(hash string) (bool, error)
 	SoftUndelete(hash string) (bool, error)
 	Delete(hash string) (bool, error)
+
+	// Set the recovery key that is allowed to reset the public key
+	SetRecoveryKey(hash string, recoveryKey *bmcrypto.PubKey) error
+	// Start a key reset that can be completed from resetAt
+	RequestKeyReset(hash string, publicKey *bmcrypto.PubKey, resetAt time.Time) error
+	// Cancel a pending key reset
+	CancelKeyReset(hash string) error
+	// Replace the public key with the pending reset key and remove the admin keys
+	CompleteKeyReset(info *ResolveInfoType) (bool, error)
+
+	SetAdminKeys(hash string, adminKeys []string, threshold int) error
 }
 
 var resolver Repository
 
-// GetResolveRepository returns a new repository based on DynamoDB
+// GetResolveRepository returns a new repository based on DynamoDB. Every change made through the repository is
+// recorded in the change feed.
 func GetResolveRepository() Repository {
-	if resolver != nil {
-		return resolver
+	if resolver == nil {
+		resolver = newResolveRepository()
 	}
 
+	return withChangeFeed(resolver)
+}
+
+func newResolveRepository() Repository {
 	if os.Getenv("USE_BOLT") == "1" {
-		resolver = NewBoltResolver()
-		return resolver
+		return NewBoltResolver()
 	}
 
 	sess := session.Must(session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable,
 	}))
 
-	resolver = NewDynamoDBResolver(dynamodb.New(sess), os.Getenv("ORGANISATION_TABLE_NAME"))
-	return resolver
+	return NewDynamoDBResolver(dynamodb.New(sess), os.Getenv("ORGANISATION_TABLE_NAME"))
 }
 
 // Sets the default repository for resolving. Can be used to override for mocking/testing purposes
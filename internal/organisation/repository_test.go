@@ -0,0 +1,160 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package organisation
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/hash"
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoRepo(t *testing.T) {
+	_ = os.Setenv("USE_BOLT", "0")
+	_ = os.Setenv("ORGANISATION_TABLE_NAME", "mock")
+	SetDefaultRepository(nil)
+
+	r := GetResolveRepository()
+	assert.IsType(t, &changeRecorder{}, r)
+	assert.IsType(t, r.(*changeRecorder).Repository, NewDynamoDBResolver(nil, ""))
+}
+
+func runRepositoryKeyResetTests(t *testing.T, db Repository) {
+	h1 := hash.New("acme-inc")
+
+	_, pub1, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	_, pub2, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+	_, pub3, _ := testing2.ReadTestKey("../../testdata/key-3.json")
+
+	ok, err := db.Create(h1.String(), pub1.String(), "proof", []string{})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Cannot set recovery key on unknown entry
+	err = db.SetRecoveryKey("unknown", pub2)
+	assert.Error(t, err)
+
+	err = db.SetRecoveryKey(h1.String(), pub2)
+	assert.NoError(t, err)
+
+	info, err := db.Get(h1.String())
+	assert.NoError(t, err)
+	assert.Equal(t, pub2.String(), info.RecoveryKey)
+	assert.Equal(t, "", info.ResetKey)
+
+	// Cannot complete without a pending reset
+	ok, err = db.CompleteKeyReset(info)
+	assert.Error(t, err)
+	assert.False(t, ok)
+
+	// Request and cancel a reset
+	resetAt := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+	err = db.RequestKeyReset(h1.String(), pub3, resetAt)
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, pub3.String(), info.ResetKey)
+	assert.True(t, resetAt.Equal(info.ResetAt))
+
+	err = db.CancelKeyReset(h1.String())
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, "", info.ResetKey)
+	assert.True(t, info.ResetAt.IsZero())
+	assert.Equal(t, pub1.String(), info.PubKey)
+
+	// Request and complete a reset
+	err = db.RequestKeyReset(h1.String(), pub3, resetAt)
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	ok, err = db.CompleteKeyReset(info)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, pub3.String(), info.PubKey)
+	assert.Equal(t, pub2.String(), info.RecoveryKey)
+	assert.Equal(t, "", info.ResetKey)
+}
+
+func runRepositoryAdminKeyTests(t *testing.T, db Repository) {
+	h1 := hash.New("acme-inc")
+
+	_, pub1, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+	_, pub2, _ := testing2.ReadTestKey("../../testdata/key-2.json")
+	_, pub3, _ := testing2.ReadTestKey("../../testdata/key-3.json")
+	_, pub4, _ := testing2.ReadTestKey("../../testdata/key-4.json")
+
+	ok, err := db.Create(h1.String(), pub1.String(), "proof", []string{})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Cannot set admin keys on unknown entry
+	err = db.SetAdminKeys("unknown", []string{pub2.String()}, 1)
+	assert.Error(t, err)
+
+	err = db.SetAdminKeys(h1.String(), []string{pub2.String(), pub3.String()}, 2)
+	assert.NoError(t, err)
+
+	info, err := db.Get(h1.String())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{pub2.String(), pub3.String()}, info.AdminKeys)
+	assert.Equal(t, 2, info.Threshold)
+
+	// Admin keys are kept on a regular update
+	ok, err = db.Update(info, pub1.String(), "proof", []string{"dns: example.com"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, []string{pub2.String(), pub3.String()}, info.AdminKeys)
+	assert.Equal(t, 2, info.Threshold)
+
+	// A completed key reset removes the admin keys
+	err = db.SetRecoveryKey(h1.String(), pub4)
+	assert.NoError(t, err)
+	err = db.RequestKeyReset(h1.String(), pub4, time.Now())
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	ok, err = db.CompleteKeyReset(info)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	info, _ = db.Get(h1.String())
+	assert.Equal(t, pub4.String(), info.PubKey)
+	assert.Empty(t, info.AdminKeys)
+	assert.Equal(t, 0, info.Threshold)
+
+	// Admin keys can be removed
+	err = db.SetAdminKeys(h1.String(), []string{pub2.String()}, 1)
+	assert.NoError(t, err)
+	err = db.SetAdminKeys(h1.String(), nil, 0)
+	assert.NoError(t, err)
+
+	info, _ = db.Get(h1.String())
+	assert.Empty(t, info.AdminKeys)
+	assert.Equal(t, 0, info.Threshold)
+}
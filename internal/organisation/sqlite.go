@@ -28,6 +28,7 @@ import (
 
 	"database/sql"
 
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
 	_ "github.com/mattn/go-sqlite3" // SQLite driver
 )
 
@@ -58,7 +59,7 @@ func NewSqliteResolver(dsn string) Repository {
 		TimeNow: time.Now(),
 	}
 
-	_, _ = db.conn.Exec("CREATE TABLE IF NOT EXISTS mock_organisation (hash VARCHAR(64) PRIMARY KEY, proof TEXT, validations TEXT, pubkey TEXT, serial INTEGER, deleted INTEGER, deleted_at INTEGER)")
+	_, _ = db.conn.Exec("CREATE TABLE IF NOT EXISTS mock_organisation (hash VARCHAR(64) PRIMARY KEY, proof TEXT, validations TEXT, pubkey TEXT, serial INTEGER, deleted INTEGER, deleted_at INTEGER, recovery_key TEXT, reset_key TEXT, reset_at INTEGER, admin_keys TEXT, threshold INTEGER)")
 	return db
 }
 
@@ -92,7 +93,7 @@ func (r *SqliteDbResolver) Create(hash, publicKey, proof string, validations []s
 		return false, err
 	}
 
-	res, err := r.conn.Exec("INSERT INTO mock_organisation VALUES (?, ?, ?, ?, ?, 0, 0)", hash, proof, string(b), publicKey, newSerial)
+	res, err := r.conn.Exec("INSERT INTO mock_organisation VALUES (?, ?, ?, ?, ?, 0, 0, '', '', 0, '', 0)", hash, proof, string(b), publicKey, newSerial)
 	if err != nil {
 		return false, err
 	}
@@ -108,10 +109,15 @@ func (r *SqliteDbResolver) Get(hash string) (*ResolveInfoType, error) {
 		pow string
 		sn  uint64
 		v   []byte
+		rck string
+		rsk string
+		rsa int64
+		ak  string
+		th  int
 	)
 
-	query := "SELECT hash, pubkey, proof, validations, serial FROM mock_organisation WHERE hash LIKE ?"
-	err := r.conn.QueryRow(query, hash).Scan(&h, &pk, &pow, &v, &sn)
+	query := "SELECT hash, pubkey, proof, validations, serial, recovery_key, reset_key, reset_at, admin_keys, threshold FROM mock_organisation WHERE hash LIKE ?"
+	err := r.conn.QueryRow(query, hash).Scan(&h, &pk, &pow, &v, &sn, &rck, &rsk, &rsa, &ak, &th)
 	if err != nil {
 		return nil, ErrNotFound
 	}
@@ -122,13 +128,27 @@ func (r *SqliteDbResolver) Get(hash string) (*ResolveInfoType, error) {
 		return nil, err
 	}
 
-	return &ResolveInfoType{
+	info := &ResolveInfoType{
 		Hash:        h,
 		PubKey:      pk,
 		Proof:       pow,
 		Validations: val,
 		Serial:      sn,
-	}, nil
+		RecoveryKey: rck,
+		ResetKey:    rsk,
+		Threshold:   th,
+	}
+	if rsk != "" {
+		info.ResetAt = time.Unix(rsa, 0)
+	}
+	if ak != "" {
+		err = json.Unmarshal([]byte(ak), &info.AdminKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return info, nil
 }
 
 func (r *SqliteDbResolver) Delete(hash string) (bool, error) {
@@ -172,3 +192,56 @@ func (r *SqliteDbResolver) SoftUndelete(hash string) (bool, error) {
 	count, err := res.RowsAffected()
 	return count != 0, err
 }
+
+func (r *SqliteDbResolver) SetRecoveryKey(hash string, recoveryKey *bmcrypto.PubKey) error {
+	return r.exec("UPDATE mock_organisation SET recovery_key=? WHERE hash=?", recoveryKey.String(), hash)
+}
+
+func (r *SqliteDbResolver) RequestKeyReset(hash string, publicKey *bmcrypto.PubKey, resetAt time.Time) error {
+	return r.exec("UPDATE mock_organisation SET reset_key=?, reset_at=? WHERE hash=?", publicKey.String(), resetAt.Unix(), hash)
+}
+
+func (r *SqliteDbResolver) CancelKeyReset(hash string) error {
+	return r.exec("UPDATE mock_organisation SET reset_key='', reset_at=0 WHERE hash=?", hash)
+}
+
+func (r *SqliteDbResolver) CompleteKeyReset(info *ResolveInfoType) (bool, error) {
+	newSerial := strconv.FormatUint(uint64(r.TimeNow.UnixNano()), 10)
+
+	err := r.exec("UPDATE mock_organisation SET pubkey=reset_key, reset_key='', reset_at=0, admin_keys='', threshold=0, serial=? WHERE hash=? AND serial=? AND reset_key != ''", newSerial, info.Hash, info.Serial)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *SqliteDbResolver) SetAdminKeys(hash string, adminKeys []string, threshold int) error {
+	ak := ""
+	if len(adminKeys) > 0 {
+		b, err := json.Marshal(adminKeys)
+		if err != nil {
+			return err
+		}
+		ak = string(b)
+	}
+
+	return r.exec("UPDATE mock_organisation SET admin_keys=?, threshold=? WHERE hash=?", ak, threshold, hash)
+}
+
+// exec runs the given query and returns ErrNotFound when no rows are affected
+func (r *SqliteDbResolver) exec(query string, args ...interface{}) error {
+	res, err := r.conn.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
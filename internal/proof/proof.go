@@ -0,0 +1,256 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package proof
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"golang.org/x/crypto/argon2"
+)
+
+// Algorithms that can be used for proof-of-work
+const (
+	AlgorithmSHA256   = "sha256"   // Hashcash with double SHA256, as done by the bitmaelum-suite
+	AlgorithmArgon2id = "argon2id" // Memory-hard hashcash with argon2id
+)
+
+// Length of the argon2id output in bytes
+const argon2KeyLen = 32
+
+// maxBits is the highest number of bits a proof can have, as both algorithms produce a hash of 256 bits
+const maxBits = argon2KeyLen * 8
+
+var errFormat = errors.New("incorrect proof-of-work format")
+
+// Argon2Params are the cost parameters of an argon2id proof
+type Argon2Params struct {
+	Time    uint32 `json:"time"`    // Number of passes over the memory
+	Memory  uint32 `json:"memory"`  // Memory in KiB
+	Threads uint8  `json:"threads"` // Degree of parallelism
+}
+
+// String returns the parameters in the same notation as the PHC string format
+func (p Argon2Params) String() string {
+	return fmt.Sprintf("t=%d,m=%d,p=%d", p.Time, p.Memory, p.Threads)
+}
+
+// Validate returns an error when argon2id cannot be computed with the parameters
+func (p Argon2Params) Validate() error {
+	if p.Time < 1 {
+		return errors.New("argon2id time must be at least 1")
+	}
+	if p.Threads < 1 {
+		return errors.New("argon2id threads must be at least 1")
+	}
+	if p.Memory < 8*uint32(p.Threads) {
+		return errors.New("argon2id memory must be at least 8 KiB per thread")
+	}
+
+	return nil
+}
+
+func parseArgon2Params(s string) (Argon2Params, error) {
+	var p Argon2Params
+
+	n, err := fmt.Sscanf(s, "t=%d,m=%d,p=%d", &p.Time, &p.Memory, &p.Threads)
+	if err != nil || n != 3 || p.String() != s {
+		return p, errFormat
+	}
+
+	return p, nil
+}
+
+// Proof is a versioned proof-of-work. Proofs in the format of the bitmaelum-suite are SHA256 proofs, while other
+// algorithms prefix the proof with their name and parameters:
+//
+//	<bits>$<base64 data>$<nonce>
+//	argon2id$t=<time>,m=<memory>,p=<threads>$<bits>$<base64 data>$<nonce>
+type Proof struct {
+	Algorithm string
+	Params    Argon2Params // Only used by argon2id proofs
+	Bits      int
+	Data      string
+	Nonce     uint64
+}
+
+// FromProofOfWork returns a SHA256 proof from a bitmaelum-suite proof-of-work
+func FromProofOfWork(pow *proofofwork.ProofOfWork) *Proof {
+	return &Proof{
+		Algorithm: AlgorithmSHA256,
+		Bits:      pow.Bits,
+		Data:      pow.Data,
+		Nonce:     pow.Proof,
+	}
+}
+
+// NewArgon2id returns a new argon2id proof
+func NewArgon2id(params Argon2Params, bits int, data string, nonce uint64) *Proof {
+	return &Proof{
+		Algorithm: AlgorithmArgon2id,
+		Params:    params,
+		Bits:      bits,
+		Data:      data,
+		Nonce:     nonce,
+	}
+}
+
+// Parse parses a proof in any of the supported formats
+func Parse(s string) (*Proof, error) {
+	if !strings.HasPrefix(s, AlgorithmArgon2id+"$") {
+		pow, err := proofofwork.NewFromString(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if !validBits(pow.Bits) {
+			return nil, errFormat
+		}
+
+		return FromProofOfWork(pow), nil
+	}
+
+	parts := strings.Split(s, "$")
+	if len(parts) != 5 {
+		return nil, errFormat
+	}
+
+	params, err := parseArgon2Params(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	bits, err := strconv.Atoi(parts[2])
+	if err != nil || !validBits(bits) {
+		return nil, errFormat
+	}
+
+	data, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, errFormat
+	}
+
+	nonce, err := strconv.ParseUint(parts[4], 10, 64)
+	if err != nil {
+		return nil, errFormat
+	}
+
+	return NewArgon2id(params, bits, string(data), nonce), nil
+}
+
+// String returns the proof in its versioned format. SHA256 proofs keep the format of the bitmaelum-suite.
+func (p *Proof) String() string {
+	if p.Algorithm == AlgorithmArgon2id {
+		return fmt.Sprintf("%s$%s$%d$%s$%d", p.Algorithm, p.Params, p.Bits, base64.StdEncoding.EncodeToString([]byte(p.Data)), p.Nonce)
+	}
+
+	return p.proofOfWork().String()
+}
+
+// IsValid returns true when the nonce proves the work for the data. The parameters of argon2id proofs are not
+// checked, so they must be verified before calling this.
+func (p *Proof) IsValid() bool {
+	if !validBits(p.Bits) {
+		return false
+	}
+
+	switch p.Algorithm {
+	case AlgorithmSHA256:
+		return p.proofOfWork().IsValid()
+	case AlgorithmArgon2id:
+		return p.argon2Valid(p.Nonce)
+	}
+
+	return false
+}
+
+// Work finds the nonce for the proof
+func (p *Proof) Work() {
+	if p.Algorithm == AlgorithmSHA256 {
+		pow := p.proofOfWork()
+		pow.WorkMulticore()
+		p.Nonce = pow.Proof
+		return
+	}
+
+	var nonce uint64
+	for !p.argon2Valid(nonce) {
+		nonce++
+	}
+	p.Nonce = nonce
+}
+
+// MarshalJSON marshals the proof into its string format
+func (p *Proof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON unmarshals a proof from any of the supported formats
+func (p *Proof) UnmarshalJSON(b []byte) error {
+	var s string
+
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*p = *parsed
+	return nil
+}
+
+func (p *Proof) proofOfWork() *proofofwork.ProofOfWork {
+	return proofofwork.New(p.Bits, p.Data, p.Nonce)
+}
+
+// argon2Valid returns true when the argon2id hash of the nonce is below the target. The salt is derived from the
+// data, so every piece of data needs its own work.
+func (p *Proof) argon2Valid(nonce uint64) bool {
+	if p.Params.Time == 0 || p.Params.Threads == 0 || !validBits(p.Bits) {
+		return false
+	}
+
+	salt := sha256.Sum256([]byte(p.Data))
+	key := argon2.IDKey([]byte(strconv.FormatUint(nonce, 16)), salt[:], p.Params.Time, p.Params.Memory, p.Params.Threads, argon2KeyLen)
+
+	var hashInt big.Int
+	hashInt.SetBytes(key)
+
+	target := big.NewInt(1)
+	target = target.Lsh(target, uint(argon2KeyLen*8-p.Bits))
+
+	return hashInt.Cmp(target) == -1
+}
+
+// validBits returns true when a hash can have the number of leading zero bits
+func validBits(bits int) bool {
+	return bits >= 1 && bits <= maxBits
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package proof
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/proofofwork"
+	"github.com/stretchr/testify/assert"
+)
+
+var testParams = Argon2Params{Time: 1, Memory: 64, Threads: 1}
+
+func TestSHA256(t *testing.T) {
+	pow := proofofwork.New(22, "2244643da7475120bf84d744435d15ea297c36ca165ea0baaa69ec818d0e952f", 1540921)
+
+	p, err := Parse(pow.String())
+	assert.NoError(t, err)
+	assert.Equal(t, AlgorithmSHA256, p.Algorithm)
+	assert.Equal(t, 22, p.Bits)
+	assert.Equal(t, uint64(1540921), p.Nonce)
+	assert.Equal(t, pow.String(), p.String())
+	assert.Equal(t, pow.IsValid(), p.IsValid())
+
+	p = FromProofOfWork(proofofwork.New(8, "foobar", 0))
+	p.Work()
+	assert.True(t, p.IsValid())
+	p.Nonce++
+	assert.False(t, p.IsValid())
+
+	p.Bits = 257
+	assert.False(t, p.IsValid())
+}
+
+func TestArgon2id(t *testing.T) {
+	p := NewArgon2id(testParams, 4, "foobar", 0)
+	p.Work()
+	assert.True(t, p.IsValid())
+	assert.Equal(t, "argon2id$t=1,m=64,p=1$4$Zm9vYmFy$"+strconv.FormatUint(p.Nonce, 10), p.String())
+
+	p2, err := Parse(p.String())
+	assert.NoError(t, err)
+	assert.Equal(t, p, p2)
+
+	// Not enough work for more bits
+	p2.Bits = 255
+	assert.False(t, p2.IsValid())
+
+	p2.Bits = 256
+	assert.False(t, p2.IsValid())
+
+	// Out of range bits are invalid instead of panicking
+	for _, bits := range []int{-1, 0, 257, 1000000} {
+		p2.Bits = bits
+		assert.False(t, p2.IsValid(), bits)
+	}
+
+	p3 := *p
+	p3.Params.Threads = 0
+	assert.False(t, p3.IsValid())
+}
+
+func TestArgon2ParamsValidate(t *testing.T) {
+	assert.NoError(t, testParams.Validate())
+	assert.Error(t, Argon2Params{Time: 0, Memory: 64, Threads: 1}.Validate())
+	assert.Error(t, Argon2Params{Time: 1, Memory: 64, Threads: 0}.Validate())
+	assert.Error(t, Argon2Params{Time: 1, Memory: 16, Threads: 4}.Validate())
+}
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"foobar",
+		"argon2id$t=1,m=64,p=1$4$Zm9vYmFy",
+		"argon2id$t=1,m=64$4$Zm9vYmFy$1",
+		"argon2id$t=1,m=64,p=1,x=1$4$Zm9vYmFy$1",
+		"argon2id$t=1,m=64,p=1$x$Zm9vYmFy$1",
+		"argon2id$t=1,m=64,p=1$4$!!!$1",
+		"argon2id$t=1,m=64,p=1$4$Zm9vYmFy$-1",
+		"argon2id$t=1,m=64,p=1$0$Zm9vYmFy$1",
+		"argon2id$t=1,m=64,p=1$-4$Zm9vYmFy$1",
+		"argon2id$t=1,m=64,p=1$257$Zm9vYmFy$1",
+		"argon2id$t=1,m=64,p=1$1000000$Zm9vYmFy$1",
+		"0$Zm9vYmFy$1",
+		"257$Zm9vYmFy$1",
+	} {
+		_, err := Parse(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type body struct {
+		Proof *Proof `json:"proof"`
+	}
+
+	b, err := json.Marshal(body{Proof: NewArgon2id(testParams, 4, "foobar", 12)})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"proof": "argon2id$t=1,m=64,p=1$4$Zm9vYmFy$12"}`, string(b))
+
+	out := &body{}
+	assert.NoError(t, json.Unmarshal(b, out))
+	assert.Equal(t, AlgorithmArgon2id, out.Proof.Algorithm)
+	assert.Equal(t, uint64(12), out.Proof.Nonce)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"proof": "22$Zm9vYmFy$12"}`), out))
+	assert.Equal(t, AlgorithmSHA256, out.Proof.Algorithm)
+	assert.Equal(t, "foobar", out.Proof.Data)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"proof": "foo"}`), out))
+	assert.Error(t, json.Unmarshal([]byte(`{"proof": 12}`), out))
+}
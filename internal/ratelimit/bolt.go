@@ -0,0 +1,101 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package ratelimit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/bitmaelum/key-resolver-go/internal"
+	bolt "go.etcd.io/bbolt"
+)
+
+type boltRepository struct {
+	client     *bolt.DB
+	bucketName []byte
+	lastSweep  time.Time
+}
+
+// NewBoltRepository returns a new repository that keeps the token buckets in BoltDB, so they are shared by every
+// process using the same database file
+func NewBoltRepository() Repository {
+	return &boltRepository{
+		client:     internal.GetBoltDb(),
+		bucketName: []byte("rate_limits"),
+	}
+}
+
+func (b *boltRepository) Update(key string, now time.Time, f func(b *Bucket)) error {
+	return b.client.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.bucketName)
+		if err != nil {
+			return err
+		}
+
+		if now.Sub(b.lastSweep) >= sweepInterval {
+			err = b.sweep(bucket, now)
+			if err != nil {
+				return err
+			}
+			b.lastSweep = now
+		}
+
+		tb := Bucket{}
+		if data := bucket.Get([]byte(key)); data != nil {
+			err = json.Unmarshal(data, &tb)
+			if err != nil || tb.IsExpired(now) {
+				tb = Bucket{}
+			}
+		}
+
+		f(&tb)
+
+		data, err := json.Marshal(tb)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(key), data)
+	})
+}
+
+// sweep removes all expired buckets
+func (b *boltRepository) sweep(bucket *bolt.Bucket, now time.Time) error {
+	var expired [][]byte
+
+	err := bucket.ForEach(func(k, data []byte) error {
+		tb := Bucket{}
+		if json.Unmarshal(data, &tb) != nil || tb.IsExpired(now) {
+			expired = append(expired, append([]byte{}, k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range expired {
+		err = bucket.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
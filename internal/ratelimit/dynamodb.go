@@ -0,0 +1,137 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package ratelimit
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// The buckets are stored in a single table with "key" as partition key. The "expires" attribute can be used as TTL
+// attribute, so DynamoDB removes buckets that are full again.
+
+// Number of times an update is retried when another request changed the bucket at the same time
+const maxAttempts = 5
+
+var errConflict = errors.New("rate limit bucket changed concurrently")
+
+type dynamoDbRepository struct {
+	Dyna      dynamodbiface.DynamoDBAPI
+	TableName string
+}
+
+// Record in dynamodb
+type dynamoBucketRecord struct {
+	Key     string  `dynamodbav:"key"`
+	Tokens  float64 `dynamodbav:"tokens"`
+	Updated int64   `dynamodbav:"updated"`
+	Expires int64   `dynamodbav:"expires"`
+}
+
+// NewDynamoDBRepository returns a new repository that keeps the token buckets in DynamoDB, so they are shared by all
+// lambda instances
+func NewDynamoDBRepository(client dynamodbiface.DynamoDBAPI, tableName string) Repository {
+	return &dynamoDbRepository{
+		Dyna:      client,
+		TableName: tableName,
+	}
+}
+
+// Update does an optimistic update: the bucket is only written when it has not been changed since it was read
+func (r *dynamoDbRepository) Update(key string, now time.Time, f func(b *Bucket)) error {
+	for i := 0; i < maxAttempts; i++ {
+		out, err := r.Dyna.GetItem(&dynamodb.GetItemInput{
+			TableName:      aws.String(r.TableName),
+			ConsistentRead: aws.Bool(true),
+			Key: map[string]*dynamodb.AttributeValue{
+				"key": {S: aws.String(key)},
+			},
+		})
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+
+		record := &dynamoBucketRecord{}
+		if out.Item != nil {
+			err = dynamodbattribute.UnmarshalMap(out.Item, record)
+			if err != nil {
+				return err
+			}
+		}
+
+		b := Bucket{}
+		if out.Item != nil {
+			b = Bucket{Tokens: record.Tokens, Updated: record.Updated, Expires: record.Expires}
+		}
+		if b.IsExpired(now) {
+			b = Bucket{}
+		}
+
+		f(&b)
+
+		av, err := dynamodbattribute.MarshalMap(dynamoBucketRecord{
+			Key:     key,
+			Tokens:  b.Tokens,
+			Updated: b.Updated,
+			Expires: b.Expires,
+		})
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+
+		input := &dynamodb.PutItemInput{
+			TableName:           aws.String(r.TableName),
+			Item:                av,
+			ConditionExpression: aws.String("attribute_not_exists(#k)"),
+			ExpressionAttributeNames: map[string]*string{
+				"#k": aws.String("key"),
+			},
+		}
+		if out.Item != nil {
+			input.ConditionExpression = aws.String("updated = :updated")
+			input.ExpressionAttributeNames = nil
+			input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+				":updated": {N: aws.String(strconv.FormatInt(record.Updated, 10))},
+			}
+		}
+
+		_, err = r.Dyna.PutItem(input)
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			continue
+		}
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+
+		return nil
+	}
+
+	return errConflict
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	dynamock "github.com/gusaul/go-dynamock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBRepository(t *testing.T) {
+	client, mock := dynamock.New()
+	repo := NewDynamoDBRepository(client, "mock_ratelimit_table")
+
+	now := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+	key := map[string]*dynamodb.AttributeValue{
+		"key": {S: aws.String("key1")},
+	}
+	item := map[string]*dynamodb.AttributeValue{
+		"key":     {S: aws.String("key1")},
+		"tokens":  {N: aws.String("2")},
+		"updated": {N: aws.String("1270643696000000000")},
+		"expires": {N: aws.String("1270643756")},
+	}
+
+	mock.ExpectGetItem().ToTable("mock_ratelimit_table").WithKeys(key).WillReturns(dynamodb.GetItemOutput{})
+	mock.ExpectPutItem().ToTable("mock_ratelimit_table").WithItems(item).WillReturns(dynamodb.PutItemOutput{})
+	err := repo.Update("key1", now, func(b *Bucket) {
+		assert.Equal(t, Bucket{}, *b)
+		b.Tokens = 2
+		b.Updated = now.UnixNano()
+		b.Expires = now.Add(time.Minute).Unix()
+	})
+	assert.NoError(t, err)
+
+	mock.ExpectGetItem().ToTable("mock_ratelimit_table").WithKeys(key).WillReturns(dynamodb.GetItemOutput{Item: item})
+	mock.ExpectPutItem().ToTable("mock_ratelimit_table").WillReturns(dynamodb.PutItemOutput{})
+	err = repo.Update("key1", now, func(b *Bucket) {
+		assert.Equal(t, Bucket{Tokens: 2, Updated: now.UnixNano(), Expires: now.Add(time.Minute).Unix()}, *b)
+		b.Tokens = 1
+	})
+	assert.NoError(t, err)
+
+	// Expired buckets are passed as zero bucket
+	mock.ExpectGetItem().ToTable("mock_ratelimit_table").WithKeys(key).WillReturns(dynamodb.GetItemOutput{Item: item})
+	mock.ExpectPutItem().ToTable("mock_ratelimit_table").WillReturns(dynamodb.PutItemOutput{})
+	err = repo.Update("key1", now.Add(2*time.Minute), func(b *Bucket) {
+		assert.Equal(t, Bucket{}, *b)
+	})
+	assert.NoError(t, err)
+
+	// Errors are passed on
+	err = repo.Update("key1", now, func(b *Bucket) {})
+	assert.Error(t, err)
+}
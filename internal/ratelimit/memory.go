@@ -0,0 +1,65 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Expired buckets are removed at most once per sweepInterval
+const sweepInterval = time.Minute
+
+type memoryRepository struct {
+	mu        sync.Mutex
+	buckets   map[string]Bucket
+	lastSweep time.Time
+}
+
+// NewMemoryRepository returns a new repository that keeps the token buckets in memory. This only limits requests
+// handled by the current process.
+func NewMemoryRepository() Repository {
+	return &memoryRepository{
+		buckets: make(map[string]Bucket),
+	}
+}
+
+func (r *memoryRepository) Update(key string, now time.Time, f func(b *Bucket)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.lastSweep) >= sweepInterval {
+		for k, b := range r.buckets {
+			if b.IsExpired(now) {
+				delete(r.buckets, k)
+			}
+		}
+		r.lastSweep = now
+	}
+
+	b := r.buckets[key]
+	if b.IsExpired(now) {
+		b = Bucket{}
+	}
+
+	f(&b)
+	r.buckets[key] = b
+
+	return nil
+}
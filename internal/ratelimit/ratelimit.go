@@ -0,0 +1,201 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Classes of requests, which each have their own budget
+const (
+	ClassRead       = "read"  // Requests that only read data
+	ClassWrite      = "write" // Requests that create, change or delete data
+	ClassFailedAuth = "auth"  // Requests that failed authentication
+)
+
+// Limit is the budget of a token bucket. The bucket holds at most Burst tokens, and is refilled with Rate tokens per
+// second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses a limit in the form "<count>/<duration>", like "100/1m". This allows bursts of count requests, and
+// refills the bucket with count tokens per duration. An empty string or "0" returns a disabled limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, errors.New("rate limit must be in the form <count>/<duration>: " + s)
+	}
+
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count < 0 {
+		return Limit{}, errors.New("invalid rate limit count: " + s)
+	}
+
+	d, err := time.ParseDuration(parts[1])
+	if err != nil || d <= 0 {
+		return Limit{}, errors.New("invalid rate limit duration: " + s)
+	}
+
+	return Limit{
+		Rate:  float64(count) / d.Seconds(),
+		Burst: count,
+	}, nil
+}
+
+// Enabled returns true when requests are limited
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// wait returns the time it takes to refill the given number of tokens
+func (l Limit) wait(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+
+	return time.Duration(math.Ceil(tokens / l.Rate * float64(time.Second)))
+}
+
+// Budgets are the limits for every class of requests
+type Budgets struct {
+	Read       Limit `json:"read"`
+	Write      Limit `json:"write"`
+	FailedAuth Limit `json:"failed_auth"`
+}
+
+// ParseBudgets parses the limits of all classes, see ParseLimit
+func ParseBudgets(read, write, failedAuth string) (Budgets, error) {
+	var b Budgets
+	var err error
+
+	if b.Read, err = ParseLimit(read); err != nil {
+		return b, err
+	}
+	if b.Write, err = ParseLimit(write); err != nil {
+		return b, err
+	}
+	if b.FailedAuth, err = ParseLimit(failedAuth); err != nil {
+		return b, err
+	}
+
+	return b, nil
+}
+
+// Enabled returns true when any class is limited
+func (b Budgets) Enabled() bool {
+	return b.Read.Enabled() || b.Write.Enabled() || b.FailedAuth.Enabled()
+}
+
+// Limit returns the limit for the class
+func (b Budgets) Limit(class string) Limit {
+	switch class {
+	case ClassRead:
+		return b.Read
+	case ClassWrite:
+		return b.Write
+	case ClassFailedAuth:
+		return b.FailedAuth
+	}
+
+	return Limit{}
+}
+
+// Config holds the budgets per client and per target hash. Requests must fit in both.
+type Config struct {
+	Client Budgets
+	Hash   Budgets
+}
+
+// Enabled returns true when any request is limited
+func (c Config) Enabled() bool {
+	return c.Client.Enabled() || c.Hash.Enabled()
+}
+
+// Bucket is the stored state of a token bucket. A zero bucket is full.
+type Bucket struct {
+	Tokens  float64 `json:"tokens"`
+	Updated int64   `json:"updated"` // Unix time in nanoseconds of the last refill
+	Expires int64   `json:"expires"` // Unix time in seconds after which the bucket is full again and can be removed
+}
+
+// IsExpired returns true when the bucket is full again at the given time
+func (b Bucket) IsExpired(now time.Time) bool {
+	return b.Expires > 0 && b.Expires <= now.Unix()
+}
+
+func (b *Bucket) refill(l Limit, now time.Time) {
+	if b.Updated == 0 {
+		b.Tokens = float64(l.Burst)
+	} else {
+		b.Tokens += now.Sub(time.Unix(0, b.Updated)).Seconds() * l.Rate
+		if b.Tokens > float64(l.Burst) {
+			b.Tokens = float64(l.Burst)
+		}
+	}
+
+	b.Updated = now.UnixNano()
+}
+
+// Take takes a token from the bucket with the given key. It returns zero when the token was taken, or the time until
+// a token is available otherwise.
+func Take(repo Repository, key string, l Limit, now time.Time) (time.Duration, error) {
+	return take(repo, key, l, 1, now)
+}
+
+// TakeN takes n tokens from the bucket with the given key. The tokens are taken as long as the bucket holds at least
+// one, so requests that cost more than the burst are still possible. The bucket then stays empty for longer.
+func TakeN(repo Repository, key string, l Limit, n float64, now time.Time) (time.Duration, error) {
+	return take(repo, key, l, n, now)
+}
+
+// Check returns the time until the bucket with the given key holds a token, without taking one
+func Check(repo Repository, key string, l Limit, now time.Time) (time.Duration, error) {
+	return take(repo, key, l, 0, now)
+}
+
+func take(repo Repository, key string, l Limit, cost float64, now time.Time) (time.Duration, error) {
+	if !l.Enabled() {
+		return 0, nil
+	}
+
+	var wait time.Duration
+	err := repo.Update(key, now, func(b *Bucket) {
+		b.refill(l, now)
+
+		if b.Tokens < 1 {
+			wait = l.wait(1 - b.Tokens)
+		} else {
+			b.Tokens -= cost
+		}
+
+		b.Expires = now.Add(l.wait(float64(l.Burst) - b.Tokens)).Add(time.Second).Unix()
+	})
+
+	return wait, err
+}
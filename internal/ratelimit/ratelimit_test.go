@@ -0,0 +1,155 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	l, err := ParseLimit("100/1m")
+	assert.NoError(t, err)
+	assert.Equal(t, 100, l.Burst)
+	assert.InDelta(t, 100.0/60, l.Rate, 0.0001)
+	assert.True(t, l.Enabled())
+
+	l, err = ParseLimit("")
+	assert.NoError(t, err)
+	assert.False(t, l.Enabled())
+
+	l, err = ParseLimit("0")
+	assert.NoError(t, err)
+	assert.False(t, l.Enabled())
+
+	_, err = ParseLimit("100")
+	assert.Error(t, err)
+	_, err = ParseLimit("foo/1m")
+	assert.Error(t, err)
+	_, err = ParseLimit("100/foo")
+	assert.Error(t, err)
+	_, err = ParseLimit("100/0s")
+	assert.Error(t, err)
+}
+
+func TestTake(t *testing.T) {
+	repo := NewMemoryRepository()
+	now := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+	l := Limit{Rate: 1, Burst: 3}
+
+	// Full bucket allows a burst
+	for i := 0; i < 3; i++ {
+		wait, err := Take(repo, "client", l, now)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(0), wait)
+	}
+
+	wait, err := Take(repo, "client", l, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, wait)
+
+	wait, err = Check(repo, "client", l, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, wait)
+
+	// Other keys have their own bucket
+	wait, err = Take(repo, "other", l, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+
+	// Half a token has been refilled
+	now = now.Add(500 * time.Millisecond)
+	wait, err = Take(repo, "client", l, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// Checking does not take the token
+	now = now.Add(500 * time.Millisecond)
+	wait, err = Check(repo, "client", l, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+	wait, err = Take(repo, "client", l, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+	wait, err = Take(repo, "client", l, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, wait)
+
+	// The bucket never holds more than the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		wait, err = Take(repo, "client", l, now)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(0), wait)
+	}
+	wait, err = Take(repo, "client", l, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, wait)
+
+	// Disabled limits allow everything
+	wait, err = Take(repo, "client", Limit{}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+}
+
+func TestTakeN(t *testing.T) {
+	repo := NewMemoryRepository()
+	now := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+	l := Limit{Rate: 1, Burst: 3}
+
+	wait, err := TakeN(repo, "client", l, 2, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+
+	// More than the remaining tokens can be taken, after which the bucket needs longer to refill
+	wait, err = TakeN(repo, "client", l, 5, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+
+	wait, err = Take(repo, "client", l, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, wait)
+}
+
+func TestBudgets(t *testing.T) {
+	b := Budgets{
+		Read:       Limit{Rate: 1, Burst: 1},
+		Write:      Limit{Rate: 2, Burst: 2},
+		FailedAuth: Limit{Rate: 3, Burst: 3},
+	}
+
+	assert.Equal(t, 1, b.Limit(ClassRead).Burst)
+	assert.Equal(t, 2, b.Limit(ClassWrite).Burst)
+	assert.Equal(t, 3, b.Limit(ClassFailedAuth).Burst)
+	assert.False(t, b.Limit("foo").Enabled())
+
+	b, err := ParseBudgets("10/1s", "", "5/1h")
+	assert.NoError(t, err)
+	assert.Equal(t, Limit{Rate: 10, Burst: 10}, b.Read)
+	assert.False(t, b.Write.Enabled())
+	assert.Equal(t, 5, b.FailedAuth.Burst)
+	assert.True(t, b.Enabled())
+	assert.True(t, Config{Hash: b}.Enabled())
+	assert.False(t, Config{}.Enabled())
+
+	_, err = ParseBudgets("", "foo", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runRepositoryTests(t *testing.T, repo Repository) {
+	now := time.Date(2010, 04, 07, 12, 34, 56, 0, time.UTC)
+
+	// Missing buckets are passed as zero bucket
+	err := repo.Update("key1", now, func(b *Bucket) {
+		assert.Equal(t, Bucket{}, *b)
+		b.Tokens = 2
+		b.Updated = now.UnixNano()
+		b.Expires = now.Add(time.Minute).Unix()
+	})
+	assert.NoError(t, err)
+
+	err = repo.Update("key1", now, func(b *Bucket) {
+		assert.Equal(t, Bucket{Tokens: 2, Updated: now.UnixNano(), Expires: now.Add(time.Minute).Unix()}, *b)
+		b.Tokens = 1
+	})
+	assert.NoError(t, err)
+
+	err = repo.Update("key2", now, func(b *Bucket) {
+		assert.Equal(t, Bucket{}, *b)
+	})
+	assert.NoError(t, err)
+
+	// Expired buckets are passed as zero bucket
+	now = now.Add(2 * time.Minute)
+	err = repo.Update("key1", now, func(b *Bucket) {
+		assert.Equal(t, Bucket{}, *b)
+	})
+	assert.NoError(t, err)
+}
+
+func TestMemoryRepository(t *testing.T) {
+	runRepositoryTests(t, NewMemoryRepository())
+}
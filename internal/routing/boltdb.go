@@ -64,7 +64,39 @@ func (b boltResolver) Get(hash string) (*ResolveInfoType, error) {
 	return rec, nil
 }
 
-func (b boltResolver) Create(hash, routing, publicKey string) (bool, error) {
+func (b boltResolver) GetMany(hashes []string) (map[string]*ResolveInfoType, error) {
+	recs := make(map[string]*ResolveInfoType)
+
+	err := b.client.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(b.bucketName))
+		if bucket == nil {
+			return nil
+		}
+
+		for _, hash := range hashes {
+			data := bucket.Get([]byte(hash))
+			if data == nil {
+				continue
+			}
+
+			rec := &ResolveInfoType{}
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return err
+			}
+			recs[hash] = rec
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return recs, nil
+}
+
+func (b boltResolver) Create(hash, routing, publicKey, proof string) (bool, error) {
 	err := b.client.Update(func(tx *bolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists([]byte(b.bucketName))
 		if err != nil {
@@ -75,6 +107,7 @@ func (b boltResolver) Create(hash, routing, publicKey string) (bool, error) {
 			Hash:    hash,
 			Routing: routing,
 			PubKey:  publicKey,
+			Proof:   proof,
 			Serial:  uint64(time.Now().UnixNano()),
 		}
 		buf, err := json.Marshal(rec)
@@ -93,7 +126,8 @@ func (b boltResolver) Create(hash, routing, publicKey string) (bool, error) {
 }
 
 func (b boltResolver) Update(info *ResolveInfoType, routing, publicKey string) (bool, error) {
-	return b.Create(info.Hash, routing, publicKey)
+	// The proof of work is only given on creation, and is kept on updates
+	return b.Create(info.Hash, routing, publicKey, info.Proof)
 }
 
 func (b boltResolver) Delete(hash string) (bool, error) {
@@ -0,0 +1,71 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package routing
+
+import (
+	"github.com/bitmaelum/key-resolver-go/internal/changefeed"
+	"github.com/bitmaelum/key-resolver-go/internal/translog"
+)
+
+// changeRecorder adds every change that is made through the repository to the change feed
+type changeRecorder struct {
+	Repository
+}
+
+// withChangeFeed returns the repository with every change made through it recorded in the change feed
+func withChangeFeed(r Repository) Repository {
+	return &changeRecorder{Repository: r}
+}
+
+func (c *changeRecorder) Create(hash, routing, publicKey, proof string) (bool, error) {
+	ok, err := c.Repository.Create(hash, routing, publicKey, proof)
+	if ok && err == nil {
+		c.record(translog.ActionCreate, hash)
+	}
+	return ok, err
+}
+
+func (c *changeRecorder) Update(info *ResolveInfoType, routing, publicKey string) (bool, error) {
+	ok, err := c.Repository.Update(info, routing, publicKey)
+	if ok && err == nil {
+		c.record(translog.ActionUpdate, info.Hash)
+	}
+	return ok, err
+}
+
+func (c *changeRecorder) Delete(hash string) (bool, error) {
+	ok, err := c.Repository.Delete(hash)
+	if ok && err == nil {
+		c.record(translog.ActionDelete, hash)
+	}
+	return ok, err
+}
+
+// record adds the change to the feed together with the serial the record has after the change, or 0 when the record
+// does not exist anymore
+func (c *changeRecorder) record(action, hash string) {
+	var serial uint64
+	info, err := c.Repository.Get(hash)
+	if err == nil && info != nil {
+		serial = info.Serial
+	}
+
+	changefeed.Record(translog.TypeRouting, action, hash, serial)
+}
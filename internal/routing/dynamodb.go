@@ -35,6 +35,9 @@ type dynamoDbResolver struct {
 	TableName string
 }
 
+// maxBatchGetItems is the maximum number of keys DynamoDB accepts in a single BatchGetItem call
+const maxBatchGetItems = 100
+
 // ErrNotFound will be returned when a record we are looking for is not found in the db
 var ErrNotFound = errors.New("record not found")
 
@@ -43,6 +46,7 @@ type Record struct {
 	Hash      string `dynamodbav:"hash"`
 	Routing   string `dynamodbav:"routing"`
 	PublicKey string `dynamodbav:"public_key"`
+	Proof     string `dynamodbav:"proof"`
 	Serial    uint64 `dynamodbav:"sn"`
 }
 
@@ -81,11 +85,12 @@ func (r *dynamoDbResolver) Update(info *ResolveInfoType, routing, publicKey stri
 	return true, nil
 }
 
-func (r *dynamoDbResolver) Create(hash, routing, publicKey string) (bool, error) {
+func (r *dynamoDbResolver) Create(hash, routing, publicKey, proof string) (bool, error) {
 	record := Record{
 		Hash:      hash,
 		Routing:   routing,
 		PublicKey: publicKey,
+		Proof:     proof,
 		Serial:    uint64(time.Now().UnixNano()),
 	}
 
@@ -133,10 +138,76 @@ func (r *dynamoDbResolver) Get(hash string) (*ResolveInfoType, error) {
 		Hash:    record.Hash,
 		Routing: record.Routing,
 		PubKey:  record.PublicKey,
+		Proof:   record.Proof,
 		Serial:  record.Serial,
 	}, nil
 }
 
+func (r *dynamoDbResolver) GetMany(hashes []string) (map[string]*ResolveInfoType, error) {
+	infos := make(map[string]*ResolveInfoType)
+
+	// BatchGetItem does not allow duplicate keys
+	var unique []string
+	seen := make(map[string]bool)
+	for _, hash := range hashes {
+		if !seen[hash] {
+			seen[hash] = true
+			unique = append(unique, hash)
+		}
+	}
+
+	// A single BatchGetItem call can fetch at most maxBatchGetItems keys
+	for start := 0; start < len(unique); start += maxBatchGetItems {
+		end := start + maxBatchGetItems
+		if end > len(unique) {
+			end = len(unique)
+		}
+
+		keys := make([]map[string]*dynamodb.AttributeValue, 0, end-start)
+		for _, hash := range unique[start:end] {
+			keys = append(keys, map[string]*dynamodb.AttributeValue{
+				"hash": {S: aws.String(hash)},
+			})
+		}
+
+		request := map[string]*dynamodb.KeysAndAttributes{
+			r.TableName: {Keys: keys},
+		}
+
+		// Keys that could not be processed (due to throttling or size limits) are returned and must be requested again
+		for len(request) > 0 {
+			result, err := r.C.BatchGetItem(&dynamodb.BatchGetItemInput{
+				RequestItems: request,
+			})
+			if err != nil {
+				log.Print(err)
+				return nil, err
+			}
+
+			for _, item := range result.Responses[r.TableName] {
+				record := Record{}
+				err = dynamodbattribute.UnmarshalMap(item, &record)
+				if err != nil {
+					log.Print(err)
+					continue
+				}
+
+				infos[record.Hash] = &ResolveInfoType{
+					Hash:    record.Hash,
+					Routing: record.Routing,
+					PubKey:  record.PublicKey,
+					Proof:   record.Proof,
+					Serial:  record.Serial,
+				}
+			}
+
+			request = result.UnprocessedKeys
+		}
+	}
+
+	return infos, nil
+}
+
 func (r *dynamoDbResolver) Delete(hash string) (bool, error) {
 	_, err := r.C.DeleteItem(&dynamodb.DeleteItemInput{
 		TableName: aws.String(r.TableName),
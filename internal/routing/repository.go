@@ -31,36 +31,41 @@ type ResolveInfoType struct {
 	Hash    string
 	Routing string
 	PubKey  string
+	Proof   string
 	Serial  uint64
 }
 
 // Repository to resolve records
 type Repository interface {
 	Get(hash string) (*ResolveInfoType, error)
-	Create(hash, routing, publicKey string) (bool, error)
+	GetMany(hashes []string) (map[string]*ResolveInfoType, error)
+	Create(hash, routing, publicKey, proof string) (bool, error)
 	Update(info *ResolveInfoType, routing, publicKey string) (bool, error)
 	Delete(hash string) (bool, error)
 }
 
 var resolver Repository
 
-// GetResolveRepository returns a new repository based on DynamoDB
+// GetResolveRepository returns a new repository based on DynamoDB. Every change made through the repository is
+// recorded in the change feed.
 func GetResolveRepository() Repository {
-	if resolver != nil {
-		return resolver
+	if resolver == nil {
+		resolver = newResolveRepository()
 	}
 
+	return withChangeFeed(resolver)
+}
+
+func newResolveRepository() Repository {
 	if os.Getenv("USE_BOLT") == "1" {
-		resolver = NewBoltResolver()
-		return resolver
+		return NewBoltResolver()
 	}
 
 	sess := session.Must(session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable,
 	}))
 
-	resolver = NewDynamoDBResolver(dynamodb.New(sess), os.Getenv("ROUTING_TABLE_NAME"))
-	return resolver
+	return NewDynamoDBResolver(dynamodb.New(sess), os.Getenv("ROUTING_TABLE_NAME"))
 }
 
 // Sets the default repository for resolving. Can be used to override for mocking/testing purposes
@@ -57,7 +57,7 @@ func NewSqliteResolver(dsn string) *SqliteDbResolver {
 		TimeNow: time.Now(),
 	}
 
-	_, _ = db.conn.Exec("CREATE TABLE IF NOT EXISTS mock_routing (routing_id VARCHAR(64) PRIMARY KEY, pubkey TEXT, routing TEXT, serial INTEGER)")
+	_, _ = db.conn.Exec("CREATE TABLE IF NOT EXISTS mock_routing (routing_id VARCHAR(64) PRIMARY KEY, pubkey TEXT, routing TEXT, serial INTEGER, proof TEXT)")
 	return db
 }
 
@@ -78,10 +78,10 @@ func (r *SqliteDbResolver) Update(info *ResolveInfoType, routing, publicKey stri
 	return count != 0, err
 }
 
-func (r *SqliteDbResolver) Create(hash, routing, publicKey string) (bool, error) {
+func (r *SqliteDbResolver) Create(hash, routing, publicKey, proof string) (bool, error) {
 	newSerial := strconv.FormatUint(uint64(r.TimeNow.UnixNano()), 10)
 
-	res, err := r.conn.Exec("INSERT INTO mock_routing VALUES (?, ?, ?, ?)", hash, publicKey, routing, newSerial)
+	res, err := r.conn.Exec("INSERT INTO mock_routing VALUES (?, ?, ?, ?, ?)", hash, publicKey, routing, newSerial, proof)
 	if err != nil {
 		return false, err
 	}
@@ -96,9 +96,10 @@ func (r *SqliteDbResolver) Get(hash string) (*ResolveInfoType, error) {
 		pk string
 		rt string
 		sn uint64
+		pr string
 	)
 
-	err := r.conn.QueryRow("SELECT routing_id, pubkey, routing, serial FROM mock_routing WHERE routing_id LIKE ?", hash).Scan(&h, &pk, &rt, &sn)
+	err := r.conn.QueryRow("SELECT routing_id, pubkey, routing, serial, proof FROM mock_routing WHERE routing_id LIKE ?", hash).Scan(&h, &pk, &rt, &sn, &pr)
 	if err != nil {
 		return nil, ErrNotFound
 	}
@@ -107,10 +108,43 @@ func (r *SqliteDbResolver) Get(hash string) (*ResolveInfoType, error) {
 		Hash:    h,
 		PubKey:  pk,
 		Routing: rt,
+		Proof:   pr,
 		Serial:  sn,
 	}, nil
 }
 
+func (r *SqliteDbResolver) GetMany(hashes []string) (map[string]*ResolveInfoType, error) {
+	infos := make(map[string]*ResolveInfoType)
+	if len(hashes) == 0 {
+		return infos, nil
+	}
+
+	args := make([]interface{}, len(hashes))
+	for i := range hashes {
+		args[i] = hashes[i]
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(hashes)), ", ")
+
+	rows, err := r.conn.Query("SELECT routing_id, pubkey, routing, serial, proof FROM mock_routing WHERE routing_id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		info := &ResolveInfoType{}
+		err = rows.Scan(&info.Hash, &info.PubKey, &info.Routing, &info.Serial, &info.Proof)
+		if err != nil {
+			return nil, err
+		}
+		infos[info.Hash] = info
+	}
+
+	return infos, rows.Err()
+}
+
 func (r *SqliteDbResolver) Delete(hash string) (bool, error) {
 	res, err := r.conn.Exec("DELETE FROM mock_routing WHERE routing_id LIKE ?", hash)
 	if err != nil {
@@ -0,0 +1,119 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package translog
+
+import (
+	"encoding/binary"
+
+	"github.com/bitmaelum/key-resolver-go/internal"
+	bolt "go.etcd.io/bbolt"
+)
+
+type boltRepository struct {
+	client     *bolt.DB
+	bucketName []byte
+}
+
+// NewBoltRepository returns a new log repository based on BoltDB
+func NewBoltRepository() Repository {
+	return &boltRepository{
+		client:     internal.GetBoltDb(),
+		bucketName: []byte("translog"),
+	}
+}
+
+func (b boltRepository) Append(data []byte) (uint64, error) {
+	var index uint64
+
+	err := b.client.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.bucketName)
+		if err != nil {
+			return err
+		}
+
+		// Sequences start at 1, our indices at 0
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		index = seq - 1
+
+		return bucket.Put(indexKey(index), data)
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return index, nil
+}
+
+func (b boltRepository) Size() (uint64, error) {
+	var size uint64
+
+	err := b.client.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil {
+			return nil
+		}
+
+		size = bucket.Sequence()
+		return nil
+	})
+
+	return size, err
+}
+
+func (b boltRepository) Get(start, end uint64) ([][]byte, error) {
+	if start > end {
+		return nil, ErrInvalidRange
+	}
+
+	var ret [][]byte
+
+	err := b.client.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil || end >= bucket.Sequence() {
+			return ErrInvalidRange
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Seek(indexKey(start)); k != nil && binary.BigEndian.Uint64(k) <= end; k, v = c.Next() {
+			// Bolt only guarantees the data during the transaction, so copy it
+			data := make([]byte, len(v))
+			copy(data, v)
+			ret = append(ret, data)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func indexKey(index uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, index)
+	return k
+}
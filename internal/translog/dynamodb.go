@@ -0,0 +1,186 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package translog
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// The log is stored in a single table with "log" as partition key and "idx" as sort key. The entries are stored in
+// the "entries" partition, and the number of entries is kept in a counter item in the "size" partition.
+const (
+	entriesPartition = "entries"
+	sizePartition    = "size"
+)
+
+type dynamoDbRepository struct {
+	Dyna      dynamodbiface.DynamoDBAPI
+	TableName string
+}
+
+// NewDynamoDBRepository returns a new log repository based on DynamoDB
+func NewDynamoDBRepository(client dynamodbiface.DynamoDBAPI, tableName string) Repository {
+	return &dynamoDbRepository{
+		Dyna:      client,
+		TableName: tableName,
+	}
+}
+
+// maxAttempts is the number of times an append is tried when other appends reserve the same index
+const maxAttempts = 5
+
+var errConflict = errors.New("log index reserved concurrently")
+
+func (r *dynamoDbRepository) Append(data []byte) (uint64, error) {
+	for i := 0; i < maxAttempts; i++ {
+		index, err := r.Size()
+		if err != nil {
+			return 0, err
+		}
+
+		// The counter and the entry are written in a single transaction, so a failed write never leaves a gap
+		_, err = r.Dyna.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+			TransactItems: []*dynamodb.TransactWriteItem{
+				{Update: r.counterUpdate(index)},
+				{Put: &dynamodb.Put{
+					TableName: aws.String(r.TableName),
+					Item: map[string]*dynamodb.AttributeValue{
+						"log":  {S: aws.String(entriesPartition)},
+						"idx":  {N: aws.String(strconv.FormatUint(index, 10))},
+						"data": {B: data},
+					},
+					ConditionExpression: aws.String("attribute_not_exists(idx)"),
+				}},
+			},
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeTransactionCanceledException {
+			continue
+		}
+		if err != nil {
+			log.Print(err)
+			return 0, err
+		}
+
+		return index, nil
+	}
+
+	return 0, errConflict
+}
+
+// counterUpdate increases the counter from index to index+1, as long as no other append has done so already
+func (r *dynamoDbRepository) counterUpdate(index uint64) *dynamodb.Update {
+	update := &dynamodb.Update{
+		TableName:        aws.String(r.TableName),
+		Key:              counterKey(),
+		UpdateExpression: aws.String("SET #s = :next"),
+		ExpressionAttributeNames: map[string]*string{
+			"#s": aws.String("size"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":next": {N: aws.String(strconv.FormatUint(index+1, 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#s)"),
+	}
+
+	if index > 0 {
+		update.ConditionExpression = aws.String("#s = :cur")
+		update.ExpressionAttributeValues[":cur"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatUint(index, 10))}
+	}
+
+	return update
+}
+
+func (r *dynamoDbRepository) Size() (uint64, error) {
+	out, err := r.Dyna.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(r.TableName),
+		Key:            counterKey(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		log.Print(err)
+		return 0, err
+	}
+
+	// No counter means an empty log
+	if out.Item == nil || out.Item["size"] == nil {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(aws.StringValue(out.Item["size"].N), 10, 64)
+}
+
+func (r *dynamoDbRepository) Get(start, end uint64) ([][]byte, error) {
+	if start > end {
+		return nil, ErrInvalidRange
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.TableName),
+		KeyConditionExpression: aws.String("#l = :l AND idx BETWEEN :s AND :e"),
+		ExpressionAttributeNames: map[string]*string{
+			"#l": aws.String("log"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":l": {S: aws.String(entriesPartition)},
+			":s": {N: aws.String(strconv.FormatUint(start, 10))},
+			":e": {N: aws.String(strconv.FormatUint(end, 10))},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	var ret [][]byte
+	for {
+		out, err := r.Dyna.Query(input)
+		if err != nil {
+			log.Print(err)
+			return nil, err
+		}
+
+		for _, item := range out.Items {
+			ret = append(ret, item["data"].B)
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	// An entry that is missing (or not yet written) would result in an incorrect tree
+	if uint64(len(ret)) != end-start+1 {
+		return nil, ErrInvalidRange
+	}
+
+	return ret, nil
+}
+
+func counterKey() map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"log": {S: aws.String(sizePartition)},
+		"idx": {N: aws.String("0")},
+	}
+}
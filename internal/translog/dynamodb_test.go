@@ -0,0 +1,83 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package translog
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	dynamock "github.com/gusaul/go-dynamock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBRepository(t *testing.T) {
+	client, mock := dynamock.New()
+	repo := NewDynamoDBRepository(client, "mock_log_table")
+
+	// Empty log
+	mock.ExpectGetItem().ToTable("mock_log_table").WithKeys(counterKey()).WillReturns(dynamodb.GetItemOutput{})
+	size, err := repo.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), size)
+
+	// Append writes the entry together with the counter
+	mock.ExpectGetItem().ToTable("mock_log_table").WithKeys(counterKey()).WillReturns(dynamodb.GetItemOutput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"log":  {S: aws.String("size")},
+			"idx":  {N: aws.String("0")},
+			"size": {N: aws.String("2")},
+		},
+	})
+	mock.ExpectTransactWriteItems().WillReturns(dynamodb.TransactWriteItemsOutput{})
+	idx, err := repo.Append([]byte("foobar"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), idx)
+
+	mock.ExpectGetItem().ToTable("mock_log_table").WithKeys(counterKey()).WillReturns(dynamodb.GetItemOutput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"log":  {S: aws.String("size")},
+			"idx":  {N: aws.String("0")},
+			"size": {N: aws.String("3")},
+		},
+	})
+	size, err = repo.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), size)
+
+	mock.ExpectQuery().Table("mock_log_table").WillReturns(dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			{"data": {B: []byte("foo")}},
+			{"data": {B: []byte("bar")}},
+		},
+	})
+	data, err := repo.Get(1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("foo"), []byte("bar")}, data)
+
+	// Missing entries
+	mock.ExpectQuery().Table("mock_log_table").WillReturns(dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			{"data": {B: []byte("foo")}},
+		},
+	})
+	_, err = repo.Get(1, 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package translog
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+)
+
+// Types of objects that are logged
+const (
+	TypeAddress      = "address"
+	TypeOrganisation = "organisation"
+	TypeRouting      = "routing"
+)
+
+// Actions that are logged
+const (
+	ActionCreate     = "create"
+	ActionUpdate     = "update"
+	ActionSoftDelete = "soft-delete"
+	ActionUndelete   = "undelete"
+	ActionDelete     = "delete"
+	ActionKeyStatus  = "key-status"
+)
+
+// Entry is a single mutation in the transparency log
+type Entry struct {
+	Timestamp   int64  `json:"timestamp"`
+	Type        string `json:"type"`
+	Action      string `json:"action"`
+	Hash        string `json:"hash"`
+	PublicKey   string `json:"public_key,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	KeyStatus   string `json:"key_status,omitempty"`
+}
+
+// SignedTreeHead is the signed root of the merkle tree of the log at a certain size
+type SignedTreeHead struct {
+	TreeSize  uint64 `json:"tree_size"`
+	Timestamp int64  `json:"timestamp"`
+	RootHash  []byte `json:"root_hash"`
+	Signature []byte `json:"signature"`
+}
+
+// signatureData returns the data that is signed for the tree head
+func (sth SignedTreeHead) signatureData() []byte {
+	b, _ := json.Marshal(struct {
+		TreeSize  uint64 `json:"tree_size"`
+		Timestamp int64  `json:"timestamp"`
+		RootHash  []byte `json:"root_hash"`
+	}{sth.TreeSize, sth.Timestamp, sth.RootHash})
+
+	h := sha256.Sum256(b)
+	return h[:]
+}
+
+// Sign signs the tree head with the given key
+func (sth *SignedTreeHead) Sign(key bmcrypto.PrivKey) error {
+	sig, err := bmcrypto.Sign(key, sth.signatureData())
+	if err != nil {
+		return err
+	}
+
+	sth.Signature = sig
+	return nil
+}
+
+// Verify returns true when the tree head is signed by the given key
+func (sth SignedTreeHead) Verify(key bmcrypto.PubKey) bool {
+	ok, err := bmcrypto.Verify(key, sth.signatureData(), sth.Signature)
+	return err == nil && ok
+}
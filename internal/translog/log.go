@@ -0,0 +1,144 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package translog
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Error codes
+var (
+	ErrInvalidRange = errors.New("invalid range")
+)
+
+// AppendEntry adds the entry to the log and returns the index of the entry
+func AppendEntry(repo Repository, entry Entry) (uint64, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	return repo.Append(data)
+}
+
+// DecodeEntry decodes the raw data of an entry as stored in the log
+func DecodeEntry(data []byte) (*Entry, error) {
+	entry := &Entry{}
+	err := json.Unmarshal(data, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// GetTreeHead returns the (unsigned) tree head of the current log
+func GetTreeHead(repo Repository, now time.Time) (*SignedTreeHead, error) {
+	size, err := repo.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	var root []byte
+	err = withTree(repo, size, func(t *Tree) {
+		root = t.RootHash(size)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignedTreeHead{
+		TreeSize:  size,
+		Timestamp: now.Unix(),
+		RootHash:  root,
+	}, nil
+}
+
+// GetInclusionProof returns the audit path of the entry at index in the tree with the given size
+func GetInclusionProof(repo Repository, index, size uint64) ([][]byte, error) {
+	if index >= size {
+		return nil, ErrInvalidRange
+	}
+
+	var proof [][]byte
+	err := withTree(repo, size, func(t *Tree) {
+		proof = t.InclusionProof(index, size)
+	})
+
+	return proof, err
+}
+
+// GetConsistencyProof returns the proof that the tree with size first is a prefix of the tree with size second
+func GetConsistencyProof(repo Repository, first, second uint64) ([][]byte, error) {
+	if first > second {
+		return nil, ErrInvalidRange
+	}
+
+	var proof [][]byte
+	err := withTree(repo, second, func(t *Tree) {
+		proof = t.ConsistencyProof(first, second)
+	})
+
+	return proof, err
+}
+
+// The merkle tree of the log is kept in memory, so only entries that have been appended since the last request have
+// to be read from the repository
+var (
+	treeMu   sync.Mutex
+	treeRepo Repository
+	tree     *Tree
+)
+
+// withTree calls f with a tree that holds at least the first size entries of the log
+func withTree(repo Repository, size uint64, f func(t *Tree)) error {
+	treeMu.Lock()
+	defer treeMu.Unlock()
+
+	if treeRepo != repo || tree == nil {
+		treeRepo = repo
+		tree = &Tree{}
+	}
+
+	if size > tree.Size() {
+		current, err := repo.Size()
+		if err != nil {
+			return err
+		}
+		if size > current {
+			return ErrInvalidRange
+		}
+
+		data, err := repo.Get(tree.Size(), size-1)
+		if err != nil {
+			return err
+		}
+
+		for i := range data {
+			tree.Append(LeafHash(data[i]))
+		}
+	}
+
+	f(tree)
+	return nil
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package translog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/bits"
+)
+
+// The merkle tree hashing follows RFC 6962 (Certificate Transparency), so existing tooling and knowledge can be
+// used to verify the log.
+
+// LeafHash returns the hash of a leaf in the merkle tree
+func LeafHash(data []byte) []byte {
+	h := sha256.Sum256(append([]byte{0x00}, data...))
+	return h[:]
+}
+
+func nodeHash(left, right []byte) []byte {
+	buf := make([]byte, 0, 1+len(left)+len(right))
+	buf = append(buf, 0x01)
+	buf = append(buf, left...)
+	buf = append(buf, right...)
+
+	h := sha256.Sum256(buf)
+	return h[:]
+}
+
+// largestPowerOfTwoBelow returns the largest power of two that is smaller than n
+func largestPowerOfTwoBelow(n uint64) uint64 {
+	k := uint64(1)
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+// Tree holds the hashes of all complete subtrees of a merkle tree, so root hashes and proofs for any tree size can be
+// calculated without hashing all leaves again. Leaves can only be appended.
+type Tree struct {
+	levels [][][]byte // levels[k][i] is the hash of the complete subtree of 2^k leaves starting at leaf i*2^k
+}
+
+// NewTree returns a tree made of the given leaf hashes
+func NewTree(leaves [][]byte) *Tree {
+	t := &Tree{}
+	for _, leaf := range leaves {
+		t.Append(leaf)
+	}
+
+	return t
+}
+
+// Size returns the number of leaves in the tree
+func (t *Tree) Size() uint64 {
+	if len(t.levels) == 0 {
+		return 0
+	}
+
+	return uint64(len(t.levels[0]))
+}
+
+// Append adds a leaf hash to the tree, together with the subtrees it completes
+func (t *Tree) Append(leafHash []byte) {
+	node := leafHash
+	for k := 0; ; k++ {
+		if k == len(t.levels) {
+			t.levels = append(t.levels, nil)
+		}
+		t.levels[k] = append(t.levels[k], node)
+
+		// An odd number of nodes on this level means the node has no sibling yet
+		n := len(t.levels[k])
+		if n%2 == 1 {
+			return
+		}
+		node = nodeHash(t.levels[k][n-2], t.levels[k][n-1])
+	}
+}
+
+// RootHash returns the merkle tree hash of the first size leaves
+func (t *Tree) RootHash(size uint64) []byte {
+	return t.hash(0, size)
+}
+
+// InclusionProof returns the audit path for the leaf at index in the tree of the first size leaves
+func (t *Tree) InclusionProof(index, size uint64) [][]byte {
+	if size <= 1 || index >= size {
+		return [][]byte{}
+	}
+
+	return t.inclusionProof(index, 0, size)
+}
+
+// ConsistencyProof returns the proof that the tree of the first m leaves is a prefix of the tree of the first size
+// leaves
+func (t *Tree) ConsistencyProof(m, size uint64) [][]byte {
+	if m == 0 || m >= size {
+		return [][]byte{}
+	}
+
+	return t.subProof(m, 0, size, true)
+}
+
+// hash returns the merkle tree hash of the n leaves starting at start
+func (t *Tree) hash(start, n uint64) []byte {
+	if n == 0 {
+		h := sha256.Sum256(nil)
+		return h[:]
+	}
+
+	// Complete subtrees are stored, everything else is built from them
+	if n&(n-1) == 0 && start%n == 0 {
+		k := bits.TrailingZeros64(n)
+		return t.levels[k][start/n]
+	}
+
+	k := largestPowerOfTwoBelow(n)
+	return nodeHash(t.hash(start, k), t.hash(start+k, n-k))
+}
+
+func (t *Tree) inclusionProof(index, start, n uint64) [][]byte {
+	if n <= 1 {
+		return [][]byte{}
+	}
+
+	k := largestPowerOfTwoBelow(n)
+	if index < k {
+		return append(t.inclusionProof(index, start, k), t.hash(start+k, n-k))
+	}
+
+	return append(t.inclusionProof(index-k, start+k, n-k), t.hash(start, k))
+}
+
+func (t *Tree) subProof(m, start, n uint64, complete bool) [][]byte {
+	if m == n {
+		if complete {
+			return [][]byte{}
+		}
+		return [][]byte{t.hash(start, n)}
+	}
+
+	k := largestPowerOfTwoBelow(n)
+	if m <= k {
+		return append(t.subProof(m, start, k, complete), t.hash(start+k, n-k))
+	}
+
+	return append(t.subProof(m-k, start+k, n-k, false), t.hash(start, k))
+}
+
+// RootHash returns the merkle tree hash of the given leaf hashes
+func RootHash(leaves [][]byte) []byte {
+	return NewTree(leaves).RootHash(uint64(len(leaves)))
+}
+
+// InclusionProof returns the audit path for the leaf at index in the tree made of the given leaf hashes
+func InclusionProof(index uint64, leaves [][]byte) [][]byte {
+	return NewTree(leaves).InclusionProof(index, uint64(len(leaves)))
+}
+
+// ConsistencyProof returns the proof that the tree of the first m leaves is a prefix of the tree made of the given
+// leaf hashes
+func ConsistencyProof(m uint64, leaves [][]byte) [][]byte {
+	return NewTree(leaves).ConsistencyProof(m, uint64(len(leaves)))
+}
+
+// VerifyInclusion verifies that the leaf hash is found at index in the tree with the given size and root hash
+func VerifyInclusion(index, size uint64, leafHash []byte, proof [][]byte, rootHash []byte) bool {
+	if index >= size {
+		return false
+	}
+
+	fn, sn := index, size-1
+	r := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return false
+		}
+
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	return sn == 0 && bytes.Equal(r, rootHash)
+}
+
+// VerifyConsistency verifies that the tree with size first and root hash firstHash is a prefix of the tree with size
+// second and root hash secondHash
+func VerifyConsistency(first, second uint64, firstHash, secondHash []byte, proof [][]byte) bool {
+	if first > second {
+		return false
+	}
+	if first == second {
+		return len(proof) == 0 && bytes.Equal(firstHash, secondHash)
+	}
+	if first == 0 {
+		// The empty tree is a prefix of every tree
+		return len(proof) == 0
+	}
+	if len(proof) == 0 {
+		return false
+	}
+
+	// When first is an exact power of two, the first hash is the start of the proof
+	if first&(first-1) == 0 {
+		proof = append([][]byte{firstHash}, proof...)
+	}
+
+	fn, sn := first-1, second-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return false
+		}
+
+		if fn&1 == 1 || fn == sn {
+			fr = nodeHash(c, fr)
+			sr = nodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = nodeHash(sr, c)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	return sn == 0 && bytes.Equal(fr, firstHash) && bytes.Equal(sr, secondHash)
+}
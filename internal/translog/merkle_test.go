@@ -0,0 +1,101 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package translog
+
+import (
+	"encoding/hex"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func generateLeaves(n int) [][]byte {
+	var leaves [][]byte
+	for i := 0; i < n; i++ {
+		leaves = append(leaves, LeafHash([]byte("entry "+strconv.Itoa(i))))
+	}
+
+	return leaves
+}
+
+func TestRootHash(t *testing.T) {
+	// Empty tree hash from RFC 6962
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", hex.EncodeToString(RootHash(nil)))
+
+	leaves := generateLeaves(3)
+	assert.Equal(t, leaves[0], RootHash(leaves[:1]))
+	assert.Equal(t, nodeHash(leaves[0], leaves[1]), RootHash(leaves[:2]))
+	assert.Equal(t, nodeHash(nodeHash(leaves[0], leaves[1]), leaves[2]), RootHash(leaves))
+}
+
+func TestInclusionProof(t *testing.T) {
+	leaves := generateLeaves(17)
+
+	for size := 1; size <= len(leaves); size++ {
+		root := RootHash(leaves[:size])
+
+		for i := 0; i < size; i++ {
+			proof := InclusionProof(uint64(i), leaves[:size])
+			assert.True(t, VerifyInclusion(uint64(i), uint64(size), leaves[i], proof, root), "size %d index %d", size, i)
+
+			// Must not verify for other leaves
+			other := leaves[(i+1)%len(leaves)]
+			assert.False(t, VerifyInclusion(uint64(i), uint64(size), other, proof, root))
+		}
+	}
+
+	assert.False(t, VerifyInclusion(5, 5, leaves[0], nil, RootHash(leaves[:5])))
+}
+
+func TestConsistencyProof(t *testing.T) {
+	leaves := generateLeaves(17)
+
+	for second := 1; second <= len(leaves); second++ {
+		secondHash := RootHash(leaves[:second])
+
+		for first := 0; first <= second; first++ {
+			firstHash := RootHash(leaves[:first])
+			proof := ConsistencyProof(uint64(first), leaves[:second])
+			assert.True(t, VerifyConsistency(uint64(first), uint64(second), firstHash, secondHash, proof), "first %d second %d", first, second)
+
+			// Must not verify against another tree
+			if first > 0 && first < second {
+				otherHash := RootHash(generateLeaves(first + 1)[1:])
+				assert.False(t, VerifyConsistency(uint64(first), uint64(second), otherHash, secondHash, proof))
+			}
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	leaves := generateLeaves(17)
+
+	tree := &Tree{}
+	for i := range leaves {
+		tree.Append(leaves[i])
+		assert.Equal(t, uint64(i+1), tree.Size())
+
+		// Earlier tree sizes can still be calculated after appending
+		for size := 0; size <= i+1; size++ {
+			assert.Equal(t, RootHash(leaves[:size]), tree.RootHash(uint64(size)), "size %d", size)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package translog
+
+import (
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Repository stores the raw data of the entries in the transparency log. Entries can only be appended.
+type Repository interface {
+	// Append the data of an entry and return its index
+	Append(data []byte) (uint64, error)
+	// Return the number of entries in the log
+	Size() (uint64, error)
+	// Return the data of the entries from start up to and including end
+	Get(start, end uint64) ([][]byte, error)
+}
+
+var repository Repository
+
+// GetRepository returns the repository for the transparency log
+func GetRepository() Repository {
+	if repository != nil {
+		return repository
+	}
+
+	if os.Getenv("USE_BOLT") == "1" {
+		repository = NewBoltRepository()
+		return repository
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	repository = NewDynamoDBRepository(dynamodb.New(sess), os.Getenv("LOG_TABLE_NAME"))
+	return repository
+}
+
+// Sets the default repository for the log. Can be used to override for mocking/testing purposes
+func SetDefaultRepository(r Repository) {
+	repository = r
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package translog
+
+import (
+	"testing"
+	"time"
+
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func runRepositoryTests(t *testing.T, repo Repository) {
+	size, err := repo.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), size)
+
+	_, err = repo.Get(0, 0)
+	assert.Error(t, err)
+
+	for i := uint64(0); i < 5; i++ {
+		idx, err := repo.Append([]byte{byte(i), 'a'})
+		assert.NoError(t, err)
+		assert.Equal(t, i, idx)
+	}
+
+	size, err = repo.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), size)
+
+	data, err := repo.Get(1, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{{1, 'a'}, {2, 'a'}, {3, 'a'}}, data)
+
+	data, err = repo.Get(4, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{{4, 'a'}}, data)
+
+	_, err = repo.Get(3, 5)
+	assert.Error(t, err)
+	_, err = repo.Get(3, 2)
+	assert.Error(t, err)
+}
+
+func runLogTests(t *testing.T, repo Repository) {
+	privKey, pubKey, _ := testing2.ReadTestKey("../../testdata/key-1.json")
+
+	for i := 0; i < 3; i++ {
+		idx, err := AppendEntry(repo, Entry{Timestamp: int64(i), Type: TypeAddress, Action: ActionCreate, Hash: "hash"})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(i), idx)
+	}
+
+	data, err := repo.Get(1, 1)
+	assert.NoError(t, err)
+	entry, err := DecodeEntry(data[0])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), entry.Timestamp)
+	assert.Equal(t, ActionCreate, entry.Action)
+
+	sth1, err := GetTreeHead(repo, time.Unix(1000, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), sth1.TreeSize)
+	assert.Equal(t, int64(1000), sth1.Timestamp)
+
+	// Signed tree heads
+	assert.False(t, sth1.Verify(*pubKey))
+	err = sth1.Sign(*privKey)
+	assert.NoError(t, err)
+	assert.True(t, sth1.Verify(*pubKey))
+	sth1.TreeSize++
+	assert.False(t, sth1.Verify(*pubKey))
+	sth1.TreeSize--
+
+	_, err = AppendEntry(repo, Entry{Timestamp: 3, Type: TypeRouting, Action: ActionDelete, Hash: "hash"})
+	assert.NoError(t, err)
+
+	sth2, err := GetTreeHead(repo, time.Unix(1001, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), sth2.TreeSize)
+
+	// Inclusion proof for an entry
+	proof, err := GetInclusionProof(repo, 1, sth2.TreeSize)
+	assert.NoError(t, err)
+	assert.True(t, VerifyInclusion(1, sth2.TreeSize, LeafHash(data[0]), proof, sth2.RootHash))
+
+	_, err = GetInclusionProof(repo, 4, sth2.TreeSize)
+	assert.Error(t, err)
+	_, err = GetInclusionProof(repo, 1, 5)
+	assert.Error(t, err)
+
+	// Consistency between both tree heads
+	proof, err = GetConsistencyProof(repo, sth1.TreeSize, sth2.TreeSize)
+	assert.NoError(t, err)
+	assert.True(t, VerifyConsistency(sth1.TreeSize, sth2.TreeSize, sth1.RootHash, sth2.RootHash, proof))
+
+	_, err = GetConsistencyProof(repo, 4, 3)
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package translog
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+type SqliteRepository struct {
+	conn *sql.DB
+	dsn  string
+}
+
+// NewSqliteRepository returns a new log repository based on SQLite
+func NewSqliteRepository(dsn string) Repository {
+	if !strings.HasPrefix(dsn, "file:") {
+		if dsn == ":memory:" {
+			dsn = "file::memory:?mode=memory"
+		} else {
+			dsn = fmt.Sprintf("file:%s?cache=shared&mode=rwc", dsn)
+		}
+	}
+
+	conn, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil
+	}
+
+	db := &SqliteRepository{
+		conn: conn,
+		dsn:  dsn,
+	}
+
+	_, err = db.conn.Exec("CREATE TABLE IF NOT EXISTS mock_translog (idx INTEGER PRIMARY KEY, data BLOB)")
+	if err != nil {
+		return nil
+	}
+
+	return db
+}
+
+func (r *SqliteRepository) Append(data []byte) (uint64, error) {
+	// Calculate the index in the same statement, so concurrent appends cannot get the same index
+	res, err := r.conn.Exec("INSERT INTO mock_translog (idx, data) SELECT COUNT(*), ? FROM mock_translog", data)
+	if err != nil {
+		return 0, err
+	}
+
+	index, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(index), nil
+}
+
+func (r *SqliteRepository) Size() (uint64, error) {
+	var size uint64
+
+	err := r.conn.QueryRow("SELECT COUNT(*) FROM mock_translog").Scan(&size)
+	if err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+func (r *SqliteRepository) Get(start, end uint64) ([][]byte, error) {
+	size, err := r.Size()
+	if err != nil {
+		return nil, err
+	}
+	if start > end || end >= size {
+		return nil, ErrInvalidRange
+	}
+
+	rows, err := r.conn.Query("SELECT data FROM mock_translog WHERE idx >= ? AND idx <= ? ORDER BY idx", start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var ret [][]byte
+	for rows.Next() {
+		var data []byte
+		err = rows.Scan(&data)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, data)
+	}
+
+	return ret, rows.Err()
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package version
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/bitmaelum/key-resolver-go/internal"
+	bolt "go.etcd.io/bbolt"
+)
+
+type boltRepository struct {
+	client     *bolt.DB
+	bucketName []byte
+}
+
+// NewBoltRepository returns a new version repository based on BoltDB. Every record has its own nested bucket, with
+// the versions stored under their serial.
+func NewBoltRepository() Repository {
+	return &boltRepository{
+		client:     internal.GetBoltDb(),
+		bucketName: []byte("versions"),
+	}
+}
+
+func (b boltRepository) Add(v Version) error {
+	return b.client.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(b.bucketName)
+		if err != nil {
+			return err
+		}
+
+		bucket, err := root.CreateBucketIfNotExists(recordKey(v.Type, v.Hash))
+		if err != nil {
+			return err
+		}
+
+		if bucket.Get(serialKey(v.Serial)) != nil {
+			return nil
+		}
+
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(serialKey(v.Serial), data)
+	})
+}
+
+func (b boltRepository) List(typ, hash string) ([]Version, error) {
+	var ret []Version
+
+	err := b.client.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(b.bucketName)
+		if root == nil {
+			return nil
+		}
+
+		bucket := root.Bucket(recordKey(typ, hash))
+		if bucket == nil {
+			return nil
+		}
+
+		// Keys are big endian serials, so the versions are iterated in order
+		return bucket.ForEach(func(_, data []byte) error {
+			v := Version{}
+			err := json.Unmarshal(data, &v)
+			if err != nil {
+				return err
+			}
+			ret = append(ret, v)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (b boltRepository) Remove(typ, hash string, serials []uint64) error {
+	return b.client.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(b.bucketName)
+		if root == nil {
+			return nil
+		}
+
+		bucket := root.Bucket(recordKey(typ, hash))
+		if bucket == nil {
+			return nil
+		}
+
+		for _, serial := range serials {
+			err := bucket.Delete(serialKey(serial))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func recordKey(typ, hash string) []byte {
+	return []byte(typ + "/" + hash)
+}
+
+func serialKey(serial uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, serial)
+	return k
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package version
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// The versions are stored in a single table with "record" (type and hash) as partition key and "sn" as sort key
+
+type dynamoDbRepository struct {
+	Dyna      dynamodbiface.DynamoDBAPI
+	TableName string
+}
+
+// Record in dynamodb
+type dynamoVersionRecord struct {
+	Record    string `dynamodbav:"record"`
+	Serial    uint64 `dynamodbav:"sn"`
+	Type      string `dynamodbav:"type"`
+	Hash      string `dynamodbav:"hash"`
+	Timestamp int64  `dynamodbav:"timestamp"`
+	Data      string `dynamodbav:"data"`
+}
+
+// NewDynamoDBRepository returns a new version repository based on DynamoDB
+func NewDynamoDBRepository(client dynamodbiface.DynamoDBAPI, tableName string) Repository {
+	return &dynamoDbRepository{
+		Dyna:      client,
+		TableName: tableName,
+	}
+}
+
+func (r *dynamoDbRepository) Add(v Version) error {
+	av, err := dynamodbattribute.MarshalMap(dynamoVersionRecord{
+		Record:    recordID(v.Type, v.Hash),
+		Serial:    v.Serial,
+		Type:      v.Type,
+		Hash:      v.Hash,
+		Timestamp: v.Timestamp,
+		Data:      string(v.Record),
+	})
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	_, err = r.Dyna.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(r.TableName),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(sn)"),
+	})
+
+	// The version is already stored
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *dynamoDbRepository) List(typ, hash string) ([]Version, error) {
+	var ret []Version
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.TableName),
+		KeyConditionExpression: aws.String("#r = :r"),
+		ExpressionAttributeNames: map[string]*string{
+			"#r": aws.String("record"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":r": {S: aws.String(recordID(typ, hash))},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	for {
+		out, err := r.Dyna.Query(input)
+		if err != nil {
+			log.Print(err)
+			return nil, err
+		}
+
+		for _, item := range out.Items {
+			record := &dynamoVersionRecord{}
+			err = dynamodbattribute.UnmarshalMap(item, record)
+			if err != nil {
+				return nil, err
+			}
+
+			ret = append(ret, Version{
+				Type:      record.Type,
+				Hash:      record.Hash,
+				Serial:    record.Serial,
+				Timestamp: record.Timestamp,
+				Record:    []byte(record.Data),
+			})
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return ret, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+func (r *dynamoDbRepository) Remove(typ, hash string, serials []uint64) error {
+	for _, serial := range serials {
+		_, err := r.Dyna.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String(r.TableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"record": {S: aws.String(recordID(typ, hash))},
+				"sn":     {N: aws.String(strconv.FormatUint(serial, 10))},
+			},
+		})
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func recordID(typ, hash string) string {
+	return typ + "/" + hash
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package version
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	dynamock "github.com/gusaul/go-dynamock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBRepository(t *testing.T) {
+	client, mock := dynamock.New()
+	repo := NewDynamoDBRepository(client, "mock_versions_table")
+
+	item := map[string]*dynamodb.AttributeValue{
+		"record":    {S: aws.String("address/hash")},
+		"sn":        {N: aws.String("1234")},
+		"type":      {S: aws.String("address")},
+		"hash":      {S: aws.String("hash")},
+		"timestamp": {N: aws.String("999")},
+		"data":      {S: aws.String(`{"hash":"hash"}`)},
+	}
+
+	mock.ExpectPutItem().ToTable("mock_versions_table").WithItems(item).WillReturns(dynamodb.PutItemOutput{})
+	err := repo.Add(Version{Type: "address", Hash: "hash", Serial: 1234, Timestamp: 999, Record: []byte(`{"hash":"hash"}`)})
+	assert.NoError(t, err)
+
+	mock.ExpectQuery().Table("mock_versions_table").WillReturns(dynamodb.QueryOutput{
+		Items:            []map[string]*dynamodb.AttributeValue{item},
+		LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"record": {S: aws.String("address/hash")}},
+	})
+	mock.ExpectQuery().Table("mock_versions_table").WillReturns(dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{item},
+	})
+	versions, err := repo.List("address", "hash")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, Version{Type: "address", Hash: "hash", Serial: 1234, Timestamp: 999, Record: []byte(`{"hash":"hash"}`)}, versions[0])
+
+	mock.ExpectDeleteItem().ToTable("mock_versions_table").WithKeys(map[string]*dynamodb.AttributeValue{
+		"record": {S: aws.String("address/hash")},
+		"sn":     {N: aws.String("1234")},
+	}).WillReturns(dynamodb.DeleteItemOutput{})
+	err = repo.Remove("address", "hash", []uint64{1234})
+	assert.NoError(t, err)
+}
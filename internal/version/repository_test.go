@@ -0,0 +1,71 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package version
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runRepositoryTests(t *testing.T, repo Repository) {
+	versions, err := repo.List("address", "hash")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 0)
+
+	for i := 1; i <= 3; i++ {
+		err = repo.Add(Version{
+			Type:      "address",
+			Hash:      "hash",
+			Serial:    uint64(i * 100),
+			Timestamp: int64(i),
+			Record:    []byte(fmt.Sprintf(`{"serial_number":%d}`, i)),
+		})
+		assert.NoError(t, err)
+	}
+
+	// Another record with the same hash
+	err = repo.Add(Version{Type: "routing", Hash: "hash", Serial: 100, Timestamp: 1, Record: []byte(`{}`)})
+	assert.NoError(t, err)
+
+	versions, err = repo.List("address", "hash")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 3)
+	assert.Equal(t, Version{Type: "address", Hash: "hash", Serial: 100, Timestamp: 1, Record: []byte(`{"serial_number":1}`)}, versions[0])
+	assert.Equal(t, uint64(300), versions[2].Serial)
+
+	// Versions are immutable
+	err = repo.Add(Version{Type: "address", Hash: "hash", Serial: 200, Timestamp: 10, Record: []byte(`{}`)})
+	assert.NoError(t, err)
+	versions, _ = repo.List("address", "hash")
+	assert.Len(t, versions, 3)
+	assert.Equal(t, int64(2), versions[1].Timestamp)
+	assert.Equal(t, `{"serial_number":2}`, string(versions[1].Record))
+
+	err = repo.Remove("address", "hash", []uint64{100, 200})
+	assert.NoError(t, err)
+	versions, _ = repo.List("address", "hash")
+	assert.Len(t, versions, 1)
+	assert.Equal(t, uint64(300), versions[0].Serial)
+
+	versions, _ = repo.List("routing", "hash")
+	assert.Len(t, versions, 1)
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package version
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+type SqliteRepository struct {
+	conn *sql.DB
+	dsn  string
+}
+
+// NewSqliteRepository returns a new version repository based on SQLite
+func NewSqliteRepository(dsn string) Repository {
+	if !strings.HasPrefix(dsn, "file:") {
+		if dsn == ":memory:" {
+			dsn = "file::memory:?mode=memory"
+		} else {
+			dsn = fmt.Sprintf("file:%s?cache=shared&mode=rwc", dsn)
+		}
+	}
+
+	conn, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil
+	}
+
+	db := &SqliteRepository{
+		conn: conn,
+		dsn:  dsn,
+	}
+
+	_, err = db.conn.Exec("CREATE TABLE IF NOT EXISTS mock_versions (type TEXT, hash TEXT, serial INTEGER, timestamp INTEGER, record TEXT, PRIMARY KEY (type, hash, serial))")
+	if err != nil {
+		return nil
+	}
+
+	return db
+}
+
+func (r *SqliteRepository) Add(v Version) error {
+	_, err := r.conn.Exec("INSERT OR IGNORE INTO mock_versions VALUES (?, ?, ?, ?, ?)", v.Type, v.Hash, v.Serial, v.Timestamp, string(v.Record))
+	return err
+}
+
+func (r *SqliteRepository) List(typ, hash string) ([]Version, error) {
+	rows, err := r.conn.Query("SELECT type, hash, serial, timestamp, record FROM mock_versions WHERE type = ? AND hash = ? ORDER BY serial", typ, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var ret []Version
+	for rows.Next() {
+		var record string
+
+		v := Version{}
+		err = rows.Scan(&v.Type, &v.Hash, &v.Serial, &v.Timestamp, &record)
+		if err != nil {
+			return nil, err
+		}
+		v.Record = []byte(record)
+
+		ret = append(ret, v)
+	}
+
+	return ret, rows.Err()
+}
+
+func (r *SqliteRepository) Remove(typ, hash string, serials []uint64) error {
+	for _, serial := range serials {
+		_, err := r.conn.Exec("DELETE FROM mock_versions WHERE type = ? AND hash = ? AND serial = ?", typ, hash, serial)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package version
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Version is an immutable snapshot of a record, as it was stored under the given serial
+type Version struct {
+	Type      string          `json:"type"`          // Type of the record (address, organisation or routing)
+	Hash      string          `json:"hash"`          // Hash of the record
+	Serial    uint64          `json:"serial_number"` // Serial of the record in this version
+	Timestamp int64           `json:"timestamp"`     // Time from which this version was the current version
+	Record    json.RawMessage `json:"record"`        // Public representation of the record
+}
+
+// FindSerial returns the version with the given serial, or nil when there is no such version
+func FindSerial(versions []Version, serial uint64) *Version {
+	for i := range versions {
+		if versions[i].Serial == serial {
+			return &versions[i]
+		}
+	}
+
+	return nil
+}
+
+// FindAt returns the version that was current at the given unix timestamp, or nil when the record did not exist yet.
+// The versions must be ordered by serial.
+func FindAt(versions []Version, ts int64) *Version {
+	var found *Version
+	for i := range versions {
+		if versions[i].Timestamp > ts {
+			break
+		}
+		found = &versions[i]
+	}
+
+	return found
+}
+
+// Expired returns the serials of the versions that have been superseded for longer than the retention period. The
+// latest version is never expired. The versions must be ordered by serial.
+func Expired(versions []Version, retention time.Duration, now time.Time) []uint64 {
+	var serials []uint64
+
+	cutoff := now.Add(-retention).Unix()
+	for i := 0; i < len(versions)-1; i++ {
+		// A version is superseded at the moment the next version was stored
+		if versions[i+1].Timestamp < cutoff {
+			serials = append(serials, versions[i].Serial)
+		}
+	}
+
+	return serials
+}
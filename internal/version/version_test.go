@@ -0,0 +1,59 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package version
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFind(t *testing.T) {
+	versions := []Version{
+		{Serial: 100, Timestamp: 1000},
+		{Serial: 200, Timestamp: 2000},
+		{Serial: 300, Timestamp: 3000},
+	}
+
+	assert.Equal(t, uint64(200), FindSerial(versions, 200).Serial)
+	assert.Nil(t, FindSerial(versions, 250))
+
+	assert.Nil(t, FindAt(versions, 999))
+	assert.Equal(t, uint64(100), FindAt(versions, 1000).Serial)
+	assert.Equal(t, uint64(200), FindAt(versions, 2999).Serial)
+	assert.Equal(t, uint64(300), FindAt(versions, 5000).Serial)
+	assert.Nil(t, FindAt(nil, 5000))
+}
+
+func TestExpired(t *testing.T) {
+	versions := []Version{
+		{Serial: 100, Timestamp: 1000},
+		{Serial: 200, Timestamp: 2000},
+		{Serial: 300, Timestamp: 3000},
+	}
+
+	assert.Nil(t, Expired(versions, time.Hour, time.Unix(2000, 0)))
+	assert.Equal(t, []uint64{100}, Expired(versions, 10*time.Second, time.Unix(2500, 0)))
+	assert.Equal(t, []uint64{100, 200}, Expired(versions, time.Second, time.Unix(5000, 0)))
+
+	// The latest version never expires
+	assert.Nil(t, Expired(versions[2:], time.Second, time.Unix(5000, 0)))
+}
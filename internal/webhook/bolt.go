@@ -0,0 +1,230 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"encoding/json"
+
+	"github.com/bitmaelum/key-resolver-go/internal"
+	bolt "go.etcd.io/bbolt"
+)
+
+type boltRepository struct {
+	client             *bolt.DB
+	bucketName         []byte
+	deliveryBucketName []byte
+}
+
+// NewBoltRepository returns a new subscription repository based on BoltDB
+func NewBoltRepository() Repository {
+	return &boltRepository{
+		client:             internal.GetBoltDb(),
+		bucketName:         []byte("webhooks"),
+		deliveryBucketName: []byte("webhook_deliveries"),
+	}
+}
+
+func (b boltRepository) Create(sub Subscription) error {
+	return b.client.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.bucketName)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(sub)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(sub.ID), data)
+	})
+}
+
+func (b boltRepository) Get(typ, hash, id string) (*Subscription, error) {
+	sub := &Subscription{}
+
+	err := b.client.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil {
+			return ErrNotFound
+		}
+
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+
+		err := json.Unmarshal(data, sub)
+		if err != nil {
+			return err
+		}
+
+		if sub.Type != typ || sub.Hash != hash {
+			return ErrNotFound
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+func (b boltRepository) List(typ, hash string) ([]Subscription, error) {
+	var ret []Subscription
+
+	err := b.client.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(_, v []byte) error {
+			sub := Subscription{}
+			err := json.Unmarshal(v, &sub)
+			if err != nil {
+				return err
+			}
+
+			if sub.Type == typ && sub.Hash == hash {
+				ret = append(ret, sub)
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (b boltRepository) Remove(typ, hash, id string) error {
+	_, err := b.Get(typ, hash, id)
+	if err != nil {
+		return err
+	}
+
+	return b.client.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(b.bucketName).Delete([]byte(id))
+	})
+}
+
+func (b boltRepository) AddDelivery(d Delivery) error {
+	return b.client.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.deliveryBucketName)
+		if err != nil {
+			return err
+		}
+
+		return putDelivery(bucket, d)
+	})
+}
+
+func (b boltRepository) ListDeliveries(limit int) ([]Delivery, error) {
+	var ret []Delivery
+
+	err := b.client.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.deliveryBucketName)
+		if bucket == nil {
+			return nil
+		}
+
+		// Keys are ordered by creation time
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil && len(ret) < limit; k, v = c.Next() {
+			d := Delivery{}
+			err := json.Unmarshal(v, &d)
+			if err != nil {
+				return err
+			}
+			ret = append(ret, d)
+		}
+
+		return nil
+	})
+
+	return ret, err
+}
+
+func (b boltRepository) ClaimDelivery(d Delivery, next int64) (bool, error) {
+	claimed := false
+
+	err := b.client.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.deliveryBucketName)
+		if bucket == nil {
+			return nil
+		}
+
+		current := Delivery{}
+		data := bucket.Get([]byte(d.ID))
+		if data == nil {
+			return nil
+		}
+		err := json.Unmarshal(data, &current)
+		if err != nil {
+			return err
+		}
+
+		if current.NextAttempt != d.NextAttempt {
+			return nil
+		}
+
+		current.NextAttempt = next
+		claimed = true
+		return putDelivery(bucket, current)
+	})
+
+	return claimed, err
+}
+
+func (b boltRepository) UpdateDelivery(d Delivery) error {
+	return b.client.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.deliveryBucketName)
+		if bucket == nil || bucket.Get([]byte(d.ID)) == nil {
+			return nil
+		}
+
+		return putDelivery(bucket, d)
+	})
+}
+
+func (b boltRepository) RemoveDelivery(id string) error {
+	return b.client.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.deliveryBucketName)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.Delete([]byte(id))
+	})
+}
+
+func putDelivery(bucket *bolt.Bucket, d Delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(d.ID), data)
+}
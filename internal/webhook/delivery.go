@@ -0,0 +1,47 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"fmt"
+	"time"
+)
+
+// Delivery is an event that still has to be posted to the URL of a subscription. Deliveries are stored, so they are
+// not lost when the resolver restarts.
+type Delivery struct {
+	ID           string `json:"id"`           // Unique ID, ordered by the time the delivery was created
+	Subscription string `json:"subscription"` // ID of the subscription
+	URL          string `json:"url"`          // URL to which the event is posted
+	Payload      []byte `json:"payload"`      // Event to post
+	Attempts     int    `json:"attempts"`     // Number of failed attempts so far
+	NextAttempt  int64  `json:"next_attempt"` // Time in nanoseconds from which the next attempt can be made
+}
+
+// NewDelivery returns a new delivery of the payload that can be made directly
+func NewDelivery(sub Subscription, payload []byte, now time.Time) Delivery {
+	return Delivery{
+		ID:           fmt.Sprintf("%020d-%s", now.UnixNano(), NewID()),
+		Subscription: sub.ID,
+		URL:          sub.URL,
+		Payload:      payload,
+		NextAttempt:  now.UnixNano(),
+	}
+}
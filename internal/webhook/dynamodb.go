@@ -0,0 +1,319 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Subscriptions are stored with the watched record ("<type>/<hash>") as partition key and the ID as sort key, so all
+// subscriptions of a record can be fetched with a single query.
+type dynamoDbRepository struct {
+	Dyna      dynamodbiface.DynamoDBAPI
+	TableName string
+}
+
+// Record in dynamodb
+type dynamoSubscriptionRecord struct {
+	Record    string `dynamodbav:"record"`
+	ID        string `dynamodbav:"id"`
+	Type      string `dynamodbav:"type"`
+	Hash      string `dynamodbav:"hash"`
+	URL       string `dynamodbav:"url"`
+	CreatedAt int64  `dynamodbav:"created_at"`
+}
+
+// Deliveries are stored under a single partition key, so they can be read back in order of creation
+const deliveryRecord = "deliveries"
+
+// Delivery record in dynamodb
+type dynamoDeliveryRecord struct {
+	Record       string `dynamodbav:"record"`
+	ID           string `dynamodbav:"id"`
+	Subscription string `dynamodbav:"subscription"`
+	URL          string `dynamodbav:"url"`
+	Payload      []byte `dynamodbav:"payload"`
+	Attempts     int    `dynamodbav:"attempts"`
+	NextAttempt  int64  `dynamodbav:"next_attempt"`
+}
+
+// NewDynamoDBRepository returns a new subscription repository based on DynamoDB
+func NewDynamoDBRepository(client dynamodbiface.DynamoDBAPI, tableName string) Repository {
+	return &dynamoDbRepository{
+		Dyna:      client,
+		TableName: tableName,
+	}
+}
+
+func (r *dynamoDbRepository) Create(sub Subscription) error {
+	av, err := dynamodbattribute.MarshalMap(dynamoSubscriptionRecord{
+		Record:    recordKey(sub.Type, sub.Hash),
+		ID:        sub.ID,
+		Type:      sub.Type,
+		Hash:      sub.Hash,
+		URL:       sub.URL,
+		CreatedAt: sub.CreatedAt,
+	})
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	_, err = r.Dyna.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(r.TableName),
+		Item:      av,
+	})
+	if err != nil {
+		log.Print(err)
+	}
+
+	return err
+}
+
+func (r *dynamoDbRepository) Get(typ, hash, id string) (*Subscription, error) {
+	result, err := r.Dyna.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(r.TableName),
+		Key:       itemKey(typ, hash, id),
+	})
+	if err != nil {
+		log.Print(err)
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	record := dynamoSubscriptionRecord{}
+	err = dynamodbattribute.UnmarshalMap(result.Item, &record)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrNotFound
+	}
+
+	return record.subscription(), nil
+}
+
+func (r *dynamoDbRepository) List(typ, hash string) ([]Subscription, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.TableName),
+		KeyConditionExpression: aws.String("#r = :r"),
+		ExpressionAttributeNames: map[string]*string{
+			"#r": aws.String("record"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":r": {S: aws.String(recordKey(typ, hash))},
+		},
+	}
+
+	var ret []Subscription
+	for {
+		out, err := r.Dyna.Query(input)
+		if err != nil {
+			log.Print(err)
+			return nil, err
+		}
+
+		for _, item := range out.Items {
+			record := dynamoSubscriptionRecord{}
+			err = dynamodbattribute.UnmarshalMap(item, &record)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, *record.subscription())
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	return ret, nil
+}
+
+func (r *dynamoDbRepository) Remove(typ, hash, id string) error {
+	_, err := r.Dyna.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.TableName),
+		Key:                 itemKey(typ, hash, id),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		log.Print(err)
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrNotFound
+		}
+	}
+
+	return err
+}
+
+func (r *dynamoDbRepository) AddDelivery(d Delivery) error {
+	av, err := dynamodbattribute.MarshalMap(dynamoDeliveryRecord{
+		Record:       deliveryRecord,
+		ID:           d.ID,
+		Subscription: d.Subscription,
+		URL:          d.URL,
+		Payload:      d.Payload,
+		Attempts:     d.Attempts,
+		NextAttempt:  d.NextAttempt,
+	})
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	_, err = r.Dyna.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(r.TableName),
+		Item:      av,
+	})
+	if err != nil {
+		log.Print(err)
+	}
+
+	return err
+}
+
+func (r *dynamoDbRepository) ListDeliveries(limit int) ([]Delivery, error) {
+	out, err := r.Dyna.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(r.TableName),
+		KeyConditionExpression: aws.String("#r = :r"),
+		ExpressionAttributeNames: map[string]*string{
+			"#r": aws.String("record"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":r": {S: aws.String(deliveryRecord)},
+		},
+		Limit: aws.Int64(int64(limit)),
+	})
+	if err != nil {
+		log.Print(err)
+		return nil, err
+	}
+
+	var ret []Delivery
+	for _, item := range out.Items {
+		record := dynamoDeliveryRecord{}
+		err = dynamodbattribute.UnmarshalMap(item, &record)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, Delivery{
+			ID:           record.ID,
+			Subscription: record.Subscription,
+			URL:          record.URL,
+			Payload:      record.Payload,
+			Attempts:     record.Attempts,
+			NextAttempt:  record.NextAttempt,
+		})
+	}
+
+	return ret, nil
+}
+
+func (r *dynamoDbRepository) ClaimDelivery(d Delivery, next int64) (bool, error) {
+	_, err := r.Dyna.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.TableName),
+		Key:                 deliveryKey(d.ID),
+		UpdateExpression:    aws.String("SET next_attempt = :next"),
+		ConditionExpression: aws.String("next_attempt = :cur"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":next": {N: aws.String(strconv.FormatInt(next, 10))},
+			":cur":  {N: aws.String(strconv.FormatInt(d.NextAttempt, 10))},
+		},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		log.Print(err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *dynamoDbRepository) UpdateDelivery(d Delivery) error {
+	_, err := r.Dyna.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.TableName),
+		Key:                 deliveryKey(d.ID),
+		UpdateExpression:    aws.String("SET attempts = :a, next_attempt = :next"),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":a":    {N: aws.String(strconv.Itoa(d.Attempts))},
+			":next": {N: aws.String(strconv.FormatInt(d.NextAttempt, 10))},
+		},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil
+		}
+		log.Print(err)
+	}
+
+	return err
+}
+
+func (r *dynamoDbRepository) RemoveDelivery(id string) error {
+	_, err := r.Dyna.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(r.TableName),
+		Key:       deliveryKey(id),
+	})
+	if err != nil {
+		log.Print(err)
+	}
+
+	return err
+}
+
+func (record dynamoSubscriptionRecord) subscription() *Subscription {
+	return &Subscription{
+		ID:        record.ID,
+		Type:      record.Type,
+		Hash:      record.Hash,
+		URL:       record.URL,
+		CreatedAt: record.CreatedAt,
+	}
+}
+
+func recordKey(typ, hash string) string {
+	return typ + "/" + hash
+}
+
+func itemKey(typ, hash, id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"record": {S: aws.String(recordKey(typ, hash))},
+		"id":     {S: aws.String(id)},
+	}
+}
+
+func deliveryKey(id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"record": {S: aws.String(deliveryRecord)},
+		"id":     {S: aws.String(id)},
+	}
+}
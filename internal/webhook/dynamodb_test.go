@@ -0,0 +1,100 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	dynamock "github.com/gusaul/go-dynamock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDBRepository(t *testing.T) {
+	client, mock := dynamock.New()
+	repo := NewDynamoDBRepository(client, "mock_webhook_table")
+
+	item := map[string]*dynamodb.AttributeValue{
+		"record":     {S: aws.String("address/hash1")},
+		"id":         {S: aws.String("id1")},
+		"type":       {S: aws.String("address")},
+		"hash":       {S: aws.String("hash1")},
+		"url":        {S: aws.String("https://example.org/1")},
+		"created_at": {N: aws.String("1")},
+	}
+	sub1 := Subscription{ID: "id1", Type: "address", Hash: "hash1", URL: "https://example.org/1", CreatedAt: 1}
+
+	mock.ExpectPutItem().ToTable("mock_webhook_table").WithItems(item)
+	assert.NoError(t, repo.Create(sub1))
+
+	mock.ExpectGetItem().ToTable("mock_webhook_table").WithKeys(itemKey("address", "hash1", "id1")).WillReturns(dynamodb.GetItemOutput{
+		Item: item,
+	})
+	sub, err := repo.Get("address", "hash1", "id1")
+	assert.NoError(t, err)
+	assert.Equal(t, sub1, *sub)
+
+	mock.ExpectGetItem().ToTable("mock_webhook_table").WithKeys(itemKey("address", "hash1", "id2")).WillReturns(dynamodb.GetItemOutput{})
+	_, err = repo.Get("address", "hash1", "id2")
+	assert.Equal(t, ErrNotFound, err)
+
+	mock.ExpectQuery().Table("mock_webhook_table").WillReturns(dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{item},
+	})
+	subs, err := repo.List("address", "hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, []Subscription{sub1}, subs)
+
+	mock.ExpectDeleteItem().ToTable("mock_webhook_table").WithKeys(itemKey("address", "hash1", "id1"))
+	assert.NoError(t, repo.Remove("address", "hash1", "id1"))
+
+	ditem := map[string]*dynamodb.AttributeValue{
+		"record":       {S: aws.String("deliveries")},
+		"id":           {S: aws.String("0001-a")},
+		"subscription": {S: aws.String("id1")},
+		"url":          {S: aws.String("https://example.org/1")},
+		"payload":      {B: []byte("{}")},
+		"attempts":     {N: aws.String("0")},
+		"next_attempt": {N: aws.String("100")},
+	}
+	d1 := Delivery{ID: "0001-a", Subscription: "id1", URL: "https://example.org/1", Payload: []byte("{}"), NextAttempt: 100}
+
+	mock.ExpectPutItem().ToTable("mock_webhook_table").WithItems(ditem)
+	assert.NoError(t, repo.AddDelivery(d1))
+
+	mock.ExpectQuery().Table("mock_webhook_table").WillReturns(dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{ditem},
+	})
+	deliveries, err := repo.ListDeliveries(10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Delivery{d1}, deliveries)
+
+	mock.ExpectUpdateItem().ToTable("mock_webhook_table").WithKeys(deliveryKey("0001-a")).WillReturns(dynamodb.UpdateItemOutput{})
+	ok, err := repo.ClaimDelivery(d1, 200)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	mock.ExpectUpdateItem().ToTable("mock_webhook_table").WithKeys(deliveryKey("0001-a")).WillReturns(dynamodb.UpdateItemOutput{})
+	assert.NoError(t, repo.UpdateDelivery(d1))
+
+	mock.ExpectDeleteItem().ToTable("mock_webhook_table").WithKeys(deliveryKey("0001-a"))
+	assert.NoError(t, repo.RemoveDelivery("0001-a"))
+}
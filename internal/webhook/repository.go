@@ -0,0 +1,76 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Repository stores the webhook subscriptions and the deliveries that still have to be made
+type Repository interface {
+	// Store a new subscription
+	Create(sub Subscription) error
+	// Retrieve a subscription for the given record by its ID
+	Get(typ, hash, id string) (*Subscription, error)
+	// Retrieve all subscriptions for the given record
+	List(typ, hash string) ([]Subscription, error)
+	// Remove the subscription for the given record
+	Remove(typ, hash, id string) error
+
+	// Store a delivery that still has to be made
+	AddDelivery(d Delivery) error
+	// Retrieve at most limit deliveries, oldest first
+	ListDeliveries(limit int) ([]Delivery, error)
+	// Move the next attempt of the delivery to next, as long as no other worker has claimed it since it was read
+	ClaimDelivery(d Delivery, next int64) (bool, error)
+	// Store the attempts and next attempt of the delivery
+	UpdateDelivery(d Delivery) error
+	// Remove a delivery that has been made or has been given up on
+	RemoveDelivery(id string) error
+}
+
+var repository Repository
+
+// GetRepository returns the repository for the webhook subscriptions
+func GetRepository() Repository {
+	if repository != nil {
+		return repository
+	}
+
+	if os.Getenv("USE_BOLT") == "1" {
+		repository = NewBoltRepository()
+		return repository
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	repository = NewDynamoDBRepository(dynamodb.New(sess), os.Getenv("WEBHOOK_TABLE_NAME"))
+	return repository
+}
+
+// Sets the default repository for the subscriptions. Can be used to override for mocking/testing purposes
+func SetDefaultRepository(r Repository) {
+	repository = r
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runRepositoryTests(t *testing.T, repo Repository) {
+	subs, err := repo.List("address", "hash1")
+	assert.NoError(t, err)
+	assert.Len(t, subs, 0)
+
+	_, err = repo.Get("address", "hash1", "id1")
+	assert.Equal(t, ErrNotFound, err)
+
+	sub1 := Subscription{ID: "id1", Type: "address", Hash: "hash1", URL: "https://example.org/1", CreatedAt: 1}
+	sub2 := Subscription{ID: "id2", Type: "address", Hash: "hash1", URL: "https://example.org/2", CreatedAt: 2}
+	sub3 := Subscription{ID: "id3", Type: "routing", Hash: "hash1", URL: "https://example.org/3", CreatedAt: 3}
+	assert.NoError(t, repo.Create(sub1))
+	assert.NoError(t, repo.Create(sub2))
+	assert.NoError(t, repo.Create(sub3))
+
+	sub, err := repo.Get("address", "hash1", "id1")
+	assert.NoError(t, err)
+	assert.Equal(t, sub1, *sub)
+
+	// Subscriptions are only found through their own record
+	_, err = repo.Get("routing", "hash1", "id1")
+	assert.Equal(t, ErrNotFound, err)
+
+	subs, err = repo.List("address", "hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, []Subscription{sub1, sub2}, subs)
+
+	subs, err = repo.List("routing", "hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, []Subscription{sub3}, subs)
+
+	assert.Equal(t, ErrNotFound, repo.Remove("routing", "hash1", "id1"))
+	assert.NoError(t, repo.Remove("address", "hash1", "id1"))
+	assert.Equal(t, ErrNotFound, repo.Remove("address", "hash1", "id1"))
+
+	subs, err = repo.List("address", "hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, []Subscription{sub2}, subs)
+
+	runDeliveryTests(t, repo)
+}
+
+func runDeliveryTests(t *testing.T, repo Repository) {
+	deliveries, err := repo.ListDeliveries(10)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 0)
+
+	d1 := Delivery{ID: "0001-a", Subscription: "id1", URL: "https://example.org/1", Payload: []byte("{}"), NextAttempt: 100}
+	d2 := Delivery{ID: "0002-b", Subscription: "id2", URL: "https://example.org/2", Payload: []byte("[]"), NextAttempt: 200}
+	d3 := Delivery{ID: "0003-c", Subscription: "id1", URL: "https://example.org/1", Payload: []byte("{}"), NextAttempt: 300}
+	assert.NoError(t, repo.AddDelivery(d2))
+	assert.NoError(t, repo.AddDelivery(d3))
+	assert.NoError(t, repo.AddDelivery(d1))
+
+	// Oldest first
+	deliveries, err = repo.ListDeliveries(10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Delivery{d1, d2, d3}, deliveries)
+
+	deliveries, err = repo.ListDeliveries(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []Delivery{d1, d2}, deliveries)
+
+	// Only the first claim succeeds
+	ok, err := repo.ClaimDelivery(d1, 150)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = repo.ClaimDelivery(d1, 160)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	d1.Attempts = 1
+	d1.NextAttempt = 500
+	assert.NoError(t, repo.UpdateDelivery(d1))
+
+	assert.NoError(t, repo.RemoveDelivery(d2.ID))
+	deliveries, err = repo.ListDeliveries(10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Delivery{d1, d3}, deliveries)
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+type SqliteRepository struct {
+	conn *sql.DB
+	dsn  string
+}
+
+// NewSqliteRepository returns a new subscription repository based on SQLite
+func NewSqliteRepository(dsn string) Repository {
+	if !strings.HasPrefix(dsn, "file:") {
+		if dsn == ":memory:" {
+			dsn = "file::memory:?mode=memory"
+		} else {
+			dsn = fmt.Sprintf("file:%s?cache=shared&mode=rwc", dsn)
+		}
+	}
+
+	conn, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil
+	}
+
+	// Every connection to an in-memory database opens a new database, so all queries must share a single connection
+	conn.SetMaxOpenConns(1)
+
+	db := &SqliteRepository{
+		conn: conn,
+		dsn:  dsn,
+	}
+
+	_, err = db.conn.Exec("CREATE TABLE IF NOT EXISTS mock_webhooks (id TEXT PRIMARY KEY, type TEXT, hash TEXT, url TEXT, created_at INTEGER)")
+	if err != nil {
+		return nil
+	}
+
+	_, err = db.conn.Exec("CREATE TABLE IF NOT EXISTS mock_deliveries (id TEXT PRIMARY KEY, subscription TEXT, url TEXT, payload BLOB, attempts INTEGER, next_attempt INTEGER)")
+	if err != nil {
+		return nil
+	}
+
+	return db
+}
+
+func (r *SqliteRepository) Create(sub Subscription) error {
+	_, err := r.conn.Exec("INSERT INTO mock_webhooks VALUES (?, ?, ?, ?, ?)", sub.ID, sub.Type, sub.Hash, sub.URL, sub.CreatedAt)
+	return err
+}
+
+func (r *SqliteRepository) Get(typ, hash, id string) (*Subscription, error) {
+	sub := &Subscription{}
+
+	err := r.conn.QueryRow("SELECT id, type, hash, url, created_at FROM mock_webhooks WHERE id LIKE ? AND type LIKE ? AND hash LIKE ?", id, typ, hash).Scan(&sub.ID, &sub.Type, &sub.Hash, &sub.URL, &sub.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+func (r *SqliteRepository) List(typ, hash string) ([]Subscription, error) {
+	rows, err := r.conn.Query("SELECT id, type, hash, url, created_at FROM mock_webhooks WHERE type LIKE ? AND hash LIKE ? ORDER BY created_at", typ, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var ret []Subscription
+	for rows.Next() {
+		sub := Subscription{}
+		err = rows.Scan(&sub.ID, &sub.Type, &sub.Hash, &sub.URL, &sub.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, sub)
+	}
+
+	return ret, rows.Err()
+}
+
+func (r *SqliteRepository) Remove(typ, hash, id string) error {
+	res, err := r.conn.Exec("DELETE FROM mock_webhooks WHERE id LIKE ? AND type LIKE ? AND hash LIKE ?", id, typ, hash)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *SqliteRepository) AddDelivery(d Delivery) error {
+	_, err := r.conn.Exec("INSERT INTO mock_deliveries VALUES (?, ?, ?, ?, ?, ?)", d.ID, d.Subscription, d.URL, d.Payload, d.Attempts, d.NextAttempt)
+	return err
+}
+
+func (r *SqliteRepository) ListDeliveries(limit int) ([]Delivery, error) {
+	rows, err := r.conn.Query("SELECT id, subscription, url, payload, attempts, next_attempt FROM mock_deliveries ORDER BY id LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var ret []Delivery
+	for rows.Next() {
+		d := Delivery{}
+		err = rows.Scan(&d.ID, &d.Subscription, &d.URL, &d.Payload, &d.Attempts, &d.NextAttempt)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, d)
+	}
+
+	return ret, rows.Err()
+}
+
+func (r *SqliteRepository) ClaimDelivery(d Delivery, next int64) (bool, error) {
+	res, err := r.conn.Exec("UPDATE mock_deliveries SET next_attempt=? WHERE id=? AND next_attempt=?", next, d.ID, d.NextAttempt)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return count == 1, nil
+}
+
+func (r *SqliteRepository) UpdateDelivery(d Delivery) error {
+	_, err := r.conn.Exec("UPDATE mock_deliveries SET attempts=?, next_attempt=? WHERE id=?", d.Attempts, d.NextAttempt, d.ID)
+	return err
+}
+
+func (r *SqliteRepository) RemoveDelivery(id string) error {
+	_, err := r.conn.Exec("DELETE FROM mock_deliveries WHERE id=?", id)
+	return err
+}
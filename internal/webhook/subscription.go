@@ -0,0 +1,131 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrNotFound is returned when a subscription cannot be found
+var ErrNotFound = errors.New("subscription not found")
+
+// Subscription is a request to receive events for a record on the given URL
+type Subscription struct {
+	ID        string `json:"id"`         // Unique ID of the subscription
+	Type      string `json:"type"`       // Type of the watched record (address, organisation or routing)
+	Hash      string `json:"hash"`       // Hash of the watched record
+	URL       string `json:"url"`        // URL to which the events are posted
+	CreatedAt int64  `json:"created_at"` // Time the subscription was created
+}
+
+// Event is posted to the URL of a subscription when the watched record changes
+type Event struct {
+	Subscription string `json:"subscription"`  // ID of the subscription
+	Type         string `json:"type"`          // Type of the record
+	Action       string `json:"action"`        // Kind of change (create, update, delete etc)
+	Hash         string `json:"hash"`          // Hash of the record
+	Serial       uint64 `json:"serial_number"` // Serial of the record after the change, or 0 when it has been removed
+	Timestamp    int64  `json:"timestamp"`     // Time of the change
+}
+
+// NewID generates a new random subscription ID
+func NewID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// nonPublicNetworks are the networks that events are never delivered to
+var nonPublicNetworks = parseNetworks(
+	"0.0.0.0/8",      // "This" network
+	"10.0.0.0/8",     // Private
+	"100.64.0.0/10",  // Carrier-grade NAT
+	"127.0.0.0/8",    // Loopback
+	"169.254.0.0/16", // Link-local
+	"172.16.0.0/12",  // Private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // Private
+	"198.18.0.0/15",  // Benchmarking
+	"224.0.0.0/4",    // Multicast
+	"240.0.0.0/4",    // Reserved and broadcast
+	"::/128",         // Unspecified
+	"::1/128",        // Loopback
+	"fc00::/7",       // Unique local
+	"fe80::/10",      // Link-local
+	"ff00::/8",       // Multicast
+)
+
+// IsValidURL returns true when the URL can be used to deliver events to. Hosts that are an IP address must be a public
+// address. Host names are checked again when they are resolved during delivery.
+func IsValidURL(u string) bool {
+	p, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+
+	if (p.Scheme != "http" && p.Scheme != "https") || p.Hostname() == "" {
+		return false
+	}
+
+	host := strings.ToLower(p.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+
+	if ip := net.ParseIP(host); ip != nil && !isPublicIP(ip) {
+		return false
+	}
+
+	return true
+}
+
+// isPublicIP returns true when the address is not a loopback, link-local, private or otherwise reserved address
+func isPublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range nonPublicNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	var ret []*net.IPNet
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		ret = append(ret, n)
+	}
+
+	return ret
+}
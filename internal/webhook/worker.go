@@ -0,0 +1,291 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SignFunc returns the headers that sign the given payload, which is posted to the given target of the webhook URL
+type SignFunc func(target string, payload []byte) map[string]string
+
+// Worker delivers the events of record changes to the subscribers. Deliveries are stored in the repository, so they
+// survive a restart, and are made in order for each subscription. Every subscription is delivered to concurrently, so
+// a slow subscriber does not hold up the others. Failed deliveries are retried with an exponential backoff until the
+// maximum number of attempts has been reached.
+type Worker struct {
+	Client       *http.Client
+	MaxAttempts  int           // Number of attempts before a delivery is dropped
+	Backoff      time.Duration // Delay before the first retry, doubled on every next retry
+	MaxBackoff   time.Duration // Maximum delay between retries
+	PollInterval time.Duration // Delay between checks for deliveries that are due
+	Lease        time.Duration // Time a delivery is reserved for the worker that claimed it
+
+	repo Repository
+	sign SignFunc
+	wake chan struct{}
+
+	mu   sync.Mutex
+	busy map[string]bool // Subscriptions that are currently being delivered to
+}
+
+// maxPendingDeliveries is the number of deliveries read from the repository on every check
+const maxPendingDeliveries = 1000
+
+// NewWorker returns a new worker that delivers the events to the subscriptions found in the repository. The sign
+// function can be nil, in which case the events are not signed.
+func NewWorker(repo Repository, sign SignFunc) *Worker {
+	return &Worker{
+		Client:       newClient(),
+		MaxAttempts:  5,
+		Backoff:      time.Second,
+		MaxBackoff:   5 * time.Minute,
+		PollInterval: time.Second,
+		Lease:        time.Minute,
+		repo:         repo,
+		sign:         sign,
+		wake:         make(chan struct{}, 1),
+		busy:         make(map[string]bool),
+	}
+}
+
+// newClient returns a HTTP client that only connects to public addresses and does not follow redirects, so
+// subscriptions cannot be used to reach services on the internal network. The address is checked after the host has
+// been resolved, so a DNS name pointing to an internal address is refused as well.
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if !isPublicIP(net.ParseIP(host)) {
+				return fmt.Errorf("refusing to connect to non-public address %s", host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Notify stores a delivery of the event for every subscription on the changed record
+func (w *Worker) Notify(event Event) error {
+	subs, err := w.repo.List(event.Type, event.Hash)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		event.Subscription = sub.ID
+
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		err = w.repo.AddDelivery(NewDelivery(sub, payload, time.Now()))
+		if err != nil {
+			return err
+		}
+	}
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Run delivers the stored events until the context is done
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// poll starts delivering to every subscription with pending deliveries that is not already being delivered to
+func (w *Worker) poll(ctx context.Context) {
+	deliveries, err := w.repo.ListDeliveries(maxPendingDeliveries)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	var order []string
+	perSub := make(map[string][]Delivery)
+	for _, d := range deliveries {
+		if _, ok := perSub[d.Subscription]; !ok {
+			order = append(order, d.Subscription)
+		}
+		perSub[d.Subscription] = append(perSub[d.Subscription], d)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, sub := range order {
+		if w.busy[sub] {
+			continue
+		}
+
+		w.busy[sub] = true
+		go w.deliverAll(ctx, sub, perSub[sub])
+	}
+}
+
+// deliverAll makes the deliveries of a single subscription in order. It stops at the first delivery that is not due
+// yet, so later events are never delivered before earlier ones.
+func (w *Worker) deliverAll(ctx context.Context, sub string, deliveries []Delivery) {
+	defer func() {
+		w.mu.Lock()
+		delete(w.busy, sub)
+		w.mu.Unlock()
+	}()
+
+	for _, d := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+
+		now := time.Now().UnixNano()
+		if d.NextAttempt > now {
+			return
+		}
+
+		// Another worker could be delivering the same event
+		ok, err := w.repo.ClaimDelivery(d, now+int64(w.Lease))
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		if !ok {
+			return
+		}
+
+		if !w.deliver(d) {
+			return
+		}
+	}
+}
+
+// deliver makes a single attempt and returns true when the next delivery of the subscription can be made
+func (w *Worker) deliver(d Delivery) bool {
+	d.Attempts++
+
+	err := w.send(d)
+	if err == nil {
+		w.remove(d)
+		return true
+	}
+
+	if d.Attempts >= w.MaxAttempts {
+		log.Printf("dropping event for %s after %d attempts: %s", d.URL, d.Attempts, err)
+		w.remove(d)
+		return true
+	}
+
+	d.NextAttempt = time.Now().Add(w.backoff(d.Attempts)).UnixNano()
+	err = w.repo.UpdateDelivery(d)
+	if err != nil {
+		log.Print(err)
+	}
+
+	return false
+}
+
+func (w *Worker) remove(d Delivery) {
+	err := w.repo.RemoveDelivery(d.ID)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+func (w *Worker) send(d Delivery) error {
+	req, err := http.NewRequest("POST", d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if w.sign != nil {
+		for k, v := range w.sign(req.URL.RequestURI(), d.Payload) {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// backoff returns the delay before the next attempt
+func (w *Worker) backoff(attempt int) time.Duration {
+	d := w.Backoff
+	for i := 1; i < attempt && d < w.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > w.MaxBackoff {
+		d = w.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type receiver struct {
+	sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+	received chan struct{}
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+
+	body, _ := ioutil.ReadAll(req.Body)
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(500)
+	} else {
+		w.WriteHeader(204)
+	}
+
+	r.received <- struct{}{}
+}
+
+func (r *receiver) wait(t *testing.T) {
+	select {
+	case <-r.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for delivery")
+	}
+}
+
+func TestWorker(t *testing.T) {
+	rcv := &receiver{failures: 2, received: make(chan struct{}, 10)}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	repo := NewSqliteRepository(":memory:")
+	_ = repo.Create(Subscription{ID: "id1", Type: "address", Hash: "hash1", URL: srv.URL + "/hook"})
+	_ = repo.Create(Subscription{ID: "id2", Type: "address", Hash: "hash2", URL: srv.URL + "/other"})
+
+	w := NewWorker(repo, func(target string, payload []byte) map[string]string {
+		return map[string]string{"X-Signature": "sig-" + target + "-" + string(payload[:1])}
+	})
+	w.Client = &http.Client{}
+	w.Backoff = 10 * time.Millisecond
+	w.PollInterval = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	err := w.Notify(Event{Type: "address", Action: "update", Hash: "hash1", Serial: 1234, Timestamp: 1000})
+	assert.NoError(t, err)
+
+	// Two failures and a successful retry
+	rcv.wait(t)
+	rcv.wait(t)
+	rcv.wait(t)
+
+	rcv.Lock()
+	defer rcv.Unlock()
+
+	assert.Len(t, rcv.requests, 3)
+	assert.Equal(t, "/hook", rcv.requests[2].URL.Path)
+	assert.Equal(t, "POST", rcv.requests[2].Method)
+	assert.Equal(t, "application/json", rcv.requests[2].Header.Get("Content-Type"))
+	assert.Equal(t, "sig-/hook-{", rcv.requests[2].Header.Get("X-Signature"))
+
+	event := &Event{}
+	_ = json.Unmarshal(rcv.bodies[2], event)
+	assert.Equal(t, Event{Subscription: "id1", Type: "address", Action: "update", Hash: "hash1", Serial: 1234, Timestamp: 1000}, *event)
+}
+
+func TestWorkerGivesUp(t *testing.T) {
+	rcv := &receiver{failures: 100, received: make(chan struct{}, 10)}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	repo := NewSqliteRepository(":memory:")
+	_ = repo.Create(Subscription{ID: "id1", Type: "routing", Hash: "hash1", URL: srv.URL})
+
+	w := NewWorker(repo, nil)
+	w.Client = &http.Client{}
+	w.Backoff = time.Millisecond
+	w.PollInterval = 5 * time.Millisecond
+	w.MaxAttempts = 3
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	assert.NoError(t, w.Notify(Event{Type: "routing", Action: "delete", Hash: "hash1"}))
+
+	rcv.wait(t)
+	rcv.wait(t)
+	rcv.wait(t)
+
+	// No more attempts after the maximum has been reached
+	select {
+	case <-rcv.received:
+		t.Fatal("unexpected delivery")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	deliveries, _ := repo.ListDeliveries(10)
+	assert.Len(t, deliveries, 0)
+}
+
+func TestWorkerPersistsDeliveries(t *testing.T) {
+	rcv := &receiver{received: make(chan struct{}, 10)}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	repo := NewSqliteRepository(":memory:")
+	_ = repo.Create(Subscription{ID: "id1", Type: "address", Hash: "hash1", URL: srv.URL})
+
+	// The event is stored while no worker is running
+	w := NewWorker(repo, nil)
+	assert.NoError(t, w.Notify(Event{Type: "address", Action: "create", Hash: "hash1"}))
+
+	deliveries, _ := repo.ListDeliveries(10)
+	assert.Len(t, deliveries, 1)
+
+	w = NewWorker(repo, nil)
+	w.Client = &http.Client{}
+	w.PollInterval = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	rcv.wait(t)
+}
+
+func TestWorkerDeliversConcurrently(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+		w.WriteHeader(204)
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	rcv := &receiver{received: make(chan struct{}, 10)}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	repo := NewSqliteRepository(":memory:")
+	_ = repo.Create(Subscription{ID: "id1", Type: "address", Hash: "hash1", URL: slow.URL})
+	_ = repo.Create(Subscription{ID: "id2", Type: "address", Hash: "hash1", URL: srv.URL})
+
+	w := NewWorker(repo, nil)
+	w.Client = &http.Client{}
+	w.PollInterval = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	// The slow subscriber does not hold up the other one
+	assert.NoError(t, w.Notify(Event{Type: "address", Action: "update", Hash: "hash1"}))
+	rcv.wait(t)
+	assert.NoError(t, w.Notify(Event{Type: "address", Action: "delete", Hash: "hash1"}))
+	rcv.wait(t)
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(204)
+	}))
+	defer srv.Close()
+
+	_, err := newClient().Get(srv.URL)
+	assert.Error(t, err)
+
+	// Redirects are not followed
+	redirect := httptest.NewServer(http.RedirectHandler(srv.URL, http.StatusFound))
+	defer redirect.Close()
+
+	client := newClient()
+	client.Transport = http.DefaultTransport
+	resp, err := client.Get(redirect.URL)
+	assert.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+}
+
+func TestBackoff(t *testing.T) {
+	w := NewWorker(nil, nil)
+	w.Backoff = time.Second
+	w.MaxBackoff = 5 * time.Second
+
+	assert.Equal(t, time.Second, w.backoff(1))
+	assert.Equal(t, 2*time.Second, w.backoff(2))
+	assert.Equal(t, 4*time.Second, w.backoff(3))
+	assert.Equal(t, 5*time.Second, w.backoff(4))
+	assert.Equal(t, 5*time.Second, w.backoff(10))
+}
+
+func TestIsValidURL(t *testing.T) {
+	assert.True(t, IsValidURL("https://example.org/hook"))
+	assert.True(t, IsValidURL("http://93.184.216.34:8080"))
+	assert.False(t, IsValidURL("http://127.0.0.1:8080"))
+	assert.False(t, IsValidURL("http://localhost/hook"))
+	assert.False(t, IsValidURL("http://169.254.169.254/latest/meta-data"))
+	assert.False(t, IsValidURL("http://10.0.0.1"))
+	assert.False(t, IsValidURL("http://[::1]:8080"))
+	assert.False(t, IsValidURL("http://[::ffff:192.168.1.1]"))
+	assert.False(t, IsValidURL("ftp://example.org"))
+	assert.False(t, IsValidURL("example.org/hook"))
+	assert.False(t, IsValidURL("https://"))
+}
+
+func TestIsPublicIP(t *testing.T) {
+	assert.True(t, isPublicIP(net.ParseIP("93.184.216.34")))
+	assert.True(t, isPublicIP(net.ParseIP("2606:2800:220:1::248")))
+	assert.False(t, isPublicIP(net.ParseIP("127.0.0.1")))
+	assert.False(t, isPublicIP(net.ParseIP("172.16.5.4")))
+	assert.False(t, isPublicIP(net.ParseIP("192.168.1.1")))
+	assert.False(t, isPublicIP(net.ParseIP("100.64.0.1")))
+	assert.False(t, isPublicIP(net.ParseIP("0.0.0.0")))
+	assert.False(t, isPublicIP(net.ParseIP("::")))
+	assert.False(t, isPublicIP(net.ParseIP("fe80::1")))
+	assert.False(t, isPublicIP(net.ParseIP("fd00::1")))
+	assert.False(t, isPublicIP(net.ParseIP("::ffff:10.1.2.3")))
+	assert.False(t, isPublicIP(nil))
+}
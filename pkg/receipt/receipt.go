@@ -0,0 +1,89 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package receipt contains the registration receipts that the key resolver hands out when a record is created or
+// updated. A receipt is signed by the resolver operator, so it can be used to prove to third parties that a key was
+// registered for a hash at a certain time.
+package receipt
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+
+	"github.com/bitmaelum/bitmaelum-suite/pkg/bmcrypto"
+)
+
+// Receipt is a signed statement of the resolver that a record has been registered
+type Receipt struct {
+	Type        string  `json:"type"`                // Type of the record (address, organisation or routing)
+	Hash        string  `json:"hash"`                // Hash of the record
+	Serial      uint64  `json:"serial_number"`       // Serial number of the record after the registration
+	Fingerprint string  `json:"fingerprint"`         // Fingerprint of the key of the record
+	Timestamp   int64   `json:"timestamp"`           // Time of registration
+	LogIndex    *uint64 `json:"log_index,omitempty"` // Index of the registration in the transparency log, if logged
+	Signature   []byte  `json:"signature"`           // Signature of the resolver operator
+}
+
+// signatureData returns the data that is signed for the receipt
+func (r Receipt) signatureData() []byte {
+	b, _ := json.Marshal(struct {
+		Type        string  `json:"type"`
+		Hash        string  `json:"hash"`
+		Serial      uint64  `json:"serial_number"`
+		Fingerprint string  `json:"fingerprint"`
+		Timestamp   int64   `json:"timestamp"`
+		LogIndex    *uint64 `json:"log_index,omitempty"`
+	}{r.Type, r.Hash, r.Serial, r.Fingerprint, r.Timestamp, r.LogIndex})
+
+	h := sha256.Sum256(b)
+	return h[:]
+}
+
+// Sign signs the receipt with the given key of the resolver
+func (r *Receipt) Sign(key bmcrypto.PrivKey) error {
+	sig, err := bmcrypto.Sign(key, r.signatureData())
+	if err != nil {
+		return err
+	}
+
+	r.Signature = sig
+	return nil
+}
+
+// Verify returns true when the receipt is signed by the given key of the resolver
+func (r Receipt) Verify(key bmcrypto.PubKey) bool {
+	ok, err := bmcrypto.Verify(key, r.signatureData(), r.Signature)
+	return err == nil && ok
+}
+
+// VerifyJSON parses a receipt in JSON format as returned by the resolver, and returns it when it is signed by the given
+// key of the resolver. Use the signing key from the config.json of the resolver as key.
+func VerifyJSON(data []byte, key bmcrypto.PubKey) (*Receipt, bool) {
+	r := &Receipt{}
+	err := json.Unmarshal(data, r)
+	if err != nil {
+		return nil, false
+	}
+
+	if !r.Verify(key) {
+		return nil, false
+	}
+
+	return r, true
+}
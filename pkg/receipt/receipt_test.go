@@ -0,0 +1,68 @@
+// Copyright (c) 2020 BitMaelum Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package receipt
+
+import (
+	"encoding/json"
+	"testing"
+
+	testing2 "github.com/bitmaelum/key-resolver-go/internal/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceipt(t *testing.T) {
+	privKey, pubKey, _ := testing2.ReadTestKey("../../testdata/key-7.json")
+	_, otherKey, _ := testing2.ReadTestKey("../../testdata/key-6.json")
+
+	idx := uint64(12)
+	r := &Receipt{
+		Type:        "address",
+		Hash:        "2244643da7475120bf84d744435d15ea297c36ca165ea0baaa69ec818d0e952f",
+		Serial:      1270643696000000000,
+		Fingerprint: "ad4ab4aab3e93d31ab7a94c3b4d9e3bcef3f4a1f5ce3bd9c4e0e8fc5c17b9e0d",
+		Timestamp:   1270643696,
+		LogIndex:    &idx,
+	}
+
+	assert.False(t, r.Verify(*pubKey))
+	assert.NoError(t, r.Sign(*privKey))
+	assert.True(t, r.Verify(*pubKey))
+	assert.False(t, r.Verify(*otherKey))
+
+	data, err := json.Marshal(r)
+	assert.NoError(t, err)
+
+	r2, ok := VerifyJSON(data, *pubKey)
+	assert.True(t, ok)
+	assert.Equal(t, r, r2)
+
+	_, ok = VerifyJSON(data, *otherKey)
+	assert.False(t, ok)
+
+	// Any change to the receipt invalidates the signature
+	r.Serial++
+	assert.False(t, r.Verify(*pubKey))
+	r.Serial--
+	r.LogIndex = nil
+	assert.False(t, r.Verify(*pubKey))
+
+	_, ok = VerifyJSON([]byte("not json"), *pubKey)
+	assert.False(t, ok)
+}